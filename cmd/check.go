@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/metal-toolbox/firmware-syncer/internal/app"
+	"github.com/metal-toolbox/firmware-syncer/pkg/types"
+)
+
+// checkCmd is a preflight for deployments, it checks the configuration and the services the syncer depends on
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Validate the configuration and check the S3 buckets and inventory are reachable, without syncing",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, _ []string) {
+		if cfgFile == "" {
+			fmt.Println("No firmware-syncer configuration file found.")
+			os.Exit(1)
+		}
+
+		results := app.Check(cmd.Context(), types.InventoryKind(inventoryKind), cfgFile, logLevel)
+
+		if !writeCheckResults(os.Stdout, results) {
+			os.Exit(1)
+		}
+	},
+}
+
+// writeCheckResults writes a line per check, it returns false when a check failed.
+func writeCheckResults(w io.Writer, results []app.CheckResult) bool {
+	passed := true
+
+	for i := range results {
+		if results[i].Passed() {
+			fmt.Fprintf(w, "PASS %s\n", results[i].Name)
+			continue
+		}
+
+		passed = false
+
+		fmt.Fprintf(w, "FAIL %s: %s\n", results[i].Name, results[i].Err)
+	}
+
+	return passed
+}
+
+func init() {
+	rootCmd.AddCommand(checkCmd)
+}
@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/metal-toolbox/firmware-syncer/internal/app"
+	"github.com/metal-toolbox/firmware-syncer/internal/inventory"
+	"github.com/metal-toolbox/firmware-syncer/pkg/types"
+
+	fleetdbapi "github.com/metal-toolbox/fleetdb/pkg/api/v1"
+)
+
+var outputFormat string
+
+// manifestCmd groups the firmware manifest commands, meant to check a new modeldata.json in CI before it's deployed
+var manifestCmd = &cobra.Command{
+	Use:   "manifest",
+	Short: "Validate a firmware manifest and compare it with inventory",
+}
+
+var manifestValidateCmd = &cobra.Command{
+	Use:   "validate <manifest path or URL>",
+	Short: "Validate a firmware manifest, exits with an error when problems are found",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		problems, err := app.ValidateManifest(cmd.Context(), args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if outputFormat == "json" {
+			writeJSON(os.Stdout, map[string][]string{"problems": problems})
+		} else {
+			for _, problem := range problems {
+				fmt.Println(problem)
+			}
+		}
+
+		if len(problems) > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+var manifestDiffCmd = &cobra.Command{
+	Use:   "diff [manifest path or URL]",
+	Short: "Show the firmware a manifest adds, changes and removes in inventory, the configured manifest is used by default",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if cfgFile == "" {
+			fmt.Println("No firmware-syncer configuration file found.")
+			os.Exit(1)
+		}
+
+		var manifestURL string
+		if len(args) > 0 {
+			manifestURL = args[0]
+		}
+
+		diff, err := app.DiffManifest(cmd.Context(), types.InventoryKind(inventoryKind), cfgFile, logLevel, manifestURL)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if outputFormat == "json" {
+			writeJSON(os.Stdout, diff)
+			return
+		}
+
+		writeDiff(os.Stdout, diff)
+	},
+}
+
+func writeJSON(w io.Writer, v any) {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	if err := encoder.Encode(v); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// writeDiff writes the diff in a human readable format, one firmware per line.
+func writeDiff(w io.Writer, diff *inventory.ManifestDiff) {
+	if diff.Empty() {
+		fmt.Fprintln(w, "No changes")
+		return
+	}
+
+	describe := func(fw *fleetdbapi.ComponentFirmwareVersion) string {
+		return fmt.Sprintf("%s/%s %s %s", fw.Vendor, fw.Component, fw.Version, fw.Filename)
+	}
+
+	for _, fw := range diff.Added {
+		fmt.Fprintln(w, "+ "+describe(fw))
+	}
+
+	for _, change := range diff.Changed {
+		fmt.Fprintf(w, "~ %s (was %s)\n", describe(change.New), describe(change.Current))
+	}
+
+	for _, fw := range diff.Removed {
+		fmt.Fprintln(w, "- "+describe(fw))
+	}
+}
+
+func init() {
+	manifestCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", "text", "output format - text or json")
+	manifestCmd.AddCommand(manifestValidateCmd, manifestDiffCmd)
+	rootCmd.AddCommand(manifestCmd)
+}
@@ -1,19 +1,35 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/metal-toolbox/firmware-syncer/internal/app"
+	"github.com/metal-toolbox/firmware-syncer/internal/config"
 	"github.com/metal-toolbox/firmware-syncer/pkg/types"
 	"github.com/spf13/cobra"
 )
 
 var (
-	cfgFile       string
-	inventoryKind string
-	logLevel      string
+	cfgFile        string
+	inventoryKind  string
+	logLevel       string
+	since          string
+	latestOnly     bool
+	pruneTmpOnExit bool
+	verifyUpload   bool
+	components     []string
+	concurrency    int
+	output         string
+)
+
+const (
+	outputText = "text"
+	outputJSON = "json"
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -26,13 +42,42 @@ var rootCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
-		syncerApp, err := app.New(cmd.Context(), types.InventoryKind(inventoryKind), cfgFile, logLevel)
+		if output != outputText && output != outputJSON {
+			fmt.Println("--output must be one of text, json")
+			os.Exit(1)
+		}
+
+		if concurrency < 0 {
+			fmt.Println("--concurrency must be at least 1")
+			os.Exit(1)
+		}
+
+		syncerApp, err := app.New(
+			cmd.Context(),
+			types.InventoryKind(inventoryKind),
+			cfgFile,
+			logLevel,
+			app.WithSince(since),
+			app.WithLatestOnly(latestOnly),
+			app.WithPruneTmpOnExit(pruneTmpOnExit),
+			app.WithComponents(components),
+			app.WithConcurrency(concurrency),
+			app.WithVerifyUpload(verifyUpload),
+		)
 		if err != nil {
 			log.Fatal(err)
 		}
 
 		syncerApp.Logger.Info("Sync starting")
 		err = syncerApp.SyncFirmwares(cmd.Context())
+
+		// the summary is written even when the sync failed, for CI to report the failures
+		if output == outputJSON {
+			if writeErr := syncerApp.WriteSummary(os.Stdout); writeErr != nil {
+				syncerApp.Logger.WithError(writeErr).Error("Failed to write the sync summary")
+			}
+		}
+
 		if err != nil {
 			syncerApp.Logger.Fatal(err)
 		}
@@ -42,8 +87,15 @@ var rootCmd = &cobra.Command{
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
+// The command context is canceled on SIGINT and SIGTERM, for the in-flight syncs to wind down.
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+
+	err := rootCmd.ExecuteContext(ctx)
+
+	stop()
+
+	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
@@ -53,4 +105,12 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "", "set logging level - info, debug, trace")
 	rootCmd.PersistentFlags().StringVarP(&cfgFile, "config-file", "c", "", "Syncer configuration file")
 	rootCmd.PersistentFlags().StringVar(&inventoryKind, "inventory", "serverservice", "Inventory to publish firmwares.")
+	rootCmd.Flags().StringVar(&since, "since", "", "skip firmware built before this date - MM/DD/YYYY, YYYY-MM-DD or RFC 3339")
+	rootCmd.Flags().BoolVar(&latestOnly, "latest-only", false, "only sync the firmware flagged latest in the manifest")
+	rootCmd.Flags().StringSliceVar(&components, "component", nil, "only sync the firmware of this component, can be repeated - bios, bmc...")
+	rootCmd.Flags().IntVar(&concurrency, "concurrency", 0,
+		fmt.Sprintf("number of firmware each vendor syncs in parallel, defaults to %d - ignored with adaptive_concurrency", config.SyncerConcurrency))
+	rootCmd.Flags().StringVar(&output, "output", outputText, "sync summary format - text or json, the json summary is written to stdout")
+	rootCmd.Flags().BoolVar(&verifyUpload, "verify-upload", false, "read back the hash of the uploaded firmware, corrupt uploads are retried once")
+	rootCmd.Flags().BoolVar(&pruneTmpOnExit, "prune-tmp-on-exit", false, "remove the download directories left in the work directory on exit")
 }
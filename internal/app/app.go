@@ -2,10 +2,11 @@ package app
 
 import (
 	"context"
-	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/bmc-toolbox/common"
 	"github.com/jeremywohl/flatten"
@@ -14,17 +15,31 @@ import (
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 
+	"github.com/metal-toolbox/firmware-syncer/internal/audit"
 	"github.com/metal-toolbox/firmware-syncer/internal/config"
+	"github.com/metal-toolbox/firmware-syncer/internal/events"
 	"github.com/metal-toolbox/firmware-syncer/internal/inventory"
 	"github.com/metal-toolbox/firmware-syncer/internal/logging"
 	"github.com/metal-toolbox/firmware-syncer/internal/vendors"
+	"github.com/metal-toolbox/firmware-syncer/internal/vendors/ami"
+	"github.com/metal-toolbox/firmware-syncer/internal/vendors/broadcom"
+	"github.com/metal-toolbox/firmware-syncer/internal/vendors/dell"
 	"github.com/metal-toolbox/firmware-syncer/internal/vendors/github"
+	"github.com/metal-toolbox/firmware-syncer/internal/vendors/mellanox"
 	"github.com/metal-toolbox/firmware-syncer/internal/vendors/supermicro"
 	"github.com/metal-toolbox/firmware-syncer/pkg/types"
+
+	fleetdbapi "github.com/metal-toolbox/fleetdb/pkg/api/v1"
+	rcloneFs "github.com/rclone/rclone/fs"
 )
 
 const (
 	VendorEquinix = "equinix"
+	// VendorAMI is the vendor whitebox servers list their AMI Aptio BIOS under
+	VendorAMI = "ami"
+
+	// staleDownloadDirAge is the age past which download directories left in the work directory are removed
+	staleDownloadDirAge = 24 * time.Hour
 )
 
 // App holds attributes for the firmware-syncer application
@@ -34,13 +49,34 @@ type App struct {
 	// firmware-syncer configuration.
 	Config *config.Configuration
 	// Logger is the app logger
-	Logger  *logrus.Logger
-	vendors []vendors.Vendor
+	Logger    *logrus.Logger
+	vendors   []vendors.Vendor
+	inventory inventory.ServerService
+	queue     *inventory.WriteBehindQueue
+	verifier  *vendors.Verifier
+	state     *vendors.SyncState
+	notifier  Notifier
+	// proxy routes the outbound requests, the standard proxy env vars are honored when it's not configured
+	proxy     *vendors.Proxy
+	firmwares []*fleetdbapi.ComponentFirmwareVersion
+	// syncDuration is how long the last SyncFirmwares took
+	syncDuration time.Duration
 }
 
-// nolint:gocyclo // Instantiating new app is cyclomatic
-// New returns a new instance of the firmware-syncer app
-func New(ctx context.Context, inventoryKind types.InventoryKind, cfgFile, logLevel string) (*App, error) {
+// Option sets configuration parameters given on the command line, they take precedence over config and env vars.
+type Option func(*App)
+
+// WithSince only syncs firmware built after the given date, see config.Configuration.Since.
+func WithSince(since string) Option {
+	return func(a *App) {
+		if since != "" {
+			a.Config.Since = since
+		}
+	}
+}
+
+// newApp returns an App with its configuration loaded and its logger set up.
+func newApp(inventoryKind types.InventoryKind, cfgFile, logLevel string, opts ...Option) (*App, error) {
 	app := &App{
 		v:      viper.New(),
 		Config: &config.Configuration{},
@@ -55,78 +91,677 @@ func New(ctx context.Context, inventoryKind types.InventoryKind, cfgFile, logLev
 		app.Config.LogLevel = logLevel
 	}
 
+	for _, opt := range opts {
+		opt(app)
+	}
+
 	app.Logger = logging.NewLogger(app.Config.LogLevel)
 
+	if app.Config.LogConfig {
+		app.Config.LogRedacted(app.Logger)
+	}
+
+	if err := app.setProxy(); err != nil {
+		return nil, err
+	}
+
+	vendors.SetUserAgent(app.Config.UserAgent)
+
+	return app, nil
+}
+
+// setProxy sets up the configured proxy, before any outbound request as rclone reads the proxy env vars once.
+func (a *App) setProxy() error {
+	if a.Config.HTTPProxy == "" && a.Config.NoProxy == "" {
+		return nil
+	}
+
+	proxy, err := vendors.NewProxy(a.Config.HTTPProxy, a.Config.NoProxy)
+	if err != nil {
+		return errors.Wrap(config.ErrConfig, err.Error())
+	}
+
+	if err = proxy.SetRcloneEnv(); err != nil {
+		return err
+	}
+
+	a.proxy = proxy
+
+	return nil
+}
+
+// WithPruneTmpOnExit removes the download directories left in the work directory on exit, see config.Configuration.PruneTmpOnExit.
+func WithPruneTmpOnExit(pruneTmpOnExit bool) Option {
+	return func(a *App) {
+		if pruneTmpOnExit {
+			a.Config.PruneTmpOnExit = true
+		}
+	}
+}
+
+// WithVerifyUpload verifies the hash of the uploaded objects, see config.Configuration.VerifyUpload.
+func WithVerifyUpload(verifyUpload bool) Option {
+	return func(a *App) {
+		if verifyUpload {
+			a.Config.VerifyUpload = true
+		}
+	}
+}
+
+// WithComponents only syncs the firmware of the given components, see config.Configuration.Components.
+func WithComponents(components []string) Option {
+	return func(a *App) {
+		if len(components) > 0 {
+			a.Config.Components = components
+		}
+	}
+}
+
+// WithConcurrency sets the number of firmware each vendor syncs in parallel, see config.Configuration.Concurrency.
+func WithConcurrency(concurrency int) Option {
+	return func(a *App) {
+		if concurrency > 0 {
+			a.Config.Concurrency = concurrency
+		}
+	}
+}
+
+// WithLatestOnly only syncs the firmware flagged latest in the manifest, see config.Configuration.LatestOnly.
+func WithLatestOnly(latestOnly bool) Option {
+	return func(a *App) {
+		if latestOnly {
+			a.Config.LatestOnly = true
+		}
+	}
+}
+
+// nolint:gocyclo // Instantiating new app is cyclomatic
+// New returns a new instance of the firmware-syncer app
+func New(ctx context.Context, inventoryKind types.InventoryKind, cfgFile, logLevel string, opts ...Option) (*App, error) {
+	app, err := newApp(inventoryKind, cfgFile, logLevel, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	since, err := app.since()
+	if err != nil {
+		return nil, err
+	}
+
+	app.cleanWorkDir()
+
+	var auditLogger *audit.Logger
+	if app.Config.AuditLog != "" {
+		if auditLogger, err = audit.Open(app.Config.AuditLog); err != nil {
+			return nil, err
+		}
+	}
+
+	if app.Config.Notifications.WebhookURL != "" {
+		// the notifications aren't retried, a receiver failing after processing one would get it twice
+		client := vendors.NewHTTPClient(&vendors.HTTPClientOptions{MaxRetries: -1, Proxy: app.proxy})
+		app.notifier = NewWebhookNotifier(client, app.Config.Notifications.WebhookURL)
+	}
+
+	for alias, vendor := range app.Config.VendorAliases {
+		config.RegisterVendorAlias(alias, vendor)
+	}
+
 	// Load firmware manifest
-	firmwaresByVendor, err := config.LoadFirmwareManifest(ctx, app.Config.FirmwareManifestURL)
+	manifestClient := vendors.NewHTTPClient(&vendors.HTTPClientOptions{Proxy: app.proxy})
+
+	firmwaresByVendor, manifestDetails, err := config.LoadFirmwareManifest(
+		ctx,
+		manifestClient,
+		app.Config.FirmwareManifestURL,
+		app.Config.FirmwareManifestOverrides...,
+	)
 	if err != nil {
 		app.Logger.Error(err.Error())
 		return nil, err
 	}
 
-	inventoryClient, err := inventory.New(ctx, app.Config.ServerserviceOptions, app.Config.ArtifactsURL, app.Logger)
+	for _, override := range manifestDetails.Overrides {
+		app.Logger.WithField("url", override.URL).
+			WithField("replaced", override.Replaced).
+			WithField("added", override.Added).
+			Info("Applied manifest override")
+	}
+
+	for manufacturer, vendor := range manifestDetails.VendorAliases {
+		app.Logger.WithField("manufacturer", manufacturer).
+			WithField("vendor", vendor).
+			Info("Resolved manifest manufacturer alias")
+	}
+
+	// the firmware is uploaded and published under the same destination path
+	if err = app.setDstPathTemplate(); err != nil {
+		return nil, err
+	}
+
+	vendors.SetExtractLimits(vendors.ExtractLimits{MaxBytes: app.Config.MaxExtractedSize, MaxEntries: app.Config.MaxArchiveEntries})
+
+	inventoryClient, err := app.newInventory(ctx, manifestDetails.Checksums)
 	if err != nil {
 		return nil, err
 	}
 
+	app.inventory = inventoryClient
+
+	if queueCfg := app.Config.InventoryQueue; queueCfg.Size > 0 {
+		app.queue = inventory.NewWriteBehindQueue(
+			ctx,
+			inventoryClient,
+			app.Logger,
+			queueCfg.Size,
+			queueCfg.BatchSize,
+			queueCfg.FlushInterval,
+		)
+		app.inventory = app.queue
+	}
+
 	dstFs, err := vendors.InitS3Fs(ctx, app.Config.FirmwareRepository, "/")
 	if err != nil {
 		return nil, err
 	}
 
-	tmpFs, err := vendors.InitLocalFs(ctx, &vendors.LocalFsConfig{Root: os.TempDir()})
+	app.abortStaleMultipartUploads(ctx, dstFs)
+
+	mirrors := make([]rcloneFs.Fs, 0, len(app.Config.MirrorRepositories))
+
+	for _, mirror := range app.Config.MirrorRepositories {
+		mirrorFs, err := vendors.InitS3Fs(ctx, mirror, "/")
+		if err != nil {
+			return nil, err
+		}
+
+		mirrors = append(mirrors, mirrorFs)
+	}
+
+	tmpFs, err := app.newTmpFs(ctx)
 	if err != nil {
 		return nil, err
 	}
 
+	if app.Config.Verify.Enabled {
+		app.verifier = vendors.NewVerifier(dstFs, app.Logger, app.Config.Verify.Concurrency, app.Config.Verify.RateLimit)
+	}
+
+	if len(app.Config.SourceHeaders) > 0 {
+		app.Logger.WithField("headers", vendors.SourceHeaders(app.Config.SourceHeaders).Redacted()).
+			Info("Sending the configured headers with firmware downloads")
+	}
+
+	if app.Config.StateFile != "" {
+		if app.state, err = app.loadSyncState(firmwaresByVendor); err != nil {
+			return nil, err
+		}
+	}
+
 	for vendor, firmwares := range firmwaresByVendor {
-		var downloader vendors.Downloader
-
-		switch vendor {
-		case common.VendorDell:
-			downloader = vendors.NewRcloneDownloader(app.Logger)
-		case common.VendorAsrockrack:
-			s3Fs, err := vendors.InitS3Fs(ctx, app.Config.AsRockRackRepository, "/")
-			if err != nil {
-				return nil, err
-			}
-
-			downloader = vendors.NewS3Downloader(app.Logger, s3Fs)
-		case common.VendorSupermicro:
-			downloader = supermicro.NewSupermicroDownloader(app.Logger)
-		case common.VendorMellanox:
-			downloader = vendors.NewArchiveDownloader(app.Logger)
-		case common.VendorIntel:
-			downloader = vendors.NewArchiveDownloader(app.Logger)
-		case VendorEquinix:
-			ghClient := github.NewGitHubClient(ctx, app.Config.GithubOpenBmcToken)
-			downloader = github.NewGitHubDownloader(app.Logger, ghClient)
-		default:
-			if app.Config.DefaultDownloadURL == "" {
-				app.Logger.Error("Vendor not supported: " + vendor)
-				continue
-			}
-
-			downloader = vendors.NewSourceOverrideDownloader(app.Logger, http.DefaultClient, app.Config.DefaultDownloadURL)
-		}
-
-		syncer := vendors.NewSyncer(dstFs, tmpFs, downloader, inventoryClient, firmwares, app.Logger)
+		// every manifest firmware is kept in inventory, even when it's not synced
+		app.firmwares = append(app.firmwares, firmwares...)
+
+		if len(app.Config.Components) > 0 {
+			firmwares = app.componentFirmware(vendor, firmwares)
+		}
+
+		if len(app.Config.ModelAllowlist) > 0 || len(app.Config.ModelDenylist) > 0 {
+			firmwares = app.modelFirmware(vendor, firmwares)
+		}
+
+		if app.Config.LatestOnly {
+			firmwares = app.latestFirmware(vendor, firmwares, manifestDetails.Latest)
+		}
+
+		downloader, err := app.newDownloader(ctx, vendor)
+		if err != nil {
+			return nil, err
+		}
+
+		if downloader == nil {
+			app.Logger.Error("Vendor not supported: " + vendor)
+			continue
+		}
+
+		opts := []vendors.SyncerOption{
+			vendors.WithExpectedSizes(manifestDetails.Sizes),
+			vendors.WithChecksums(manifestDetails.Checksums),
+		}
+		if app.Config.SanitizeFilenames {
+			opts = append(opts, vendors.WithFilenameSanitizer(vendors.NewFilenameSanitizer()))
+		}
+
+		if app.Config.QuarantineDir != "" {
+			opts = append(opts, vendors.WithQuarantine(app.Config.QuarantineDir))
+		}
+
+		if auditLogger != nil {
+			opts = append(opts, vendors.WithAuditLogger(auditLogger, app.Config.ArtifactsURL))
+		}
+
+		if app.Config.EventsWebhookURL != "" {
+			// the events aren't retried, a receiver failing after processing an event would get it twice
+			client := vendors.NewHTTPClient(&vendors.HTTPClientOptions{MaxRetries: -1, Proxy: app.proxy})
+			publisher := events.NewWebhookPublisher(client, app.Config.EventsWebhookURL)
+			opts = append(opts, vendors.WithEventPublisher(publisher, app.Config.ArtifactsURL))
+		}
+
+		// the firmware is copied without a round trip through the local filesystem when the buckets share an account
+		if vendor == common.VendorAsrockrack && vendors.SameS3Account(app.Config.AsRockRackRepository, app.Config.FirmwareRepository) {
+			opts = append(opts, vendors.WithServerSideCopy())
+		}
+
+		if app.state != nil {
+			opts = append(opts, vendors.WithSyncState(app.state))
+		}
+
+		if app.Config.VerifyUpload {
+			opts = append(opts, vendors.WithUploadVerification())
+		}
+
+		if app.Config.CompressUploads {
+			opts = append(opts, vendors.WithUploadCompression())
+		}
+
+		if len(mirrors) > 0 {
+			opts = append(opts, vendors.WithMirrors(mirrors, app.Config.UploadQuorum))
+		}
+
+		if app.Config.ProgressInterval != 0 {
+			opts = append(opts, vendors.WithProgressInterval(app.Config.ProgressInterval))
+		}
+
+		if len(app.Config.SourceHeaders) > 0 {
+			opts = append(opts, vendors.WithSourceHeaders(app.Config.SourceHeaders))
+		}
+
+		if len(app.Config.FTPCredentials) > 0 {
+			opts = append(opts, vendors.WithFTPCredentials(app.Config.FTPCredentials))
+		}
+
+		if len(app.Config.WebDAVCredentials) > 0 {
+			opts = append(opts, vendors.WithWebDAVCredentials(app.Config.WebDAVCredentials))
+		}
+
+		if app.Config.MaxFileSize > 0 {
+			opts = append(opts, vendors.WithMaxFileSize(app.Config.MaxFileSize))
+		}
+
+		if app.Config.AllowGitFileURLs {
+			opts = append(opts, vendors.WithGitFileURLs())
+		}
+
+		if !since.IsZero() {
+			opts = append(opts, vendors.WithSince(since, manifestDetails.BuildDates))
+		}
+
+		if concurrency := app.Config.AdaptiveConcurrency; concurrency.Max > 0 {
+			limiter := vendors.NewAdaptiveConcurrency(concurrency.Min, concurrency.Max, concurrency.Initial)
+			opts = append(opts, vendors.WithAdaptiveConcurrency(limiter))
+		} else {
+			opts = append(opts, vendors.WithConcurrency(app.Config.Concurrency))
+		}
+
+		if app.proxy != nil {
+			opts = append(opts, vendors.WithProxy(app.proxy))
+		}
+
+		syncer := vendors.NewSyncer(dstFs, tmpFs, downloader, app.inventory, firmwares, app.Logger, opts...)
 		app.vendors = append(app.vendors, syncer)
 	}
 
 	return app, nil
 }
 
-// SyncFirmwares syncs all firmware files from the configured providers
-func (a *App) SyncFirmwares(ctx context.Context) error {
+// loadSyncState returns the state recorded by an interrupted run in the state file.
+func (a *App) loadSyncState(firmwaresByVendor map[string][]*fleetdbapi.ComponentFirmwareVersion) (*vendors.SyncState, error) {
+	var firmwares []*fleetdbapi.ComponentFirmwareVersion
+	for _, vendorFirmwares := range firmwaresByVendor {
+		firmwares = append(firmwares, vendorFirmwares...)
+	}
+
+	state, err := vendors.LoadSyncState(a.Config.StateFile, a.Config.FirmwareManifestURL, firmwares)
+	if err != nil {
+		return nil, err
+	}
+
+	if state.Len() > 0 {
+		a.Logger.WithField("stateFile", a.Config.StateFile).
+			WithField("count", state.Len()).
+			Info("Resuming the interrupted sync, the firmware it synced isn't checked again")
+	}
+
+	return state, nil
+}
+
+// newInventory returns the inventory client firmware is published with,
+// the firmware records are matched on any of the checksums the manifest declares.
+func (a *App) newInventory(ctx context.Context, checksums config.FirmwareChecksums) (inventory.ServerService, error) {
+	// the repository URL points to the path the firmware is uploaded to
+	opts := []inventory.Option{inventory.WithRepositoryPath(vendors.DstPath), inventory.WithChecksums(checksums)}
+	if a.Config.ServerserviceOptions.RecoverDuplicates {
+		opts = append(opts, inventory.WithDuplicateRecovery())
+	}
+
+	return inventory.New(ctx, a.Config.ServerserviceOptions, a.Config.ArtifactsURL, a.Logger, opts...)
+}
+
+// latestFirmware returns the vendor firmware flagged latest in the manifest,
+// components with more than one firmware flagged latest are logged and all of their latest firmware is kept.
+func (a *App) latestFirmware(
+	vendor string,
+	firmwares []*fleetdbapi.ComponentFirmwareVersion,
+	latest config.FirmwareLatest,
+) []*fleetdbapi.ComponentFirmwareVersion {
+	filtered, conflicts := config.FilterLatest(firmwares, latest)
+
+	for component, fws := range conflicts {
+		versions := make([]string, 0, len(fws))
+		for _, fw := range fws {
+			versions = append(versions, fw.Version)
+		}
+
+		a.Logger.WithField("component", component).
+			WithField("versions", versions).
+			Warn("Multiple firmware flagged latest for the component, syncing all of them")
+	}
+
+	a.Logger.WithField("vendor", vendor).
+		WithField("latest", len(filtered)).
+		WithField("skipped", len(firmwares)-len(filtered)).
+		Info("Syncing only the firmware flagged latest")
+
+	return filtered
+}
+
+// componentFirmware returns the vendor firmware of the configured components.
+func (a *App) componentFirmware(vendor string, firmwares []*fleetdbapi.ComponentFirmwareVersion) []*fleetdbapi.ComponentFirmwareVersion {
+	filtered := config.FilterComponents(firmwares, a.Config.Components)
+
+	a.Logger.WithField("vendor", vendor).
+		WithField("components", a.Config.Components).
+		WithField("selected", len(filtered)).
+		WithField("skipped", len(firmwares)-len(filtered)).
+		Info("Syncing only the firmware of the selected components")
+
+	return filtered
+}
+
+// modelFirmware returns the vendor firmware passing the configured model allowlist and denylist.
+func (a *App) modelFirmware(vendor string, firmwares []*fleetdbapi.ComponentFirmwareVersion) []*fleetdbapi.ComponentFirmwareVersion {
+	filtered := config.FilterModels(firmwares, a.Config.ModelAllowlist, a.Config.ModelDenylist)
+
+	a.Logger.WithField("vendor", vendor).
+		WithField("allowlist", a.Config.ModelAllowlist).
+		WithField("denylist", a.Config.ModelDenylist).
+		WithField("selected", len(filtered)).
+		WithField("skipped", len(firmwares)-len(filtered)).
+		Info("Syncing only the firmware of the selected models")
+
+	return filtered
+}
+
+// since returns the date firmware has to be built after to be synced, it's zero when not configured.
+func (a *App) since() (time.Time, error) {
+	if a.Config.Since == "" {
+		return time.Time{}, nil
+	}
+
+	since, err := config.ParseBuildDate(a.Config.Since)
+	if err != nil {
+		return time.Time{}, errors.Wrap(config.ErrConfig, "since is invalid: "+err.Error())
+	}
+
+	return since, nil
+}
+
+// abortStaleMultipartUploads aborts the incomplete multipart uploads on the firmware repository when configured,
+// a failure doesn't prevent syncing.
+func (a *App) abortStaleMultipartUploads(ctx context.Context, dstFs rcloneFs.Fs) {
+	maxAge := a.Config.FirmwareRepository.MultipartUploadMaxAge
+	if maxAge <= 0 {
+		return
+	}
+
+	if err := vendors.AbortStaleMultipartUploads(ctx, dstFs, maxAge); err != nil {
+		a.Logger.WithError(err).Warn("Failed to abort stale multipart uploads")
+	}
+}
+
+// setDstPathTemplate sets the configured template of the firmware destination path.
+func (a *App) setDstPathTemplate() error {
+	if a.Config.DstPathTemplate == "" {
+		return nil
+	}
+
+	tmpl, err := vendors.ParseDstPathTemplate(a.Config.DstPathTemplate)
+	if err != nil {
+		return errors.Wrap(config.ErrConfig, err.Error())
+	}
+
+	vendors.SetDstPathTemplate(tmpl)
+
+	return nil
+}
+
+// cleanWorkDir removes the download directories a previous run left behind in the work directory,
+// failures are logged since they don't prevent syncing.
+func (a *App) cleanWorkDir() {
+	removed, err := vendors.CleanStaleDownloadDirs(a.Config.WorkDir, staleDownloadDirAge)
+	if err != nil {
+		a.Logger.WithError(err).Warn("Failed to clean up stale download directories")
+	}
+
+	if len(removed) > 0 {
+		a.Logger.WithField("dirs", removed).Info("Removed stale download directories")
+	}
+}
+
+// pruneDownloadDirs removes the download directories left in the work directory, whatever their age.
+func (a *App) pruneDownloadDirs() {
+	removed, err := vendors.CleanStaleDownloadDirs(a.Config.WorkDir, 0)
+	if err != nil {
+		a.Logger.WithError(err).Warn("Failed to prune download directories")
+	}
+
+	if len(removed) > 0 {
+		a.Logger.WithField("dirs", removed).Info("Pruned download directories")
+	}
+}
+
+// newTmpFs returns the local fs firmware is downloaded to, rooted at the configured WorkDir.
+func (a *App) newTmpFs(ctx context.Context) (rcloneFs.Fs, error) {
+	return vendors.InitLocalFs(ctx, &vendors.LocalFsConfig{
+		Root:            a.Config.WorkDir,
+		CopyLinks:       a.Config.LocalFs.CopyLinks,
+		OneFileSystem:   a.Config.LocalFs.OneFileSystem,
+		NoPreallocation: a.Config.LocalFs.NoPreallocation,
+	})
+}
+
+// newDellDownloader returns the Downloader for the Dell DUPs,
+// their signature is verified when it's enabled, see config.DellSignatures.
+func (a *App) newDellDownloader() (vendors.Downloader, error) {
+	if !a.Config.DellSignatures.Enabled {
+		return vendors.NewRcloneDownloader(a.Logger), nil
+	}
+
+	verifier, err := dell.NewSignatureVerifier(a.Config.DellSignatures.TrustedRoots)
+	if err != nil {
+		return nil, err
+	}
+
+	return dell.NewDellDownloader(a.Logger, verifier), nil
+}
+
+// newDownloader returns the Downloader for the vendor's firmware,
+// vendors without a dedicated downloader fall back to the DefaultDownloadURL when it's configured.
+// nil is returned when the vendor isn't supported.
+func (a *App) newDownloader(ctx context.Context, vendor string) (vendors.Downloader, error) {
+	switch config.NormalizeVendor(vendor) {
+	case common.VendorDell:
+		return a.newDellDownloader()
+	case common.VendorAsrockrack:
+		s3Fs, err := vendors.InitS3Fs(ctx, a.Config.AsRockRackRepository, "/")
+		if err != nil {
+			return nil, err
+		}
+
+		return vendors.NewS3Downloader(a.Logger, s3Fs), nil
+	case common.VendorSupermicro:
+		return supermicro.NewSupermicroDownloader(a.Logger), nil
+	case common.VendorMellanox:
+		return mellanox.NewMellanoxDownloader(a.Logger), nil
+	case common.VendorIntel:
+		return vendors.NewArchiveDownloader(a.Logger), nil
+	case common.VendorBroadcom:
+		return broadcom.NewBroadcomDownloader(a.Logger), nil
+	case VendorAMI:
+		return ami.NewAMIDownloader(a.Logger), nil
+	case VendorEquinix:
+		ghClient := github.NewGitHubClient(ctx, a.Config.GithubOpenBmcToken)
+		return github.NewGitHubDownloader(a.Logger, ghClient), nil
+	default:
+		if a.Config.DefaultDownloadURL == "" {
+			return nil, nil
+		}
+
+		a.Logger.WithField("vendor", vendor).
+			WithField("url", a.Config.DefaultDownloadURL).
+			Info("No dedicated downloader for vendor, falling back to the default download URL")
+
+		// firmware downloads can take a while, they're not bound by the client timeout
+		client := vendors.NewHTTPClient(&vendors.HTTPClientOptions{Timeout: -1, Proxy: a.proxy})
+
+		return vendors.NewSourceOverrideDownloader(a.Logger, client, a.Config.DefaultDownloadURL), nil
+	}
+}
+
+// SyncFirmwares syncs all firmware files from the configured providers,
+// the outcome is notified once done when notifications are configured.
+func (a *App) SyncFirmwares(ctx context.Context) (err error) {
+	started := time.Now()
+	defer func() {
+		a.syncDuration = time.Since(started)
+		a.notify(ctx, err)
+	}()
+
+	if a.Config.PruneTmpOnExit {
+		// the vendor syncs return once their in-flight downloads are done, nothing is pruned from under them
+		defer a.pruneDownloadDirs()
+	}
+
 	for _, v := range a.vendors {
+		if ctx.Err() != nil {
+			break
+		}
+
 		err := v.Sync(ctx)
 		if err != nil {
 			a.Logger.WithError(err).Error("Failed to sync vendor")
 		}
 	}
 
-	return nil
+	publishErr := a.closeQueue()
+
+	if err := ctx.Err(); err != nil {
+		return errors.Wrap(err, "sync interrupted")
+	}
+
+	// the run completed, the next one starts over, unless queued firmware failed to publish:
+	// the next run resumes from the state and publishes it again without transferring it
+	if a.state != nil && publishErr == nil {
+		if err := a.state.Remove(); err != nil {
+			a.Logger.WithError(err).Error("Failed to remove the sync state file")
+		}
+	}
+
+	if a.verifier != nil {
+		a.VerifyFirmwares(ctx)
+	}
+
+	if a.Config.PruneInventory {
+		a.Logger.Info("Pruning firmware no longer in the manifest from inventory")
+
+		if err := a.inventory.Prune(ctx, a.firmwares); err != nil {
+			return errors.Wrap(err, "failed to prune inventory")
+		}
+	}
+
+	return publishErr
+}
+
+// closeQueue flushes the inventory write-behind queue once the vendors are synced,
+// the firmware failing to publish is reported as failed by its vendor and the publish errors are returned.
+func (a *App) closeQueue() error {
+	if a.queue == nil {
+		return nil
+	}
+
+	defer func() { a.inventory = a.queue.Inner() }()
+
+	err := a.queue.Close()
+	if err == nil {
+		return nil
+	}
+
+	a.Logger.WithError(err).Error("Failed to publish queued firmware")
+
+	var publishErrors inventory.PublishErrors
+	if errors.As(err, &publishErrors) {
+		for key, publishErr := range publishErrors {
+			a.reportPublishFailed(key, publishErr)
+		}
+	}
+
+	return errors.Wrap(err, "failed to publish queued firmware")
+}
+
+// reportPublishFailed reports the firmware with the <vendor>/<filename> key as failed in the report of its vendor.
+func (a *App) reportPublishFailed(key string, err error) {
+	for _, v := range a.vendors {
+		reporter, ok := v.(vendors.Reporter)
+		if ok && reporter.Report() != nil && reporter.Report().PublishFailed(key, err) {
+			return
+		}
+	}
+}
+
+// VerifyFirmwares verifies the checksum of the firmware files present in the firmware repository,
+// mismatches are logged and counted in the sync errors metric.
+func (a *App) VerifyFirmwares(ctx context.Context) {
+	a.Logger.Info("Verifying firmware in the firmware repository")
+
+	failed := 0
+
+	for _, result := range a.verifier.Verify(ctx, a.publishedFirmwares()) {
+		if result.Err != nil {
+			failed++
+		}
+	}
+
+	a.Logger.WithField("count", len(a.firmwares)).
+		WithField("failed", failed).
+		Info("Verified firmware")
+}
+
+// publishedFirmwares returns the manifest firmware as published, with their filenames sanitized
+// when sanitize_filenames is set, so they're looked up under the path they were uploaded to.
+func (a *App) publishedFirmwares() []*fleetdbapi.ComponentFirmwareVersion {
+	if !a.Config.SanitizeFilenames {
+		return a.firmwares
+	}
+
+	published := make([]*fleetdbapi.ComponentFirmwareVersion, 0, len(a.firmwares))
+
+	for _, firmware := range a.firmwares {
+		sanitized := *firmware
+		sanitized.Filename = vendors.SanitizeFilename(firmware.Filename)
+		published = append(published, &sanitized)
+	}
+
+	return published
 }
 
 // nolint:gocyclo // config load is cyclomatic
@@ -134,7 +769,7 @@ func (a *App) SyncFirmwares(ctx context.Context) error {
 //
 // Reads in the cfgFile when available and overrides from environment variables.
 func (a *App) LoadConfiguration(cfgFile string, inventoryKind types.InventoryKind) error {
-	a.v.SetConfigType("yaml")
+	a.v.SetConfigType(configType(cfgFile))
 	a.v.SetEnvPrefix(types.AppName)
 	a.v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 	a.v.AutomaticEnv()
@@ -175,15 +810,54 @@ func (a *App) LoadConfiguration(cfgFile string, inventoryKind types.InventoryKin
 		}
 	}
 
+	if inventoryKind != "" {
+		a.Config.InventoryKind = inventoryKind
+	}
+
+	if a.Config.WorkDir == "" {
+		a.Config.WorkDir = os.TempDir()
+	}
+
+	if a.Config.Concurrency == 0 {
+		a.Config.Concurrency = config.SyncerConcurrency
+	}
+
+	if err := a.Config.Validate(); err != nil {
+		return err
+	}
+
+	if a.Config.DstPathTemplate != "" {
+		if _, err := vendors.ParseDstPathTemplate(a.Config.DstPathTemplate); err != nil {
+			return errors.Wrap(config.ErrConfig, err.Error())
+		}
+	}
+
 	return nil
 }
 
+// configType returns the viper config type for the config file based on its extension,
+// yaml is assumed when the extension isn't recognized.
+func configType(cfgFile string) string {
+	switch strings.ToLower(filepath.Ext(cfgFile)) {
+	case ".toml":
+		return "toml"
+	case ".json":
+		return "json"
+	default:
+		return "yaml"
+	}
+}
+
 // nolint:gocyclo // env var load is cyclomatic
 func (a *App) envVarAppOverrides() error {
 	if a.v.GetString("log.level") != "" {
 		a.Config.LogLevel = a.v.GetString("log.level")
 	}
 
+	if a.v.GetString("log.config") != "" {
+		a.Config.LogConfig = a.v.GetBool("log.config")
+	}
+
 	if a.v.GetString("s3.endpoint") != "" {
 		a.Config.FirmwareRepository.Endpoint = a.v.GetString("s3.endpoint")
 	}
@@ -204,6 +878,50 @@ func (a *App) envVarAppOverrides() error {
 		a.Config.FirmwareRepository.SecretKey = a.v.GetString("s3.secret.key")
 	}
 
+	if a.v.GetString("s3.probe.connectivity") != "" {
+		a.Config.FirmwareRepository.ProbeConnectivity = a.v.GetBool("s3.probe.connectivity")
+	}
+
+	if a.v.GetString("s3.probe.timeout") != "" {
+		a.Config.FirmwareRepository.ProbeTimeout = a.v.GetDuration("s3.probe.timeout")
+	}
+
+	if a.v.GetString("s3.leave.parts.on.error") != "" {
+		a.Config.FirmwareRepository.LeavePartsOnError = a.v.GetBool("s3.leave.parts.on.error")
+	}
+
+	if a.v.GetString("s3.multipart.upload.max.age") != "" {
+		a.Config.FirmwareRepository.MultipartUploadMaxAge = a.v.GetDuration("s3.multipart.upload.max.age")
+	}
+
+	if a.v.GetString("s3.storage.class") != "" {
+		a.Config.FirmwareRepository.StorageClass = a.v.GetString("s3.storage.class")
+	}
+
+	if a.v.GetString("s3.acl") != "" {
+		a.Config.FirmwareRepository.ACL = a.v.GetString("s3.acl")
+	}
+
+	if a.v.GetString("s3.sse.algorithm") != "" {
+		a.Config.FirmwareRepository.SSEAlgorithm = a.v.GetString("s3.sse.algorithm")
+	}
+
+	if a.v.GetString("s3.sse.kms.key.id") != "" {
+		a.Config.FirmwareRepository.SSEKMSKeyID = a.v.GetString("s3.sse.kms.key.id")
+	}
+
+	if a.v.GetString("dell.signatures.enabled") != "" {
+		a.Config.DellSignatures.Enabled = a.v.GetBool("dell.signatures.enabled")
+	}
+
+	if a.v.GetString("dell.signatures.trusted.roots") != "" {
+		a.Config.DellSignatures.TrustedRoots = a.v.GetString("dell.signatures.trusted.roots")
+	}
+
+	if a.v.GetString("upload.quorum") != "" {
+		a.Config.UploadQuorum = a.v.GetInt("upload.quorum")
+	}
+
 	if a.v.GetString("asrr.s3.region") != "" {
 		a.Config.AsRockRackRepository.Region = a.v.GetString("asrr.s3.region")
 	}
@@ -232,6 +950,142 @@ func (a *App) envVarAppOverrides() error {
 		a.Config.DefaultDownloadURL = a.v.GetString("default.download.url")
 	}
 
+	if a.v.GetString("sanitize.filenames") != "" {
+		a.Config.SanitizeFilenames = a.v.GetBool("sanitize.filenames")
+	}
+
+	if a.v.GetString("prune.inventory") != "" {
+		a.Config.PruneInventory = a.v.GetBool("prune.inventory")
+	}
+
+	if a.v.GetString("audit.log") != "" {
+		a.Config.AuditLog = a.v.GetString("audit.log")
+	}
+
+	if a.v.GetString("events.webhook.url") != "" {
+		a.Config.EventsWebhookURL = a.v.GetString("events.webhook.url")
+	}
+
+	if a.v.GetString("concurrency") != "" {
+		a.Config.Concurrency = a.v.GetInt("concurrency")
+	}
+
+	if a.v.GetString("verify.enabled") != "" {
+		a.Config.Verify.Enabled = a.v.GetBool("verify.enabled")
+	}
+
+	if a.v.GetString("verify.concurrency") != "" {
+		a.Config.Verify.Concurrency = a.v.GetInt("verify.concurrency")
+	}
+
+	if a.v.GetString("verify.rate.limit") != "" {
+		a.Config.Verify.RateLimit = a.v.GetFloat64("verify.rate.limit")
+	}
+
+	if a.v.GetString("quarantine.dir") != "" {
+		a.Config.QuarantineDir = a.v.GetString("quarantine.dir")
+	}
+
+	if a.v.GetString("inventory.queue.size") != "" {
+		a.Config.InventoryQueue.Size = a.v.GetInt("inventory.queue.size")
+	}
+
+	if a.v.GetString("inventory.queue.batch.size") != "" {
+		a.Config.InventoryQueue.BatchSize = a.v.GetInt("inventory.queue.batch.size")
+	}
+
+	if a.v.GetString("inventory.queue.flush.interval") != "" {
+		a.Config.InventoryQueue.FlushInterval = a.v.GetDuration("inventory.queue.flush.interval")
+	}
+
+	if a.v.GetString("state.file") != "" {
+		a.Config.StateFile = a.v.GetString("state.file")
+	}
+
+	if a.v.GetString("work.dir") != "" {
+		a.Config.WorkDir = a.v.GetString("work.dir")
+	}
+
+	if a.v.GetString("progress.interval") != "" {
+		a.Config.ProgressInterval = a.v.GetDuration("progress.interval")
+	}
+
+	if a.v.GetString("dst.path.template") != "" {
+		a.Config.DstPathTemplate = a.v.GetString("dst.path.template")
+	}
+
+	if a.v.GetString("max.file.size") != "" {
+		a.Config.MaxFileSize = a.v.GetInt64("max.file.size")
+	}
+
+	if a.v.GetString("allow.git.file.urls") != "" {
+		a.Config.AllowGitFileURLs = a.v.GetBool("allow.git.file.urls")
+	}
+
+	if a.v.GetString("max.extracted.size") != "" {
+		a.Config.MaxExtractedSize = a.v.GetInt64("max.extracted.size")
+	}
+
+	if a.v.GetString("max.archive.entries") != "" {
+		a.Config.MaxArchiveEntries = a.v.GetInt("max.archive.entries")
+	}
+
+	if a.v.GetString("since") != "" {
+		a.Config.Since = a.v.GetString("since")
+	}
+
+	if a.v.GetString("latest.only") != "" {
+		a.Config.LatestOnly = a.v.GetBool("latest.only")
+	}
+
+	if a.v.GetString("components") != "" {
+		a.Config.Components = strings.Split(a.v.GetString("components"), ",")
+	}
+
+	if a.v.GetString("model.allowlist") != "" {
+		a.Config.ModelAllowlist = strings.Split(a.v.GetString("model.allowlist"), ",")
+	}
+
+	if a.v.GetString("model.denylist") != "" {
+		a.Config.ModelDenylist = strings.Split(a.v.GetString("model.denylist"), ",")
+	}
+
+	if a.v.GetString("prune.tmp.on.exit") != "" {
+		a.Config.PruneTmpOnExit = a.v.GetBool("prune.tmp.on.exit")
+	}
+
+	if a.v.GetString("firmware.manifest.overrides") != "" {
+		a.Config.FirmwareManifestOverrides = strings.Split(a.v.GetString("firmware.manifest.overrides"), ",")
+	}
+
+	if a.v.GetString("notifications.webhook.url") != "" {
+		a.Config.Notifications.WebhookURL = a.v.GetString("notifications.webhook.url")
+	}
+
+	if a.v.GetString("notifications.on") != "" {
+		a.Config.Notifications.On = a.v.GetString("notifications.on")
+	}
+
+	if a.v.GetString("verify.upload") != "" {
+		a.Config.VerifyUpload = a.v.GetBool("verify.upload")
+	}
+
+	if a.v.GetString("compress.uploads") != "" {
+		a.Config.CompressUploads = a.v.GetBool("compress.uploads")
+	}
+
+	if a.v.GetString("http.proxy") != "" {
+		a.Config.HTTPProxy = a.v.GetString("http.proxy")
+	}
+
+	if a.v.GetString("no.proxy") != "" {
+		a.Config.NoProxy = a.v.GetString("no.proxy")
+	}
+
+	if a.v.GetString("user.agent") != "" {
+		a.Config.UserAgent = a.v.GetString("user.agent")
+	}
+
 	return nil
 }
 
@@ -281,11 +1135,20 @@ func (a *App) envVarServerserviceOverrides() error {
 
 	a.Config.ServerserviceOptions.EndpointURL = endpointURL
 
+	if a.v.GetString("serverservice.recover.duplicates") != "" {
+		a.Config.ServerserviceOptions.RecoverDuplicates = a.v.GetBool("serverservice.recover.duplicates")
+	}
+
 	if a.v.GetString("serverservice.disable.oauth") != "" {
 		a.Config.ServerserviceOptions.DisableOAuth = a.v.GetBool("serverservice.disable.oauth")
 	}
 
-	if a.Config.ServerserviceOptions.DisableOAuth {
+	if a.v.GetString("serverservice.static.token") != "" {
+		a.Config.ServerserviceOptions.StaticToken = a.v.GetString("serverservice.static.token")
+	}
+
+	// a static token takes precedence over OAuth, the OIDC parameters are not required
+	if a.Config.ServerserviceOptions.StaticToken != "" || a.Config.ServerserviceOptions.DisableOAuth {
 		return nil
 	}
 
@@ -321,6 +1184,10 @@ func (a *App) envVarServerserviceOverrides() error {
 		return errors.New("serverservice.oidc.client.id not defined")
 	}
 
+	if a.v.GetString("serverservice.oidc.discovery.timeout") != "" {
+		a.Config.ServerserviceOptions.OidcDiscoveryTimeout = a.v.GetDuration("serverservice.oidc.discovery.timeout")
+	}
+
 	if a.v.GetString("serverservice.oidc.client.scopes") != "" {
 		a.Config.ServerserviceOptions.OidcClientScopes = a.v.GetStringSlice("serverservice.oidc.client.scopes")
 	}
@@ -0,0 +1,511 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	fleetdbapi "github.com/metal-toolbox/fleetdb/pkg/api/v1"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+
+	"github.com/metal-toolbox/firmware-syncer/internal/config"
+	"github.com/metal-toolbox/firmware-syncer/internal/inventory"
+	mockinventory "github.com/metal-toolbox/firmware-syncer/internal/inventory/mocks"
+	"github.com/metal-toolbox/firmware-syncer/internal/vendors"
+	"github.com/metal-toolbox/firmware-syncer/internal/vendors/ami"
+	"github.com/metal-toolbox/firmware-syncer/internal/vendors/broadcom"
+	"github.com/metal-toolbox/firmware-syncer/internal/vendors/mellanox"
+	"github.com/metal-toolbox/firmware-syncer/internal/vendors/supermicro"
+	"github.com/metal-toolbox/firmware-syncer/pkg/types"
+)
+
+const yamlConfig = `
+log_level: debug
+artifacts_url: "https://example.com"
+firmware_manifest_url: "https://example.com/modeldata.json"
+sanitize_filenames: true
+adaptive_concurrency:
+  min: 1
+  max: 8
+s3bucket:
+  region: "us-east-1"
+  endpoint: "https://s3.example.com"
+  bucket: "firmware"
+  access_key: "access"
+  secret_key: "secret"
+serverservice:
+  endpoint: "http://localhost:8000"
+  disable_oauth: true
+`
+
+const tomlConfig = `
+log_level = "debug"
+artifacts_url = "https://example.com"
+firmware_manifest_url = "https://example.com/modeldata.json"
+sanitize_filenames = true
+
+[adaptive_concurrency]
+min = 1
+max = 8
+
+[s3bucket]
+region = "us-east-1"
+endpoint = "https://s3.example.com"
+bucket = "firmware"
+access_key = "access"
+secret_key = "secret"
+
+[serverservice]
+endpoint = "http://localhost:8000"
+disable_oauth = true
+`
+
+const jsonConfig = `{
+  "log_level": "debug",
+  "artifacts_url": "https://example.com",
+  "firmware_manifest_url": "https://example.com/modeldata.json",
+  "sanitize_filenames": true,
+  "adaptive_concurrency": {"min": 1, "max": 8},
+  "s3bucket": {
+    "region": "us-east-1",
+    "endpoint": "https://s3.example.com",
+    "bucket": "firmware",
+    "access_key": "access",
+    "secret_key": "secret"
+  },
+  "serverservice": {
+    "endpoint": "http://localhost:8000",
+    "disable_oauth": true
+  }
+}`
+
+func loadConfiguration(t *testing.T, filename, content string) *config.Configuration {
+	cfgFile := filepath.Join(t.TempDir(), filename)
+	if err := os.WriteFile(cfgFile, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	a := &App{v: viper.New(), Config: &config.Configuration{}}
+	if err := a.LoadConfiguration(cfgFile, types.InventoryStoreServerservice); err != nil {
+		t.Fatal(err)
+	}
+
+	return a.Config
+}
+
+func TestLoadConfigurationFormats(t *testing.T) {
+	expected := loadConfiguration(t, "config.yaml", yamlConfig)
+
+	assert.Equal(t, "debug", expected.LogLevel)
+	assert.Equal(t, "https://example.com", expected.ArtifactsURL)
+	assert.True(t, expected.SanitizeFilenames)
+	assert.Equal(t, 8, expected.AdaptiveConcurrency.Max)
+	assert.Equal(t, "firmware", expected.FirmwareRepository.Bucket)
+	assert.Equal(t, "http://localhost:8000", expected.ServerserviceOptions.Endpoint)
+	assert.True(t, expected.ServerserviceOptions.DisableOAuth)
+
+	testCases := []struct {
+		filename string
+		content  string
+	}{
+		{"config.yml", yamlConfig},
+		{"config", yamlConfig},
+		{"config.toml", tomlConfig},
+		{"config.json", jsonConfig},
+		{"CONFIG.JSON", jsonConfig},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.filename, func(t *testing.T) {
+			assert.Equal(t, expected, loadConfiguration(t, tt.filename, tt.content))
+		})
+	}
+}
+
+func TestNewDownloaderDefaultDownloadURL(t *testing.T) {
+	logger := logrus.New()
+	logger.Out = io.Discard
+
+	testCases := []struct {
+		name               string
+		defaultDownloadURL string
+		expectDownloader   bool
+	}{
+		{
+			name:               "fallback downloader",
+			defaultDownloadURL: "https://firmware.example.com",
+			expectDownloader:   true,
+		},
+		{
+			name: "vendor skipped",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &App{
+				Config: &config.Configuration{DefaultDownloadURL: tt.defaultDownloadURL},
+				Logger: logger,
+			}
+
+			downloader, err := a.newDownloader(context.Background(), "acme")
+			assert.NoError(t, err)
+
+			if !tt.expectDownloader {
+				assert.Nil(t, downloader)
+				return
+			}
+
+			assert.IsType(t, &vendors.SourceOverrideDownloader{}, downloader)
+		})
+	}
+}
+
+func TestNewDownloaderVendorAliases(t *testing.T) {
+	logger := logrus.New()
+	logger.Out = io.Discard
+
+	config.RegisterVendorAlias("Acme Supermicro Reseller", "supermicro")
+	config.RegisterVendorAlias("NVIDIA Networking", "mellanox")
+
+	a := &App{
+		Config: &config.Configuration{
+			AsRockRackRepository: &config.S3Bucket{
+				Region:    "eu-west-1",
+				Endpoint:  "s3.example.com",
+				Bucket:    "asrr-firmware",
+				AccessKey: "access",
+				SecretKey: "secret",
+			},
+		},
+		Logger: logger,
+	}
+
+	testCases := []struct {
+		vendor   string
+		expected vendors.Downloader
+	}{
+		{"SuperMicro", &supermicro.Downloader{}},
+		{"Super Micro", &supermicro.Downloader{}},
+		{"Acme Supermicro Reseller", &supermicro.Downloader{}},
+		{"ASRockRack", &vendors.S3Downloader{}},
+		{"asrockrack", &vendors.S3Downloader{}},
+		{"Mellanox", &mellanox.Downloader{}},
+		{"NVIDIA Networking", &mellanox.Downloader{}},
+		{"nvidia", nil},
+		{"Broadcom", &broadcom.Downloader{}},
+		{"LSI", &broadcom.Downloader{}},
+		{"AMI", &ami.Downloader{}},
+		{"acme", nil},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.vendor, func(t *testing.T) {
+			downloader, err := a.newDownloader(context.Background(), tt.vendor)
+			assert.NoError(t, err)
+
+			if tt.expected == nil {
+				assert.Nil(t, downloader)
+				return
+			}
+
+			assert.IsType(t, tt.expected, downloader)
+		})
+	}
+}
+
+func TestNewDownloaderDefaultDownloadURLDownloads(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/firmware.bin", r.URL.Path)
+		_, _ = w.Write([]byte("firmware"))
+	}))
+	defer server.Close()
+
+	logger := logrus.New()
+	logger.Out = io.Discard
+
+	a := &App{
+		Config: &config.Configuration{DefaultDownloadURL: server.URL},
+		Logger: logger,
+	}
+
+	downloader, err := a.newDownloader(context.Background(), "acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	firmware := &fleetdbapi.ComponentFirmwareVersion{Vendor: "acme", Filename: "firmware.bin"}
+
+	firmwareFile, err := downloader.Download(context.Background(), t.TempDir(), firmware)
+	assert.NoError(t, err)
+	assert.FileExists(t, firmwareFile.Path)
+}
+
+func TestLoadConfigurationS3Buckets(t *testing.T) {
+	cfgFile := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(cfgFile, []byte(yamlConfig+`
+asrr_s3bucket:
+  region: "eu-west-1"
+  bucket: "asrr-firmware"
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("SYNCER_S3_ENDPOINT", "https://dst.example.com")
+	t.Setenv("SYNCER_ASRR_S3_ENDPOINT", "https://asrr.example.com")
+	t.Setenv("SYNCER_ASRR_S3_ACCESS_KEY", "asrr-access")
+
+	a := &App{v: viper.New(), Config: &config.Configuration{}}
+	if err := a.LoadConfiguration(cfgFile, types.InventoryStoreServerservice); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, &config.S3Bucket{
+		Region:    "us-east-1",
+		Endpoint:  "https://dst.example.com",
+		Bucket:    "firmware",
+		AccessKey: "access",
+		SecretKey: "secret",
+	}, a.Config.FirmwareRepository)
+
+	assert.Equal(t, &config.S3Bucket{
+		Region:    "eu-west-1",
+		Endpoint:  "https://asrr.example.com",
+		Bucket:    "asrr-firmware",
+		AccessKey: "asrr-access",
+	}, a.Config.AsRockRackRepository)
+}
+
+func TestLoadConfigurationWorkDir(t *testing.T) {
+	assert.Equal(t, os.TempDir(), loadConfiguration(t, "config.yaml", yamlConfig).WorkDir)
+
+	workDir := t.TempDir()
+	t.Setenv("SYNCER_WORK_DIR", workDir)
+
+	a := &App{Config: loadConfiguration(t, "config.yaml", yamlConfig)}
+	assert.Equal(t, workDir, a.Config.WorkDir)
+
+	tmpFs, err := a.newTmpFs(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, workDir, tmpFs.Root())
+}
+
+func TestLoadConfigurationConcurrency(t *testing.T) {
+	assert.Equal(t, config.SyncerConcurrency, loadConfiguration(t, "config.yaml", yamlConfig).Concurrency)
+
+	cfgFile := writeConfig(t, yamlConfig+"concurrency: 4\n")
+
+	a, err := newApp(types.InventoryStoreServerservice, cfgFile, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 4, a.Config.Concurrency)
+
+	// the CLI parameter takes precedence, it's not set when zero
+	a, err = newApp(types.InventoryStoreServerservice, cfgFile, "", WithConcurrency(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 4, a.Config.Concurrency)
+
+	a, err = newApp(types.InventoryStoreServerservice, cfgFile, "", WithConcurrency(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 2, a.Config.Concurrency)
+
+	_, err = newApp(types.InventoryStoreServerservice, writeConfig(t, yamlConfig+"concurrency: -1\n"), "")
+	assert.ErrorIs(t, err, config.ErrConfig)
+}
+
+func TestLoadConfigurationDstPathTemplate(t *testing.T) {
+	t.Setenv("SYNCER_DST_PATH_TEMPLATE", "{{.Vendor}}/{{.Model}}/{{.Filename}}")
+	assert.Equal(t, "{{.Vendor}}/{{.Model}}/{{.Filename}}", loadConfiguration(t, "config.yaml", yamlConfig).DstPathTemplate)
+
+	t.Setenv("SYNCER_DST_PATH_TEMPLATE", "{{.Vendor}}/{{.Slug}}")
+
+	cfgFile := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(cfgFile, []byte(yamlConfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	a := &App{v: viper.New(), Config: &config.Configuration{}}
+	err := a.LoadConfiguration(cfgFile, types.InventoryStoreServerservice)
+	assert.ErrorIs(t, err, config.ErrConfig)
+	assert.ErrorContains(t, err, "invalid destination path template")
+}
+
+// shutdownVendor leaves a download directory behind and cancels the sync, as SIGTERM does mid sync
+type shutdownVendor struct {
+	workDir string
+	cancel  context.CancelFunc
+	synced  bool
+}
+
+func (v *shutdownVendor) Sync(context.Context) error {
+	v.synced = true
+
+	if _, err := os.MkdirTemp(v.workDir, vendors.DownloadDirPrefix); err != nil {
+		return err
+	}
+
+	v.cancel()
+
+	return nil
+}
+
+func TestSyncFirmwaresPruneTmpOnExit(t *testing.T) {
+	workDir := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	interrupted := &shutdownVendor{workDir: workDir, cancel: cancel}
+	next := &shutdownVendor{workDir: workDir, cancel: cancel}
+
+	a := &App{
+		Config:  &config.Configuration{WorkDir: workDir, PruneTmpOnExit: true},
+		Logger:  logrus.New(),
+		vendors: []vendors.Vendor{interrupted, next},
+	}
+
+	// unrelated files in the work directory are kept
+	if err := os.WriteFile(filepath.Join(workDir, "keep.txt"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	err := a.SyncFirmwares(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.True(t, interrupted.synced)
+	assert.False(t, next.synced, "no vendor should be synced once interrupted")
+
+	entries, err := os.ReadDir(workDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "keep.txt", entries[0].Name())
+	}
+}
+
+func TestSyncFirmwaresSyncState(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+
+	state, err := vendors.LoadSyncState(stateFile, "https://example.com/modeldata.json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = state.Record("foo-vendor/foobar1.zip", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	a := &App{
+		Config:  &config.Configuration{WorkDir: t.TempDir()},
+		Logger:  logrus.New(),
+		vendors: []vendors.Vendor{&shutdownVendor{workDir: t.TempDir(), cancel: cancel}},
+		state:   state,
+	}
+
+	// the state file is kept for the next run to resume from
+	assert.ErrorIs(t, a.SyncFirmwares(ctx), context.Canceled)
+	assert.FileExists(t, stateFile)
+
+	a.vendors = nil
+
+	assert.NoError(t, a.SyncFirmwares(context.Background()))
+	assert.NoFileExists(t, stateFile)
+}
+
+// The firmware failing to publish once the write-behind queue is flushed fails the sync
+// and is reported as failed, the state is kept for the next run to publish it again.
+func TestSyncFirmwaresQueuePublishErrors(t *testing.T) {
+	ctx := context.Background()
+
+	dstFs, err := vendors.InitLocalFs(ctx, &vendors.LocalFsConfig{Root: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpFs, err := vendors.InitLocalFs(ctx, &vendors.LocalFsConfig{Root: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the firmware is already in the firmware repository, it's only published
+	firmware := &fleetdbapi.ComponentFirmwareVersion{Vendor: "dell", Filename: "BIOS.EXE", Checksum: "1234"}
+	if err = os.MkdirAll(filepath.Join(dstFs.Root(), "dell"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = os.WriteFile(filepath.Join(dstFs.Root(), "dell", "BIOS.EXE"), []byte("firmware"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+
+	state, err := vendors.LoadSyncState(stateFile, "https://example.com/modeldata.json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inner := mockinventory.NewMockServerService(gomock.NewController(t))
+	inner.EXPECT().PublishBatch(gomock.Any(), gomock.Any()).
+		Return(inventory.PublishErrors{"dell/BIOS.EXE": errors.New("inventory unavailable")})
+
+	logger := logrus.New()
+	queue := inventory.NewWriteBehindQueue(ctx, inner, logger, 10, 10, time.Hour)
+	syncer := vendors.NewSyncer(dstFs, tmpFs, nil, queue, []*fleetdbapi.ComponentFirmwareVersion{firmware}, logger,
+		vendors.WithSyncState(state))
+
+	a := &App{
+		Config:    &config.Configuration{},
+		Logger:    logger,
+		vendors:   []vendors.Vendor{syncer},
+		inventory: queue,
+		queue:     queue,
+		state:     state,
+	}
+
+	err = a.SyncFirmwares(ctx)
+	assert.ErrorContains(t, err, "inventory unavailable")
+	assert.FileExists(t, stateFile)
+
+	summary := a.Summary()
+	assert.Equal(t, 0, summary.Present)
+	assert.Equal(t, 1, summary.Failed)
+}
+
+func TestPublishedFirmwares(t *testing.T) {
+	firmwares := []*fleetdbapi.ComponentFirmwareVersion{
+		{Vendor: "supermicro", Filename: "X11SCH-(LN4)F BIOS.zip"},
+		{Vendor: "dell", Filename: "BIOS.EXE"},
+	}
+
+	a := &App{Config: &config.Configuration{}, firmwares: firmwares}
+	assert.Equal(t, firmwares, a.publishedFirmwares())
+
+	a.Config.SanitizeFilenames = true
+	published := a.publishedFirmwares()
+
+	assert.Equal(t, "supermicro/X11SCH-_LN4_F_BIOS.zip", vendors.DstPath(published[0]))
+	assert.Equal(t, "dell/BIOS.EXE", vendors.DstPath(published[1]))
+	assert.Equal(t, "X11SCH-(LN4)F BIOS.zip", firmwares[0].Filename, "the manifest firmware is left as is")
+}
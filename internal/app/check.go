@@ -0,0 +1,82 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/metal-toolbox/firmware-syncer/internal/config"
+	"github.com/metal-toolbox/firmware-syncer/internal/inventory"
+	"github.com/metal-toolbox/firmware-syncer/internal/vendors"
+	"github.com/metal-toolbox/firmware-syncer/pkg/types"
+)
+
+var ErrInventoryPing = errors.New("inventory doesn't support connectivity checks")
+
+// CheckResult is the outcome of a preflight check, Err is nil when the check passed.
+type CheckResult struct {
+	Name string
+	Err  error
+}
+
+// Passed returns true when the check passed.
+func (r *CheckResult) Passed() bool {
+	return r.Err == nil
+}
+
+// Check runs the preflight checks without syncing any firmware:
+// the configuration is loaded and validated, the S3 buckets are listed and the inventory is queried.
+// The other checks are skipped when the configuration fails to load.
+func Check(ctx context.Context, inventoryKind types.InventoryKind, cfgFile, logLevel string) []CheckResult {
+	app, err := newApp(inventoryKind, cfgFile, logLevel)
+	if err != nil {
+		return []CheckResult{{Name: "config", Err: err}}
+	}
+
+	results := []CheckResult{
+		{Name: "config"},
+		{Name: "s3bucket", Err: checkS3Bucket(ctx, app.Config.FirmwareRepository)},
+	}
+
+	for i, mirror := range app.Config.MirrorRepositories {
+		results = append(results, CheckResult{Name: fmt.Sprintf("mirror_s3buckets[%d]", i), Err: checkS3Bucket(ctx, mirror)})
+	}
+
+	if app.Config.AsRockRackRepository != nil && app.Config.AsRockRackRepository.Bucket != "" {
+		results = append(results, CheckResult{Name: "asrr_s3bucket", Err: checkS3Bucket(ctx, app.Config.AsRockRackRepository)})
+	}
+
+	if app.Config.InventoryKind == types.InventoryStoreServerservice {
+		results = append(results, CheckResult{Name: "serverservice", Err: app.checkInventory(ctx)})
+	}
+
+	return results
+}
+
+// checkS3Bucket lists the root of the bucket, to check its credentials.
+func checkS3Bucket(ctx context.Context, bucket *config.S3Bucket) error {
+	fs, err := vendors.InitS3Fs(ctx, bucket, "/")
+	if err != nil {
+		return err
+	}
+
+	_, err = fs.List(ctx, "")
+
+	return err
+}
+
+// checkInventory queries the inventory, to check its endpoint and credentials.
+func (a *App) checkInventory(ctx context.Context) error {
+	inventoryClient, err := a.newInventory(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	pinger, ok := inventoryClient.(inventory.Pinger)
+	if !ok {
+		return ErrInventoryPing
+	}
+
+	return pinger.Ping(ctx)
+}
@@ -0,0 +1,117 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/metal-toolbox/firmware-syncer/internal/config"
+	"github.com/metal-toolbox/firmware-syncer/pkg/types"
+)
+
+const emptyBucketListing = `<?xml version="1.0" encoding="UTF-8"?>
+<ListBucketResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <Name>firmware</Name>
+  <Prefix></Prefix>
+  <KeyCount>0</KeyCount>
+  <MaxKeys>1000</MaxKeys>
+  <IsTruncated>false</IsTruncated>
+</ListBucketResult>`
+
+// newCheckServer serves an empty S3 bucket listing and an empty inventory firmware listing.
+func newCheckServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/firmware", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/xml")
+		_, _ = w.Write([]byte(emptyBucketListing))
+	})
+	mux.HandleFunc("/api/v1/server-component-firmwares", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"records": []}`))
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func writeConfig(t *testing.T, content string) string {
+	cfgFile := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(cfgFile, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return cfgFile
+}
+
+func TestCheck(t *testing.T) {
+	server := newCheckServer(t)
+
+	goodConfig := strings.NewReplacer(
+		"https://s3.example.com", server.URL,
+		"http://localhost:8000", server.URL,
+	).Replace(yamlConfig)
+
+	testCases := []struct {
+		name     string
+		config   string
+		expected []string
+		err      error
+	}{
+		{
+			name:     "good config",
+			config:   goodConfig,
+			expected: []string{"config", "s3bucket", "serverservice"},
+		},
+		{
+			name: "missing s3 bucket",
+			config: strings.Replace(goodConfig, `  bucket: "firmware"
+`, "", 1),
+			expected: []string{"config"},
+			err:      config.ErrConfig,
+		},
+		{
+			name:     "missing serverservice endpoint",
+			config:   strings.Replace(goodConfig, "  endpoint: \""+server.URL+"\"\n  disable_oauth", "  disable_oauth", 1),
+			expected: []string{"config"},
+			err:      config.ErrConfig,
+		},
+		{
+			name:     "malformed config",
+			config:   "s3bucket: [",
+			expected: []string{"config"},
+			err:      config.ErrConfig,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			results := Check(context.Background(), types.InventoryStoreServerservice, writeConfig(t, tt.config), "")
+
+			names := make([]string, 0, len(results))
+			for _, result := range results {
+				names = append(names, result.Name)
+			}
+
+			assert.Equal(t, tt.expected, names)
+
+			if tt.err != nil {
+				assert.False(t, results[0].Passed())
+				assert.ErrorIs(t, results[0].Err, tt.err)
+
+				return
+			}
+
+			for _, result := range results {
+				assert.NoError(t, result.Err, result.Name)
+			}
+		})
+	}
+}
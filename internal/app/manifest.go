@@ -0,0 +1,71 @@
+package app
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/metal-toolbox/firmware-syncer/internal/config"
+	"github.com/metal-toolbox/firmware-syncer/internal/inventory"
+	"github.com/metal-toolbox/firmware-syncer/internal/vendors"
+	"github.com/metal-toolbox/firmware-syncer/pkg/types"
+
+	fleetdbapi "github.com/metal-toolbox/fleetdb/pkg/api/v1"
+)
+
+var ErrInventoryDiff = errors.New("inventory doesn't support comparing firmware")
+
+// ValidateManifest returns the problems found with the firmware manifest at manifestURL, a URL or a local path.
+func ValidateManifest(ctx context.Context, manifestURL string) ([]string, error) {
+	return config.ValidateFirmwareManifest(ctx, vendors.NewHTTPClient(nil), manifestURL)
+}
+
+// DiffManifest returns the changes syncing the firmware manifest at manifestURL would make to inventory,
+// the configured firmware manifest is compared when manifestURL is empty.
+func DiffManifest(
+	ctx context.Context,
+	inventoryKind types.InventoryKind,
+	cfgFile, logLevel, manifestURL string,
+) (*inventory.ManifestDiff, error) {
+	app, err := newApp(inventoryKind, cfgFile, logLevel)
+	if err != nil {
+		return nil, err
+	}
+
+	if manifestURL == "" {
+		manifestURL = app.Config.FirmwareManifestURL
+	}
+
+	for alias, vendor := range app.Config.VendorAliases {
+		config.RegisterVendorAlias(alias, vendor)
+	}
+
+	if err = app.setDstPathTemplate(); err != nil {
+		return nil, err
+	}
+
+	// the configured overrides apply to the compared manifest as they would to a sync
+	overrides := app.Config.FirmwareManifestOverrides
+
+	firmwaresByVendor, manifestDetails, err := config.LoadFirmwareManifest(ctx, vendors.NewHTTPClient(&vendors.HTTPClientOptions{Proxy: app.proxy}), manifestURL, overrides...)
+	if err != nil {
+		return nil, err
+	}
+
+	inventoryClient, err := app.newInventory(ctx, manifestDetails.Checksums)
+	if err != nil {
+		return nil, err
+	}
+
+	differ, ok := inventoryClient.(inventory.Differ)
+	if !ok {
+		return nil, ErrInventoryDiff
+	}
+
+	var firmwares []*fleetdbapi.ComponentFirmwareVersion
+	for _, vendorFirmwares := range firmwaresByVendor {
+		firmwares = append(firmwares, vendorFirmwares...)
+	}
+
+	return differ.Diff(ctx, firmwares)
+}
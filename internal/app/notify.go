@@ -0,0 +1,146 @@
+package app
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"time"
+
+	fleetdbapi "github.com/metal-toolbox/fleetdb/pkg/api/v1"
+	"github.com/pkg/errors"
+
+	"github.com/metal-toolbox/firmware-syncer/internal/config"
+	"github.com/metal-toolbox/firmware-syncer/internal/events"
+	"github.com/metal-toolbox/firmware-syncer/internal/vendors"
+)
+
+const (
+	// NotificationSyncSucceeded is sent when every firmware synced
+	NotificationSyncSucceeded = "sync.succeeded"
+	// NotificationSyncFailed is sent when the sync was interrupted or failed, or firmware failed to sync
+	NotificationSyncFailed = "sync.failed"
+
+	// notifyTimeout bounds the notification sent once the sync is done, interrupted syncs included
+	notifyTimeout = 30 * time.Second
+)
+
+var ErrNotify = errors.New("error sending sync notification")
+
+// Notification is the outcome of a sync, as sent to the Notifier.
+// The secrets in the firmware URLs are redacted, the failure reasons included.
+type Notification struct {
+	Event string `json:"event"`
+	// Error is why the sync failed, it's empty when only firmware failed to sync
+	Error   string       `json:"error,omitempty"`
+	Summary *SyncSummary `json:"summary"`
+}
+
+// Notifier is notified of the outcome of each sync.
+type Notifier interface {
+	Notify(ctx context.Context, notification *Notification) error
+}
+
+// WebhookNotifier notifies by POSTing the notifications as JSON to a webhook URL.
+type WebhookNotifier struct {
+	client fleetdbapi.Doer
+	url    string
+}
+
+// NewWebhookNotifier returns a WebhookNotifier posting notifications to the given URL.
+func NewWebhookNotifier(client fleetdbapi.Doer, webhookURL string) Notifier {
+	return &WebhookNotifier{client: client, url: webhookURL}
+}
+
+// Notify posts the notification to the webhook, any non 2xx response is returned as an error.
+func (w *WebhookNotifier) Notify(ctx context.Context, notification *Notification) error {
+	if err := events.PostJSON(ctx, w.client, w.url, notification); err != nil {
+		return errors.Wrap(ErrNotify, err.Error())
+	}
+
+	return nil
+}
+
+// notify notifies the outcome of the sync when it's one of the configured notifications.on outcomes,
+// the notification is sent even when the sync was interrupted.
+func (a *App) notify(ctx context.Context, syncErr error) {
+	if a.notifier == nil {
+		return
+	}
+
+	notification := newNotification(a.Summary(), syncErr)
+	if !a.Config.Notifications.Notifies(notification.Event == NotificationSyncFailed) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), notifyTimeout)
+	defer cancel()
+
+	if err := a.notifier.Notify(ctx, notification); err != nil {
+		a.Logger.WithError(err).Error("Failed to send the sync notification")
+	}
+}
+
+// newNotification returns the notification of the sync outcome, with the secrets of the summary redacted.
+func newNotification(summary *SyncSummary, syncErr error) *Notification {
+	notification := &Notification{Event: NotificationSyncSucceeded, Summary: redactSummary(summary)}
+
+	if syncErr != nil {
+		notification.Event = NotificationSyncFailed
+		notification.Error = syncErr.Error()
+	}
+
+	if summary.Failed > 0 {
+		notification.Event = NotificationSyncFailed
+	}
+
+	return notification
+}
+
+// redactSummary returns a copy of the summary with the credentials and query parameters of the firmware URLs redacted,
+// as they are in the failure reasons quoting them.
+func redactSummary(summary *SyncSummary) *SyncSummary {
+	redactedSummary := *summary
+	redactedSummary.Vendors = make([]*vendors.SyncReport, 0, len(summary.Vendors))
+
+	for _, report := range summary.Vendors {
+		failures := make([]vendors.SyncFailure, 0, len(report.Failures))
+
+		for _, failure := range report.Failures {
+			redactedURL := redactURL(failure.UpstreamURL)
+
+			failure.Reason = strings.ReplaceAll(failure.Reason, failure.UpstreamURL, redactedURL)
+			failure.UpstreamURL = redactedURL
+			failures = append(failures, failure)
+		}
+
+		redactedSummary.Vendors = append(redactedSummary.Vendors, &vendors.SyncReport{
+			Vendor:          report.Vendor,
+			Synced:          report.Synced,
+			Present:         report.Present,
+			Skipped:         report.Skipped,
+			Failed:          report.Failed,
+			Failures:        failures,
+			Bytes:           report.Bytes,
+			DurationSeconds: report.DurationSeconds,
+		})
+	}
+
+	return &redactedSummary
+}
+
+// redactURL returns the URL with its password and query parameter values redacted, as for presigned URLs.
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return rawURL
+	}
+
+	query := u.Query()
+	for key := range query {
+		query.Set(key, config.RedactedValue)
+	}
+
+	u.RawQuery = query.Encode()
+
+	return u.Redacted()
+}
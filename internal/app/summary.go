@@ -0,0 +1,59 @@
+package app
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+
+	"github.com/metal-toolbox/firmware-syncer/internal/vendors"
+)
+
+// SyncSummary is the outcome of the last SyncFirmwares, totalled over the vendor sync reports.
+type SyncSummary struct {
+	Synced          int                   `json:"synced"`
+	Present         int                   `json:"present"`
+	Skipped         int                   `json:"skipped"`
+	Failed          int                   `json:"failed"`
+	Bytes           int64                 `json:"bytes"`
+	DurationSeconds float64               `json:"duration_seconds"`
+	Vendors         []*vendors.SyncReport `json:"vendors"`
+}
+
+// Summary returns the outcome of the last SyncFirmwares, sorted by vendor,
+// vendors which don't report the outcome of their sync are left out.
+func (a *App) Summary() *SyncSummary {
+	summary := &SyncSummary{
+		DurationSeconds: a.syncDuration.Seconds(),
+		Vendors:         []*vendors.SyncReport{},
+	}
+
+	for _, v := range a.vendors {
+		reporter, ok := v.(vendors.Reporter)
+		if !ok || reporter.Report() == nil {
+			continue
+		}
+
+		report := reporter.Report()
+
+		summary.Synced += report.Synced
+		summary.Present += report.Present
+		summary.Skipped += report.Skipped
+		summary.Failed += report.Failed
+		summary.Bytes += report.Bytes
+		summary.Vendors = append(summary.Vendors, report)
+	}
+
+	sort.SliceStable(summary.Vendors, func(i, j int) bool {
+		return summary.Vendors[i].Vendor < summary.Vendors[j].Vendor
+	})
+
+	return summary
+}
+
+// WriteSummary writes the Summary to w as JSON.
+func (a *App) WriteSummary(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(a.Summary())
+}
@@ -0,0 +1,107 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/metal-toolbox/firmware-syncer/internal/config"
+	"github.com/metal-toolbox/firmware-syncer/internal/vendors"
+)
+
+// reportingVendor reports the given outcome once synced
+type reportingVendor struct {
+	outcome *vendors.SyncReport
+	report  *vendors.SyncReport
+}
+
+func (v *reportingVendor) Sync(context.Context) error {
+	v.report = v.outcome
+	return nil
+}
+
+func (v *reportingVendor) Report() *vendors.SyncReport {
+	return v.report
+}
+
+// silentVendor doesn't report the outcome of its sync
+type silentVendor struct{}
+
+func (silentVendor) Sync(context.Context) error {
+	return nil
+}
+
+func TestWriteSummary(t *testing.T) {
+	a := &App{
+		Config: &config.Configuration{},
+		Logger: logrus.New(),
+		vendors: []vendors.Vendor{
+			&reportingVendor{outcome: &vendors.SyncReport{
+				Vendor:  "supermicro",
+				Synced:  2,
+				Present: 3,
+				Bytes:   2048,
+				Failed:  1,
+				Failures: []vendors.SyncFailure{
+					{Filename: "BMC_X12.bin", Version: "1.0", UpstreamURL: "https://example.com/BMC_X12.bin", Reason: "checksum mismatch"},
+				},
+			}},
+			silentVendor{},
+			&reportingVendor{outcome: &vendors.SyncReport{Vendor: "dell", Synced: 1, Skipped: 4, Bytes: 1024, Failures: []vendors.SyncFailure{}}},
+		},
+	}
+
+	assert.NoError(t, a.SyncFirmwares(context.Background()))
+
+	var out bytes.Buffer
+	if err := a.WriteSummary(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	var summary map[string]any
+	if err := json.Unmarshal(out.Bytes(), &summary); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.ElementsMatch(t,
+		[]string{"synced", "present", "skipped", "failed", "bytes", "duration_seconds", "vendors"},
+		keys(summary),
+	)
+	assert.Equal(t, float64(3), summary["synced"])
+	assert.Equal(t, float64(3), summary["present"])
+	assert.Equal(t, float64(4), summary["skipped"])
+	assert.Equal(t, float64(1), summary["failed"])
+	assert.Equal(t, float64(3072), summary["bytes"])
+
+	reports, ok := summary["vendors"].([]any)
+	if !assert.True(t, ok) || !assert.Len(t, reports, 2) {
+		return
+	}
+
+	dell, supermicro := reports[0].(map[string]any), reports[1].(map[string]any)
+	assert.Equal(t, "dell", dell["vendor"])
+	assert.Equal(t, "supermicro", supermicro["vendor"])
+	assert.ElementsMatch(t,
+		[]string{"vendor", "synced", "present", "skipped", "failed", "failures", "bytes", "duration_seconds"},
+		keys(supermicro),
+	)
+	assert.Equal(t, []any{map[string]any{
+		"filename":     "BMC_X12.bin",
+		"version":      "1.0",
+		"upstream_url": "https://example.com/BMC_X12.bin",
+		"reason":       "checksum mismatch",
+	}}, supermicro["failures"])
+}
+
+func keys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
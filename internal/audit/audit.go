@@ -0,0 +1,76 @@
+package audit
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Stdout is the audit log sink writing records to the standard output
+const Stdout = "stdout"
+
+var (
+	ErrOpenAuditLog  = errors.New("error opening audit log")
+	ErrWriteAuditLog = errors.New("error writing audit log")
+)
+
+// Record is the audit record of a firmware synced to the firmware repository.
+type Record struct {
+	Time          time.Time `json:"time"`
+	Vendor        string    `json:"vendor"`
+	Filename      string    `json:"filename"`
+	Version       string    `json:"version"`
+	UpstreamURL   string    `json:"upstream_url"`
+	RepositoryURL string    `json:"repository_url"`
+	Checksum      string    `json:"checksum"`
+	Bytes         int64     `json:"bytes"`
+	// DurationSeconds is the time taken to transfer the firmware to the firmware repository
+	DurationSeconds float64 `json:"duration_seconds"`
+}
+
+// Logger writes audit records as JSON lines, separately from the operational logs.
+type Logger struct {
+	mutex sync.Mutex
+	w     io.Writer
+}
+
+// NewLogger returns a Logger writing audit records to w.
+func NewLogger(w io.Writer) *Logger {
+	return &Logger{w: w}
+}
+
+// Open returns a Logger writing to the sink, either Stdout or the path to a file records are appended to.
+func Open(sink string) (*Logger, error) {
+	if sink == Stdout {
+		return NewLogger(os.Stdout), nil
+	}
+
+	// the file is only ever appended to, records are never rewritten
+	f, err := os.OpenFile(sink, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, errors.Wrap(ErrOpenAuditLog, err.Error())
+	}
+
+	return NewLogger(f), nil
+}
+
+// Log writes the record as a single JSON line.
+func (l *Logger) Log(record *Record) error {
+	b, err := json.Marshal(record)
+	if err != nil {
+		return errors.Wrap(ErrWriteAuditLog, err.Error())
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if _, err = l.w.Write(append(b, '\n')); err != nil {
+		return errors.Wrap(ErrWriteAuditLog, err.Error())
+	}
+
+	return nil
+}
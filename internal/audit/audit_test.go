@@ -0,0 +1,55 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpen(t *testing.T) {
+	sink := filepath.Join(t.TempDir(), "audit.log")
+
+	// records are appended to the existing file
+	for _, filename := range []string{"first.bin", "second.bin"} {
+		logger, err := Open(sink)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		record := &Record{Time: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), Vendor: "dell", Filename: filename, Bytes: 1024}
+		if err = logger.Log(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f, err := os.Open(sink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var filenames []string
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, "dell", record.Vendor)
+		assert.Equal(t, int64(1024), record.Bytes)
+
+		filenames = append(filenames, record.Filename)
+	}
+
+	assert.Equal(t, []string{"first.bin", "second.bin"}, filenames)
+
+	_, err = Open(filepath.Join(t.TempDir(), "missing", "audit.log"))
+	assert.ErrorIs(t, err, ErrOpenAuditLog)
+}
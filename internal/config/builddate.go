@@ -0,0 +1,30 @@
+package config
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var ErrBuildDate = errors.New("unrecognized build date")
+
+// buildDateLayouts are the build_date formats found in modeldata.json, MM/DD/YYYY being the most common,
+// the month and day may not be zero padded.
+var buildDateLayouts = []string{"1/2/2006", "2006-01-02", time.RFC3339}
+
+// FirmwareBuildDates maps firmware upstream URLs to the build date declared in the firmware manifest.
+type FirmwareBuildDates map[string]string
+
+// ParseBuildDate parses a manifest build_date, dates without a time are parsed as midnight UTC.
+func ParseBuildDate(buildDate string) (time.Time, error) {
+	buildDate = strings.TrimSpace(buildDate)
+
+	for _, layout := range buildDateLayouts {
+		if t, err := time.Parse(layout, buildDate); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, errors.Wrap(ErrBuildDate, "'"+buildDate+"'")
+}
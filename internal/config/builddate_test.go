@@ -0,0 +1,38 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseBuildDate(t *testing.T) {
+	cases := []struct {
+		buildDate string
+		expected  time.Time
+		err       error
+	}{
+		{"03/15/2023", time.Date(2023, 3, 15, 0, 0, 0, 0, time.UTC), nil},
+		{"3/5/2023", time.Date(2023, 3, 5, 0, 0, 0, 0, time.UTC), nil},
+		{" 12/01/2022 ", time.Date(2022, 12, 1, 0, 0, 0, 0, time.UTC), nil},
+		{"2023-03-15", time.Date(2023, 3, 15, 0, 0, 0, 0, time.UTC), nil},
+		{"2023-03-15T10:30:00Z", time.Date(2023, 3, 15, 10, 30, 0, 0, time.UTC), nil},
+		{"15/03/2023", time.Time{}, ErrBuildDate},
+		{"March 2023", time.Time{}, ErrBuildDate},
+		{"", time.Time{}, ErrBuildDate},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.buildDate, func(t *testing.T) {
+			buildDate, err := ParseBuildDate(tc.buildDate)
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.True(t, tc.expected.Equal(buildDate), buildDate)
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package config
+
+import (
+	fleetdbapi "github.com/metal-toolbox/fleetdb/pkg/api/v1"
+)
+
+// FilterComponents returns the firmware of the given components, compared once converted to slugs, see ComponentSlug,
+// all the firmware is returned when no component is given.
+func FilterComponents(
+	firmwares []*fleetdbapi.ComponentFirmwareVersion,
+	components []string,
+) []*fleetdbapi.ComponentFirmwareVersion {
+	if len(components) == 0 {
+		return firmwares
+	}
+
+	wanted := make(map[string]bool, len(components))
+	for _, component := range components {
+		wanted[ComponentSlug(component)] = true
+	}
+
+	var filtered []*fleetdbapi.ComponentFirmwareVersion
+
+	for _, fw := range firmwares {
+		if wanted[ComponentSlug(fw.Component)] {
+			filtered = append(filtered, fw)
+		}
+	}
+
+	return filtered
+}
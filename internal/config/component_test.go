@@ -0,0 +1,58 @@
+package config
+
+import (
+	"testing"
+
+	fleetdbapi "github.com/metal-toolbox/fleetdb/pkg/api/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterComponents(t *testing.T) {
+	firmwares := []*fleetdbapi.ComponentFirmwareVersion{
+		{Component: "bios", Version: "3.7"},
+		{Component: "bmc", Version: "1.74.11"},
+		{Component: "bios", Version: "3.8a"},
+		{Component: "nic", Version: "1.0"},
+	}
+
+	testCases := []struct {
+		name       string
+		components []string
+		expected   []string
+	}{
+		{
+			name:     "no filter",
+			expected: []string{"bios-3.7", "bmc-1.74.11", "bios-3.8a", "nic-1.0"},
+		},
+		{
+			name:       "single component",
+			components: []string{"BIOS"},
+			expected:   []string{"bios-3.7", "bios-3.8a"},
+		},
+		{
+			name:       "multiple components",
+			components: []string{"bmc", " nic "},
+			expected:   []string{"bmc-1.74.11", "nic-1.0"},
+		},
+		{
+			name:       "component name converted to its slug",
+			components: []string{"NICs"},
+			expected:   []string{"nic-1.0"},
+		},
+		{
+			name:       "component not in the manifest",
+			components: []string{"drive"},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			var versions []string
+			for _, fw := range FilterComponents(firmwares, tt.components) {
+				versions = append(versions, fw.Component+"-"+fw.Version)
+			}
+
+			assert.Equal(t, tt.expected, versions)
+		})
+	}
+}
@@ -2,10 +2,10 @@ package config
 
 import (
 	"context"
-	"encoding/json"
-	"io"
-	"net/http"
+	"fmt"
 	"net/url"
+	"os"
+	"slices"
 	"strings"
 	"time"
 
@@ -23,12 +23,18 @@ var (
 	ErrProviderNotSupported = errors.New("provider not suppported")
 )
 
+// SyncerConcurrency is the default number of firmware each vendor syncs in parallel.
+const SyncerConcurrency = 1
+
 // Config holds application configuration read from a YAML or set by env variables.
 type Configuration struct {
 	// LogLevel is the app verbose logging level.
 	// one of - info, debug, trace
 	LogLevel string `mapstructure:"log_level"`
 
+	// LogConfig logs the effective configuration at startup, the fields tagged secret are redacted.
+	LogConfig bool `mapstructure:"log_config"`
+
 	InventoryKind types.InventoryKind `mapstructure:"inventory_kind"`
 
 	// ServerserviceOptions defines the serverservice client configuration parameters
@@ -39,8 +45,16 @@ type Configuration struct {
 	// FirmwareRepository defines configuration for the s3 bucket firmware will be synced to
 	FirmwareRepository *S3Bucket `mapstructure:"s3bucket"`
 
+	// MirrorRepositories are the s3 buckets the firmware is mirrored to in addition to the FirmwareRepository,
+	// inventory is published with the FirmwareRepository URL.
+	MirrorRepositories []*S3Bucket `mapstructure:"mirror_s3buckets"`
+
+	// UploadQuorum is the number of destinations, the FirmwareRepository included, the firmware has to be uploaded to
+	// for the sync to succeed, the FirmwareRepository upload is always required. Every destination is required when it's not set.
+	UploadQuorum int `mapstructure:"upload_quorum"`
+
 	// AsRockRackRepository defines configuration for the asrockrack s3 source firmware bucket
-	AsRockRackRepository *S3Bucket `mapstructure:"s3bucket"`
+	AsRockRackRepository *S3Bucket `mapstructure:"asrr_s3bucket"`
 
 	// ArtifactsURL defines the artifacts URL used by all firmware
 	ArtifactsURL string `mapstructure:"artifacts_url"`
@@ -48,11 +62,321 @@ type Configuration struct {
 	// FirmwareManifestURL defines the URL for modeldata.json
 	FirmwareManifestURL string `mapstructure:"firmware_manifest_url"`
 
+	// FirmwareManifestOverrides are manifests merged over the firmware manifest in order, as for per environment
+	// mirror URLs or extra firmware. Their records replace the records of the same vendor and filename, see mergeManifest.
+	FirmwareManifestOverrides []string `mapstructure:"firmware_manifest_overrides"`
+
 	// GithubOpenBmcToken defines the token used to access internal openbmc repository
-	GithubOpenBmcToken string `mapstructure:"github_openbmc_token"`
+	GithubOpenBmcToken string `mapstructure:"github_openbmc_token" secret:"true"`
 
 	// DefaultDownloadURL defines where unsupported firmware will be downloaded from
 	DefaultDownloadURL string `mapstructure:"default_download_url"`
+
+	// SanitizeFilenames replaces characters problematic for S3 keys and local filesystems in firmware filenames
+	SanitizeFilenames bool `mapstructure:"sanitize_filenames"`
+
+	// PruneInventory deletes firmware from inventory which is no longer listed in the firmware manifest
+	PruneInventory bool `mapstructure:"prune_inventory"`
+
+	// Concurrency is the number of firmware each vendor syncs in parallel, it defaults to SyncerConcurrency.
+	// It's ignored when AdaptiveConcurrency is enabled.
+	Concurrency int `mapstructure:"concurrency"`
+
+	// AdaptiveConcurrency enables syncing each vendor's firmware concurrently,
+	// with the concurrency adjusted based on the error rate.
+	AdaptiveConcurrency AdaptiveConcurrency `mapstructure:"adaptive_concurrency"`
+
+	// QuarantineDir is where archives the firmware can't be extracted from are moved to,
+	// so the vendor sync continues with the next firmware and the archive can be inspected.
+	QuarantineDir string `mapstructure:"quarantine_dir"`
+
+	// Verify enables verifying the checksum of firmware already present in the firmware repository
+	Verify Verify `mapstructure:"verify"`
+
+	// DellSignatures enables verifying the Authenticode signature embedded in Dell DUP executables
+	DellSignatures DellSignatures `mapstructure:"dell_signatures"`
+
+	// EventsWebhookURL is notified with a POST of each firmware newly synced, events are disabled when not set
+	EventsWebhookURL string `mapstructure:"events_webhook_url" secret:"true"`
+
+	// Notifications posts the outcome of each sync to a webhook, as for a chat channel, they're disabled when not set
+	Notifications Notifications `mapstructure:"notifications"`
+
+	// AuditLog is where an audit record of each firmware newly synced is written, as a JSON line,
+	// either stdout or the path to a file the records are appended to. Nothing is audited when not set.
+	AuditLog string `mapstructure:"audit_log"`
+
+	// InventoryQueue enables buffering inventory publishes and flushing them in batches
+	InventoryQueue InventoryQueue `mapstructure:"inventory_queue"`
+
+	// ProgressInterval is how often the progress of a firmware transfer is logged, defaults to 30s,
+	// a negative interval disables progress logging.
+	ProgressInterval time.Duration `mapstructure:"progress_interval"`
+
+	// WorkDir is the local directory firmware is downloaded and extracted in before being uploaded,
+	// it defaults to the OS temp directory and must have room for multi GB firmware files.
+	WorkDir string `mapstructure:"work_dir"`
+
+	// LocalFs overrides the options of the local filesystem in the work directory.
+	LocalFs LocalFs `mapstructure:"local_fs"`
+
+	// PruneTmpOnExit removes the download directories left in the work directory once the sync ends or is interrupted,
+	// the work directory is expected to be dedicated to the syncer.
+	PruneTmpOnExit bool `mapstructure:"prune_tmp_on_exit"`
+
+	// StateFile is where the firmware synced during the run is recorded, for an interrupted run to resume
+	// without checking the firmware repository for the firmware it already synced. It's removed once the run completes.
+	StateFile string `mapstructure:"state_file"`
+
+	// VerifyUpload reads back the hash of each uploaded object and compares it with the local file,
+	// a corrupt object is uploaded once more before the firmware fails. It costs a hash read per upload.
+	VerifyUpload bool `mapstructure:"verify_upload"`
+
+	// CompressUploads uploads a gzip compressed copy of the firmware next to it, with a .gz suffix,
+	// when it isn't already compressed and compression saves at least a tenth of its size.
+	// The firmware is still published uncompressed, the copy carries its checksums in its metadata.
+	CompressUploads bool `mapstructure:"compress_uploads"`
+
+	// MaxFileSize is the size in bytes past which firmware is skipped instead of downloaded,
+	// based on the server reported Content-Length, there's no limit when not set.
+	MaxFileSize int64 `mapstructure:"max_file_size"`
+
+	// AllowGitFileURLs allows firmware to be fetched from git+file:// URLs, they are refused otherwise
+	// as they let the manifest read any git repository on the syncer host.
+	AllowGitFileURLs bool `mapstructure:"allow_git_file_urls"`
+
+	// MaxExtractedSize is the size in bytes an archive can decompress to, it guards against zip bombs
+	// and defaults to 16 GiB.
+	MaxExtractedSize int64 `mapstructure:"max_extracted_size"`
+
+	// MaxArchiveEntries is the number of entries an archive can hold, it defaults to 10000.
+	MaxArchiveEntries int `mapstructure:"max_archive_entries"`
+
+	// DstPathTemplate is the text/template of the firmware path in the firmware repository,
+	// with the .Vendor, .Model, .Component and .Filename fields, it defaults to {{.Vendor}}/{{.Filename}}.
+	DstPathTemplate string `mapstructure:"dst_path_template"`
+
+	// VendorAliases maps manifest manufacturer names to the vendor they're synced as,
+	// in addition to the built-in aliases, see NormalizeVendor.
+	VendorAliases map[string]string `mapstructure:"vendor_aliases"`
+
+	// SourceHeaders are the HTTP headers sent with firmware downloads by source host, as for bearer token or API key auth,
+	// header values are secrets and are only logged redacted.
+	SourceHeaders map[string]map[string]string `mapstructure:"source_headers" secret:"true"`
+
+	// Since skips firmware with a manifest build_date before it, as a MM/DD/YYYY or YYYY-MM-DD date or an RFC 3339 time,
+	// firmware with an unparseable build date is synced.
+	Since string `mapstructure:"since"`
+
+	// LatestOnly only syncs the firmware flagged latest in the manifest
+	LatestOnly bool `mapstructure:"latest_only"`
+
+	// Components only syncs the firmware of these components, as bios or bmc, all the components are synced when empty.
+	Components []string `mapstructure:"components"`
+
+	// ModelAllowlist only syncs the firmware for any of these hardware models, all the models are synced when empty.
+	ModelAllowlist []string `mapstructure:"model_allowlist"`
+
+	// ModelDenylist skips the firmware for any of these hardware models, it takes precedence over the ModelAllowlist.
+	ModelDenylist []string `mapstructure:"model_denylist"`
+
+	// FTPCredentials are the credentials logged in with to ftp:// firmware sources by host,
+	// credentials in the firmware URL take precedence and FTP is anonymous for other hosts.
+	FTPCredentials map[string]FTPCredential `mapstructure:"ftp_credentials"`
+
+	// WebDAVCredentials are the credentials logged in with to webdav:// (http) and webdavs:// (https) firmware sources by host,
+	// credentials in the firmware URL take precedence.
+	WebDAVCredentials map[string]WebDAVCredential `mapstructure:"webdav_credentials"`
+
+	// HTTPProxy is the URL of the proxy outbound http and https requests are sent through,
+	// the HTTP_PROXY and HTTPS_PROXY env vars are used when it's not set.
+	HTTPProxy string `mapstructure:"http_proxy"`
+
+	// NoProxy are the comma separated hosts, domains and CIDRs requested without the proxy,
+	// the NO_PROXY env var is used when it's not set.
+	NoProxy string `mapstructure:"no_proxy"`
+
+	// UserAgent is the user agent of the outbound requests, firmware-syncer/<version> when it's not set.
+	UserAgent string `mapstructure:"user_agent"`
+}
+
+// WebDAVCredential is the user and password logged in with to a WebDAV source,
+// Vendor is the rclone webdav vendor of the share, as nextcloud or owncloud, it defaults to other.
+type WebDAVCredential struct {
+	User     string `mapstructure:"user"`
+	Password string `mapstructure:"password" secret:"true"`
+	Vendor   string `mapstructure:"vendor"`
+}
+
+// FTPCredential is the user and password logged in with to an FTP source.
+type FTPCredential struct {
+	User     string `mapstructure:"user"`
+	Password string `mapstructure:"password" secret:"true"`
+}
+
+// Validate checks the configuration has the parameters required for the selected inventory kind,
+// all the missing or invalid parameters are listed in the returned error.
+func (c *Configuration) Validate() error {
+	var problems []string
+
+	required := func(field, value string) {
+		if value == "" {
+			problems = append(problems, field+" is required")
+		}
+	}
+
+	required("firmware_manifest_url", c.FirmwareManifestURL)
+	required("artifacts_url", c.ArtifactsURL)
+
+	if c.FirmwareRepository == nil {
+		problems = append(problems, "s3bucket is required")
+	} else {
+		problems = append(problems, c.FirmwareRepository.destinationProblems("s3bucket.")...)
+	}
+
+	for i, mirror := range c.MirrorRepositories {
+		prefix := fmt.Sprintf("mirror_s3buckets[%d]", i)
+		if mirror == nil {
+			problems = append(problems, prefix+" is empty")
+			continue
+		}
+
+		problems = append(problems, mirror.destinationProblems(prefix+".")...)
+	}
+
+	if destinations := len(c.MirrorRepositories) + 1; c.UploadQuorum < 0 || c.UploadQuorum > destinations {
+		problems = append(problems, fmt.Sprintf("upload_quorum must not be negative or greater than the %d destinations", destinations))
+	}
+
+	if c.InventoryKind == types.InventoryStoreServerservice {
+		problems = append(problems, c.ServerserviceOptions.validate()...)
+	}
+
+	problems = append(problems, c.validateSyncOptions()...)
+	problems = append(problems, c.Notifications.validate()...)
+
+	if len(problems) > 0 {
+		return errors.Wrap(ErrConfig, strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+// validateSyncOptions returns the problems found with the optional sync parameters.
+func (c *Configuration) validateSyncOptions() []string {
+	var problems []string
+
+	if c.Concurrency < 1 {
+		problems = append(problems, "concurrency must be at least 1")
+	}
+
+	if c.AdaptiveConcurrency.Max > 0 && c.AdaptiveConcurrency.Min > c.AdaptiveConcurrency.Max {
+		problems = append(problems, "adaptive_concurrency.min must not be greater than adaptive_concurrency.max")
+	}
+
+	if c.WorkDir != "" {
+		if err := checkWritableDir(c.WorkDir); err != nil {
+			problems = append(problems, "work_dir is not writable: "+err.Error())
+		}
+	}
+
+	if c.Since != "" {
+		if _, err := ParseBuildDate(c.Since); err != nil {
+			problems = append(problems, "since is invalid: "+err.Error())
+		}
+	}
+
+	if c.DellSignatures.Enabled && c.DellSignatures.TrustedRoots == "" {
+		problems = append(problems, "dell_signatures.trusted_roots is required when dell_signatures.enabled is set")
+	}
+
+	return problems
+}
+
+// checkWritableDir checks a file can be created in the directory.
+func checkWritableDir(dir string) error {
+	f, err := os.CreateTemp(dir, ".firmware-syncer-")
+	if err != nil {
+		return err
+	}
+
+	f.Close()
+
+	return os.Remove(f.Name())
+}
+
+// validate returns the problems found with the serverservice parameters.
+func (o *ServerserviceOptions) validate() []string {
+	if o == nil {
+		return []string{"serverservice is required"}
+	}
+
+	var problems []string
+
+	if o.Endpoint == "" {
+		problems = append(problems, "serverservice.endpoint is required")
+	} else if _, err := url.ParseRequestURI(o.Endpoint); err != nil {
+		problems = append(problems, "serverservice.endpoint is invalid: "+err.Error())
+	}
+
+	// the OIDC parameters are only used with OAuth
+	if o.StaticToken != "" || o.DisableOAuth {
+		return problems
+	}
+
+	oidcParams := []struct {
+		field string
+		value string
+	}{
+		{"serverservice.oidc_issuer_endpoint", o.OidcIssuerEndpoint},
+		{"serverservice.oidc_audience_endpoint", o.OidcAudienceEndpoint},
+		{"serverservice.oidc_client_id", o.OidcClientID},
+		{"serverservice.oidc_client_secret", o.OidcClientSecret},
+	}
+
+	for _, param := range oidcParams {
+		if param.value == "" {
+			problems = append(problems, param.field+" is required")
+		}
+	}
+
+	if len(o.OidcClientScopes) == 0 {
+		problems = append(problems, "serverservice.oidc_client_scopes is required")
+	}
+
+	return problems
+}
+
+// InventoryQueue defines the inventory write-behind queue, it's disabled when Size is not set.
+type InventoryQueue struct {
+	// Size is the number of firmware buffered before publishing blocks
+	Size          int           `mapstructure:"size"`
+	BatchSize     int           `mapstructure:"batch_size"`
+	FlushInterval time.Duration `mapstructure:"flush_interval"`
+}
+
+// Verify defines the verification of firmware already present in the firmware repository.
+type Verify struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Concurrency is the number of objects verified in parallel
+	Concurrency int `mapstructure:"concurrency"`
+	// RateLimit is the maximum number of objects verified per second, unlimited when not set
+	RateLimit float64 `mapstructure:"rate_limit"`
+}
+
+// DellSignatures defines the verification of the Dell DUP signatures, see dell.SignatureVerifier for its limits.
+type DellSignatures struct {
+	Enabled bool `mapstructure:"enabled"`
+	// TrustedRoots is the PEM bundle of the root certificates the DUP signing certificates have to chain up to
+	TrustedRoots string `mapstructure:"trusted_roots"`
+}
+
+// AdaptiveConcurrency defines the bounds of the adaptive concurrency controller,
+// it's disabled when Max is not set.
+type AdaptiveConcurrency struct {
+	Min     int `mapstructure:"min"`
+	Max     int `mapstructure:"max"`
+	Initial int `mapstructure:"initial"`
 }
 
 // ServerserviceOptions defines configuration for the Serverservice client.
@@ -62,10 +386,18 @@ type ServerserviceOptions struct {
 	Endpoint             string   `mapstructure:"endpoint"`
 	OidcIssuerEndpoint   string   `mapstructure:"oidc_issuer_endpoint"`
 	OidcAudienceEndpoint string   `mapstructure:"oidc_audience_endpoint"`
-	OidcClientSecret     string   `mapstructure:"oidc_client_secret"`
+	OidcClientSecret     string   `mapstructure:"oidc_client_secret" secret:"true"`
 	OidcClientID         string   `mapstructure:"oidc_client_id"`
 	OidcClientScopes     []string `mapstructure:"oidc_client_scopes"`
 	DisableOAuth         bool     `mapstructure:"disable_oauth"`
+	// StaticToken is a long-lived bearer token issued out of band,
+	// when set it's used instead of OAuth.
+	StaticToken string `mapstructure:"static_token" secret:"true"`
+	// OidcDiscoveryTimeout is the time allowed for OIDC issuer discovery, including retries.
+	OidcDiscoveryTimeout time.Duration `mapstructure:"oidc_discovery_timeout"`
+	// RecoverDuplicates picks a canonical firmware record when multiple records share a checksum,
+	// instead of failing to publish the firmware.
+	RecoverDuplicates bool `mapstructure:"recover_duplicates"`
 }
 
 // FirmwareRecord from modeldata.json
@@ -79,6 +411,13 @@ type FirmwareRecord struct {
 	Model           string `json:"model,omitempty"`
 	InstallInband   bool   `json:"install_inband"`
 	Oem             bool   `json:"oem"`
+	// Size is the firmware download size in bytes, for servers not sending a Content-Length
+	Size int64 `json:"size,omitempty"`
+	// SHA256Sum is the firmware sha256 digest, optionally hinted with sha256:,
+	// it's published to inventory in place of the MD5Sum when both are set.
+	SHA256Sum string `json:"sha256,omitempty"`
+	// Checksums are the firmware digests by algorithm, the firmware has to match all of them
+	Checksums map[string]string `json:"checksums,omitempty"`
 	// intentionally ignoring preerequisite field in modeldata.json
 	// because sometimes it's a bool (false) or a string with the prerequisite
 }
@@ -90,70 +429,329 @@ type Model struct {
 	Components   map[string][]FirmwareRecord `json:"firmware"`
 }
 
+const (
+	NotifyOnSuccess = "success"
+	NotifyOnFailure = "failure"
+	NotifyOnAny     = "any"
+)
+
+// Notifications holds the parameters of the sync outcome notifications.
+type Notifications struct {
+	// WebhookURL is POSTed the outcome of each sync as JSON
+	WebhookURL string `mapstructure:"webhook_url" secret:"true"`
+	// On is the outcome notified: success, failure or any, it defaults to any.
+	// A sync fails when it's interrupted or any firmware fails to sync.
+	On string `mapstructure:"on"`
+}
+
+// Notifies returns true when the sync outcome is to be notified.
+func (n *Notifications) Notifies(failed bool) bool {
+	switch n.On {
+	case NotifyOnSuccess:
+		return !failed
+	case NotifyOnFailure:
+		return failed
+	default:
+		return true
+	}
+}
+
+func (n *Notifications) validate() []string {
+	switch n.On {
+	case "", NotifyOnSuccess, NotifyOnFailure, NotifyOnAny:
+		return nil
+	default:
+		return []string{fmt.Sprintf("notifications.on %q is not one of %s, %s, %s", n.On, NotifyOnSuccess, NotifyOnFailure, NotifyOnAny)}
+	}
+}
+
+// LocalFs holds the options of the local filesystem firmware is downloaded to, they default to true when not set.
+type LocalFs struct {
+	// CopyLinks follows symlinks and copies the files they point to
+	CopyLinks *bool `mapstructure:"copy_links"`
+	// OneFileSystem doesn't cross filesystem boundaries, it has to be disabled when the work directory is a bind mount
+	OneFileSystem *bool `mapstructure:"one_file_system"`
+	// NoPreallocation disables preallocating the files written, preallocation helps large writes on some filesystems
+	NoPreallocation *bool `mapstructure:"no_preallocation"`
+}
+
 // S3Bucket holds configuration parameters to connect to an S3 compatible bucket
 type S3Bucket struct {
 	Region    string `mapstructure:"region"`   // AWS region location for the s3 bucket
 	Endpoint  string `mapstructure:"endpoint"` // s3.foobar.com
 	Bucket    string `mapstructure:"bucket"`   // fup-data
-	AccessKey string `mapstructure:"access_key"`
-	SecretKey string `mapstructure:"secret_key"`
+	AccessKey string `mapstructure:"access_key" secret:"true"`
+	SecretKey string `mapstructure:"secret_key" secret:"true"`
+	// ProbeConnectivity enables a bucket listing after the s3 fs is initialized
+	// to fail early on DNS, TLS, credential or missing bucket errors.
+	ProbeConnectivity bool `mapstructure:"probe_connectivity"`
+	// ProbeTimeout is how long the connectivity probe waits for an unreachable bucket, as an object store
+	// started along with the syncer, the probe fails on the first attempt when it's not set.
+	ProbeTimeout time.Duration `mapstructure:"probe_timeout"`
+	// LeavePartsOnError keeps the parts of failed multipart uploads for manual recovery,
+	// they're aborted by default as they're billed until removed.
+	LeavePartsOnError bool `mapstructure:"leave_parts_on_error"`
+	// MultipartUploadMaxAge enables aborting the incomplete multipart uploads initiated longer ago on startup,
+	// as the uploads of a crashed sync.
+	MultipartUploadMaxAge time.Duration `mapstructure:"multipart_upload_max_age"`
+	// StorageClass is the storage class of the uploaded objects, the bucket default is used when it's not set.
+	StorageClass string `mapstructure:"storage_class"`
+	// ACL is the canned ACL of the uploaded objects, the bucket default is used when it's not set.
+	ACL string `mapstructure:"acl"`
+	// SSEAlgorithm is the server-side encryption of the uploaded objects, AES256 or aws:kms.
+	SSEAlgorithm string `mapstructure:"sse_algorithm"`
+	// SSEKMSKeyID is the KMS key the uploaded objects are encrypted with, required with the aws:kms algorithm.
+	SSEKMSKeyID string `mapstructure:"sse_kms_key_id"`
+}
+
+// S3StorageClasses are the storage classes accepted for the uploaded objects.
+var S3StorageClasses = []string{
+	"STANDARD",
+	"REDUCED_REDUNDANCY",
+	"STANDARD_IA",
+	"ONEZONE_IA",
+	"INTELLIGENT_TIERING",
+	"GLACIER",
+	"GLACIER_IR",
+	"DEEP_ARCHIVE",
+}
+
+// S3ACLs are the canned ACLs accepted for the uploaded objects.
+var S3ACLs = []string{
+	"private",
+	"public-read",
+	"public-read-write",
+	"authenticated-read",
+	"bucket-owner-read",
+	"bucket-owner-full-control",
 }
 
-func LoadFirmwareManifest(ctx context.Context, manifestURL string) (map[string][]*fleetdbapi.ComponentFirmwareVersion, error) {
-	var httpClient = &http.Client{
-		Timeout: time.Second * 15,
+const (
+	S3SSEAlgorithmAES256 = "AES256"
+	S3SSEAlgorithmKMS    = "aws:kms"
+)
+
+// S3SSEAlgorithms are the server-side encryption algorithms accepted for the uploaded objects.
+var S3SSEAlgorithms = []string{S3SSEAlgorithmAES256, S3SSEAlgorithmKMS}
+
+// destinationProblems returns the problems found with the parameters of a bucket firmware is uploaded to.
+func (b *S3Bucket) destinationProblems(prefix string) []string {
+	var problems []string
+
+	required := func(field, value string) {
+		if value == "" {
+			problems = append(problems, prefix+field+" is required")
+		}
 	}
 
-	req, err := http.NewRequestWithContext(
-		ctx,
-		"GET",
-		manifestURL,
-		http.NoBody,
-	)
-	if err != nil {
-		return nil, err
+	required("region", b.Region)
+	required("endpoint", b.Endpoint)
+	required("bucket", b.Bucket)
+	required("access_key", b.AccessKey)
+	required("secret_key", b.SecretKey)
+
+	return append(problems, b.uploadOptionProblems(prefix)...)
+}
+
+// ValidateUploadOptions returns an ErrConfig when the storage class, the ACL or the server-side encryption
+// isn't one of the accepted values, or when the KMS key id is missing for the aws:kms encryption.
+func (b *S3Bucket) ValidateUploadOptions() error {
+	problems := b.uploadOptionProblems("")
+	if len(problems) > 0 {
+		return errors.Wrap(ErrConfig, strings.Join(problems, "; "))
 	}
 
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		return nil, err
+	return nil
+}
+
+// uploadOptionProblems returns the problems found with the upload options, the field names are prefixed with prefix.
+func (b *S3Bucket) uploadOptionProblems(prefix string) []string {
+	var problems []string
+
+	if b.StorageClass != "" && !slices.Contains(S3StorageClasses, b.StorageClass) {
+		problems = append(problems, fmt.Sprintf("%sstorage_class %q is not one of %s",
+			prefix, b.StorageClass, strings.Join(S3StorageClasses, ", ")))
 	}
-	defer resp.Body.Close()
 
-	b, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+	if b.ACL != "" && !slices.Contains(S3ACLs, b.ACL) {
+		problems = append(problems, fmt.Sprintf("%sacl %q is not one of %s", prefix, b.ACL, strings.Join(S3ACLs, ", ")))
 	}
 
-	var models []Model
+	return append(problems, b.sseProblems(prefix)...)
+}
 
-	err = json.Unmarshal(b, &models)
+// sseProblems returns the problems found with the server-side encryption options, the field names are prefixed with prefix.
+func (b *S3Bucket) sseProblems(prefix string) []string {
+	switch b.SSEAlgorithm {
+	case "", S3SSEAlgorithmAES256:
+		if b.SSEKMSKeyID != "" {
+			return []string{prefix + "sse_kms_key_id requires the " + S3SSEAlgorithmKMS + " sse_algorithm"}
+		}
+	case S3SSEAlgorithmKMS:
+		if b.SSEKMSKeyID == "" {
+			return []string{prefix + "sse_kms_key_id is required with the " + S3SSEAlgorithmKMS + " sse_algorithm"}
+		}
+	default:
+		return []string{fmt.Sprintf("%ssse_algorithm %q is not one of %s",
+			prefix, b.SSEAlgorithm, strings.Join(S3SSEAlgorithms, ", "))}
+	}
+
+	return nil
+}
+
+// publishedChecksumHints is the order of preference of the checksum published to inventory,
+// when a record declares multiple checksums.
+var publishedChecksumHints = []string{"md5sum", "sha256", "sha512", "sha1", "blake3"}
+
+// checksumHint returns the checksum hint for the algorithm name used in the manifest.
+func checksumHint(algorithm string) string {
+	hint := strings.ToLower(algorithm)
+	if hint == "md5" {
+		return "md5sum"
+	}
+
+	return hint
+}
+
+// AllChecksums returns the record checksums keyed by checksum hint, with the legacy md5sum folded in.
+func (r *FirmwareRecord) AllChecksums() map[string]string {
+	checksums := make(map[string]string, len(r.Checksums)+1)
+
+	for algorithm, value := range r.Checksums {
+		if value != "" {
+			checksums[checksumHint(algorithm)] = value
+		}
+	}
+
+	if _, ok := checksums["md5sum"]; !ok && r.MD5Sum != "" {
+		checksums["md5sum"] = r.MD5Sum
+	}
+
+	if _, ok := checksums["sha256"]; !ok && r.sha256Sum() != "" {
+		checksums["sha256"] = r.sha256Sum()
+	}
+
+	return checksums
+}
+
+// sha256Sum returns the SHA256Sum without its hint, which is matched regardless of case.
+func (r *FirmwareRecord) sha256Sum() string {
+	if hint, value, found := strings.Cut(r.SHA256Sum, ":"); found && strings.EqualFold(hint, "sha256") {
+		return value
+	}
+
+	return r.SHA256Sum
+}
+
+// PublishedChecksum returns the record checksum published to inventory with its hash hint,
+// the sha256 field is preferred over the checksums. The record published with another of its checksums
+// is still matched in inventory, see inventory.WithChecksums.
+func (r *FirmwareRecord) PublishedChecksum() string {
+	if r.sha256Sum() != "" {
+		return "sha256:" + r.sha256Sum()
+	}
+
+	checksums := r.AllChecksums()
+
+	for _, hint := range publishedChecksumHints {
+		if value, ok := checksums[hint]; ok {
+			return hint + ":" + value
+		}
+	}
+
+	return "md5sum:" + r.MD5Sum
+}
+
+// FirmwareSizes maps firmware upstream URLs to the download size declared in the firmware manifest.
+type FirmwareSizes map[string]int64
+
+// FirmwareChecksums maps firmware upstream URLs to the checksums declared in the firmware manifest, keyed by checksum hint.
+type FirmwareChecksums map[string]map[string]string
+
+// ManifestDetails holds the firmware details declared in the manifest which aren't part of a ComponentFirmwareVersion.
+type ManifestDetails struct {
+	Sizes     FirmwareSizes
+	Checksums FirmwareChecksums
+	// BuildDates are the raw build_date of the firmware, see ParseBuildDate
+	BuildDates FirmwareBuildDates
+	// Latest are the firmware flagged latest, see FilterLatest
+	Latest FirmwareLatest
+	// VendorAliases maps the manifest manufacturers resolved through an alias to their vendor
+	VendorAliases map[string]string
+	// Overrides are the override manifests merged over the manifest, in order
+	Overrides []ManifestOverride
+}
+
+// addRecord adds the details the firmware record declares.
+func (d *ManifestDetails) addRecord(fw *FirmwareRecord) {
+	if fw.Size > 0 {
+		d.Sizes[fw.VendorURI] = fw.Size
+	}
+
+	if fw.Latest {
+		d.Latest[fw.VendorURI] = true
+	}
+
+	if fw.BuildDate != "" {
+		d.BuildDates[fw.VendorURI] = fw.BuildDate
+	}
+
+	if checksums := fw.AllChecksums(); len(checksums) > 0 {
+		d.Checksums[fw.VendorURI] = checksums
+	}
+}
+
+// LoadFirmwareManifest returns the firmware listed in the manifest by vendor,
+// along with the download sizes, checksums, build dates and latest flags the manifest declares.
+// The override manifests are merged over the manifest in order, see mergeManifest.
+func LoadFirmwareManifest(
+	ctx context.Context,
+	httpClient fleetdbapi.Doer,
+	manifestURL string,
+	overrideURLs ...string,
+) (map[string][]*fleetdbapi.ComponentFirmwareVersion, *ManifestDetails, error) {
+	models, overrides, err := fetchManifests(ctx, httpClient, manifestURL, overrideURLs)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	firmwaresByVendor := make(map[string][]*fleetdbapi.ComponentFirmwareVersion)
+	details := &ManifestDetails{
+		Sizes:         make(FirmwareSizes),
+		Checksums:     make(FirmwareChecksums),
+		BuildDates:    make(FirmwareBuildDates),
+		Latest:        make(FirmwareLatest),
+		VendorAliases: make(map[string]string),
+		Overrides:     overrides,
+	}
 
 	for _, m := range models {
+		vendor := NormalizeVendor(m.Manufacturer)
+		if vendor != strings.ToLower(strings.TrimSpace(m.Manufacturer)) {
+			details.VendorAliases[m.Manufacturer] = vendor
+		}
+
 		for component, firmwareRecords := range m.Components {
 			for _, fw := range firmwareRecords {
-				cModels := []string{strings.ToLower(m.Model)}
+				cModels := []string{NormalizeModel(m.Model)}
 				if fw.Model != "" {
-					cModels = append(cModels, strings.ToLower(fw.Model))
+					cModels = append(cModels, NormalizeModel(fw.Model))
 				}
 
+				details.addRecord(&fw)
+
 				tmpInstallInband := fw.InstallInband
 				tmpOEM := fw.Oem
-				firmwaresByVendor[m.Manufacturer] = append(firmwaresByVendor[m.Manufacturer],
+				firmwaresByVendor[vendor] = append(firmwaresByVendor[vendor],
 					&fleetdbapi.ComponentFirmwareVersion{
-						Vendor:      strings.ToLower(m.Manufacturer),
+						Vendor:      vendor,
 						Version:     fw.FirmwareVersion,
 						Model:       cModels,
-						Component:   strings.ToLower(component),
+						Component:   ComponentSlug(component),
 						UpstreamURL: fw.VendorURI,
 						Filename:    fw.Filename,
 						// publish checksum with hash hint
-						Checksum:      "md5sum:" + fw.MD5Sum,
+						Checksum:      fw.PublishedChecksum(),
 						InstallInband: &tmpInstallInband,
 						OEM:           &tmpOEM,
 					})
@@ -161,7 +759,7 @@ func LoadFirmwareManifest(ctx context.Context, manifestURL string) (map[string][
 		}
 	}
 
-	return firmwaresByVendor, nil
+	return firmwaresByVendor, details, nil
 }
 
 func ParseRepositoryURL(repositoryURL string) (endpoint, bucket string, err error) {
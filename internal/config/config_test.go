@@ -4,10 +4,13 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"golang.org/x/net/context"
+
+	"github.com/metal-toolbox/firmware-syncer/pkg/types"
 )
 
 func Test_LoadFirmwareManifest(t *testing.T) {
@@ -24,6 +27,7 @@ func Test_LoadFirmwareManifest(t *testing.T) {
 					"firmware_version": "4.00",
 					"vendor_uri": "https://downloadmirror.intel.com/738712/E810_NVMUpdatePackage_v4_00.zip",
 					"md5sum": "95cadf0842eb97cd29c3083362db0a35",
+					"size": 1228800,
 					"latest": true,
 					"prerequisite": false,
 					"note": "for in band update"
@@ -85,20 +89,31 @@ func Test_LoadFirmwareManifest(t *testing.T) {
 		vendor            string
 		expectedModels    []string
 		expectedComponent string
+		expectedSize      int64
 	}{
 		{
 			"dell-boss-s1",
 			dellR6415ModelData,
 			"dell",
 			[]string{"r6415", "boss-s1"},
-			"storagecontroller",
+			"storage-controller",
+			0,
 		},
 		{
 			"dell-hba355i",
 			dellR750ModelData,
 			"dell",
 			[]string{"r750", "hba355i"},
-			"storagecontroller",
+			"storage-controller",
+			0,
+		},
+		{
+			"dell-manufacturer-alias",
+			strings.Replace(dellR750ModelData, `"manufacturer": "dell"`, `"manufacturer": "Dell Inc."`, 1),
+			"dell",
+			[]string{"r750", "hba355i"},
+			"storage-controller",
+			0,
 		},
 		{
 			"intel-e810",
@@ -106,6 +121,7 @@ func Test_LoadFirmwareManifest(t *testing.T) {
 			"intel",
 			[]string{"e810"},
 			"nic",
+			1228800,
 		},
 	}
 
@@ -117,16 +133,296 @@ func Test_LoadFirmwareManifest(t *testing.T) {
 
 			defer ts.Close()
 
-			firmwaresByVendor, err := LoadFirmwareManifest(context.Background(), ts.URL)
+			firmwaresByVendor, details, err := LoadFirmwareManifest(context.Background(), http.DefaultClient, ts.URL)
 			if err != nil {
 				assert.EqualError(t, err, "Failed to load firmware manifest")
 				return
 			}
 
+			assert.NotEmpty(t, firmwaresByVendor[tc.vendor])
+
 			for _, cfv := range firmwaresByVendor[tc.vendor] {
+				assert.Equal(t, tc.vendor, cfv.Vendor)
 				assert.Equal(t, tc.expectedModels, cfv.Model)
 				assert.Equal(t, tc.expectedComponent, cfv.Component)
+				assert.Equal(t, tc.expectedSize, details.Sizes[cfv.UpstreamURL])
 			}
 		})
 	}
 }
+
+func validConfiguration() *Configuration {
+	return &Configuration{
+		InventoryKind:       types.InventoryStoreServerservice,
+		ArtifactsURL:        "https://example.com/artifacts",
+		FirmwareManifestURL: "https://example.com/modeldata.json",
+		Concurrency:         SyncerConcurrency,
+		FirmwareRepository: &S3Bucket{
+			Region:    "us-east-1",
+			Endpoint:  "https://s3.example.com",
+			Bucket:    "firmware",
+			AccessKey: "access",
+			SecretKey: "secret",
+		},
+		ServerserviceOptions: &ServerserviceOptions{
+			Endpoint:             "https://fleetdb.example.com",
+			OidcIssuerEndpoint:   "https://issuer.example.com",
+			OidcAudienceEndpoint: "https://fleetdb.example.com",
+			OidcClientID:         "client-id",
+			OidcClientSecret:     "client-secret",
+			OidcClientScopes:     []string{"read", "write"},
+		},
+	}
+}
+
+func TestConfigurationValidate(t *testing.T) {
+	testCases := []struct {
+		name           string
+		modify         func(c *Configuration)
+		expectedFields []string
+	}{
+		{
+			name:   "valid",
+			modify: func(*Configuration) {},
+		},
+		{
+			name: "missing manifest and artifacts URLs",
+			modify: func(c *Configuration) {
+				c.FirmwareManifestURL = ""
+				c.ArtifactsURL = ""
+			},
+			expectedFields: []string{"firmware_manifest_url", "artifacts_url"},
+		},
+		{
+			name:           "missing s3 repository",
+			modify:         func(c *Configuration) { c.FirmwareRepository = nil },
+			expectedFields: []string{"s3bucket"},
+		},
+		{
+			name: "s3 repository without endpoint and credentials",
+			modify: func(c *Configuration) {
+				c.FirmwareRepository.Endpoint = ""
+				c.FirmwareRepository.SecretKey = ""
+			},
+			expectedFields: []string{"s3bucket.endpoint", "s3bucket.secret_key"},
+		},
+		{
+			name: "s3 repository storage class and acl",
+			modify: func(c *Configuration) {
+				c.FirmwareRepository.StorageClass = "INTELLIGENT_TIERING"
+				c.FirmwareRepository.ACL = "private"
+			},
+		},
+		{
+			name: "invalid s3 repository storage class and acl",
+			modify: func(c *Configuration) {
+				c.FirmwareRepository.StorageClass = "intelligent-tiering"
+				c.FirmwareRepository.ACL = "everyone"
+			},
+			expectedFields: []string{"s3bucket.storage_class", "s3bucket.acl"},
+		},
+		{
+			name: "s3 repository kms encryption",
+			modify: func(c *Configuration) {
+				c.FirmwareRepository.SSEAlgorithm = "aws:kms"
+				c.FirmwareRepository.SSEKMSKeyID = "alias/firmware"
+			},
+		},
+		{
+			name:   "s3 repository aes256 encryption",
+			modify: func(c *Configuration) { c.FirmwareRepository.SSEAlgorithm = "AES256" },
+		},
+		{
+			name:           "s3 repository kms encryption without a key id",
+			modify:         func(c *Configuration) { c.FirmwareRepository.SSEAlgorithm = "aws:kms" },
+			expectedFields: []string{"s3bucket.sse_kms_key_id"},
+		},
+		{
+			name:           "s3 repository kms key id without kms encryption",
+			modify:         func(c *Configuration) { c.FirmwareRepository.SSEKMSKeyID = "alias/firmware" },
+			expectedFields: []string{"s3bucket.sse_kms_key_id"},
+		},
+		{
+			name:           "invalid s3 repository encryption",
+			modify:         func(c *Configuration) { c.FirmwareRepository.SSEAlgorithm = "rot13" },
+			expectedFields: []string{"s3bucket.sse_algorithm"},
+		},
+		{
+			name: "mirror repositories with a quorum",
+			modify: func(c *Configuration) {
+				c.MirrorRepositories = []*S3Bucket{
+					{Region: "eu-west-1", Endpoint: "https://s3.dr.example.com", Bucket: "firmware", AccessKey: "key", SecretKey: "secret"},
+				}
+				c.UploadQuorum = 1
+			},
+		},
+		{
+			name: "mirror repository without credentials",
+			modify: func(c *Configuration) {
+				c.MirrorRepositories = []*S3Bucket{
+					{Region: "eu-west-1", Endpoint: "https://s3.dr.example.com", Bucket: "firmware", ACL: "everyone"},
+					nil,
+				}
+			},
+			expectedFields: []string{
+				"mirror_s3buckets[0].access_key",
+				"mirror_s3buckets[0].secret_key",
+				"mirror_s3buckets[0].acl",
+				"mirror_s3buckets[1]",
+			},
+		},
+		{
+			name:           "upload quorum greater than the destinations",
+			modify:         func(c *Configuration) { c.UploadQuorum = 2 },
+			expectedFields: []string{"upload_quorum"},
+		},
+		{
+			name:           "invalid serverservice endpoint",
+			modify:         func(c *Configuration) { c.ServerserviceOptions.Endpoint = "not a url" },
+			expectedFields: []string{"serverservice.endpoint"},
+		},
+		{
+			name: "missing oidc parameters",
+			modify: func(c *Configuration) {
+				c.ServerserviceOptions.OidcClientSecret = ""
+				c.ServerserviceOptions.OidcClientScopes = nil
+			},
+			expectedFields: []string{"serverservice.oidc_client_secret", "serverservice.oidc_client_scopes"},
+		},
+		{
+			name: "oidc parameters not required with oauth disabled",
+			modify: func(c *Configuration) {
+				c.ServerserviceOptions = &ServerserviceOptions{Endpoint: "https://fleetdb.example.com", DisableOAuth: true}
+			},
+		},
+		{
+			name: "oidc parameters not required with a static token",
+			modify: func(c *Configuration) {
+				c.ServerserviceOptions = &ServerserviceOptions{Endpoint: "https://fleetdb.example.com", StaticToken: "token"}
+			},
+		},
+		{
+			name: "serverservice not required for yaml inventory",
+			modify: func(c *Configuration) {
+				c.InventoryKind = types.InventoryStoreYAML
+				c.ServerserviceOptions = nil
+			},
+		},
+		{
+			name: "adaptive concurrency bounds",
+			modify: func(c *Configuration) {
+				c.AdaptiveConcurrency = AdaptiveConcurrency{Min: 4, Max: 2}
+			},
+			expectedFields: []string{"adaptive_concurrency.min"},
+		},
+		{
+			name:           "concurrency not set",
+			modify:         func(c *Configuration) { c.Concurrency = 0 },
+			expectedFields: []string{"concurrency must be at least 1"},
+		},
+		{
+			name:           "dell signatures without trusted roots",
+			modify:         func(c *Configuration) { c.DellSignatures.Enabled = true },
+			expectedFields: []string{"dell_signatures.trusted_roots"},
+		},
+		{
+			name:           "work dir missing",
+			modify:         func(c *Configuration) { c.WorkDir = "/nonexistent/firmware-syncer" },
+			expectedFields: []string{"work_dir"},
+		},
+		{
+			name:           "unknown notifications outcome",
+			modify:         func(c *Configuration) { c.Notifications.On = "always" },
+			expectedFields: []string{"notifications.on"},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfiguration()
+			tt.modify(cfg)
+
+			err := cfg.Validate()
+			if len(tt.expectedFields) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.ErrorIs(t, err, ErrConfig)
+
+			for _, field := range tt.expectedFields {
+				assert.ErrorContains(t, err, field)
+			}
+		})
+	}
+}
+
+func Test_FirmwareRecordChecksums(t *testing.T) {
+	cases := []struct {
+		name              string
+		record            FirmwareRecord
+		expectedChecksums map[string]string
+		expectedPublished string
+	}{
+		{
+			"legacy md5sum",
+			FirmwareRecord{MD5Sum: "95cadf0842eb97cd29c3083362db0a35"},
+			map[string]string{"md5sum": "95cadf0842eb97cd29c3083362db0a35"},
+			"md5sum:95cadf0842eb97cd29c3083362db0a35",
+		},
+		{
+			"md5sum folded into checksums",
+			FirmwareRecord{
+				MD5Sum:    "95cadf0842eb97cd29c3083362db0a35",
+				Checksums: map[string]string{"SHA256": "ab36ec58c25098015b911bed7448b7d2506068a7362c28993fd1182e59710dae"},
+			},
+			map[string]string{
+				"md5sum": "95cadf0842eb97cd29c3083362db0a35",
+				"sha256": "ab36ec58c25098015b911bed7448b7d2506068a7362c28993fd1182e59710dae",
+			},
+			"md5sum:95cadf0842eb97cd29c3083362db0a35",
+		},
+		{
+			"checksums only",
+			FirmwareRecord{
+				Checksums: map[string]string{
+					"sha256": "ab36ec58c25098015b911bed7448b7d2506068a7362c28993fd1182e59710dae",
+					"md5":    "",
+				},
+			},
+			map[string]string{"sha256": "ab36ec58c25098015b911bed7448b7d2506068a7362c28993fd1182e59710dae"},
+			"sha256:ab36ec58c25098015b911bed7448b7d2506068a7362c28993fd1182e59710dae",
+		},
+		{
+			"sha256 preferred over md5sum",
+			FirmwareRecord{
+				MD5Sum:    "95cadf0842eb97cd29c3083362db0a35",
+				SHA256Sum: "ab36ec58c25098015b911bed7448b7d2506068a7362c28993fd1182e59710dae",
+			},
+			map[string]string{
+				"md5sum": "95cadf0842eb97cd29c3083362db0a35",
+				"sha256": "ab36ec58c25098015b911bed7448b7d2506068a7362c28993fd1182e59710dae",
+			},
+			"sha256:ab36ec58c25098015b911bed7448b7d2506068a7362c28993fd1182e59710dae",
+		},
+		{
+			"hinted sha256",
+			FirmwareRecord{SHA256Sum: "sha256:ab36ec58c25098015b911bed7448b7d2506068a7362c28993fd1182e59710dae"},
+			map[string]string{"sha256": "ab36ec58c25098015b911bed7448b7d2506068a7362c28993fd1182e59710dae"},
+			"sha256:ab36ec58c25098015b911bed7448b7d2506068a7362c28993fd1182e59710dae",
+		},
+		{
+			"uppercase hinted sha256",
+			FirmwareRecord{SHA256Sum: "SHA256:ab36ec58c25098015b911bed7448b7d2506068a7362c28993fd1182e59710dae"},
+			map[string]string{"sha256": "ab36ec58c25098015b911bed7448b7d2506068a7362c28993fd1182e59710dae"},
+			"sha256:ab36ec58c25098015b911bed7448b7d2506068a7362c28993fd1182e59710dae",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expectedChecksums, tc.record.AllChecksums())
+			assert.Equal(t, tc.expectedPublished, tc.record.PublishedChecksum())
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package config
+
+import (
+	"path"
+	"strings"
+
+	fleetdbapi "github.com/metal-toolbox/fleetdb/pkg/api/v1"
+)
+
+// FirmwareLatest holds the upstream URLs of the firmware flagged latest in the firmware manifest.
+type FirmwareLatest map[string]bool
+
+// FilterLatest returns the firmware flagged latest,
+// along with the components more than one firmware claims to be the latest of, keyed by vendor/models/component.
+// Every firmware claiming to be the latest is kept.
+func FilterLatest(
+	firmwares []*fleetdbapi.ComponentFirmwareVersion,
+	latest FirmwareLatest,
+) (filtered []*fleetdbapi.ComponentFirmwareVersion, conflicts map[string][]*fleetdbapi.ComponentFirmwareVersion) {
+	byComponent := make(map[string][]*fleetdbapi.ComponentFirmwareVersion)
+
+	for _, fw := range firmwares {
+		if !latest[fw.UpstreamURL] {
+			continue
+		}
+
+		filtered = append(filtered, fw)
+
+		key := path.Join(fw.Vendor, strings.Join(fw.Model, ","), fw.Component)
+		byComponent[key] = append(byComponent[key], fw)
+	}
+
+	conflicts = make(map[string][]*fleetdbapi.ComponentFirmwareVersion)
+
+	for key, fws := range byComponent {
+		if len(fws) > 1 {
+			conflicts[key] = fws
+		}
+	}
+
+	return filtered, conflicts
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterLatest(t *testing.T) {
+	modelData := `
+[
+	{
+		"model": "X11DPH-T",
+		"manufacturer": "supermicro",
+		"firmware": {
+			"BMC": [
+				{
+					"filename": "BMC_X11AST2500-4101MS_20221005_01.74.11_STDsp.zip",
+					"firmware_version": "1.74.11",
+					"vendor_uri": "https://example.com/bmc-1.74.11.zip",
+					"md5sum": "67e1ea4d9a3c2b5a6e3b4f7a4d0a5b11",
+					"latest": true
+				},
+				{
+					"filename": "BMC_X11AST2500-4101MS_20210803_01.73.12_STDsp.zip",
+					"firmware_version": "1.73.12",
+					"vendor_uri": "https://example.com/bmc-1.73.12.zip",
+					"md5sum": "0c1ba0a0d5e3f4b1a7c69e5d5d4b2f22",
+					"latest": false
+				}
+			],
+			"BIOS": [
+				{
+					"filename": "BIOS_X11DPH-0981_20220302_3.7_STDsp.zip",
+					"firmware_version": "3.7",
+					"vendor_uri": "https://example.com/bios-3.7.zip",
+					"md5sum": "4f5c8b2e6d1a3c7b9e0f2a4d6c8b0e33",
+					"latest": true
+				},
+				{
+					"filename": "BIOS_X11DPH-0981_20230112_3.8a_STDsp.zip",
+					"firmware_version": "3.8a",
+					"vendor_uri": "https://example.com/bios-3.8a.zip",
+					"md5sum": "9a7b5c3d1e2f4a6b8c0d2e4f6a8b0c44",
+					"latest": true
+				}
+			],
+			"NIC": [
+				{
+					"filename": "NIC_1.0.zip",
+					"firmware_version": "1.0",
+					"vendor_uri": "https://example.com/nic-1.0.zip",
+					"md5sum": "1b3d5f7a9c0e2a4c6e8a0c2e4a6c8e55"
+				}
+			]
+		}
+	}
+]
+`
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(modelData))
+	}))
+	defer ts.Close()
+
+	firmwaresByVendor, details, err := LoadFirmwareManifest(context.Background(), http.DefaultClient, ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	firmwares := firmwaresByVendor["supermicro"]
+	assert.Len(t, firmwares, 5)
+
+	filtered, conflicts := FilterLatest(firmwares, details.Latest)
+
+	versions := make([]string, 0, len(filtered))
+	for _, fw := range filtered {
+		versions = append(versions, fw.Component+"-"+fw.Version)
+	}
+
+	// the NIC has no firmware flagged latest, both BIOS versions claim to be the latest
+	assert.ElementsMatch(t, []string{"bmc-1.74.11", "bios-3.7", "bios-3.8a"}, versions)
+
+	if assert.Len(t, conflicts, 1) {
+		assert.Len(t, conflicts["supermicro/x11dph-t/bios"], 2)
+	}
+}
@@ -0,0 +1,236 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	fleetdbapi "github.com/metal-toolbox/fleetdb/pkg/api/v1"
+)
+
+// fetchManifest returns the models listed in the manifest at manifestURL,
+// a manifestURL without an http(s) scheme is read from the local filesystem.
+func fetchManifest(ctx context.Context, httpClient fleetdbapi.Doer, manifestURL string) ([]Model, error) {
+	b, err := readManifest(ctx, httpClient, manifestURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var models []Model
+
+	if err = json.Unmarshal(b, &models); err != nil {
+		return nil, err
+	}
+
+	return models, nil
+}
+
+// ManifestOverride is the outcome of merging an override manifest over the base manifest, see mergeManifest.
+type ManifestOverride struct {
+	URL string
+	// Replaced is the number of firmware of the override listed in the manifests it's merged over
+	Replaced int
+	// Added is the number of firmware the override lists in addition to the manifests it's merged over
+	Added int
+}
+
+// fetchManifests returns the models listed in the manifest at manifestURL,
+// with the override manifests at overrideURLs merged over it in order.
+func fetchManifests(
+	ctx context.Context,
+	httpClient fleetdbapi.Doer,
+	manifestURL string,
+	overrideURLs []string,
+) ([]Model, []ManifestOverride, error) {
+	models, err := fetchManifest(ctx, httpClient, manifestURL)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	overrides := make([]ManifestOverride, 0, len(overrideURLs))
+
+	for _, overrideURL := range overrideURLs {
+		overrideModels, err := fetchManifest(ctx, httpClient, overrideURL)
+		if err != nil {
+			return nil, nil, fmt.Errorf("manifest override %s: %w", overrideURL, err)
+		}
+
+		var override ManifestOverride
+
+		models, override = mergeManifest(models, overrideModels)
+		override.URL = overrideURL
+		overrides = append(overrides, override)
+	}
+
+	return models, overrides, nil
+}
+
+// mergeManifest merges the override models over the base models, firmware is matched by vendor and filename.
+//
+// The override records replace every base record of the firmware, wherever the base lists it, so an override
+// fully describes the firmware under its own models and components. The override records of firmware
+// the base doesn't list are added. The other base records are left as is.
+func mergeManifest(base, override []Model) ([]Model, ManifestOverride) {
+	overridden := make(map[string]bool)
+
+	for i := range override {
+		for _, records := range override[i].Components {
+			for j := range records {
+				overridden[manifestKey(override[i].Manufacturer, &records[j])] = false
+			}
+		}
+	}
+
+	merged := make([]Model, 0, len(base)+len(override))
+
+	for i := range base {
+		m := base[i]
+		m.Components = make(map[string][]FirmwareRecord, len(base[i].Components))
+
+		for component, records := range base[i].Components {
+			kept := make([]FirmwareRecord, 0, len(records))
+
+			for j := range records {
+				key := manifestKey(m.Manufacturer, &records[j])
+				if _, ok := overridden[key]; ok {
+					overridden[key] = true
+					continue
+				}
+
+				kept = append(kept, records[j])
+			}
+
+			if len(kept) > 0 {
+				m.Components[component] = kept
+			}
+		}
+
+		merged = append(merged, m)
+	}
+
+	var result ManifestOverride
+
+	for _, replaced := range overridden {
+		if replaced {
+			result.Replaced++
+		} else {
+			result.Added++
+		}
+	}
+
+	return append(merged, override...), result
+}
+
+// manifestKey identifies the firmware of a record across manifests.
+func manifestKey(manufacturer string, record *FirmwareRecord) string {
+	return NormalizeVendor(manufacturer) + "/" + record.Filename
+}
+
+func readManifest(ctx context.Context, httpClient fleetdbapi.Doer, manifestURL string) ([]byte, error) {
+	if u, err := url.Parse(manifestURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return os.ReadFile(strings.TrimPrefix(manifestURL, "file://"))
+	}
+
+	req, err := http.NewRequestWithContext(
+		ctx,
+		"GET",
+		manifestURL,
+		http.NoBody,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return io.ReadAll(resp.Body)
+}
+
+// ValidateFirmwareManifest returns the problems found with the manifest at manifestURL,
+// an error is returned when the manifest can't be fetched or isn't a valid modeldata.json.
+func ValidateFirmwareManifest(ctx context.Context, httpClient fleetdbapi.Doer, manifestURL string) ([]string, error) {
+	models, err := fetchManifest(ctx, httpClient, manifestURL)
+	if err != nil {
+		return nil, err
+	}
+
+	problems := []string{}
+
+	// the same firmware may be listed under multiple models, but always with the same checksum
+	checksums := make(map[string]string)
+
+	for i := range models {
+		m := &models[i]
+
+		if m.Model == "" || m.Manufacturer == "" {
+			problems = append(problems, fmt.Sprintf("model %d: model and manufacturer are required", i))
+		}
+
+		components := make([]string, 0, len(m.Components))
+		for component := range m.Components {
+			components = append(components, component)
+		}
+
+		sort.Strings(components)
+
+		for _, component := range components {
+			records := m.Components[component]
+			for j := range records {
+				prefix := fmt.Sprintf("%s %s %s[%d]: ", m.Manufacturer, m.Model, component, j)
+
+				for _, problem := range records[j].validate(checksums) {
+					problems = append(problems, prefix+problem)
+				}
+			}
+		}
+	}
+
+	return problems, nil
+}
+
+// validate returns the problems found with the record,
+// checksums holds the checksum of the records already validated by vendor URI.
+func (r *FirmwareRecord) validate(checksums map[string]string) []string {
+	var problems []string
+
+	if r.Filename == "" {
+		problems = append(problems, "filename is required")
+	}
+
+	if r.FirmwareVersion == "" {
+		problems = append(problems, "firmware_version is required")
+	}
+
+	if u, err := url.ParseRequestURI(r.VendorURI); err != nil || u.Host == "" {
+		problems = append(problems, "vendor_uri is invalid: '"+r.VendorURI+"'")
+	}
+
+	if len(r.AllChecksums()) == 0 {
+		problems = append(problems, "a checksum is required")
+	}
+
+	if r.BuildDate != "" {
+		if _, err := ParseBuildDate(r.BuildDate); err != nil {
+			problems = append(problems, "build_date is invalid: "+err.Error())
+		}
+	}
+
+	checksum := r.PublishedChecksum()
+	if previous, ok := checksums[r.VendorURI]; ok && previous != checksum {
+		problems = append(problems, "vendor_uri is listed with another checksum: "+previous)
+	}
+
+	checksums[r.VendorURI] = checksum
+
+	return problems
+}
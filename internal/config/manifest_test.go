@@ -0,0 +1,209 @@
+package config
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateFirmwareManifest(t *testing.T) {
+	modelData := `
+[
+	{
+		"model": "R750",
+		"manufacturer": "dell",
+		"firmware": {
+			"BIOS": [
+				{
+					"build_date": "11/09/2022",
+					"filename": "BIOS_1.8.2.EXE",
+					"firmware_version": "1.8.2",
+					"vendor_uri": "https://dl.dell.com/BIOS_1.8.2.EXE",
+					"md5sum": "b9f12aeec12b00ad5aea6e3b0fef7feb"
+				},
+				{
+					"build_date": "Q4 2022",
+					"firmware_version": "1.9.0",
+					"vendor_uri": "dl.dell.com/BIOS_1.9.0.EXE"
+				}
+			]
+		}
+	},
+	{
+		"model": "R6515",
+		"manufacturer": "dell",
+		"firmware": {
+			"BIOS": [
+				{
+					"filename": "BIOS_1.8.2.EXE",
+					"firmware_version": "1.8.2",
+					"vendor_uri": "https://dl.dell.com/BIOS_1.8.2.EXE",
+					"md5sum": "0c1ba0a0d5e3f4b1a7c69e5d5d4b2f22"
+				}
+			]
+		}
+	}
+]
+`
+	manifestPath := filepath.Join(t.TempDir(), "modeldata.json")
+	if err := os.WriteFile(manifestPath, []byte(modelData), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	problems, err := ValidateFirmwareManifest(context.Background(), http.DefaultClient, manifestPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []string{
+		"dell R750 BIOS[1]: filename is required",
+		"dell R750 BIOS[1]: vendor_uri is invalid: 'dl.dell.com/BIOS_1.9.0.EXE'",
+		"dell R750 BIOS[1]: a checksum is required",
+		"dell R750 BIOS[1]: build_date is invalid: 'Q4 2022': unrecognized build date",
+		"dell R6515 BIOS[0]: vendor_uri is listed with another checksum: md5sum:b9f12aeec12b00ad5aea6e3b0fef7feb",
+	}, problems)
+
+	_, err = ValidateFirmwareManifest(context.Background(), http.DefaultClient, filepath.Join(t.TempDir(), "missing.json"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestLoadFirmwareManifestOverrides(t *testing.T) {
+	base := `
+[
+	{
+		"model": "R750",
+		"manufacturer": "dell",
+		"firmware": {
+			"BIOS": [
+				{
+					"filename": "BIOS_1.8.2.EXE",
+					"firmware_version": "1.8.2",
+					"vendor_uri": "https://dl.dell.com/BIOS_1.8.2.EXE",
+					"md5sum": "b9f12aeec12b00ad5aea6e3b0fef7feb"
+				}
+			]
+		}
+	},
+	{
+		"model": "R6515",
+		"manufacturer": "dell",
+		"firmware": {
+			"BIOS": [
+				{
+					"filename": "BIOS_1.8.2.EXE",
+					"firmware_version": "1.8.2",
+					"vendor_uri": "https://dl.dell.com/BIOS_1.8.2.EXE",
+					"md5sum": "b9f12aeec12b00ad5aea6e3b0fef7feb"
+				}
+			]
+		}
+	},
+	{
+		"model": "X12STH",
+		"manufacturer": "supermicro",
+		"firmware": {
+			"BMC": [
+				{
+					"filename": "BMC_X12.bin",
+					"firmware_version": "1.0",
+					"vendor_uri": "https://www.supermicro.com/BMC_X12.bin",
+					"md5sum": "0c1ba0a0d5e3f4b1a7c69e5d5d4b2f22"
+				}
+			]
+		}
+	}
+]
+`
+	// the dell BIOS is served from a mirror for the R750 only, and a newer BIOS is added
+	mirrorOverride := `
+[
+	{
+		"model": "R750",
+		"manufacturer": "Dell Inc.",
+		"firmware": {
+			"BIOS": [
+				{
+					"filename": "BIOS_1.8.2.EXE",
+					"firmware_version": "1.8.2",
+					"vendor_uri": "https://mirror.example.com/BIOS_1.8.2.EXE",
+					"md5sum": "b9f12aeec12b00ad5aea6e3b0fef7feb"
+				},
+				{
+					"filename": "BIOS_1.9.0.EXE",
+					"firmware_version": "1.9.0",
+					"vendor_uri": "https://mirror.example.com/BIOS_1.9.0.EXE",
+					"md5sum": "3a4ff6c0b2a2f13d6a1c1d5d4b2f2e11",
+					"size": 1024
+				}
+			]
+		}
+	}
+]
+`
+	// the later override replaces the dell BIOS 1.9.0 of the earlier one
+	pinOverride := `
+[
+	{
+		"model": "R750",
+		"manufacturer": "dell",
+		"firmware": {
+			"BIOS": [
+				{
+					"filename": "BIOS_1.9.0.EXE",
+					"firmware_version": "1.9.0",
+					"vendor_uri": "https://pinned.example.com/BIOS_1.9.0.EXE",
+					"md5sum": "3a4ff6c0b2a2f13d6a1c1d5d4b2f2e11"
+				}
+			]
+		}
+	}
+]
+`
+	tmpDir := t.TempDir()
+	manifests := map[string]string{"base.json": base, "mirror.json": mirrorOverride, "pin.json": pinOverride}
+
+	for name, content := range manifests {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mirrorPath, pinPath := filepath.Join(tmpDir, "mirror.json"), filepath.Join(tmpDir, "pin.json")
+
+	firmwaresByVendor, details, err := LoadFirmwareManifest(
+		context.Background(),
+		http.DefaultClient,
+		filepath.Join(tmpDir, "base.json"),
+		mirrorPath,
+		pinPath,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []ManifestOverride{
+		{URL: mirrorPath, Replaced: 1, Added: 1},
+		{URL: pinPath, Replaced: 1},
+	}, details.Overrides)
+
+	dellURLs := map[string][]string{}
+	for _, firmware := range firmwaresByVendor["dell"] {
+		dellURLs[firmware.Filename] = append(dellURLs[firmware.Filename], firmware.UpstreamURL)
+	}
+
+	assert.Equal(t, map[string][]string{
+		"BIOS_1.8.2.EXE": {"https://mirror.example.com/BIOS_1.8.2.EXE"},
+		"BIOS_1.9.0.EXE": {"https://pinned.example.com/BIOS_1.9.0.EXE"},
+	}, dellURLs)
+
+	assert.Len(t, firmwaresByVendor["supermicro"], 1, "the firmware without override is left as is")
+	assert.NotContains(t, details.Checksums, "https://dl.dell.com/BIOS_1.8.2.EXE")
+	assert.NotContains(t, details.Sizes, "https://mirror.example.com/BIOS_1.9.0.EXE", "the replaced record details are dropped")
+
+	_, _, err = LoadFirmwareManifest(context.Background(), http.DefaultClient, filepath.Join(tmpDir, "base.json"), filepath.Join(tmpDir, "missing.json"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	fleetdbapi "github.com/metal-toolbox/fleetdb/pkg/api/v1"
+)
+
+// FilterModels returns the firmware of the allowed models which isn't for any denied model,
+// models are compared once normalized, see NormalizeModel.
+//
+// A firmware passes the allowlist when any of its models is allowed, every firmware passes an empty allowlist.
+// The denylist takes precedence, a firmware for any denied model is excluded even when another of its models is allowed.
+func FilterModels(
+	firmwares []*fleetdbapi.ComponentFirmwareVersion,
+	allowlist, denylist []string,
+) []*fleetdbapi.ComponentFirmwareVersion {
+	if len(allowlist) == 0 && len(denylist) == 0 {
+		return firmwares
+	}
+
+	allowed := normalizedModels(allowlist)
+	denied := normalizedModels(denylist)
+
+	var filtered []*fleetdbapi.ComponentFirmwareVersion
+
+	for _, fw := range firmwares {
+		if anyModel(fw, denied) {
+			continue
+		}
+
+		if len(allowed) > 0 && !anyModel(fw, allowed) {
+			continue
+		}
+
+		filtered = append(filtered, fw)
+	}
+
+	return filtered
+}
+
+func normalizedModels(models []string) map[string]bool {
+	normalized := make(map[string]bool, len(models))
+	for _, model := range models {
+		normalized[NormalizeModel(model)] = true
+	}
+
+	return normalized
+}
+
+// anyModel returns true when any of the firmware models is in models.
+func anyModel(fw *fleetdbapi.ComponentFirmwareVersion, models map[string]bool) bool {
+	for _, model := range fw.Model {
+		if models[NormalizeModel(model)] {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"testing"
+
+	fleetdbapi "github.com/metal-toolbox/fleetdb/pkg/api/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterModels(t *testing.T) {
+	firmwares := []*fleetdbapi.ComponentFirmwareVersion{
+		{Filename: "r750-bios.bin", Model: []string{"r750"}},
+		{Filename: "r650-bios.bin", Model: []string{"r650"}},
+		{Filename: "r6515-bios.bin", Model: []string{"r6515", "r7515"}},
+		{Filename: "shared-nic.bin", Model: []string{"r750", "r650"}},
+	}
+
+	testCases := []struct {
+		name      string
+		allowlist []string
+		denylist  []string
+		expected  []string
+	}{
+		{
+			name:     "no filter",
+			expected: []string{"r750-bios.bin", "r650-bios.bin", "r6515-bios.bin", "shared-nic.bin"},
+		},
+		{
+			name:      "allow only",
+			allowlist: []string{" R750 "},
+			expected:  []string{"r750-bios.bin", "shared-nic.bin"},
+		},
+		{
+			name:      "allow any of the firmware models",
+			allowlist: []string{"r7515"},
+			expected:  []string{"r6515-bios.bin"},
+		},
+		{
+			name:     "deny only",
+			denylist: []string{"r650"},
+			expected: []string{"r750-bios.bin", "r6515-bios.bin"},
+		},
+		{
+			name:      "deny wins over allow",
+			allowlist: []string{"r750", "r6515"},
+			denylist:  []string{"r650"},
+			expected:  []string{"r750-bios.bin", "r6515-bios.bin"},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			var filenames []string
+			for _, fw := range FilterModels(firmwares, tt.allowlist, tt.denylist) {
+				filenames = append(filenames, fw.Filename)
+			}
+
+			assert.Equal(t, tt.expected, filenames)
+		})
+	}
+}
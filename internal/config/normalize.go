@@ -0,0 +1,102 @@
+package config
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/bmc-toolbox/common"
+)
+
+var (
+	vendorAliasesMutex sync.RWMutex
+	// vendorAliases maps the other names vendors are listed under in manifests to the vendor name used by the syncer
+	vendorAliases = map[string]string{
+		"smc":                          common.VendorSupermicro,
+		"super micro":                  common.VendorSupermicro,
+		"super micro computer":         common.VendorSupermicro,
+		"dell inc.":                    common.VendorDell,
+		"dell emc":                     common.VendorDell,
+		"asrock rack":                  common.VendorAsrockrack,
+		"hpe":                          common.VendorHPE,
+		"hewlett packard enterprise":   common.VendorHPE,
+		"intel corporation":            common.VendorIntel,
+		"advanced micro devices":       common.VendorAMD,
+		"advanced micro devices, inc.": common.VendorAMD,
+		"broadcom inc.":                common.VendorBroadcom,
+		// NICs from vendors AMD acquired are published under AMD
+		"pensando": common.VendorAMD,
+		"xilinx":   common.VendorAMD,
+		// LSI storage controllers are published by Broadcom since the Avago acquisition
+		"lsi":       common.VendorBroadcom,
+		"lsi logic": common.VendorBroadcom,
+		"avago":     common.VendorBroadcom,
+		// NVIDIA isn't aliased to Mellanox, it lists its GPUs as well as the Mellanox NICs,
+		// the vendor_aliases configuration maps it when a manifest only lists NICs under NVIDIA
+	}
+)
+
+// RegisterVendorAlias makes NormalizeVendor resolve the alias to the given vendor,
+// replacing any built-in alias with the same name.
+func RegisterVendorAlias(alias, vendor string) {
+	vendorAliasesMutex.Lock()
+	defer vendorAliasesMutex.Unlock()
+
+	vendorAliases[strings.ToLower(strings.TrimSpace(alias))] = strings.ToLower(strings.TrimSpace(vendor))
+}
+
+// NormalizeVendor returns the vendor name used across the syncer for the given vendor,
+// the vendor selects the downloader and is part of the firmware destination path.
+func NormalizeVendor(vendor string) string {
+	v := strings.ToLower(strings.TrimSpace(vendor))
+
+	vendorAliasesMutex.RLock()
+	defer vendorAliasesMutex.RUnlock()
+
+	if alias, ok := vendorAliases[v]; ok {
+		return alias
+	}
+
+	return v
+}
+
+// NormalizeModel returns the model name used across the syncer for the given hardware or component model.
+func NormalizeModel(model string) string {
+	return strings.ToLower(strings.TrimSpace(model))
+}
+
+// componentSlugs maps the component names used in manifests, lowercased and with any space, dash or underscore removed,
+// to the component slugs used across the metal-toolbox services.
+var componentSlugs = map[string]string{
+	"bios":               "bios",
+	"bmc":                "bmc",
+	"cpld":               "cpld",
+	"cpu":                "cpu",
+	"gpu":                "gpu",
+	"tpm":                "tpm",
+	"chassis":            "chassis",
+	"enclosure":          "enclosure",
+	"mainboard":          "mainboard",
+	"physicalmemory":     "physical-memory",
+	"backplaneexpander":  "backplane-expander",
+	"powersupply":        "power-supply",
+	"powersupplies":      "power-supply",
+	"psu":                "power-supply",
+	"drive":              "drive",
+	"drives":             "drive",
+	"nic":                "nic",
+	"nics":               "nic",
+	"storagecontroller":  "storage-controller",
+	"storagecontrollers": "storage-controller",
+}
+
+// ComponentSlug returns the component slug for the component name listed in a manifest,
+// as storage-controller for StorageController, names without a known slug are lowercased.
+func ComponentSlug(component string) string {
+	c := strings.ToLower(strings.TrimSpace(component))
+
+	if slug, ok := componentSlugs[strings.NewReplacer(" ", "", "-", "", "_", "").Replace(c)]; ok {
+		return slug
+	}
+
+	return c
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeVendor(t *testing.T) {
+	cases := []struct {
+		vendor   string
+		expected string
+	}{
+		{"dell", "dell"},
+		{"Dell", "dell"},
+		{" DELL ", "dell"},
+		{"Dell Inc.", "dell"},
+		{"SMC", "supermicro"},
+		{"Supermicro", "supermicro"},
+		{"ASRock Rack", "asrockrack"},
+		{"HPE", "hp"},
+		{"Pensando", "amd"},
+		{"LSI", "broadcom"},
+		{"Broadcom Inc.", "broadcom"},
+		{"equinix", "equinix"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.vendor, func(t *testing.T) {
+			assert.Equal(t, tc.expected, NormalizeVendor(tc.vendor))
+		})
+	}
+}
+
+func TestNormalizeModel(t *testing.T) {
+	assert.Equal(t, "r750", NormalizeModel(" R750 "))
+	assert.Equal(t, "x11dph-t", NormalizeModel("X11DPH-T"))
+}
+
+func TestRegisterVendorAlias(t *testing.T) {
+	assert.Equal(t, "acme ltd", NormalizeVendor("ACME Ltd"))
+
+	RegisterVendorAlias(" ACME Ltd ", "Supermicro")
+	t.Cleanup(func() {
+		vendorAliasesMutex.Lock()
+		defer vendorAliasesMutex.Unlock()
+
+		delete(vendorAliases, "acme ltd")
+	})
+
+	assert.Equal(t, "supermicro", NormalizeVendor("ACME Ltd"))
+}
+
+func TestComponentSlug(t *testing.T) {
+	cases := []struct {
+		component string
+		expected  string
+	}{
+		{"BMC", "bmc"},
+		{"BIOS", "bios"},
+		{"NIC", "nic"},
+		{"NICs", "nic"},
+		{"StorageController", "storage-controller"},
+		{"storage_controller", "storage-controller"},
+		{"Storage Controller", "storage-controller"},
+		{"storage-controller", "storage-controller"},
+		{"Backplane-Expander", "backplane-expander"},
+		{"PhysicalMemory", "physical-memory"},
+		{"Power-Supply", "power-supply"},
+		{"PSU", "power-supply"},
+		{" Drive ", "drive"},
+		{"Retimer", "retimer"},
+		{"Some Widget", "some widget"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.component, func(t *testing.T) {
+			assert.Equal(t, tc.expected, ComponentSlug(tc.component))
+		})
+	}
+}
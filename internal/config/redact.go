@@ -0,0 +1,84 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RedactedValue replaces the secret values in logs and notifications, as url.URL.Redacted does for passwords
+const RedactedValue = "xxxxx"
+
+// Redacted returns the configuration keyed by the mapstructure names of its fields, as in the configuration file.
+// The values of the fields tagged secret:"true" are redacted when set, the values nested in them included,
+// the keys of secret maps are kept.
+func (c *Configuration) Redacted() map[string]any {
+	fields, _ := redactValue(reflect.ValueOf(c), false).(map[string]any)
+
+	return fields
+}
+
+// LogRedacted logs the configuration with its secrets redacted, see Redacted.
+func (c *Configuration) LogRedacted(logger *logrus.Logger) {
+	logger.WithField("config", c.Redacted()).Info("Effective configuration")
+}
+
+// redactValue returns the value with the secrets redacted, structs are returned as maps
+// keyed by the mapstructure names of their fields, the fields without one are left out.
+func redactValue(v reflect.Value, secret bool) any {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+
+		return redactValue(v.Elem(), secret)
+	case reflect.Struct:
+		fields := make(map[string]any)
+
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+
+			name, _, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+			if name == "" || !field.IsExported() {
+				continue
+			}
+
+			fields[name] = redactValue(v.Field(i), secret || field.Tag.Get("secret") == "true")
+		}
+
+		return fields
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+
+		entries := make(map[string]any, v.Len())
+
+		iter := v.MapRange()
+		for iter.Next() {
+			entries[fmt.Sprint(iter.Key().Interface())] = redactValue(iter.Value(), secret)
+		}
+
+		return entries
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+
+		items := make([]any, v.Len())
+		for i := range items {
+			items[i] = redactValue(v.Index(i), secret)
+		}
+
+		return items
+	default:
+		if secret && !v.IsZero() {
+			return RedactedValue
+		}
+
+		return v.Interface()
+	}
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogRedacted(t *testing.T) {
+	c := &Configuration{
+		LogLevel:            "debug",
+		FirmwareManifestURL: "https://example.com/modeldata.json",
+		GithubOpenBmcToken:  "github-sekrit",
+		ServerserviceOptions: &ServerserviceOptions{
+			Endpoint:         "https://fleetdb.example.com",
+			OidcClientID:     "firmware-syncer",
+			OidcClientSecret: "oidc-sekrit",
+			OidcClientScopes: []string{"read:server", "write:server"},
+		},
+		FirmwareRepository: &S3Bucket{Bucket: "firmware", AccessKey: "access-sekrit", SecretKey: "s3-sekrit"},
+		MirrorRepositories: []*S3Bucket{{Bucket: "mirror", SecretKey: "mirror-sekrit"}},
+		SourceHeaders:      map[string]map[string]string{"downloads.example.com": {"Authorization": "Bearer header-sekrit"}},
+		FTPCredentials:     map[string]FTPCredential{"ftp.example.com": {User: "firmware", Password: "ftp-sekrit"}},
+		Notifications:      Notifications{WebhookURL: "https://hooks.example.com/webhook-sekrit", On: NotifyOnFailure},
+	}
+
+	redactedConfig := c.Redacted()
+
+	assert.Equal(t, "debug", redactedConfig["log_level"])
+	assert.Equal(t, "https://example.com/modeldata.json", redactedConfig["firmware_manifest_url"])
+	assert.Equal(t, RedactedValue, redactedConfig["github_openbmc_token"])
+	assert.Nil(t, redactedConfig["asrr_s3bucket"])
+
+	serverservice, _ := redactedConfig["serverservice"].(map[string]any)
+	assert.Equal(t, "firmware-syncer", serverservice["oidc_client_id"])
+	assert.Equal(t, RedactedValue, serverservice["oidc_client_secret"])
+	assert.Equal(t, "", serverservice["static_token"], "unset secrets are left empty")
+	assert.NotContains(t, serverservice, "EndpointURL")
+
+	headers, _ := redactedConfig["source_headers"].(map[string]any)
+	assert.Equal(t, map[string]any{"Authorization": RedactedValue}, headers["downloads.example.com"])
+
+	var out bytes.Buffer
+
+	logger := logrus.New()
+	logger.SetOutput(&out)
+	logger.SetFormatter(&logrus.JSONFormatter{})
+
+	c.LogRedacted(logger)
+
+	assert.NotContains(t, out.String(), "sekrit")
+
+	for _, value := range []string{"fleetdb.example.com", "read:server", "mirror", "ftp.example.com", "firmware", NotifyOnFailure} {
+		assert.Contains(t, out.String(), value)
+	}
+}
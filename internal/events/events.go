@@ -0,0 +1,86 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/pkg/errors"
+
+	fleetdbapi "github.com/metal-toolbox/fleetdb/pkg/api/v1"
+)
+
+const (
+	// KindFirmwareSynced is emitted when a firmware file is newly synced to the firmware repository
+	// and published to inventory.
+	KindFirmwareSynced = "firmware.synced"
+)
+
+var (
+	ErrPublishEvent = errors.New("error publishing event")
+)
+
+// Event is emitted for downstream automation to react to firmware availability.
+type Event struct {
+	Kind     string                               `json:"kind"`
+	Firmware *fleetdbapi.ComponentFirmwareVersion `json:"firmware"`
+	// URL is where the firmware file can be downloaded from the firmware repository
+	URL string `json:"url"`
+}
+
+//go:generate mockgen -source=events.go -destination=mocks/events.go Publisher
+
+// Publisher publishes events.
+type Publisher interface {
+	Publish(ctx context.Context, event *Event) error
+}
+
+// WebhookPublisher publishes events by POSTing them as JSON to a webhook URL.
+type WebhookPublisher struct {
+	client fleetdbapi.Doer
+	url    string
+}
+
+// NewWebhookPublisher returns a WebhookPublisher posting events to the given URL.
+func NewWebhookPublisher(client fleetdbapi.Doer, webhookURL string) Publisher {
+	return &WebhookPublisher{client: client, url: webhookURL}
+}
+
+// Publish posts the event to the webhook, any non 2xx response is returned as an error.
+func (w *WebhookPublisher) Publish(ctx context.Context, event *Event) error {
+	if err := PostJSON(ctx, w.client, w.url, event); err != nil {
+		return errors.Wrap(ErrPublishEvent, err.Error())
+	}
+
+	return nil
+}
+
+// PostJSON POSTs the payload as JSON to the webhook URL, any non 2xx response is returned as an error.
+// The request isn't marked idempotent, the client doesn't retry it.
+func PostJSON(ctx context.Context, client fleetdbapi.Doer, webhookURL string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook status code %d", resp.StatusCode)
+	}
+
+	return nil
+}
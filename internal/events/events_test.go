@@ -0,0 +1,60 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	fleetdbapi "github.com/metal-toolbox/fleetdb/pkg/api/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhookPublisher(t *testing.T) {
+	event := &Event{
+		Kind:     KindFirmwareSynced,
+		Firmware: &fleetdbapi.ComponentFirmwareVersion{Vendor: "dell", Filename: "firmware.bin", Version: "1.0.0"},
+		URL:      "https://example.com/dell/firmware.bin",
+	}
+
+	testCases := []struct {
+		name          string
+		statusCode    int
+		expectedError error
+	}{
+		{
+			name:       "success",
+			statusCode: http.StatusNoContent,
+		},
+		{
+			name:          "webhook error",
+			statusCode:    http.StatusInternalServerError,
+			expectedError: ErrPublishEvent,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			var received Event
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, http.MethodPost, r.Method)
+				assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+				assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+
+				w.WriteHeader(tt.statusCode)
+			}))
+			defer server.Close()
+
+			err := NewWebhookPublisher(http.DefaultClient, server.URL).Publish(context.Background(), event)
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, *event, received)
+		})
+	}
+}
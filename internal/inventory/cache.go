@@ -0,0 +1,135 @@
+package inventory
+
+import (
+	"container/list"
+	"hash/fnv"
+	"slices"
+	"sync"
+
+	"github.com/google/uuid"
+
+	fleetdbapi "github.com/metal-toolbox/fleetdb/pkg/api/v1"
+)
+
+const (
+	// lookupCacheSize is the number of checksums the firmware listed by checksum is kept for
+	lookupCacheSize = 1024
+	// checksumLockStripes is the number of locks serializing the publishes of firmware sharing a checksum
+	checksumLockStripes = 64
+)
+
+// lookupCache is an LRU of the inventory firmware listed by checksum,
+// it spares listing the firmware again when firmware shared by multiple models is published.
+//
+// The cached firmware is kept up to date with the firmware written by the ServerService,
+// and dropped when a write fails as the inventory state is unknown then.
+type lookupCache struct {
+	mutex   sync.Mutex
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type lookupEntry struct {
+	checksum  string
+	firmwares []fleetdbapi.ComponentFirmwareVersion
+}
+
+func newLookupCache(size int) *lookupCache {
+	return &lookupCache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// get returns the firmware listed for the checksum, ok is false when the checksum isn't cached.
+func (c *lookupCache) get(checksum string) (firmwares []fleetdbapi.ComponentFirmwareVersion, ok bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	element, ok := c.entries[checksum]
+	if !ok {
+		return nil, false
+	}
+
+	c.order.MoveToFront(element)
+
+	return slices.Clone(element.Value.(*lookupEntry).firmwares), true
+}
+
+// add caches the firmware listed for the checksum, evicting the least recently used checksum when the cache is full.
+func (c *lookupCache) add(checksum string, firmwares []fleetdbapi.ComponentFirmwareVersion) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if element, ok := c.entries[checksum]; ok {
+		element.Value.(*lookupEntry).firmwares = slices.Clone(firmwares)
+		c.order.MoveToFront(element)
+
+		return
+	}
+
+	c.entries[checksum] = c.order.PushFront(&lookupEntry{checksum: checksum, firmwares: slices.Clone(firmwares)})
+
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lookupEntry).checksum)
+	}
+}
+
+// remove drops the firmware cached for the checksum.
+func (c *lookupCache) remove(checksum string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if element, ok := c.entries[checksum]; ok {
+		c.order.Remove(element)
+		delete(c.entries, checksum)
+	}
+}
+
+// written updates the cached firmware with the firmware written to inventory,
+// the checksum is dropped from the cache when the write failed or the firmware UUID is unknown.
+func (c *lookupCache) written(firmware *fleetdbapi.ComponentFirmwareVersion, err error) {
+	if err != nil || firmware.UUID == uuid.Nil {
+		c.remove(firmware.Checksum)
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	element, ok := c.entries[firmware.Checksum]
+	if !ok {
+		return
+	}
+
+	record := *firmware
+	record.Model = slices.Clone(firmware.Model)
+
+	entry := element.Value.(*lookupEntry)
+
+	i := slices.IndexFunc(entry.firmwares, func(fw fleetdbapi.ComponentFirmwareVersion) bool { return fw.UUID == record.UUID })
+	if i < 0 {
+		entry.firmwares = append(entry.firmwares, record)
+		return
+	}
+
+	entry.firmwares[i] = record
+}
+
+// checksumLocks serializes the publishes of the firmware sharing a checksum,
+// so parallel publishes don't each create the firmware or overwrite the models merged by the other.
+type checksumLocks [checksumLockStripes]sync.Mutex
+
+func (l *checksumLocks) lock(checksum string) *sync.Mutex {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(checksum))
+
+	mutex := &l[h.Sum32()%checksumLockStripes]
+	mutex.Lock()
+
+	return mutex
+}
@@ -0,0 +1,53 @@
+package inventory
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	fleetdbapi "github.com/metal-toolbox/fleetdb/pkg/api/v1"
+)
+
+func TestLookupCache(t *testing.T) {
+	cache := newLookupCache(2)
+
+	cache.add("1111", nil)
+	cache.add("2222", []fleetdbapi.ComponentFirmwareVersion{{UUID: uuid.New(), Checksum: "2222"}})
+
+	// 1111 is used more recently than 2222, which is evicted
+	_, ok := cache.get("1111")
+	assert.True(t, ok)
+
+	cache.add("3333", nil)
+
+	_, ok = cache.get("2222")
+	assert.False(t, ok)
+
+	// firmware created with a cached checksum is added to its cached firmware
+	created := &fleetdbapi.ComponentFirmwareVersion{UUID: uuid.New(), Checksum: "1111", Model: []string{"model1"}}
+	cache.written(created, nil)
+
+	firmwares, _ := cache.get("1111")
+	assert.Equal(t, []fleetdbapi.ComponentFirmwareVersion{*created}, firmwares)
+
+	// updated firmware replaces the cached record
+	updated := &fleetdbapi.ComponentFirmwareVersion{UUID: created.UUID, Checksum: "1111", Model: []string{"model1", "model2"}}
+	cache.written(updated, nil)
+
+	firmwares, _ = cache.get("1111")
+	assert.Equal(t, []fleetdbapi.ComponentFirmwareVersion{*updated}, firmwares)
+
+	// the checksum is dropped when a write fails
+	cache.written(updated, errors.New("timeout"))
+
+	_, ok = cache.get("1111")
+	assert.False(t, ok)
+
+	// or when the UUID of the created firmware is unknown
+	cache.written(&fleetdbapi.ComponentFirmwareVersion{Checksum: "3333"}, nil)
+
+	_, ok = cache.get("3333")
+	assert.False(t, ok)
+}
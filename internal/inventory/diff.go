@@ -0,0 +1,106 @@
+package inventory
+
+import (
+	"context"
+
+	fleetdbapi "github.com/metal-toolbox/fleetdb/pkg/api/v1"
+)
+
+// Differ compares manifest firmware with the firmware in inventory, it's implemented by the ServerService New returns.
+type Differ interface {
+	Diff(ctx context.Context, firmwares []*fleetdbapi.ComponentFirmwareVersion) (*ManifestDiff, error)
+}
+
+// ManifestDiff lists the changes publishing the manifest firmware would make to inventory,
+// firmware is matched on its checksum, the same way Publish and Prune do.
+type ManifestDiff struct {
+	Added   []*fleetdbapi.ComponentFirmwareVersion `json:"added"`
+	Changed []FirmwareChange                       `json:"changed"`
+	// Removed is the inventory firmware of the manifest vendors which is no longer in the manifest,
+	// it's deleted when inventory is pruned.
+	Removed []*fleetdbapi.ComponentFirmwareVersion `json:"removed"`
+}
+
+// FirmwareChange is an inventory firmware record along with the update the manifest makes to it.
+type FirmwareChange struct {
+	Current *fleetdbapi.ComponentFirmwareVersion `json:"current"`
+	New     *fleetdbapi.ComponentFirmwareVersion `json:"new"`
+}
+
+// Empty returns true when the manifest doesn't change inventory.
+func (d *ManifestDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Changed) == 0 && len(d.Removed) == 0
+}
+
+// Diff returns the changes publishing the given firmwares would make to inventory, without making them.
+func (s *serverService) Diff(ctx context.Context, firmwares []*fleetdbapi.ComponentFirmwareVersion) (*ManifestDiff, error) {
+	existingByVendor := make(map[string][]fleetdbapi.ComponentFirmwareVersion)
+
+	for _, fw := range firmwares {
+		if _, listed := existingByVendor[fw.Vendor]; listed {
+			continue
+		}
+
+		existing, err := s.listVendorFirmware(ctx, fw.Vendor)
+		if err != nil {
+			return nil, err
+		}
+
+		existingByVendor[fw.Vendor] = existing
+	}
+
+	diff := &ManifestDiff{}
+	listed := make(map[string]bool)
+
+	for _, fw := range firmwares {
+		for _, checksum := range s.matchingChecksums(fw) {
+			listed[checksum] = true
+		}
+
+		change, err := s.firmwareChange(fw, existingByVendor[fw.Vendor])
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case change.Current == nil:
+			diff.Added = append(diff.Added, change.New)
+		case isDifferent(change.New, change.Current):
+			diff.Changed = append(diff.Changed, *change)
+		}
+	}
+
+	for _, existing := range existingByVendor {
+		for i := range existing {
+			if !listed[existing[i].Checksum] {
+				diff.Removed = append(diff.Removed, &existing[i])
+			}
+		}
+	}
+
+	return diff, nil
+}
+
+// firmwareChange returns the current firmware record matching fw along with the record Publish would write,
+// the current record is nil when fw isn't in inventory.
+func (s *serverService) firmwareChange(
+	fw *fleetdbapi.ComponentFirmwareVersion,
+	existing []fleetdbapi.ComponentFirmwareVersion,
+) (*FirmwareChange, error) {
+	newFirmware := *fw
+	if err := s.addRepositoryURL(&newFirmware); err != nil {
+		return nil, err
+	}
+
+	current, err := s.selectCurrentFirmware(&newFirmware, existing)
+	if err != nil {
+		return nil, err
+	}
+
+	if current != nil {
+		newFirmware.UUID = current.UUID
+		newFirmware.Model = mergeModels(current.Model, newFirmware.Model)
+	}
+
+	return &FirmwareChange{Current: current, New: &newFirmware}, nil
+}
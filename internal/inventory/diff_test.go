@@ -0,0 +1,133 @@
+package inventory
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	fleetdbapi "github.com/metal-toolbox/fleetdb/pkg/api/v1"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/metal-toolbox/firmware-syncer/internal/config"
+)
+
+func TestServerServiceDiff(t *testing.T) {
+	existingFirmwares := []*fleetdbapi.ComponentFirmwareVersion{
+		{
+			UUID:          uuid.New(),
+			Vendor:        "vendor",
+			Model:         []string{"model1"},
+			Filename:      "current.zip",
+			Version:       "1.0.0",
+			Component:     "bmc",
+			Checksum:      "1111",
+			UpstreamURL:   "http://some/location/current.zip",
+			RepositoryURL: "https://example.com/some/path/vendor/current.zip",
+		},
+		{
+			UUID:          uuid.New(),
+			Vendor:        "vendor",
+			Model:         []string{"model1"},
+			Filename:      "outdated.zip",
+			Version:       "1.0.0",
+			Component:     "bios",
+			Checksum:      "2222",
+			UpstreamURL:   "http://some/location/outdated.zip",
+			RepositoryURL: "https://example.com/some/path/vendor/outdated.zip",
+		},
+		{
+			UUID:          uuid.New(),
+			Vendor:        "vendor",
+			Model:         []string{"model1"},
+			Filename:      "removed.zip",
+			Version:       "0.9.0",
+			Component:     "nic",
+			Checksum:      "4444",
+			UpstreamURL:   "http://some/location/removed.zip",
+			RepositoryURL: "https://example.com/some/path/vendor/removed.zip",
+		},
+	}
+
+	newFirmwares := []*fleetdbapi.ComponentFirmwareVersion{
+		{
+			Vendor:      "vendor",
+			Model:       []string{"model1"},
+			Filename:    "current.zip",
+			Version:     "1.0.0",
+			Component:   "bmc",
+			Checksum:    "1111",
+			UpstreamURL: "http://some/location/current.zip",
+		},
+		{
+			Vendor:      "vendor",
+			Model:       []string{"model2"},
+			Filename:    "outdated.zip",
+			Version:     "1.0.0",
+			Component:   "bios",
+			Checksum:    "2222",
+			UpstreamURL: "http://some/location/outdated.zip",
+		},
+		{
+			Vendor:      "vendor",
+			Model:       []string{"model1"},
+			Filename:    "new.zip",
+			Version:     "2.0.0",
+			Component:   "nic",
+			Checksum:    "3333",
+			UpstreamURL: "http://some/location/new.zip",
+		},
+	}
+
+	handler := http.NewServeMux()
+	handler.HandleFunc(
+		"/api/v1/server-component-firmwares",
+		func(writer http.ResponseWriter, request *http.Request) {
+			if request.Method != http.MethodGet {
+				t.Fatal("diff must not change inventory, got: " + request.Method)
+			}
+
+			assert.Equal(t, "vendor", request.URL.Query().Get("vendor"))
+			writeResponse(t, writer, &fleetdbapi.ServerResponse{Records: existingFirmwares})
+		},
+	)
+
+	mock := httptest.NewServer(handler)
+	defer mock.Close()
+
+	logger := logrus.New()
+	logger.Out = io.Discard
+
+	hss, err := New(context.Background(), &config.ServerserviceOptions{Endpoint: mock.URL, DisableOAuth: true}, artifactsURL, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diff, err := hss.(Differ).Diff(context.Background(), newFirmwares)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.False(t, diff.Empty())
+
+	if assert.Len(t, diff.Added, 1) {
+		assert.Equal(t, "new.zip", diff.Added[0].Filename)
+		assert.Equal(t, "https://example.com/some/path/vendor/new.zip", diff.Added[0].RepositoryURL)
+	}
+
+	if assert.Len(t, diff.Changed, 1) {
+		assert.Equal(t, existingFirmwares[1].UUID, diff.Changed[0].Current.UUID)
+		assert.Equal(t, existingFirmwares[1].UUID, diff.Changed[0].New.UUID)
+		assert.Equal(t, []string{"model1", "model2"}, diff.Changed[0].New.Model)
+	}
+
+	if assert.Len(t, diff.Removed, 1) {
+		assert.Equal(t, "removed.zip", diff.Removed[0].Filename)
+	}
+
+	// the manifest firmware is left untouched
+	assert.Empty(t, newFirmwares[2].RepositoryURL)
+}
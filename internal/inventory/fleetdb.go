@@ -2,10 +2,14 @@ package inventory
 
 import (
 	"context"
+	"fmt"
 	"net/url"
+	"path"
 	"slices"
 	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/coreos/go-oidc/v3/oidc"
 	"github.com/pkg/errors"
@@ -20,46 +24,146 @@ import (
 var (
 	ErrServerServiceDuplicateFirmware = errors.New("duplicate firmware found")
 	ErrServerServiceQuery             = errors.New("server service query failed")
+	ErrPruneEmptyManifest             = errors.New("refusing to prune inventory with an empty firmware manifest")
+	ErrOidcDiscovery                  = errors.New("oidc issuer discovery failed")
 )
 
-//go:generate mockgen -source=serverservice.go -destination=mocks/serverservice.go ServerService
+const (
+	// publishBatchConcurrency is the number of firmware created/updated in parallel by PublishBatch
+	publishBatchConcurrency = 5
+
+	// listPageSize is the number of firmware requested per page when listing firmware
+	listPageSize = 100
+
+	// defaultOidcDiscoveryTimeout is the time allowed for OIDC issuer discovery when not configured
+	defaultOidcDiscoveryTimeout = time.Minute
+	// oidcDiscoveryMaxBackoff is the maximum wait between OIDC issuer discovery attempts
+	oidcDiscoveryMaxBackoff = 10 * time.Second
+)
+
+// oidcDiscoveryBackoff is the initial wait between OIDC issuer discovery attempts
+var oidcDiscoveryBackoff = time.Second
+
+//go:generate mockgen -source=fleetdb.go -destination=mocks/fleetdb.go ServerService
 
 type ServerService interface {
 	Publish(ctx context.Context, newFirmware *fleetdbapi.ComponentFirmwareVersion) error
+	PublishBatch(ctx context.Context, firmwares []*fleetdbapi.ComponentFirmwareVersion) error
+	Prune(ctx context.Context, keep []*fleetdbapi.ComponentFirmwareVersion) error
+}
+
+// PublishErrors is returned by PublishBatch when one or more firmware failed to publish,
+// it maps the firmware vendor/filename to the error encountered.
+type PublishErrors map[string]error
+
+func (e PublishErrors) Error() string {
+	keys := make([]string, 0, len(e))
+	for key := range e {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	msgs := make([]string, len(keys))
+	for i, key := range keys {
+		msgs[i] = fmt.Sprintf("%s: %s", key, e[key])
+	}
+
+	return fmt.Sprintf("failed to publish %d firmware(s): %s", len(e), strings.Join(msgs, "; "))
+}
+
+func publishKey(fw *fleetdbapi.ComponentFirmwareVersion) string {
+	return path.Join(fw.Vendor, fw.Filename)
 }
 
 type serverService struct {
-	artifactsURL string
-	client       *fleetdbapi.Client
-	logger       *logrus.Logger
+	artifactsURL      string
+	client            *fleetdbapi.Client
+	logger            *logrus.Logger
+	recoverDuplicates bool
+	// repositoryPath returns the firmware path under the artifactsURL, publishKey when not set
+	repositoryPath func(*fleetdbapi.ComponentFirmwareVersion) string
+	// checksums are the checksums the manifest declares for the firmware, keyed by upstream URL
+	checksums config.FirmwareChecksums
+	// lookups caches the firmware listed by checksum during the run
+	lookups       *lookupCache
+	checksumLocks checksumLocks
+}
+
+// Option sets optional parameters on the ServerService.
+type Option func(*serverService)
+
+// WithDuplicateRecovery makes the ServerService recover from multiple firmware records sharing a checksum
+// by logging and picking a canonical record, instead of returning ErrServerServiceDuplicateFirmware.
+func WithDuplicateRecovery() Option {
+	return func(s *serverService) {
+		s.recoverDuplicates = true
+	}
+}
+
+// WithRepositoryPath sets the func returning the firmware path under the artifacts URL,
+// it has to match where the firmware is uploaded, vendor/filename is used by default.
+func WithRepositoryPath(repositoryPath func(*fleetdbapi.ComponentFirmwareVersion) string) Option {
+	return func(s *serverService) {
+		s.repositoryPath = repositoryPath
+	}
+}
+
+// WithChecksums sets the checksums the manifest declares for the firmware, keyed by upstream URL and checksum hint.
+// A firmware record published with any of them is matched, so a record gaining a checksum the published one is
+// picked from, such as sha256 over md5sum, has its checksum updated instead of a new record being created.
+func WithChecksums(checksums config.FirmwareChecksums) Option {
+	return func(s *serverService) {
+		s.checksums = checksums
+	}
 }
 
-func New(ctx context.Context, cfg *config.ServerserviceOptions, artifactsURL string, logger *logrus.Logger) (ServerService, error) {
+func New(
+	ctx context.Context,
+	cfg *config.ServerserviceOptions,
+	artifactsURL string,
+	logger *logrus.Logger,
+	opts ...Option,
+) (ServerService, error) {
 	var client *fleetdbapi.Client
 
 	var err error
 
-	if !cfg.DisableOAuth {
+	switch {
+	case cfg.StaticToken != "":
+		client, err = fleetdbapi.NewClientWithToken(cfg.StaticToken, cfg.Endpoint, nil)
+		if err != nil {
+			return nil, err
+		}
+	case !cfg.DisableOAuth:
 		client, err = newClientWithOAuth(ctx, cfg)
 		if err != nil {
 			return nil, err
 		}
-	} else {
+	default:
 		client, err = fleetdbapi.NewClientWithToken("fake", cfg.Endpoint, nil)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	return &serverService{
-		artifactsURL: artifactsURL,
-		client:       client,
-		logger:       logger,
-	}, nil
+	s := &serverService{
+		artifactsURL:   artifactsURL,
+		client:         client,
+		logger:         logger,
+		repositoryPath: publishKey,
+		lookups:        newLookupCache(lookupCacheSize),
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s, nil
 }
 
 func newClientWithOAuth(ctx context.Context, cfg *config.ServerserviceOptions) (client *fleetdbapi.Client, err error) {
-	provider, err := oidc.NewProvider(ctx, cfg.OidcIssuerEndpoint)
+	provider, err := discoverProvider(ctx, cfg)
 	if err != nil {
 		return nil, err
 	}
@@ -80,67 +184,431 @@ func newClientWithOAuth(ctx context.Context, cfg *config.ServerserviceOptions) (
 	return client, nil
 }
 
+// discoverProvider runs the OIDC issuer discovery, retrying with backoff
+// until it succeeds or the configured discovery timeout expires.
+func discoverProvider(ctx context.Context, cfg *config.ServerserviceOptions) (*oidc.Provider, error) {
+	timeout := cfg.OidcDiscoveryTimeout
+	if timeout == 0 {
+		timeout = defaultOidcDiscoveryTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	backoff := oidcDiscoveryBackoff
+
+	for {
+		provider, err := oidc.NewProvider(ctx, cfg.OidcIssuerEndpoint)
+		if err == nil {
+			return provider, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, errors.Wrap(ErrOidcDiscovery, err.Error())
+		case <-time.After(backoff):
+		}
+
+		backoff = min(backoff*2, oidcDiscoveryMaxBackoff)
+	}
+}
+
 func (s *serverService) addRepositoryURL(fw *fleetdbapi.ComponentFirmwareVersion) (err error) {
-	fw.RepositoryURL, err = url.JoinPath(s.artifactsURL, fw.Vendor, fw.Filename)
+	fw.RepositoryURL, err = url.JoinPath(s.artifactsURL, s.repositoryPath(fw))
 
 	return err
 }
 
 func (s *serverService) getCurrentFirmware(ctx context.Context, newFirmware *fleetdbapi.ComponentFirmwareVersion) (*fleetdbapi.ComponentFirmwareVersion, error) {
-	params := fleetdbapi.ComponentFirmwareVersionListParams{
-		Checksum: newFirmware.Checksum,
+	for _, checksum := range s.matchingChecksums(newFirmware) {
+		firmwares, cached := s.lookups.get(checksum)
+		if !cached {
+			params := fleetdbapi.ComponentFirmwareVersionListParams{
+				Checksum: checksum,
+			}
+
+			var err error
+
+			firmwares, err = s.listFirmware(ctx, &params)
+			if err != nil {
+				return nil, err
+			}
+
+			s.lookups.add(checksum, firmwares)
+		}
+
+		currentFirmware, err := s.selectCurrentFirmware(newFirmware, firmwares)
+		if err != nil || currentFirmware != nil {
+			return currentFirmware, err
+		}
 	}
 
-	firmwares, _, err := s.client.ListServerComponentFirmware(ctx, &params)
-	if err != nil {
-		return nil, errors.Wrap(ErrServerServiceQuery, "ListServerComponentFirmware: "+err.Error())
+	return nil, nil
+}
+
+// matchingChecksums returns the checksums a firmware record of newFirmware can have been published with,
+// its checksum first, then the other checksums the manifest declares for it with their hash hint.
+func (s *serverService) matchingChecksums(newFirmware *fleetdbapi.ComponentFirmwareVersion) []string {
+	checksums := []string{newFirmware.Checksum}
+
+	declared := s.checksums[newFirmware.UpstreamURL]
+
+	hints := make([]string, 0, len(declared))
+	for hint := range declared {
+		hints = append(hints, hint)
+	}
+
+	sort.Strings(hints)
+
+	for _, hint := range hints {
+		if checksum := hint + ":" + declared[hint]; checksum != newFirmware.Checksum {
+			checksums = append(checksums, checksum)
+		}
 	}
 
-	firmwareCount := len(firmwares)
+	return checksums
+}
+
+// selectCurrentFirmware returns the firmware record matching the newFirmware checksum from the given candidates,
+// or else matching one of its other declared checksums, see matchingChecksums. nil is returned when there's no match.
+func (s *serverService) selectCurrentFirmware(
+	newFirmware *fleetdbapi.ComponentFirmwareVersion,
+	candidates []fleetdbapi.ComponentFirmwareVersion,
+) (*fleetdbapi.ComponentFirmwareVersion, error) {
+	for _, checksum := range s.matchingChecksums(newFirmware) {
+		var firmwares []fleetdbapi.ComponentFirmwareVersion
+
+		for i := range candidates {
+			if candidates[i].Checksum == checksum {
+				firmwares = append(firmwares, candidates[i])
+			}
+		}
 
-	if firmwareCount == 0 {
-		return nil, nil
+		if len(firmwares) > 0 {
+			return s.uniqueFirmware(newFirmware, checksum, firmwares)
+		}
 	}
 
-	if firmwareCount != 1 {
+	return nil, nil
+}
+
+// uniqueFirmware returns the single firmware record matching the checksum,
+// duplicate records are an error unless the ServerService recovers from them, see WithDuplicateRecovery.
+func (s *serverService) uniqueFirmware(
+	newFirmware *fleetdbapi.ComponentFirmwareVersion,
+	checksum string,
+	firmwares []fleetdbapi.ComponentFirmwareVersion,
+) (*fleetdbapi.ComponentFirmwareVersion, error) {
+	if len(firmwares) != 1 {
 		uuids := make([]string, len(firmwares))
 		for i := range firmwares {
 			uuids[i] = firmwares[i].UUID.String()
 		}
 
-		s.logger.WithField("matchingUUIDs", uuids).
-			WithField("checksum", newFirmware.Checksum).
+		logMsg := s.logger.WithField("matchingUUIDs", uuids).
+			WithField("checksum", checksum).
 			WithField("firmware", newFirmware.Filename).
 			WithField("vendor", newFirmware.Vendor).
-			WithField("version", newFirmware.Version).
-			Error("Multiple firmware IDs found with checksum")
+			WithField("version", newFirmware.Version)
+
+		if !s.recoverDuplicates {
+			logMsg.Error("Multiple firmware IDs found with checksum")
 
-		return nil, errors.Wrap(ErrServerServiceDuplicateFirmware, strings.Join(uuids, ","))
+			return nil, errors.Wrap(ErrServerServiceDuplicateFirmware, strings.Join(uuids, ","))
+		}
+
+		canonical := canonicalFirmware(newFirmware, firmwares)
+
+		logMsg.WithField("uuid", canonical.UUID).
+			Warn("Multiple firmware IDs found with checksum, using canonical firmware")
+
+		return canonical, nil
 	}
 
 	return &firmwares[0], nil
 }
 
+// canonicalFirmware picks the firmware record to use out of duplicate records,
+// records matching the newFirmware vendor, filename and version are preferred,
+// then the oldest record is picked, using the UUID as a tie breaker.
+func canonicalFirmware(
+	newFirmware *fleetdbapi.ComponentFirmwareVersion,
+	duplicates []fleetdbapi.ComponentFirmwareVersion,
+) *fleetdbapi.ComponentFirmwareVersion {
+	matches := func(fw *fleetdbapi.ComponentFirmwareVersion) bool {
+		return fw.Vendor == newFirmware.Vendor &&
+			fw.Filename == newFirmware.Filename &&
+			fw.Version == newFirmware.Version
+	}
+
+	candidates := slices.Clone(duplicates)
+	slices.SortFunc(candidates, func(a, b fleetdbapi.ComponentFirmwareVersion) int {
+		if matchA, matchB := matches(&a), matches(&b); matchA != matchB {
+			if matchA {
+				return -1
+			}
+
+			return 1
+		}
+
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+
+		return strings.Compare(a.UUID.String(), b.UUID.String())
+	})
+
+	return &candidates[0]
+}
+
 // Publish adds firmware data to Hollow's ServerService
 func (s *serverService) Publish(ctx context.Context, newFirmware *fleetdbapi.ComponentFirmwareVersion) error {
 	if err := s.addRepositoryURL(newFirmware); err != nil {
 		return err
 	}
 
+	defer s.checksumLocks.lock(newFirmware.Checksum).Unlock()
+
 	currentFirmware, err := s.getCurrentFirmware(ctx, newFirmware)
 	if err != nil {
 		return err
 	}
 
+	return s.reconcile(ctx, newFirmware, currentFirmware)
+}
+
+// PublishBatch adds the given firmwares to Hollow's ServerService.
+//
+// Existing firmware is listed once per vendor instead of once per firmware,
+// and the resulting creates/updates are issued with limited concurrency.
+// When any of the firmware fails to publish, a PublishErrors is returned.
+func (s *serverService) PublishBatch(ctx context.Context, firmwares []*fleetdbapi.ComponentFirmwareVersion) error {
+	publishErrors := PublishErrors{}
+	existingByVendor := make(map[string][]fleetdbapi.ComponentFirmwareVersion)
+
+	for _, fw := range firmwares {
+		if err := s.addRepositoryURL(fw); err != nil {
+			publishErrors[publishKey(fw)] = err
+			continue
+		}
+
+		if _, listed := existingByVendor[fw.Vendor]; listed {
+			continue
+		}
+
+		existing, err := s.listVendorFirmware(ctx, fw.Vendor)
+		if err != nil {
+			return err
+		}
+
+		existingByVendor[fw.Vendor] = existing
+	}
+
+	groups := s.batchGroups(firmwares, existingByVendor, publishErrors)
+
+	var (
+		wg    sync.WaitGroup
+		mutex sync.Mutex
+		sem   = make(chan struct{}, publishBatchConcurrency)
+	)
+
+	for _, group := range groups {
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func(group []batchEntry) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			errs := s.reconcileGroup(ctx, group)
+
+			mutex.Lock()
+			for key, err := range errs {
+				publishErrors[key] = err
+			}
+			mutex.Unlock()
+		}(group)
+	}
+
+	wg.Wait()
+
+	if len(publishErrors) > 0 {
+		return publishErrors
+	}
+
+	return nil
+}
+
+// batchEntry is a firmware of a batch along with the inventory record it was matched with, nil when there's none.
+type batchEntry struct {
+	firmware, current *fleetdbapi.ComponentFirmwareVersion
+}
+
+// batchGroups matches the firmwares with the existing records and groups them by checksum, in the batch order.
+// The firmware failing to be matched is recorded in publishErrors and left out.
+func (s *serverService) batchGroups(
+	firmwares []*fleetdbapi.ComponentFirmwareVersion,
+	existingByVendor map[string][]fleetdbapi.ComponentFirmwareVersion,
+	publishErrors PublishErrors,
+) [][]batchEntry {
+	var groups [][]batchEntry
+
+	groupIndex := make(map[string]int)
+
+	for _, fw := range firmwares {
+		key := publishKey(fw)
+		if _, failed := publishErrors[key]; failed {
+			continue
+		}
+
+		currentFirmware, err := s.selectCurrentFirmware(fw, existingByVendor[fw.Vendor])
+		if err != nil {
+			publishErrors[key] = err
+			continue
+		}
+
+		entry := batchEntry{firmware: fw, current: currentFirmware}
+
+		if i, found := groupIndex[fw.Checksum]; found {
+			groups[i] = append(groups[i], entry)
+			continue
+		}
+
+		groupIndex[fw.Checksum] = len(groups)
+		groups = append(groups, []batchEntry{entry})
+	}
+
+	return groups
+}
+
+// reconcileGroup reconciles the firmware sharing a checksum in order, each one with the record the previous one
+// created or updated, as successive Publish calls would, so the batch doesn't create a record per firmware.
+// The errors are returned keyed by publishKey.
+func (s *serverService) reconcileGroup(ctx context.Context, group []batchEntry) PublishErrors {
+	defer s.checksumLocks.lock(group[0].firmware.Checksum).Unlock()
+
+	errs := PublishErrors{}
+
+	var previous *fleetdbapi.ComponentFirmwareVersion
+
+	for _, entry := range group {
+		currentFirmware := entry.current
+		if previous != nil {
+			currentFirmware = previous
+		}
+
+		if err := s.reconcile(ctx, entry.firmware, currentFirmware); err != nil {
+			errs[publishKey(entry.firmware)] = err
+			continue
+		}
+
+		previous = entry.firmware
+	}
+
+	return errs
+}
+
+// Prune deletes firmware from inventory which is no longer present in the given keep firmwares.
+//
+// Only firmware of the vendors in keep is considered, and firmware is matched on its declared checksums,
+// the same way Publish matches an existing firmware record.
+// An empty keep list returns an error, so a missing manifest never wipes the inventory.
+func (s *serverService) Prune(ctx context.Context, keep []*fleetdbapi.ComponentFirmwareVersion) error {
+	if len(keep) == 0 {
+		return ErrPruneEmptyManifest
+	}
+
+	keepChecksums := make(map[string]bool)
+	vendors := make(map[string]bool)
+
+	for _, fw := range keep {
+		for _, checksum := range s.matchingChecksums(fw) {
+			keepChecksums[checksum] = true
+		}
+
+		vendors[fw.Vendor] = true
+	}
+
+	for vendor := range vendors {
+		existing, err := s.listVendorFirmware(ctx, vendor)
+		if err != nil {
+			return err
+		}
+
+		for i := range existing {
+			if keepChecksums[existing[i].Checksum] {
+				continue
+			}
+
+			if err := s.deleteFirmware(ctx, &existing[i]); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *serverService) listVendorFirmware(ctx context.Context, vendor string) ([]fleetdbapi.ComponentFirmwareVersion, error) {
+	params := fleetdbapi.ComponentFirmwareVersionListParams{
+		Vendor: vendor,
+	}
+
+	return s.listFirmware(ctx, &params)
+}
+
+// listFirmware returns all the firmware matching the params, following the result pages until exhausted.
+func (s *serverService) listFirmware(
+	ctx context.Context,
+	params *fleetdbapi.ComponentFirmwareVersionListParams,
+) ([]fleetdbapi.ComponentFirmwareVersion, error) {
+	var all []fleetdbapi.ComponentFirmwareVersion
+
+	params.Pagination = &fleetdbapi.PaginationParams{Limit: listPageSize, Page: 1}
+
+	for {
+		firmwares, resp, err := s.client.ListServerComponentFirmware(ctx, params)
+		if err != nil {
+			msg := fmt.Sprintf("ListServerComponentFirmware page %d: %s", params.Pagination.Page, err)
+			return nil, errors.Wrap(ErrServerServiceQuery, msg)
+		}
+
+		all = append(all, firmwares...)
+
+		// an empty page guards against looping forever on a misbehaving server
+		if len(firmwares) == 0 || !resp.HasNextPage() {
+			return all, nil
+		}
+
+		params.Pagination.Page++
+	}
+}
+
+// reconcile creates the newFirmware when there's no currentFirmware,
+// or updates the currentFirmware when it differs from the newFirmware.
+func (s *serverService) reconcile(ctx context.Context, newFirmware, currentFirmware *fleetdbapi.ComponentFirmwareVersion) error {
 	if currentFirmware == nil {
-		return s.createFirmware(ctx, newFirmware)
+		err := s.createFirmware(ctx, newFirmware)
+		s.lookups.written(newFirmware, err)
+
+		return err
 	}
 
 	newFirmware.UUID = currentFirmware.UUID
 	newFirmware.Model = mergeModels(currentFirmware.Model, newFirmware.Model)
 
+	// the record matched on another declared checksum moves to the published one
+	if currentFirmware.Checksum != newFirmware.Checksum {
+		s.lookups.remove(currentFirmware.Checksum)
+	}
+
 	if isDifferent(newFirmware, currentFirmware) {
-		return s.updateFirmware(ctx, newFirmware)
+		err := s.updateFirmware(ctx, newFirmware)
+		s.lookups.written(newFirmware, err)
+
+		return err
 	}
 
 	s.logger.WithField("firmware", newFirmware.Filename).
@@ -202,6 +670,10 @@ func isDifferent(firmware1, firmware2 *fleetdbapi.ComponentFirmwareVersion) bool
 		return true
 	}
 
+	if installFlagsDiffer(firmware1, firmware2) {
+		return true
+	}
+
 	if !slices.Equal(firmware1.Model, firmware2.Model) {
 		return true
 	}
@@ -209,12 +681,26 @@ func isDifferent(firmware1, firmware2 *fleetdbapi.ComponentFirmwareVersion) bool
 	return false
 }
 
+// installFlagsDiffer returns true when the install inband or OEM flags differ, an unset flag being false.
+func installFlagsDiffer(firmware1, firmware2 *fleetdbapi.ComponentFirmwareVersion) bool {
+	return boolValue(firmware1.InstallInband) != boolValue(firmware2.InstallInband) ||
+		boolValue(firmware1.OEM) != boolValue(firmware2.OEM)
+}
+
+func boolValue(b *bool) bool {
+	return b != nil && *b
+}
+
 func (s *serverService) createFirmware(ctx context.Context, firmware *fleetdbapi.ComponentFirmwareVersion) error {
 	id, _, err := s.client.CreateServerComponentFirmware(ctx, *firmware)
 	if err != nil {
 		return errors.Wrap(ErrServerServiceQuery, "CreateServerComponentFirmware: "+err.Error())
 	}
 
+	if id != nil {
+		firmware.UUID = *id
+	}
+
 	s.logger.WithField("firmware", firmware.Filename).
 		WithField("version", firmware.Version).
 		WithField("vendor", firmware.Vendor).
@@ -238,3 +724,20 @@ func (s *serverService) updateFirmware(ctx context.Context, firmware *fleetdbapi
 
 	return nil
 }
+
+func (s *serverService) deleteFirmware(ctx context.Context, firmware *fleetdbapi.ComponentFirmwareVersion) error {
+	_, err := s.client.DeleteServerComponentFirmware(ctx, *firmware)
+	s.lookups.remove(firmware.Checksum)
+
+	if err != nil {
+		return errors.Wrap(ErrServerServiceQuery, "DeleteServerComponentFirmware: "+err.Error())
+	}
+
+	s.logger.WithField("firmware", firmware.Filename).
+		WithField("uuid", firmware.UUID).
+		WithField("version", firmware.Version).
+		WithField("vendor", firmware.Vendor).
+		Info("Deleted firmware")
+
+	return nil
+}
@@ -3,10 +3,17 @@ package inventory
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"path"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	fleetdbapi "github.com/metal-toolbox/fleetdb/pkg/api/v1"
@@ -114,6 +121,40 @@ func TestServerServicePublish(t *testing.T) {
 				RepositoryURL: "https://example.com/some/path/vendor/filename.zip",
 			},
 		},
+		{
+			"Update moved Firmware repository URL",
+			&fleetdbapi.ComponentFirmwareVersion{
+				UUID:          id,
+				Vendor:        "vendor",
+				Model:         []string{"model1"},
+				Filename:      "filename.zip",
+				Version:       "1.2.3",
+				Component:     "bmc",
+				Checksum:      "1234",
+				UpstreamURL:   "http://some/location",
+				RepositoryURL: "https://old.example.com/vendor/filename.zip",
+			},
+			&fleetdbapi.ComponentFirmwareVersion{
+				Vendor:      "vendor",
+				Model:       []string{"model1"},
+				Filename:    "filename.zip",
+				Version:     "1.2.3",
+				Component:   "bmc",
+				Checksum:    "1234",
+				UpstreamURL: "http://some/location",
+			},
+			&fleetdbapi.ComponentFirmwareVersion{
+				UUID:          id,
+				Vendor:        "vendor",
+				Model:         []string{"model1"},
+				Filename:      "filename.zip",
+				Version:       "1.2.3",
+				Component:     "bmc",
+				Checksum:      "1234",
+				UpstreamURL:   "http://some/location",
+				RepositoryURL: "https://example.com/some/path/vendor/filename.zip",
+			},
+		},
 	}
 
 	for _, tt := range testCases {
@@ -202,7 +243,16 @@ func newHandler(t *testing.T, tt *testCase) *http.ServeMux {
 func testServerServicePublish(t *testing.T, tt *testCase) {
 	handler := newHandler(t, tt)
 
-	mock := httptest.NewServer(handler)
+	var writes atomic.Int32
+
+	// the expected firmware is only checked when it's written, the writes are counted to catch a missing write
+	mock := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.Method == http.MethodPost || request.Method == http.MethodPut {
+			writes.Add(1)
+		}
+
+		handler.ServeHTTP(writer, request)
+	}))
 	defer mock.Close()
 
 	cfg := config.ServerserviceOptions{
@@ -222,4 +272,976 @@ func testServerServicePublish(t *testing.T, tt *testCase) {
 	if err != nil {
 		t.Fatal(err)
 	}
+
+	if tt.expectedFirmware != nil {
+		assert.Equal(t, int32(1), writes.Load(), "expected the firmware to be written")
+	}
+}
+
+func TestServerServicePublishInstallFlags(t *testing.T) {
+	id, err := uuid.Parse(idString)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	yes, no := true, false
+
+	firmware := func(installInband, oem *bool) *fleetdbapi.ComponentFirmwareVersion {
+		return &fleetdbapi.ComponentFirmwareVersion{
+			UUID:          id,
+			Vendor:        "vendor",
+			Model:         []string{"model1"},
+			Filename:      "filename.zip",
+			Version:       "1.2.3",
+			Component:     "bmc",
+			Checksum:      "1234",
+			UpstreamURL:   "http://some/location",
+			RepositoryURL: "https://example.com/some/path/vendor/filename.zip",
+			InstallInband: installInband,
+			OEM:           oem,
+		}
+	}
+
+	testCases := []struct {
+		name           string
+		existing       *fleetdbapi.ComponentFirmwareVersion
+		newFirmware    *fleetdbapi.ComponentFirmwareVersion
+		expectedUpdate bool
+	}{
+		{"install inband differs", firmware(&no, &no), firmware(&yes, &no), true},
+		{"oem differs", firmware(&no, &no), firmware(&no, &yes), true},
+		{"oem set in the manifest only", firmware(nil, nil), firmware(&no, &yes), true},
+		{"same flags", firmware(&yes, &yes), firmware(&yes, &yes), false},
+		{"unset flags are false", firmware(nil, nil), firmware(&no, &no), false},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			var updated *fleetdbapi.ComponentFirmwareVersion
+
+			handler := http.NewServeMux()
+			handler.HandleFunc("/api/v1/server-component-firmwares", func(w http.ResponseWriter, _ *http.Request) {
+				handleGetFirmware(t, &testCase{existingFirmware: tt.existing}, w)
+			})
+			handler.HandleFunc("/api/v1/server-component-firmwares/"+idString, func(w http.ResponseWriter, r *http.Request) {
+				updated = &fleetdbapi.ComponentFirmwareVersion{}
+				if err := json.NewDecoder(r.Body).Decode(updated); err != nil {
+					t.Fatal(err)
+				}
+
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte("{}"))
+			})
+
+			mock := httptest.NewServer(handler)
+			defer mock.Close()
+
+			logger := logrus.New()
+			logger.Out = io.Discard
+
+			hss, err := New(context.Background(), &config.ServerserviceOptions{Endpoint: mock.URL, DisableOAuth: true}, artifactsURL, logger)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			newFirmware := *tt.newFirmware
+			newFirmware.UUID = uuid.Nil
+			newFirmware.RepositoryURL = ""
+
+			assert.NoError(t, hss.Publish(context.Background(), &newFirmware))
+
+			if !tt.expectedUpdate {
+				assert.Nil(t, updated)
+				return
+			}
+
+			if assert.NotNil(t, updated) {
+				assert.Equal(t, tt.newFirmware.InstallInband, updated.InstallInband)
+				assert.Equal(t, tt.newFirmware.OEM, updated.OEM)
+			}
+		})
+	}
+}
+
+func TestServerServicePublishBatch(t *testing.T) {
+	id, err := uuid.Parse(idString)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	existingFirmwares := []*fleetdbapi.ComponentFirmwareVersion{
+		{
+			// up to date
+			UUID:          uuid.New(),
+			Vendor:        "vendor",
+			Model:         []string{"model1"},
+			Filename:      "current.zip",
+			Version:       "1.0.0",
+			Component:     "bmc",
+			Checksum:      "1111",
+			UpstreamURL:   "http://some/location/current.zip",
+			RepositoryURL: "https://example.com/some/path/vendor/current.zip",
+		},
+		{
+			// outdated
+			UUID:          id,
+			Vendor:        "vendor",
+			Model:         []string{"model1"},
+			Filename:      "outdated.zip",
+			Version:       "1.0.0",
+			Component:     "bios",
+			Checksum:      "2222",
+			UpstreamURL:   "http://some/location/outdated.zip",
+			RepositoryURL: "https://example.com/some/path/vendor/outdated.zip",
+		},
+	}
+
+	newFirmwares := []*fleetdbapi.ComponentFirmwareVersion{
+		{
+			Vendor:      "vendor",
+			Model:       []string{"model1"},
+			Filename:    "current.zip",
+			Version:     "1.0.0",
+			Component:   "bmc",
+			Checksum:    "1111",
+			UpstreamURL: "http://some/location/current.zip",
+		},
+		{
+			Vendor:      "vendor",
+			Model:       []string{"model2"},
+			Filename:    "outdated.zip",
+			Version:     "1.0.0",
+			Component:   "bios",
+			Checksum:    "2222",
+			UpstreamURL: "http://some/location/outdated.zip",
+		},
+		{
+			Vendor:      "vendor",
+			Model:       []string{"model1"},
+			Filename:    "new.zip",
+			Version:     "2.0.0",
+			Component:   "nic",
+			Checksum:    "3333",
+			UpstreamURL: "http://some/location/new.zip",
+		},
+	}
+
+	var (
+		mutex   sync.Mutex
+		lists   []string
+		created []string
+		updated []string
+	)
+
+	handler := http.NewServeMux()
+	handler.HandleFunc(
+		"/api/v1/server-component-firmwares",
+		func(writer http.ResponseWriter, request *http.Request) {
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			switch request.Method {
+			case http.MethodGet:
+				lists = append(lists, request.URL.Query().Get("vendor"))
+				writeResponse(t, writer, &fleetdbapi.ServerResponse{Records: existingFirmwares})
+			case http.MethodPost:
+				fw := readFirmware(t, request)
+				created = append(created, fw.Filename)
+				writeResponse(t, writer, &fleetdbapi.ServerResponse{Slug: uuid.NewString()})
+			default:
+				t.Fatal("unexpected request method, got: " + request.Method)
+			}
+		},
+	)
+	handler.HandleFunc(
+		"/api/v1/server-component-firmwares/"+idString,
+		func(writer http.ResponseWriter, request *http.Request) {
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			if request.Method != http.MethodPut {
+				t.Fatal("unexpected request method, got: " + request.Method)
+			}
+
+			fw := readFirmware(t, request)
+			assert.Equal(t, []string{"model1", "model2"}, fw.Model)
+			updated = append(updated, fw.Filename)
+			writeResponse(t, writer, &fleetdbapi.ServerResponse{})
+		},
+	)
+
+	mock := httptest.NewServer(handler)
+	defer mock.Close()
+
+	cfg := config.ServerserviceOptions{
+		Endpoint:     mock.URL,
+		DisableOAuth: true,
+	}
+
+	logger := logrus.New()
+	logger.Out = io.Discard
+
+	hss, err := New(context.Background(), &cfg, artifactsURL, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = hss.PublishBatch(context.Background(), newFirmwares)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"vendor"}, lists)
+	assert.Equal(t, []string{"new.zip"}, created)
+	assert.Equal(t, []string{"outdated.zip"}, updated)
+}
+
+// Firmware sharing a checksum in a batch is reconciled in order, the first one creates the record
+// the next ones update, as successive Publish calls would.
+func TestServerServicePublishBatchSameChecksum(t *testing.T) {
+	newFirmwares := []*fleetdbapi.ComponentFirmwareVersion{
+		{Vendor: "vendor", Filename: "first.zip", Version: "1.0.0", Component: "bmc", Checksum: "4444"},
+		{Vendor: "vendor", Filename: "second.zip", Version: "1.0.0", Component: "bmc", Checksum: "4444"},
+	}
+
+	var (
+		mutex   sync.Mutex
+		created []string
+		updated []string
+	)
+
+	handler := http.NewServeMux()
+	handler.HandleFunc(
+		"/api/v1/server-component-firmwares",
+		func(writer http.ResponseWriter, request *http.Request) {
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			switch request.Method {
+			case http.MethodGet:
+				writeResponse(t, writer, &fleetdbapi.ServerResponse{Records: []*fleetdbapi.ComponentFirmwareVersion{}})
+			case http.MethodPost:
+				created = append(created, readFirmware(t, request).Filename)
+				writeResponse(t, writer, &fleetdbapi.ServerResponse{Slug: idString})
+			default:
+				t.Fatal("unexpected request method, got: " + request.Method)
+			}
+		},
+	)
+	handler.HandleFunc(
+		"/api/v1/server-component-firmwares/"+idString,
+		func(writer http.ResponseWriter, request *http.Request) {
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			if request.Method != http.MethodPut {
+				t.Fatal("unexpected request method, got: " + request.Method)
+			}
+
+			updated = append(updated, readFirmware(t, request).Filename)
+			writeResponse(t, writer, &fleetdbapi.ServerResponse{})
+		},
+	)
+
+	mock := httptest.NewServer(handler)
+	defer mock.Close()
+
+	logger := logrus.New()
+	logger.Out = io.Discard
+
+	hss, err := New(context.Background(), &config.ServerserviceOptions{Endpoint: mock.URL, DisableOAuth: true}, artifactsURL, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NoError(t, hss.PublishBatch(context.Background(), newFirmwares))
+	assert.Equal(t, []string{"first.zip"}, created)
+	assert.Equal(t, []string{"second.zip"}, updated)
+}
+
+// A firmware record published with another checksum the manifest declares, as an md5sum before a sha256
+// was added to the manifest, is updated to the published checksum instead of a new record being created.
+func TestServerServicePublishDeclaredChecksum(t *testing.T) {
+	id, err := uuid.Parse(idString)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	existing := &fleetdbapi.ComponentFirmwareVersion{
+		UUID:        id,
+		Vendor:      "vendor",
+		Filename:    "filename.zip",
+		Version:     "1.2.3",
+		Component:   "bmc",
+		Checksum:    "md5sum:1234",
+		UpstreamURL: "http://some/location",
+	}
+
+	checksums := config.FirmwareChecksums{"http://some/location": {"md5sum": "1234", "sha256": "5678"}}
+
+	testCases := []struct {
+		name    string
+		publish func(ServerService, *fleetdbapi.ComponentFirmwareVersion) error
+	}{
+		{
+			name: "publish",
+			publish: func(hss ServerService, fw *fleetdbapi.ComponentFirmwareVersion) error {
+				return hss.Publish(context.Background(), fw)
+			},
+		},
+		{
+			name: "publish batch",
+			publish: func(hss ServerService, fw *fleetdbapi.ComponentFirmwareVersion) error {
+				return hss.PublishBatch(context.Background(), []*fleetdbapi.ComponentFirmwareVersion{fw})
+			},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			var updated *fleetdbapi.ComponentFirmwareVersion
+
+			handler := http.NewServeMux()
+			handler.HandleFunc("/api/v1/server-component-firmwares", func(writer http.ResponseWriter, request *http.Request) {
+				if request.Method != http.MethodGet {
+					t.Fatal("unexpected request method, got: " + request.Method)
+				}
+
+				records := []*fleetdbapi.ComponentFirmwareVersion{}
+				if checksum := request.URL.Query().Get("checksum"); checksum == "" || checksum == existing.Checksum {
+					records = append(records, existing)
+				}
+
+				writeResponse(t, writer, &fleetdbapi.ServerResponse{Records: records})
+			})
+			handler.HandleFunc("/api/v1/server-component-firmwares/"+idString, func(writer http.ResponseWriter, request *http.Request) {
+				if request.Method != http.MethodPut {
+					t.Fatal("unexpected request method, got: " + request.Method)
+				}
+
+				updated = readFirmware(t, request)
+				writeResponse(t, writer, &fleetdbapi.ServerResponse{})
+			})
+
+			mock := httptest.NewServer(handler)
+			defer mock.Close()
+
+			logger := logrus.New()
+			logger.Out = io.Discard
+
+			hss, err := New(
+				context.Background(),
+				&config.ServerserviceOptions{Endpoint: mock.URL, DisableOAuth: true},
+				artifactsURL,
+				logger,
+				WithChecksums(checksums),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			newFirmware := *existing
+			newFirmware.UUID = uuid.Nil
+			newFirmware.Checksum = "sha256:5678"
+
+			assert.NoError(t, tt.publish(hss, &newFirmware))
+
+			if assert.NotNil(t, updated, "the existing record is updated") {
+				assert.Equal(t, id, updated.UUID)
+				assert.Equal(t, "sha256:5678", updated.Checksum)
+			}
+
+			// the record not updated yet is kept
+			assert.NoError(t, hss.Prune(context.Background(), []*fleetdbapi.ComponentFirmwareVersion{&newFirmware}))
+		})
+	}
+}
+
+// The firmware records are matched on their checksum, so firmware published after an artifacts URL change
+// still matches its record and every record is updated with the new repository URL.
+func TestServerServicePublishBatchArtifactsURLChange(t *testing.T) {
+	existingFirmwares := []*fleetdbapi.ComponentFirmwareVersion{
+		{
+			UUID:          uuid.New(),
+			Vendor:        "vendor",
+			Filename:      "bmc.zip",
+			Checksum:      "1111",
+			RepositoryURL: "https://old.example.com/firmware/vendor/bmc.zip",
+		},
+		{
+			UUID:          uuid.New(),
+			Vendor:        "vendor",
+			Filename:      "bios.zip",
+			Checksum:      "2222",
+			RepositoryURL: "https://old.example.com/firmware/vendor/bios.zip",
+		},
+	}
+
+	var (
+		mutex   sync.Mutex
+		updated = make(map[string]string)
+	)
+
+	handler := http.NewServeMux()
+	handler.HandleFunc(
+		"/api/v1/server-component-firmwares",
+		func(writer http.ResponseWriter, request *http.Request) {
+			if request.Method != http.MethodGet {
+				t.Fatal("unexpected request method, got: " + request.Method)
+			}
+
+			writeResponse(t, writer, &fleetdbapi.ServerResponse{Records: existingFirmwares})
+		},
+	)
+	handler.HandleFunc(
+		"/api/v1/server-component-firmwares/",
+		func(writer http.ResponseWriter, request *http.Request) {
+			if request.Method != http.MethodPut {
+				t.Fatal("unexpected request method, got: " + request.Method)
+			}
+
+			fw := readFirmware(t, request)
+
+			mutex.Lock()
+			updated[path.Base(request.URL.Path)] = fw.RepositoryURL
+			mutex.Unlock()
+
+			writeResponse(t, writer, &fleetdbapi.ServerResponse{})
+		},
+	)
+
+	mock := httptest.NewServer(handler)
+	defer mock.Close()
+
+	logger := logrus.New()
+	logger.Out = io.Discard
+
+	hss, err := New(context.Background(), &config.ServerserviceOptions{Endpoint: mock.URL, DisableOAuth: true}, artifactsURL, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newFirmwares := []*fleetdbapi.ComponentFirmwareVersion{
+		{Vendor: "vendor", Filename: "bmc.zip", Checksum: "1111"},
+		{Vendor: "vendor", Filename: "bios.zip", Checksum: "2222"},
+	}
+
+	assert.NoError(t, hss.PublishBatch(context.Background(), newFirmwares))
+
+	assert.Equal(t, map[string]string{
+		existingFirmwares[0].UUID.String(): "https://example.com/some/path/vendor/bmc.zip",
+		existingFirmwares[1].UUID.String(): "https://example.com/some/path/vendor/bios.zip",
+	}, updated)
+}
+
+func TestServerServicePublishBatchErrors(t *testing.T) {
+	handler := http.NewServeMux()
+	handler.HandleFunc(
+		"/api/v1/server-component-firmwares",
+		func(writer http.ResponseWriter, request *http.Request) {
+			switch request.Method {
+			case http.MethodGet:
+				writeResponse(t, writer, &fleetdbapi.ServerResponse{})
+			case http.MethodPost:
+				if readFirmware(t, request).Filename == "bad.zip" {
+					writer.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+
+				writeResponse(t, writer, &fleetdbapi.ServerResponse{Slug: uuid.NewString()})
+			}
+		},
+	)
+
+	mock := httptest.NewServer(handler)
+	defer mock.Close()
+
+	cfg := config.ServerserviceOptions{
+		Endpoint:     mock.URL,
+		DisableOAuth: true,
+	}
+
+	logger := logrus.New()
+	logger.Out = io.Discard
+
+	hss, err := New(context.Background(), &cfg, artifactsURL, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = hss.PublishBatch(context.Background(), []*fleetdbapi.ComponentFirmwareVersion{
+		{Vendor: "vendor", Filename: "good.zip", Checksum: "1111"},
+		{Vendor: "vendor", Filename: "bad.zip", Checksum: "2222"},
+	})
+
+	var publishErrors PublishErrors
+
+	assert.ErrorAs(t, err, &publishErrors)
+	assert.Len(t, publishErrors, 1)
+	assert.ErrorIs(t, publishErrors["vendor/bad.zip"], ErrServerServiceQuery)
+}
+
+func readFirmware(t *testing.T, request *http.Request) *fleetdbapi.ComponentFirmwareVersion {
+	b, err := io.ReadAll(request.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fw := &fleetdbapi.ComponentFirmwareVersion{}
+	if err = json.Unmarshal(b, fw); err != nil {
+		t.Fatal(err)
+	}
+
+	return fw
+}
+
+func writeResponse(t *testing.T, writer http.ResponseWriter, response *fleetdbapi.ServerResponse) {
+	writer.Header().Set("Content-Type", "application/json")
+
+	responseBytes, err := json.Marshal(response)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = writer.Write(responseBytes); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServerServicePrune(t *testing.T) {
+	staleID := uuid.New()
+
+	existingFirmwares := []*fleetdbapi.ComponentFirmwareVersion{
+		{UUID: uuid.New(), Vendor: "vendor", Filename: "one.zip", Checksum: "1111"},
+		{UUID: uuid.New(), Vendor: "vendor", Filename: "two.zip", Checksum: "2222"},
+		{UUID: staleID, Vendor: "vendor", Filename: "stale.zip", Checksum: "3333"},
+	}
+
+	manifestFirmwares := []*fleetdbapi.ComponentFirmwareVersion{
+		{Vendor: "vendor", Filename: "one.zip", Checksum: "1111"},
+		{Vendor: "vendor", Filename: "two.zip", Checksum: "2222"},
+	}
+
+	var deleted []string
+
+	handler := http.NewServeMux()
+	handler.HandleFunc(
+		"/api/v1/server-component-firmwares",
+		func(writer http.ResponseWriter, request *http.Request) {
+			if request.Method != http.MethodGet {
+				t.Fatal("unexpected request method, got: " + request.Method)
+			}
+
+			assert.Equal(t, "vendor", request.URL.Query().Get("vendor"))
+			writeResponse(t, writer, &fleetdbapi.ServerResponse{Records: existingFirmwares})
+		},
+	)
+	handler.HandleFunc(
+		"/api/v1/server-component-firmwares/",
+		func(writer http.ResponseWriter, request *http.Request) {
+			if request.Method != http.MethodDelete {
+				t.Fatal("unexpected request method, got: " + request.Method)
+			}
+
+			deleted = append(deleted, path.Base(request.URL.Path))
+			writeResponse(t, writer, &fleetdbapi.ServerResponse{})
+		},
+	)
+
+	mock := httptest.NewServer(handler)
+	defer mock.Close()
+
+	cfg := config.ServerserviceOptions{
+		Endpoint:     mock.URL,
+		DisableOAuth: true,
+	}
+
+	logger := logrus.New()
+	logger.Out = io.Discard
+
+	hss, err := New(context.Background(), &cfg, artifactsURL, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.ErrorIs(t, hss.Prune(context.Background(), nil), ErrPruneEmptyManifest)
+	assert.Empty(t, deleted)
+
+	assert.NoError(t, hss.Prune(context.Background(), manifestFirmwares))
+	assert.Equal(t, []string{staleID.String()}, deleted)
+}
+
+func TestServerServicePrunePaginated(t *testing.T) {
+	staleID := uuid.New()
+
+	pages := map[string][]*fleetdbapi.ComponentFirmwareVersion{
+		"1": {
+			{UUID: uuid.New(), Vendor: "vendor", Filename: "one.zip", Checksum: "1111"},
+		},
+		"2": {
+			{UUID: uuid.New(), Vendor: "vendor", Filename: "two.zip", Checksum: "2222"},
+			{UUID: staleID, Vendor: "vendor", Filename: "stale.zip", Checksum: "3333"},
+		},
+	}
+
+	manifestFirmwares := []*fleetdbapi.ComponentFirmwareVersion{
+		{Vendor: "vendor", Filename: "one.zip", Checksum: "1111"},
+		{Vendor: "vendor", Filename: "two.zip", Checksum: "2222"},
+	}
+
+	var requestedPages, deleted []string
+
+	handler := http.NewServeMux()
+	handler.HandleFunc(
+		"/api/v1/server-component-firmwares",
+		func(writer http.ResponseWriter, request *http.Request) {
+			page := request.URL.Query().Get("page")
+			requestedPages = append(requestedPages, page)
+
+			response := &fleetdbapi.ServerResponse{Records: pages[page], TotalPages: len(pages)}
+			if page == "1" {
+				response.Links.Next = &fleetdbapi.Link{Href: "/api/v1/server-component-firmwares?page=2"}
+			}
+
+			writeResponse(t, writer, response)
+		},
+	)
+	handler.HandleFunc(
+		"/api/v1/server-component-firmwares/",
+		func(writer http.ResponseWriter, request *http.Request) {
+			deleted = append(deleted, path.Base(request.URL.Path))
+			writeResponse(t, writer, &fleetdbapi.ServerResponse{})
+		},
+	)
+
+	mock := httptest.NewServer(handler)
+	defer mock.Close()
+
+	cfg := config.ServerserviceOptions{
+		Endpoint:     mock.URL,
+		DisableOAuth: true,
+	}
+
+	logger := logrus.New()
+	logger.Out = io.Discard
+
+	hss, err := New(context.Background(), &cfg, artifactsURL, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the firmware on the second page is accounted for
+	assert.NoError(t, hss.Prune(context.Background(), manifestFirmwares))
+	assert.Equal(t, []string{"1", "2"}, requestedPages)
+	assert.Equal(t, []string{staleID.String()}, deleted)
+}
+
+func TestServerServicePublishDuplicates(t *testing.T) {
+	canonicalID := uuid.New()
+	createdAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	duplicates := []*fleetdbapi.ComponentFirmwareVersion{
+		{
+			UUID:      uuid.New(),
+			Vendor:    "vendor",
+			Filename:  "other.zip",
+			Version:   "1.2.3",
+			Checksum:  "1234",
+			CreatedAt: createdAt,
+		},
+		{
+			UUID:      canonicalID,
+			Vendor:    "vendor",
+			Filename:  "filename.zip",
+			Version:   "1.2.3",
+			Checksum:  "1234",
+			CreatedAt: createdAt.Add(time.Hour),
+		},
+	}
+
+	newFirmware := func() *fleetdbapi.ComponentFirmwareVersion {
+		return &fleetdbapi.ComponentFirmwareVersion{
+			Vendor:      "vendor",
+			Model:       []string{"model1"},
+			Filename:    "filename.zip",
+			Version:     "1.2.3",
+			Component:   "bmc",
+			Checksum:    "1234",
+			UpstreamURL: "http://some/location",
+		}
+	}
+
+	testCases := []struct {
+		name        string
+		opts        []Option
+		expectedErr error
+		expectedPut string
+	}{
+		{
+			name:        "strict",
+			expectedErr: ErrServerServiceDuplicateFirmware,
+		},
+		{
+			name:        "recover",
+			opts:        []Option{WithDuplicateRecovery()},
+			expectedPut: canonicalID.String(),
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			var updated []string
+
+			handler := http.NewServeMux()
+			handler.HandleFunc(
+				"/api/v1/server-component-firmwares",
+				func(writer http.ResponseWriter, _ *http.Request) {
+					writeResponse(t, writer, &fleetdbapi.ServerResponse{Records: duplicates})
+				},
+			)
+			handler.HandleFunc(
+				"/api/v1/server-component-firmwares/",
+				func(writer http.ResponseWriter, request *http.Request) {
+					assert.Equal(t, http.MethodPut, request.Method)
+
+					updated = append(updated, path.Base(request.URL.Path))
+					writeResponse(t, writer, &fleetdbapi.ServerResponse{})
+				},
+			)
+
+			mock := httptest.NewServer(handler)
+			defer mock.Close()
+
+			cfg := config.ServerserviceOptions{
+				Endpoint:     mock.URL,
+				DisableOAuth: true,
+			}
+
+			logger := logrus.New()
+			logger.Out = io.Discard
+
+			hss, err := New(context.Background(), &cfg, artifactsURL, logger, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = hss.Publish(context.Background(), newFirmware())
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				assert.Empty(t, updated)
+
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, []string{tt.expectedPut}, updated)
+		})
+	}
+}
+
+// newOidcServer returns an OIDC issuer whose discovery endpoint fails discoveryFailures times before succeeding,
+// and whose token endpoint issues tokens that are immediately considered expired.
+func newOidcServer(t *testing.T, discoveryFailures int) (server *httptest.Server, tokensIssued *atomic.Int32) {
+	tokensIssued = &atomic.Int32{}
+	discoveryAttempts := 0
+
+	handler := http.NewServeMux()
+	handler.HandleFunc("/.well-known/openid-configuration", func(writer http.ResponseWriter, _ *http.Request) {
+		discoveryAttempts++
+		if discoveryAttempts <= discoveryFailures {
+			writer.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		writer.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(writer, `{"issuer":%q,"token_endpoint":%q,"jwks_uri":%q}`,
+			server.URL, server.URL+"/token", server.URL+"/jwks")
+	})
+	handler.HandleFunc("/token", func(writer http.ResponseWriter, _ *http.Request) {
+		n := tokensIssued.Add(1)
+
+		writer.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(writer, `{"access_token":"token-%d","token_type":"Bearer","expires_in":1}`, n)
+	})
+
+	server = httptest.NewServer(handler)
+
+	return server, tokensIssued
+}
+
+func TestServerServiceOAuth(t *testing.T) {
+	oidcDiscoveryBackoff = time.Millisecond
+
+	oidcServer, tokensIssued := newOidcServer(t, 1)
+	defer oidcServer.Close()
+
+	var authHeaders []string
+
+	handler := http.NewServeMux()
+	handler.HandleFunc(
+		"/api/v1/server-component-firmwares",
+		func(writer http.ResponseWriter, request *http.Request) {
+			authHeaders = append(authHeaders, request.Header.Get("Authorization"))
+			writeResponse(t, writer, &fleetdbapi.ServerResponse{})
+		},
+	)
+
+	mock := httptest.NewServer(handler)
+	defer mock.Close()
+
+	endpointURL, err := url.Parse(mock.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.ServerserviceOptions{
+		EndpointURL:          endpointURL,
+		Endpoint:             mock.URL,
+		OidcIssuerEndpoint:   oidcServer.URL,
+		OidcAudienceEndpoint: mock.URL,
+		OidcClientID:         "client-id",
+		OidcClientSecret:     "client-secret",
+		OidcClientScopes:     []string{"read", "write"},
+		OidcDiscoveryTimeout: 5 * time.Second,
+	}
+
+	logger := logrus.New()
+	logger.Out = io.Discard
+
+	// discovery fails once and is retried
+	hss, err := New(context.Background(), &cfg, artifactsURL, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// expired tokens are transparently refreshed
+	firmwares := []*fleetdbapi.ComponentFirmwareVersion{{Vendor: "vendor", Checksum: "1234"}}
+	assert.NoError(t, hss.Prune(context.Background(), firmwares))
+	assert.NoError(t, hss.Prune(context.Background(), firmwares))
+
+	assert.Equal(t, int32(2), tokensIssued.Load())
+	assert.Equal(t, []string{"Bearer token-1", "Bearer token-2"}, authHeaders)
+}
+
+func TestServerServiceOAuthDiscoveryTimeout(t *testing.T) {
+	oidcDiscoveryBackoff = time.Millisecond
+
+	oidcServer, _ := newOidcServer(t, math.MaxInt)
+	defer oidcServer.Close()
+
+	cfg := config.ServerserviceOptions{
+		EndpointURL:          &url.URL{},
+		OidcIssuerEndpoint:   oidcServer.URL,
+		OidcDiscoveryTimeout: 50 * time.Millisecond,
+	}
+
+	logger := logrus.New()
+	logger.Out = io.Discard
+
+	_, err := New(context.Background(), &cfg, artifactsURL, logger)
+	assert.ErrorIs(t, err, ErrOidcDiscovery)
+}
+
+func TestServerServiceStaticToken(t *testing.T) {
+	var authHeaders []string
+
+	handler := http.NewServeMux()
+	handler.HandleFunc(
+		"/api/v1/server-component-firmwares",
+		func(writer http.ResponseWriter, request *http.Request) {
+			authHeaders = append(authHeaders, request.Header.Get("Authorization"))
+			writeResponse(t, writer, &fleetdbapi.ServerResponse{})
+		},
+	)
+
+	mock := httptest.NewServer(handler)
+	defer mock.Close()
+
+	endpointURL, err := url.Parse(mock.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the static token takes precedence over OAuth, the OIDC issuer is never contacted
+	cfg := config.ServerserviceOptions{
+		EndpointURL:        endpointURL,
+		Endpoint:           mock.URL,
+		OidcIssuerEndpoint: "http://oidc.invalid",
+		DisableOAuth:       true,
+		StaticToken:        "service-token",
+	}
+
+	logger := logrus.New()
+	logger.Out = io.Discard
+
+	hss, err := New(context.Background(), &cfg, artifactsURL, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	firmwares := []*fleetdbapi.ComponentFirmwareVersion{{Vendor: "vendor", Checksum: "1234"}}
+	assert.NoError(t, hss.Prune(context.Background(), firmwares))
+
+	assert.Equal(t, []string{"bearer service-token"}, authHeaders)
+}
+
+func TestServerServicePublishLookupCache(t *testing.T) {
+	id, err := uuid.Parse(idString)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		lists   atomic.Int32
+		updated *fleetdbapi.ComponentFirmwareVersion
+	)
+
+	handler := http.NewServeMux()
+	handler.HandleFunc("/api/v1/server-component-firmwares", func(writer http.ResponseWriter, request *http.Request) {
+		switch request.Method {
+		case http.MethodGet:
+			lists.Add(1)
+			writeResponse(t, writer, &fleetdbapi.ServerResponse{})
+		case http.MethodPost:
+			writeResponse(t, writer, &fleetdbapi.ServerResponse{Slug: idString})
+		default:
+			t.Fatal("unexpected request method, got: " + request.Method)
+		}
+	})
+	handler.HandleFunc("/api/v1/server-component-firmwares/"+idString, func(writer http.ResponseWriter, request *http.Request) {
+		updated = readFirmware(t, request)
+		writeResponse(t, writer, &fleetdbapi.ServerResponse{})
+	})
+
+	mock := httptest.NewServer(handler)
+	defer mock.Close()
+
+	logger := logrus.New()
+	logger.Out = io.Discard
+
+	hss, err := New(context.Background(), &config.ServerserviceOptions{Endpoint: mock.URL, DisableOAuth: true}, artifactsURL, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the same binary listed under two models
+	newFirmware := func(model string) *fleetdbapi.ComponentFirmwareVersion {
+		return &fleetdbapi.ComponentFirmwareVersion{
+			Vendor:      "vendor",
+			Model:       []string{model},
+			Filename:    "filename.zip",
+			Version:     "1.2.3",
+			Component:   "bmc",
+			Checksum:    "1234",
+			UpstreamURL: "http://some/location",
+		}
+	}
+
+	assert.NoError(t, hss.Publish(context.Background(), newFirmware("model1")))
+	assert.NoError(t, hss.Publish(context.Background(), newFirmware("model2")))
+
+	assert.Equal(t, int32(1), lists.Load(), "the firmware is listed once per checksum")
+
+	if assert.NotNil(t, updated, "the firmware created by the first publish is updated") {
+		assert.Equal(t, id, updated.UUID)
+		assert.Equal(t, []string{"model1", "model2"}, updated.Model)
+	}
 }
@@ -1,10 +1,11 @@
 // Code generated by MockGen. DO NOT EDIT.
-// Source: serverservice.go
+// Source: fleetdb.go
 //
 // Generated by this command:
 //
-//	mockgen -source=serverservice.go -destination=mocks/serverservice.go ServerService
+//	mockgen -source=fleetdb.go -destination=mocks/fleetdb.go ServerService
 //
+
 // Package mock_inventory is a generated GoMock package.
 package mock_inventory
 
@@ -20,6 +21,7 @@ import (
 type MockServerService struct {
 	ctrl     *gomock.Controller
 	recorder *MockServerServiceMockRecorder
+	isgomock struct{}
 }
 
 // MockServerServiceMockRecorder is the mock recorder for MockServerService.
@@ -39,6 +41,20 @@ func (m *MockServerService) EXPECT() *MockServerServiceMockRecorder {
 	return m.recorder
 }
 
+// Prune mocks base method.
+func (m *MockServerService) Prune(ctx context.Context, keep []*fleetdbapi.ComponentFirmwareVersion) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Prune", ctx, keep)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Prune indicates an expected call of Prune.
+func (mr *MockServerServiceMockRecorder) Prune(ctx, keep any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Prune", reflect.TypeOf((*MockServerService)(nil).Prune), ctx, keep)
+}
+
 // Publish mocks base method.
 func (m *MockServerService) Publish(ctx context.Context, newFirmware *fleetdbapi.ComponentFirmwareVersion) error {
 	m.ctrl.T.Helper()
@@ -52,3 +68,17 @@ func (mr *MockServerServiceMockRecorder) Publish(ctx, newFirmware any) *gomock.C
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Publish", reflect.TypeOf((*MockServerService)(nil).Publish), ctx, newFirmware)
 }
+
+// PublishBatch mocks base method.
+func (m *MockServerService) PublishBatch(ctx context.Context, firmwares []*fleetdbapi.ComponentFirmwareVersion) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PublishBatch", ctx, firmwares)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PublishBatch indicates an expected call of PublishBatch.
+func (mr *MockServerServiceMockRecorder) PublishBatch(ctx, firmwares any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PublishBatch", reflect.TypeOf((*MockServerService)(nil).PublishBatch), ctx, firmwares)
+}
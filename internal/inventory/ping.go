@@ -0,0 +1,27 @@
+package inventory
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	fleetdbapi "github.com/metal-toolbox/fleetdb/pkg/api/v1"
+)
+
+// Pinger checks the inventory is reachable with the configured credentials, it's implemented by the ServerService New returns.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Ping lists a single firmware, an error is returned when the inventory can't be queried.
+func (s *serverService) Ping(ctx context.Context) error {
+	params := &fleetdbapi.ComponentFirmwareVersionListParams{
+		Pagination: &fleetdbapi.PaginationParams{Limit: 1, Page: 1},
+	}
+
+	if _, _, err := s.client.ListServerComponentFirmware(ctx, params); err != nil {
+		return errors.Wrap(ErrServerServiceQuery, "ListServerComponentFirmware: "+err.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,53 @@
+package inventory
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	fleetdbapi "github.com/metal-toolbox/fleetdb/pkg/api/v1"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/metal-toolbox/firmware-syncer/internal/config"
+)
+
+func TestServerServicePing(t *testing.T) {
+	status := http.StatusOK
+
+	handler := http.NewServeMux()
+	handler.HandleFunc(
+		"/api/v1/server-component-firmwares",
+		func(writer http.ResponseWriter, request *http.Request) {
+			assert.Equal(t, "1", request.URL.Query().Get("limit"))
+
+			if status != http.StatusOK {
+				writer.WriteHeader(status)
+				return
+			}
+
+			writeResponse(t, writer, &fleetdbapi.ServerResponse{})
+		},
+	)
+
+	mock := httptest.NewServer(handler)
+	defer mock.Close()
+
+	logger := logrus.New()
+	logger.Out = io.Discard
+
+	hss, err := New(context.Background(), &config.ServerserviceOptions{Endpoint: mock.URL, DisableOAuth: true}, artifactsURL, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pinger, ok := hss.(Pinger)
+	assert.True(t, ok)
+
+	assert.NoError(t, pinger.Ping(context.Background()))
+
+	status = http.StatusUnauthorized
+	assert.ErrorIs(t, pinger.Ping(context.Background()), ErrServerServiceQuery)
+}
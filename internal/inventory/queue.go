@@ -0,0 +1,240 @@
+package inventory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	fleetdbapi "github.com/metal-toolbox/fleetdb/pkg/api/v1"
+)
+
+// defaultQueueFlushInterval is used when no flush interval is configured.
+const defaultQueueFlushInterval = 5 * time.Second
+
+var ErrQueueClosed = errors.New("inventory write-behind queue is closed")
+
+// WriteBehindQueue is a ServerService which buffers published firmware
+// and flushes them in batches to the wrapped ServerService with PublishBatch.
+//
+// Publish blocks when the queue is full, so producers are slowed down instead of firmware being dropped.
+// Close must be called to flush the buffered firmware on shutdown.
+type WriteBehindQueue struct {
+	ctx           context.Context
+	inner         ServerService
+	logger        *logrus.Logger
+	queue         chan *fleetdbapi.ComponentFirmwareVersion
+	flushRequests chan chan struct{}
+	done          chan struct{}
+	batchSize     int
+	flushInterval time.Duration
+
+	// closeMutex guards sends on the queue against it being closed
+	closeMutex sync.RWMutex
+	closed     bool
+
+	errMutex sync.Mutex
+	errs     PublishErrors
+}
+
+// NewWriteBehindQueue returns a WriteBehindQueue buffering up to size firmware,
+// which are published to inner in batches of batchSize or every flushInterval.
+//
+// The ctx is used for publishing, it is not cancelled on shutdown so buffered firmware can still be flushed.
+func NewWriteBehindQueue(
+	ctx context.Context,
+	inner ServerService,
+	logger *logrus.Logger,
+	size, batchSize int,
+	flushInterval time.Duration,
+) *WriteBehindQueue {
+	if batchSize < 1 {
+		batchSize = 1
+	}
+
+	if flushInterval <= 0 {
+		flushInterval = defaultQueueFlushInterval
+	}
+
+	q := &WriteBehindQueue{
+		ctx:           context.WithoutCancel(ctx),
+		inner:         inner,
+		logger:        logger,
+		queue:         make(chan *fleetdbapi.ComponentFirmwareVersion, size),
+		flushRequests: make(chan chan struct{}),
+		done:          make(chan struct{}),
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		errs:          PublishErrors{},
+	}
+
+	go q.run()
+
+	return q
+}
+
+// Publish queues the firmware to be published, blocking while the queue is full.
+func (q *WriteBehindQueue) Publish(ctx context.Context, newFirmware *fleetdbapi.ComponentFirmwareVersion) error {
+	q.closeMutex.RLock()
+	defer q.closeMutex.RUnlock()
+
+	if q.closed {
+		return ErrQueueClosed
+	}
+
+	select {
+	case q.queue <- newFirmware:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// PublishBatch flushes the queue and publishes the given firmwares right away.
+func (q *WriteBehindQueue) PublishBatch(ctx context.Context, firmwares []*fleetdbapi.ComponentFirmwareVersion) error {
+	if err := q.Flush(ctx); err != nil {
+		return err
+	}
+
+	return q.inner.PublishBatch(ctx, firmwares)
+}
+
+// Prune flushes the queue before pruning, so firmware still in the queue is accounted for.
+func (q *WriteBehindQueue) Prune(ctx context.Context, keep []*fleetdbapi.ComponentFirmwareVersion) error {
+	if err := q.Flush(ctx); err != nil {
+		return err
+	}
+
+	return q.inner.Prune(ctx, keep)
+}
+
+// Flush publishes the firmware buffered in the queue and waits for it to complete.
+func (q *WriteBehindQueue) Flush(ctx context.Context) error {
+	flushed := make(chan struct{})
+
+	select {
+	case q.flushRequests <- flushed:
+	case <-q.done:
+		return ErrQueueClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case <-flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Inner returns the wrapped ServerService.
+func (q *WriteBehindQueue) Inner() ServerService {
+	return q.inner
+}
+
+// Close stops accepting firmware, flushes the buffered firmware and waits for it to be published.
+// The errors from all the flushed batches are returned as PublishErrors.
+func (q *WriteBehindQueue) Close() error {
+	q.closeMutex.Lock()
+
+	if !q.closed {
+		q.closed = true
+		close(q.queue)
+	}
+
+	q.closeMutex.Unlock()
+
+	<-q.done
+
+	q.errMutex.Lock()
+	defer q.errMutex.Unlock()
+
+	if len(q.errs) > 0 {
+		return q.errs
+	}
+
+	return nil
+}
+
+func (q *WriteBehindQueue) run() {
+	defer close(q.done)
+
+	ticker := time.NewTicker(q.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*fleetdbapi.ComponentFirmwareVersion, 0, q.batchSize)
+
+	for {
+		select {
+		case fw, ok := <-q.queue:
+			if !ok {
+				q.flush(batch)
+				return
+			}
+
+			batch = append(batch, fw)
+			if len(batch) >= q.batchSize {
+				batch = q.flush(batch)
+			}
+		case <-ticker.C:
+			batch = q.flush(batch)
+		case flushed := <-q.flushRequests:
+			batch = q.flush(q.drain(batch))
+			close(flushed)
+		}
+	}
+}
+
+// drain appends the firmware currently buffered in the queue to the batch.
+func (q *WriteBehindQueue) drain(batch []*fleetdbapi.ComponentFirmwareVersion) []*fleetdbapi.ComponentFirmwareVersion {
+	for {
+		select {
+		case fw, ok := <-q.queue:
+			if !ok {
+				return batch
+			}
+
+			batch = append(batch, fw)
+		default:
+			return batch
+		}
+	}
+}
+
+// flush publishes the batch, recording any errors, and returns the emptied batch.
+func (q *WriteBehindQueue) flush(batch []*fleetdbapi.ComponentFirmwareVersion) []*fleetdbapi.ComponentFirmwareVersion {
+	if len(batch) == 0 {
+		return batch
+	}
+
+	q.logger.WithField("count", len(batch)).Debug("Flushing inventory write-behind queue")
+
+	err := q.inner.PublishBatch(q.ctx, batch)
+	if err != nil {
+		q.logger.WithError(err).Error("Failed to publish firmware batch")
+		q.recordError(batch, err)
+	}
+
+	return batch[:0]
+}
+
+func (q *WriteBehindQueue) recordError(batch []*fleetdbapi.ComponentFirmwareVersion, err error) {
+	q.errMutex.Lock()
+	defer q.errMutex.Unlock()
+
+	var publishErrors PublishErrors
+	if errors.As(err, &publishErrors) {
+		for key, fwErr := range publishErrors {
+			q.errs[key] = fwErr
+		}
+
+		return
+	}
+
+	for _, fw := range batch {
+		q.errs[publishKey(fw)] = err
+	}
+}
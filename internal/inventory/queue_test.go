@@ -0,0 +1,181 @@
+package inventory
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	fleetdbapi "github.com/metal-toolbox/fleetdb/pkg/api/v1"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+
+	mock_inventory "github.com/metal-toolbox/firmware-syncer/internal/inventory/mocks"
+)
+
+func newQueueFirmware(i int) *fleetdbapi.ComponentFirmwareVersion {
+	return &fleetdbapi.ComponentFirmwareVersion{
+		Vendor:   "dell",
+		Filename: fmt.Sprintf("firmware-%d.bin", i),
+	}
+}
+
+// recordBatches makes the mock record the size of each published batch.
+func recordBatches(inner *mock_inventory.MockServerService, delay time.Duration) func() []int {
+	var mutex sync.Mutex
+
+	var sizes []int
+
+	inner.EXPECT().
+		PublishBatch(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, firmwares []*fleetdbapi.ComponentFirmwareVersion) error {
+			time.Sleep(delay)
+
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			sizes = append(sizes, len(firmwares))
+
+			return nil
+		}).
+		AnyTimes()
+
+	return func() []int {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		return sizes
+	}
+}
+
+func TestWriteBehindQueueBatches(t *testing.T) {
+	ctx := context.Background()
+	inner := mock_inventory.NewMockServerService(gomock.NewController(t))
+	batches := recordBatches(inner, 0)
+
+	queue := NewWriteBehindQueue(ctx, inner, logrus.New(), 10, 3, time.Hour)
+
+	for i := 0; i < 7; i++ {
+		assert.Nil(t, queue.Publish(ctx, newQueueFirmware(i)))
+	}
+
+	// the remaining firmware is only published on shutdown
+	assert.Eventually(t, func() bool { return len(batches()) == 2 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []int{3, 3}, batches())
+
+	assert.Nil(t, queue.Close())
+	assert.Equal(t, []int{3, 3, 1}, batches())
+
+	assert.ErrorIs(t, queue.Publish(ctx, newQueueFirmware(7)), ErrQueueClosed)
+}
+
+func TestWriteBehindQueueFlushInterval(t *testing.T) {
+	ctx := context.Background()
+	inner := mock_inventory.NewMockServerService(gomock.NewController(t))
+	batches := recordBatches(inner, 0)
+
+	queue := NewWriteBehindQueue(ctx, inner, logrus.New(), 10, 5, 10*time.Millisecond)
+
+	assert.Nil(t, queue.Publish(ctx, newQueueFirmware(0)))
+	assert.Eventually(t, func() bool { return len(batches()) == 1 }, time.Second, 10*time.Millisecond)
+
+	assert.Nil(t, queue.Close())
+	assert.Equal(t, []int{1}, batches())
+}
+
+func TestWriteBehindQueueBackpressure(t *testing.T) {
+	ctx := context.Background()
+	inner := mock_inventory.NewMockServerService(gomock.NewController(t))
+	batches := recordBatches(inner, 5*time.Millisecond)
+
+	queue := NewWriteBehindQueue(ctx, inner, logrus.New(), 1, 2, time.Hour)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+			assert.Nil(t, queue.Publish(ctx, newQueueFirmware(i)))
+		}(i)
+	}
+
+	wg.Wait()
+	assert.Nil(t, queue.Close())
+
+	published := 0
+	for _, size := range batches() {
+		assert.LessOrEqual(t, size, 2)
+		published += size
+	}
+
+	assert.Equal(t, 20, published)
+}
+
+func TestWriteBehindQueueBackpressureCancelled(t *testing.T) {
+	inner := mock_inventory.NewMockServerService(gomock.NewController(t))
+	blocked := make(chan struct{})
+
+	inner.EXPECT().
+		PublishBatch(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(context.Context, []*fleetdbapi.ComponentFirmwareVersion) error {
+			<-blocked
+			return nil
+		}).
+		AnyTimes()
+
+	queue := NewWriteBehindQueue(context.Background(), inner, logrus.New(), 1, 1, time.Hour)
+
+	// the first firmware is being published, the second fills up the queue
+	assert.Nil(t, queue.Publish(context.Background(), newQueueFirmware(0)))
+	assert.Nil(t, queue.Publish(context.Background(), newQueueFirmware(1)))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	assert.ErrorIs(t, queue.Publish(ctx, newQueueFirmware(2)), context.DeadlineExceeded)
+
+	close(blocked)
+	assert.Nil(t, queue.Close())
+}
+
+func TestWriteBehindQueueErrors(t *testing.T) {
+	ctx := context.Background()
+	inner := mock_inventory.NewMockServerService(gomock.NewController(t))
+
+	firmware := newQueueFirmware(0)
+	publishErr := fmt.Errorf("fleetdb unavailable")
+
+	inner.EXPECT().PublishBatch(gomock.Any(), gomock.Len(1)).Return(publishErr)
+
+	queue := NewWriteBehindQueue(ctx, inner, logrus.New(), 10, 10, time.Hour)
+	assert.Nil(t, queue.Publish(ctx, firmware))
+
+	err := queue.Close()
+
+	var publishErrors PublishErrors
+	assert.ErrorAs(t, err, &publishErrors)
+	assert.Equal(t, PublishErrors{publishKey(firmware): publishErr}, publishErrors)
+}
+
+func TestWriteBehindQueuePrune(t *testing.T) {
+	ctx := context.Background()
+	inner := mock_inventory.NewMockServerService(gomock.NewController(t))
+
+	keep := []*fleetdbapi.ComponentFirmwareVersion{newQueueFirmware(0)}
+
+	// queued firmware is flushed before pruning
+	gomock.InOrder(
+		inner.EXPECT().PublishBatch(gomock.Any(), gomock.Len(1)).Return(nil),
+		inner.EXPECT().Prune(gomock.Any(), keep).Return(nil),
+	)
+
+	queue := NewWriteBehindQueue(ctx, inner, logrus.New(), 10, 10, time.Hour)
+
+	assert.Nil(t, queue.Publish(ctx, newQueueFirmware(0)))
+	assert.Nil(t, queue.Prune(ctx, keep))
+	assert.Nil(t, queue.Close())
+}
@@ -14,6 +14,24 @@ var (
 
 	// SyncErrorsCounter metric measures the number of errors during update sync operations
 	SyncErrorsCounter *prometheus.CounterVec
+
+	// ArchiveBytesCounter metric measures the size of downloaded firmware archives
+	ArchiveBytesCounter *prometheus.CounterVec
+
+	// ExtractedBytesCounter metric measures the size of firmware extracted from archives
+	ExtractedBytesCounter *prometheus.CounterVec
+
+	// SuspiciousArchiveCounter metric measures the number of archives with an extreme compression ratio
+	SuspiciousArchiveCounter *prometheus.CounterVec
+
+	// QuarantinedArchiveCounter metric measures the number of corrupt archives quarantined
+	QuarantinedArchiveCounter *prometheus.CounterVec
+
+	// OversizedFirmwareCounter metric measures the number of firmware skipped for exceeding the maximum file size
+	OversizedFirmwareCounter *prometheus.CounterVec
+
+	// ChecksumMismatchCounter metric measures the number of firmware skipped for not matching the manifest checksums
+	ChecksumMismatchCounter *prometheus.CounterVec
 )
 
 func init() {
@@ -45,6 +63,58 @@ func init() {
 	},
 		labelsSync,
 	)
+
+	// labelsArchive are labels included in the Archive* metrics
+	// vendor: the hardware vendor
+	labelsArchive := []string{"vendor"}
+
+	// ArchiveBytesCounter metric measures downloaded archive bytes
+	ArchiveBytesCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "archive_bytes",
+		Help: "A counter metric for the size of downloaded firmware archives",
+	},
+		labelsArchive,
+	)
+
+	// ExtractedBytesCounter metric measures bytes extracted from archives
+	ExtractedBytesCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "archive_extracted_bytes",
+		Help: "A counter metric for the size of firmware extracted from archives",
+	},
+		labelsArchive,
+	)
+
+	// SuspiciousArchiveCounter metric measures archives flagged for their compression ratio
+	SuspiciousArchiveCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "archive_suspicious_ratio",
+		Help: "A counter metric for archives with an extreme extracted to compressed size ratio",
+	},
+		labelsArchive,
+	)
+
+	// QuarantinedArchiveCounter metric measures corrupt archives moved to quarantine
+	QuarantinedArchiveCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "archive_quarantined",
+		Help: "A counter metric for corrupt archives moved to quarantine",
+	},
+		labelsArchive,
+	)
+
+	// OversizedFirmwareCounter metric measures firmware skipped for its size
+	OversizedFirmwareCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "firmware_oversized_skipped",
+		Help: "A counter metric for firmware skipped for exceeding the maximum file size",
+	},
+		labelsArchive,
+	)
+
+	// ChecksumMismatchCounter metric measures firmware failing its checksum validation
+	ChecksumMismatchCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "firmware_checksum_mismatch",
+		Help: "A counter metric for firmware skipped for not matching the manifest checksums",
+	},
+		labelsArchive,
+	)
 }
 
 // UpdateSyncLabels is a helper method to return labels included in a update sync prometheus metric
@@ -56,3 +126,12 @@ func UpdateSyncLabels(deviceVendor, actionKind string) prometheus.Labels {
 		"actionKind": actionKind,
 	}
 }
+
+// ArchiveLabels is a helper method to return labels included in the archive prometheus metrics
+//
+// The cardinality of the labels returned here must match the ones defined in init()
+func ArchiveLabels(deviceVendor string) prometheus.Labels {
+	return prometheus.Labels{
+		"vendor": deviceVendor,
+	}
+}
@@ -0,0 +1,113 @@
+package ami
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/metal-toolbox/firmware-syncer/internal/vendors"
+
+	fleetdbapi "github.com/metal-toolbox/fleetdb/pkg/api/v1"
+)
+
+// capsuleExtensions are the extensions of the AMI Aptio BIOS images, capsules and raw ROMs
+var capsuleExtensions = []string{".cap", ".rom"}
+
+type Downloader struct {
+	logger *logrus.Logger
+}
+
+// NewAMIDownloader creates a new Downloader for the AMI Aptio BIOS of whitebox servers.
+func NewAMIDownloader(logger *logrus.Logger) vendors.Downloader {
+	return &Downloader{logger: logger}
+}
+
+// Download will download the BIOS package for the given firmware to the given downloadDir,
+// and will return the full path to the BIOS image extracted from the package.
+//
+// The packages are zips holding the image next to the flash utilities and release notes,
+// the image is the archive entry named after the firmware filename, or else the only .cap or .rom entry.
+func (d *Downloader) Download(ctx context.Context, downloadDir string, firmware *fleetdbapi.ComponentFirmwareVersion) (*vendors.FirmwareFile, error) {
+	downloadDir, err := vendors.FirmwareDownloadDir(downloadDir, firmware)
+	if err != nil {
+		return nil, err
+	}
+
+	archivePath, err := vendors.DownloadFirmwareArchive(ctx, downloadDir, firmware.UpstreamURL, "")
+	if err != nil {
+		return nil, err
+	}
+
+	// images are sometimes published as is
+	if isCapsule(archivePath) {
+		if firmware.Checksum != "" && !vendors.ValidateChecksum(archivePath, firmware.Checksum) {
+			return nil, errors.Wrap(vendors.ErrChecksumValidate, fmt.Sprintf("firmware: %s, expected checksum: %s", archivePath, firmware.Checksum))
+		}
+
+		return &vendors.FirmwareFile{Path: archivePath}, nil
+	}
+
+	entry, err := findCapsule(archivePath, firmware.Filename)
+	if err != nil {
+		return nil, err
+	}
+
+	d.logger.WithField("archivePath", archivePath).
+		WithField("entry", entry).
+		Debug("Extracting BIOS image from archive")
+
+	fwFile, err := vendors.ExtractFirmware(archivePath, path.Base(entry), firmware.Checksum)
+	if err != nil {
+		return nil, err
+	}
+
+	return fwFile, nil
+}
+
+// findCapsule returns the name of the archive entry holding the BIOS image,
+// the entry named firmwareFilename wins over the other .cap and .rom entries.
+func findCapsule(archivePath, firmwareFilename string) (string, error) {
+	r, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return "", &vendors.ArchiveError{ArchivePath: archivePath, Err: errors.Wrap(vendors.ErrArchiveCorrupt, err.Error())}
+	}
+	defer r.Close()
+
+	var capsules []string
+
+	for _, f := range r.File {
+		if path.Base(f.Name) == firmwareFilename {
+			return f.Name, nil
+		}
+
+		if isCapsule(f.Name) {
+			capsules = append(capsules, f.Name)
+		}
+	}
+
+	switch len(capsules) {
+	case 0:
+		return "", &vendors.ArchiveError{ArchivePath: archivePath, Err: errors.Wrap(vendors.ErrArchiveMemberNotFound, "no .cap or .rom entry")}
+	case 1:
+		return capsules[0], nil
+	default:
+		return "", &vendors.ArchiveError{ArchivePath: archivePath, Err: errors.Wrap(vendors.ErrAmbiguousArchiveEntry, strings.Join(capsules, ", "))}
+	}
+}
+
+func isCapsule(name string) bool {
+	name = strings.ToLower(name)
+
+	for _, extension := range capsuleExtensions {
+		if strings.HasSuffix(name, extension) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,188 @@
+package ami
+
+import (
+	"archive/zip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+
+	fleetdbapi "github.com/metal-toolbox/fleetdb/pkg/api/v1"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/metal-toolbox/firmware-syncer/internal/logging"
+	"github.com/metal-toolbox/firmware-syncer/internal/vendors"
+)
+
+const (
+	capsule         = "AMI Aptio V capsule X570D4U L3.46\n"
+	capsuleMD5      = "16564d2e68ae51ca705cb48978e24286"
+	capsuleSHA256   = "7726fd3b3fa35311efd9e45684afc4f0598447792b7ef72ebb7619bb84503c54"
+	packageFilename = "X570D4U_L3.46.zip"
+)
+
+// writeZip writes a zip archive holding the given entries to a temporary directory and returns its contents.
+func writeZip(t *testing.T, entries map[string]string) []byte {
+	filename := filepath.Join(t.TempDir(), "bios.zip")
+
+	f, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := zip.NewWriter(f)
+
+	for name, contents := range entries {
+		entry, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := entry.Write([]byte(contents)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f.Close()
+
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b
+}
+
+// newDownloadServer serves the package fixture, the bare capsule and a package holding two capsules.
+func newDownloadServer(t *testing.T) *httptest.Server {
+	fixture, err := os.ReadFile(path.Join("fixtures", packageFilename))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ambiguous := writeZip(t, map[string]string{
+		"BIOS/X570D4U_L3.46.cap": capsule,
+		"BIOS/X570D4U_L3.46.rom": "AMI Aptio V ROM X570D4U L3.46\n",
+	})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/"+packageFilename, func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(fixture)
+	})
+	mux.HandleFunc("/X570D4U_L3.46.cap", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(capsule))
+	})
+	mux.HandleFunc("/ambiguous.zip", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(ambiguous)
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func Test_isCapsule(t *testing.T) {
+	assert.True(t, isCapsule("BIOS/X570D4U_L3.46.cap"))
+	assert.True(t, isCapsule("E3C246D4U2-2T_L2.04.ROM"))
+	assert.False(t, isCapsule("Tools/AfuEfi64.efi"))
+	assert.False(t, isCapsule(packageFilename))
+}
+
+func TestDownload(t *testing.T) {
+	server := newDownloadServer(t)
+	logger := logging.NewLogger("debug")
+
+	testCases := []struct {
+		name        string
+		upstreamURL string
+		filename    string
+		checksum    string
+		err         error
+	}{
+		{
+			name:        "capsule named after the firmware",
+			upstreamURL: server.URL + "/" + packageFilename,
+			filename:    "X570D4U_L3.46.cap",
+			checksum:    capsuleMD5,
+		},
+		{
+			name:        "capsule looked up by suffix",
+			upstreamURL: server.URL + "/" + packageFilename,
+			filename:    "X570D4U_BIOS.cap",
+			checksum:    "sha256:" + capsuleSHA256,
+		},
+		{
+			name:        "bare capsule",
+			upstreamURL: server.URL + "/X570D4U_L3.46.cap",
+			filename:    "X570D4U_L3.46.cap",
+			checksum:    capsuleMD5,
+		},
+		{
+			name:        "capsule checksum mismatch",
+			upstreamURL: server.URL + "/" + packageFilename,
+			filename:    "X570D4U_L3.46.cap",
+			checksum:    "00000000000000000000000000000000",
+			err:         vendors.ErrChecksumValidate,
+		},
+		{
+			name:        "bare capsule checksum mismatch",
+			upstreamURL: server.URL + "/X570D4U_L3.46.cap",
+			filename:    "X570D4U_L3.46.cap",
+			checksum:    "00000000000000000000000000000000",
+			err:         vendors.ErrChecksumValidate,
+		},
+		{
+			name:        "several capsules in the package",
+			upstreamURL: server.URL + "/ambiguous.zip",
+			filename:    "X570D4U_BIOS.cap",
+			err:         vendors.ErrAmbiguousArchiveEntry,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			firmware := &fleetdbapi.ComponentFirmwareVersion{
+				Vendor:      "ami",
+				Filename:    tt.filename,
+				UpstreamURL: tt.upstreamURL,
+				Checksum:    tt.checksum,
+			}
+
+			firmwareFile, err := NewAMIDownloader(logger).Download(context.Background(), t.TempDir(), firmware)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, "X570D4U_L3.46.cap", filepath.Base(firmwareFile.Path))
+
+			b, err := os.ReadFile(firmwareFile.Path)
+			assert.NoError(t, err)
+			assert.Equal(t, capsule, string(b))
+		})
+	}
+}
+
+func Test_findCapsule(t *testing.T) {
+	archivePath := filepath.Join(t.TempDir(), "bios.zip")
+
+	err := os.WriteFile(archivePath, writeZip(t, map[string]string{"ReadMe.txt": "no capsule"}), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = findCapsule(archivePath, "X570D4U_L3.46.cap")
+	assert.ErrorIs(t, err, vendors.ErrArchiveMemberNotFound)
+
+	_, err = findCapsule(path.Join("fixtures", "missing.zip"), "X570D4U_L3.46.cap")
+	assert.ErrorIs(t, err, vendors.ErrArchiveCorrupt)
+}
@@ -0,0 +1,219 @@
+package vendors
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/pkg/errors"
+	rcloneFs "github.com/rclone/rclone/fs"
+)
+
+const (
+	// SuspiciousCompressionRatio is the extracted to archive size ratio above which
+	// an extracted firmware is flagged, as it could be a zip bomb or the wrong file.
+	SuspiciousCompressionRatio = 100
+
+	MetadataArchiveSize   = "firmware-archive-size"
+	MetadataExtractedSize = "firmware-extracted-size"
+
+	// DefaultMaxExtractedSize is the default limit of the bytes decompressed from an archive, firmware can be multi GB
+	DefaultMaxExtractedSize = 16 << 30
+	// DefaultMaxArchiveEntries is the default limit of the entries in an archive
+	DefaultMaxArchiveEntries = 10000
+)
+
+var (
+	ErrArchiveCorrupt        = errors.New("archive is corrupt")
+	ErrArchiveMemberNotFound = errors.New("firmware not found in archive")
+	ErrAmbiguousArchiveEntry = errors.New("firmware matches several archive entries")
+	ErrArchiveTooLarge       = errors.New("archive exceeds the extraction limits")
+)
+
+var (
+	extractLimitsMutex sync.RWMutex
+	// extractLimits guards the extraction of archives against zip bombs, the defaults are used until SetExtractLimits is called
+	extractLimits = ExtractLimits{MaxBytes: DefaultMaxExtractedSize, MaxEntries: DefaultMaxArchiveEntries}
+)
+
+// ExtractLimits bounds the bytes decompressed from an archive and the entries it can hold.
+type ExtractLimits struct {
+	MaxBytes   int64
+	MaxEntries int
+}
+
+// SetExtractLimits sets the limits archives are extracted with, the default limit is kept for a zero field.
+func SetExtractLimits(limits ExtractLimits) {
+	extractLimitsMutex.Lock()
+	defer extractLimitsMutex.Unlock()
+
+	extractLimits = ExtractLimits{MaxBytes: DefaultMaxExtractedSize, MaxEntries: DefaultMaxArchiveEntries}
+
+	if limits.MaxBytes > 0 {
+		extractLimits.MaxBytes = limits.MaxBytes
+	}
+
+	if limits.MaxEntries > 0 {
+		extractLimits.MaxEntries = limits.MaxEntries
+	}
+}
+
+func currentExtractLimits() ExtractLimits {
+	extractLimitsMutex.RLock()
+	defer extractLimitsMutex.RUnlock()
+
+	return extractLimits
+}
+
+// checkEntryCount returns an ErrArchiveTooLarge when an archive holds more than the maximum number of entries.
+func checkEntryCount(entries int) error {
+	if maxEntries := currentExtractLimits().MaxEntries; entries > maxEntries {
+		return errors.Wrap(ErrArchiveTooLarge, fmt.Sprintf("more than %d entries", maxEntries))
+	}
+
+	return nil
+}
+
+// copyLimited copies src to dst, returning an ErrArchiveTooLarge once more than limit bytes are read.
+func copyLimited(dst io.Writer, src io.Reader, limit int64) (int64, error) {
+	limited := &io.LimitedReader{R: src, N: limit + 1}
+
+	n, err := io.Copy(dst, limited)
+	if err != nil {
+		return n, err
+	}
+
+	if n > limit {
+		return n, errors.Wrap(ErrArchiveTooLarge, fmt.Sprintf("more than %d bytes decompressed", limit))
+	}
+
+	return n, nil
+}
+
+// ArchiveError is returned when the firmware couldn't be extracted from a downloaded archive,
+// it holds the path to the offending archive so it can be quarantined.
+type ArchiveError struct {
+	ArchivePath string
+	Err         error
+}
+
+func (e *ArchiveError) Error() string {
+	return fmt.Sprintf("archive %s: %s", e.ArchivePath, e.Err)
+}
+
+func (e *ArchiveError) Unwrap() error {
+	return e.Err
+}
+
+// ArchiveSizes holds the size of a downloaded archive and the size of the firmware extracted from it.
+type ArchiveSizes struct {
+	ArchiveBytes   int64
+	ExtractedBytes int64
+}
+
+// Ratio returns the extracted to archive size ratio.
+func (a ArchiveSizes) Ratio() float64 {
+	if a.ArchiveBytes == 0 {
+		return 0
+	}
+
+	return float64(a.ExtractedBytes) / float64(a.ArchiveBytes)
+}
+
+// Suspicious returns true when the extracted size is out of proportion with the archive size.
+func (a ArchiveSizes) Suspicious() bool {
+	return a.Ratio() > SuspiciousCompressionRatio
+}
+
+// Metadata returns the sizes as object metadata.
+func (a ArchiveSizes) Metadata() rcloneFs.Metadata {
+	return rcloneFs.Metadata{
+		MetadataArchiveSize:   strconv.FormatInt(a.ArchiveBytes, 10),
+		MetadataExtractedSize: strconv.FormatInt(a.ExtractedBytes, 10),
+	}
+}
+
+// extractedFirmware returns the firmware file extracted from an archive of archiveBytes, with the sizes of both.
+func extractedFirmware(archiveBytes int64, extractedPath string) (*FirmwareFile, error) {
+	extractedInfo, err := os.Stat(extractedPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FirmwareFile{
+		Path: extractedPath,
+		ArchiveSizes: &ArchiveSizes{
+			ArchiveBytes:   archiveBytes,
+			ExtractedBytes: extractedInfo.Size(),
+		},
+	}, nil
+}
+
+// writeArchiveMember writes the archive member read from r to filename next to the archive, up to limit bytes.
+// The member is written to a temporary file renamed once complete, as the member can be named after the archive
+// it is read from, which creating the file would truncate.
+func writeArchiveMember(archivePath, filename string, r io.Reader, limit int64) (string, error) {
+	dir := filepath.Dir(archivePath)
+
+	out, err := os.CreateTemp(dir, "."+filename+".*")
+	if err != nil {
+		return "", err
+	}
+
+	_, err = copyLimited(out, r, limit)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Rename(out.Name(), filepath.Join(dir, filename))
+	}
+
+	if err != nil {
+		os.Remove(out.Name())
+		return "", err
+	}
+
+	return filepath.Join(dir, filename), nil
+}
+
+// verifyZipEntries reads through the archive entries to check them against their CRC32,
+// so a corrupt download fails with the name of the bad entry before anything is extracted.
+// The archive is rejected when it holds too many entries or decompresses to too many bytes.
+func verifyZipEntries(r *zip.Reader) error {
+	if err := checkEntryCount(len(r.File)); err != nil {
+		return err
+	}
+
+	remaining := currentExtractLimits().MaxBytes
+
+	for _, f := range r.File {
+		n, err := verifyZipEntry(f, remaining)
+		if errors.Is(err, ErrArchiveTooLarge) {
+			return err
+		}
+
+		if err != nil {
+			return errors.Wrap(ErrArchiveCorrupt, fmt.Sprintf("entry %s: %s", f.Name, err.Error()))
+		}
+
+		remaining -= n
+	}
+
+	return nil
+}
+
+func verifyZipEntry(f *zip.File, limit int64) (int64, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+
+	// the CRC32 is checked by the reader once the entry is read to the end
+	return copyLimited(io.Discard, rc, limit)
+}
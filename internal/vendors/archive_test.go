@@ -0,0 +1,206 @@
+package vendors
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ExtractReturnsArchiveSizes(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	archivePath := filepath.Join(tmpDir, "foobar1.zip")
+
+	b, err := os.ReadFile(getPathToFixture("foobar1.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = os.WriteFile(archivePath, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := ExtractFromZipArchive(archivePath, "foobar1.bin", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	extractedInfo, err := os.Stat(f.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sizes := f.ArchiveSizes
+	if assert.NotNil(t, sizes) {
+		assert.Equal(t, int64(len(b)), sizes.ArchiveBytes)
+		assert.Equal(t, extractedInfo.Size(), sizes.ExtractedBytes)
+		assert.False(t, sizes.Suspicious())
+		assert.Contains(t, sizes.Metadata(), MetadataExtractedSize)
+	}
+}
+
+func Test_ExtractFlagsExtremeRatio(t *testing.T) {
+	tmpDir := t.TempDir()
+	archivePath := filepath.Join(tmpDir, "bomb.zip")
+
+	archive, err := os.Create(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := zip.NewWriter(archive)
+
+	entry, err := w.Create("bomb.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// zeroes compress extremely well
+	if _, err = entry.Write(bytes.Repeat([]byte{0}, 10<<20)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	archive.Close()
+
+	f, err := ExtractFromZipArchive(archivePath, "bomb.bin", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sizes := f.ArchiveSizes
+	if !assert.NotNil(t, sizes) {
+		return
+	}
+
+	assert.Equal(t, int64(10<<20), sizes.ExtractedBytes)
+	assert.True(t, sizes.Suspicious())
+	assert.Greater(t, sizes.Ratio(), float64(SuspiciousCompressionRatio))
+}
+
+func Test_ArchiveSizesRatio(t *testing.T) {
+	assert.Equal(t, float64(0), ArchiveSizes{}.Ratio())
+	assert.Equal(t, float64(2), ArchiveSizes{ArchiveBytes: 5, ExtractedBytes: 10}.Ratio())
+	assert.False(t, ArchiveSizes{ArchiveBytes: 1, ExtractedBytes: 100}.Suspicious())
+	assert.True(t, ArchiveSizes{ArchiveBytes: 1, ExtractedBytes: 101}.Suspicious())
+}
+
+func Test_ExtractArchiveErrors(t *testing.T) {
+	corruptPath := filepath.Join(t.TempDir(), "corrupt.zip")
+	if err := os.WriteFile(corruptPath, []byte("not a zip archive"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name             string
+		archivePath      string
+		firmwareFilename string
+		expectedError    error
+	}{
+		{
+			name:             "corrupt archive",
+			archivePath:      corruptPath,
+			firmwareFilename: "foobar1.bin",
+			expectedError:    ErrArchiveCorrupt,
+		},
+		{
+			name:             "firmware missing from archive",
+			archivePath:      getPathToFixture("foobar1.zip"),
+			firmwareFilename: "missing.bin",
+			expectedError:    ErrArchiveMemberNotFound,
+		},
+		{
+			// foobar8.zip holds us/foobar8.bin and eu/foobar8.bin
+			name:             "firmware matching several entries",
+			archivePath:      getPathToFixture("foobar8.zip"),
+			firmwareFilename: "foobar8.bin",
+			expectedError:    ErrAmbiguousArchiveEntry,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ExtractFromZipArchive(tt.archivePath, tt.firmwareFilename, "")
+			assert.ErrorIs(t, err, tt.expectedError)
+
+			var archiveErr *ArchiveError
+			if assert.ErrorAs(t, err, &archiveErr) {
+				assert.Equal(t, tt.archivePath, archiveErr.ArchivePath)
+			}
+		})
+	}
+}
+
+func Test_ExtractFromZipArchiveCorruptEntry(t *testing.T) {
+	// the README entry of the fixture fails its CRC32 check, the firmware entry is intact
+	archivePath := getPathToFixture("foobar6-corrupt.zip")
+
+	_, err := ExtractFromZipArchive(archivePath, "foobar6.bin", "")
+	assert.ErrorIs(t, err, ErrArchiveCorrupt)
+	assert.ErrorContains(t, err, "foobar6/README.txt")
+
+	_, statErr := os.Stat(filepath.Join(filepath.Dir(archivePath), "foobar6.bin"))
+	assert.ErrorIs(t, statErr, os.ErrNotExist, "nothing should be extracted from a corrupt archive")
+}
+
+func Test_ExtractLimits(t *testing.T) {
+	t.Cleanup(func() { SetExtractLimits(ExtractLimits{}) })
+
+	tmpDir := t.TempDir()
+	archivePath := filepath.Join(tmpDir, "oversized.zip")
+
+	archive, err := os.Create(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := zip.NewWriter(archive)
+
+	entry, err := w.Create("oversized.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = entry.Write(bytes.Repeat([]byte{0}, 2<<20)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	archive.Close()
+
+	SetExtractLimits(ExtractLimits{MaxBytes: 1 << 20})
+
+	_, err = ExtractFromZipArchive(archivePath, "oversized.bin", "")
+	assert.ErrorIs(t, err, ErrArchiveTooLarge)
+
+	var archiveErr *ArchiveError
+	if assert.ErrorAs(t, err, &archiveErr) {
+		assert.Equal(t, archivePath, archiveErr.ArchivePath)
+	}
+
+	_, err = os.Stat(filepath.Join(tmpDir, "oversized.bin"))
+	assert.ErrorIs(t, err, os.ErrNotExist, "nothing should be extracted from an oversized archive")
+
+	// foobar9-entries.zip holds 20 small entries
+	SetExtractLimits(ExtractLimits{MaxEntries: 10})
+
+	_, err = ExtractFromZipArchive(getPathToFixture("foobar9-entries.zip"), "part00.bin", "")
+	assert.ErrorIs(t, err, ErrArchiveTooLarge)
+
+	SetExtractLimits(ExtractLimits{})
+
+	f, err := ExtractFromZipArchive(getPathToFixture("foobar9-entries.zip"), "part00.bin", "")
+	if assert.NoError(t, err) {
+		os.Remove(f.Path)
+	}
+}
@@ -0,0 +1,114 @@
+package broadcom
+
+import (
+	"context"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/metal-toolbox/firmware-syncer/internal/vendors"
+
+	fleetdbapi "github.com/metal-toolbox/fleetdb/pkg/api/v1"
+)
+
+var ErrResolveDownload = errors.New("error resolving Broadcom download URL")
+
+// archiveExtensions are the extensions of the firmware packages published by Broadcom
+var archiveExtensions = []string{".zip", ".tar.gz", ".tgz"}
+
+type Downloader struct {
+	logger *logrus.Logger
+	client fleetdbapi.Doer
+}
+
+// NewBroadcomDownloader creates a new Downloader for the Broadcom MegaRAID and HBA storage firmware.
+func NewBroadcomDownloader(logger *logrus.Logger) vendors.Downloader {
+	return &Downloader{
+		logger: logger,
+		client: vendors.NewHTTPClient(&vendors.HTTPClientOptions{Timeout: time.Second * 15}),
+	}
+}
+
+// Download will download the firmware package for the given firmware to the given downloadDir,
+// and will return the full path to the firmware extracted from the package.
+//
+// Upstream URLs are either the package URL under docs.broadcom.com/docs-and-downloads,
+// or a docs.broadcom.com/docs/<document> link redirecting to the package.
+// The firmware is usually in a subdirectory of the package, it's looked up by its filename.
+func (d *Downloader) Download(ctx context.Context, downloadDir string, firmware *fleetdbapi.ComponentFirmwareVersion) (*vendors.FirmwareFile, error) {
+	downloadDir, err := vendors.FirmwareDownloadDir(downloadDir, firmware)
+	if err != nil {
+		return nil, err
+	}
+
+	archiveURL, err := resolveArchiveURL(ctx, d.client, firmware.UpstreamURL)
+	if err != nil {
+		return nil, err
+	}
+
+	d.logger.WithField("archiveURL", archiveURL).Debug("Downloading archive")
+
+	archivePath, err := vendors.DownloadFirmwareArchive(ctx, downloadDir, archiveURL, "")
+	if err != nil {
+		return nil, err
+	}
+
+	// single binaries are published as is
+	if path.Base(archivePath) == firmware.Filename {
+		return &vendors.FirmwareFile{Path: archivePath}, nil
+	}
+
+	d.logger.WithField("archivePath", archivePath).Debug("Extracting firmware from archive")
+
+	fwFile, err := vendors.ExtractFirmware(archivePath, firmware.Filename, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return fwFile, nil
+}
+
+// resolveArchiveURL returns the URL of the firmware package the upstreamURL points to,
+// document links are followed to the package they redirect to.
+func resolveArchiveURL(ctx context.Context, client fleetdbapi.Doer, upstreamURL string) (string, error) {
+	if isArchiveURL(upstreamURL) {
+		return upstreamURL, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, upstreamURL, http.NoBody)
+	if err != nil {
+		return "", errors.Wrap(ErrResolveDownload, err.Error())
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", errors.Wrap(ErrResolveDownload, err.Error())
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.Wrap(ErrResolveDownload, upstreamURL+": "+resp.Status)
+	}
+
+	// the request of the response is the last one of the redirects
+	return resp.Request.URL.String(), nil
+}
+
+func isArchiveURL(rawURL string) bool {
+	name := strings.ToLower(rawURL)
+	if i := strings.IndexAny(name, "?#"); i >= 0 {
+		name = name[:i]
+	}
+
+	for _, extension := range archiveExtensions {
+		if strings.HasSuffix(name, extension) {
+			return true
+		}
+	}
+
+	return false
+}
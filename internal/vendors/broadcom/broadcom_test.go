@@ -0,0 +1,161 @@
+package broadcom
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+
+	fleetdbapi "github.com/metal-toolbox/fleetdb/pkg/api/v1"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+
+	mockinventory "github.com/metal-toolbox/firmware-syncer/internal/inventory/mocks"
+	"github.com/metal-toolbox/firmware-syncer/internal/logging"
+	"github.com/metal-toolbox/firmware-syncer/internal/vendors"
+)
+
+const (
+	packagePath = "/docs-and-downloads/host-bus-adapters/9500_16i_Pkg_P28_SAS_SATA_FW.zip"
+	// documentPath redirects to the package
+	documentPath = "/docs/12345"
+)
+
+// newPortalServer serves the package fixture at packagePath and the document redirecting to it at documentPath.
+func newPortalServer(t *testing.T) *httptest.Server {
+	fixture, err := os.ReadFile(path.Join("fixtures", "9500_16i_Pkg_P28_SAS_SATA_FW.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(packagePath, func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(fixture)
+	})
+	mux.HandleFunc(documentPath, func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, packagePath, http.StatusFound)
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func Test_isArchiveURL(t *testing.T) {
+	assert.True(t, isArchiveURL("https://docs.broadcom.com/docs-and-downloads/9500_16i_Pkg_P28_SAS_SATA_FW.zip"))
+	assert.True(t, isArchiveURL("https://docs.broadcom.com/docs-and-downloads/MR_7.27.tar.gz?download=1"))
+	assert.False(t, isArchiveURL("https://docs.broadcom.com/docs/12345"))
+}
+
+func TestDownload(t *testing.T) {
+	server := newPortalServer(t)
+	logger := logging.NewLogger("debug")
+
+	testCases := []struct {
+		name        string
+		upstreamURL string
+		filename    string
+		expected    string
+		err         error
+	}{
+		{
+			name:        "package URL",
+			upstreamURL: server.URL + packagePath,
+			filename:    "HBA_9500-16i_Mixed_Profile.bin",
+			expected:    "HBA 9500-16i firmware P28\n",
+		},
+		{
+			name:        "document URL",
+			upstreamURL: server.URL + documentPath,
+			filename:    "mpt35sas_x64.rom",
+			expected:    "HBA 9500-16i UEFI P28\n",
+		},
+		{
+			name:        "firmware missing from the package",
+			upstreamURL: server.URL + packagePath,
+			filename:    "HBA_9600-16i.bin",
+			err:         vendors.ErrArchiveMemberNotFound,
+		},
+		{
+			name:        "missing document",
+			upstreamURL: server.URL + "/docs/404",
+			filename:    "HBA_9500-16i_Mixed_Profile.bin",
+			err:         ErrResolveDownload,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			firmware := &fleetdbapi.ComponentFirmwareVersion{
+				Vendor:      "broadcom",
+				Filename:    tt.filename,
+				UpstreamURL: tt.upstreamURL,
+			}
+
+			firmwareFile, err := NewBroadcomDownloader(logger).Download(context.Background(), t.TempDir(), firmware)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.filename, filepath.Base(firmwareFile.Path))
+
+			b, err := os.ReadFile(firmwareFile.Path)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, string(b))
+		})
+	}
+}
+
+func TestSyncer(t *testing.T) {
+	server := newPortalServer(t)
+	logger := logging.NewLogger("debug")
+	ctx := context.Background()
+
+	dstDir := t.TempDir()
+
+	dstFs, err := vendors.InitLocalFs(ctx, &vendors.LocalFsConfig{Root: dstDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpFs, err := vendors.InitLocalFs(ctx, &vendors.LocalFsConfig{Root: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	firmware := &fleetdbapi.ComponentFirmwareVersion{
+		Vendor:      "broadcom",
+		Model:       []string{"9500-16i"},
+		Component:   "storage-controller",
+		Filename:    "HBA_9500-16i_Mixed_Profile.bin",
+		Version:     "28.00.00.00",
+		UpstreamURL: server.URL + documentPath,
+		Checksum:    "b6768da0638f6492e0a8f4c694c2a109",
+	}
+
+	ctrl := gomock.NewController(t)
+
+	mockInventory := mockinventory.NewMockServerService(ctrl)
+	mockInventory.EXPECT().Publish(gomock.Any(), firmware)
+
+	s := vendors.NewSyncer(
+		dstFs,
+		tmpFs,
+		NewBroadcomDownloader(logger),
+		mockInventory,
+		[]*fleetdbapi.ComponentFirmwareVersion{firmware},
+		logger,
+	)
+
+	assert.NoError(t, s.Sync(ctx))
+
+	b, err := os.ReadFile(filepath.Join(dstDir, vendors.DstPath(firmware)))
+	assert.NoError(t, err)
+	assert.Equal(t, "HBA 9500-16i firmware P28\n", string(b))
+}
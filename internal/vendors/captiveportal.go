@@ -0,0 +1,127 @@
+package vendors
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// captivePortalSniffBytes is the number of bytes inspected at the start of a downloaded file.
+	captivePortalSniffBytes = 4096
+	// captivePortalMaxBytes is the size below which a downloaded HTML page is assumed to be a portal page,
+	// firmware files are expected to be larger than this.
+	captivePortalMaxBytes = 1 << 20
+)
+
+var (
+	ErrCaptivePortal = errors.New(
+		"downloaded file is an HTML page, possibly from a captive portal or login redirect, check egress authentication",
+	)
+
+	// captivePortalMarkers are found in the login and redirect pages served by captive portals.
+	captivePortalMarkers = [][]byte{
+		[]byte("captive"),
+		[]byte("portal"),
+		[]byte("login"),
+		[]byte("log in"),
+		[]byte("sign in"),
+		[]byte("signin"),
+		[]byte("authenticate"),
+		[]byte("http-equiv=\"refresh\""),
+		[]byte("window.location"),
+	}
+)
+
+// isHTMLFilename returns true when the firmware file itself is expected to be HTML.
+func isHTMLFilename(filename string) bool {
+	ext := strings.ToLower(filepath.Ext(filename))
+	return ext == ".html" || ext == ".htm"
+}
+
+// DetectCaptivePortal returns an ErrCaptivePortal when the downloaded file looks like an HTML page
+// instead of the expected firmware, which happens when egress is intercepted by a captive portal.
+//
+// A file is reported when it starts with HTML markup and either contains a known portal marker
+// or is too small to be a firmware file.
+func DetectCaptivePortal(filePath string) error {
+	if isHTMLFilename(filePath) {
+		return nil
+	}
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	head := make([]byte, captivePortalSniffBytes)
+
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return err
+	}
+
+	head = bytes.ToLower(head[:n])
+
+	if !looksLikeHTML(head) {
+		return nil
+	}
+
+	if info.Size() < captivePortalMaxBytes || containsPortalMarker(head) {
+		msg := fmt.Sprintf("file: %s, size: %d", filePath, info.Size())
+		return errors.Wrap(ErrCaptivePortal, msg)
+	}
+
+	return nil
+}
+
+// checkCaptivePortalResponse returns an ErrCaptivePortal when the response serves HTML for a firmware
+// file which isn't expected to be HTML.
+func checkCaptivePortalResponse(resp *http.Response, filename string) error {
+	if isHTMLFilename(filename) {
+		return nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || mediaType != "text/html" {
+		return nil
+	}
+
+	msg := fmt.Sprintf("firmware: %s, content type: %s", filename, mediaType)
+
+	return errors.Wrap(ErrCaptivePortal, msg)
+}
+
+func looksLikeHTML(head []byte) bool {
+	// skip the UTF-8 byte order mark and leading whitespace
+	head = bytes.TrimPrefix(head, []byte("\xef\xbb\xbf"))
+	head = bytes.TrimSpace(head)
+
+	return bytes.HasPrefix(head, []byte("<!doctype html")) ||
+		bytes.HasPrefix(head, []byte("<html")) ||
+		bytes.HasPrefix(head, []byte("<head")) ||
+		bytes.HasPrefix(head, []byte("<meta"))
+}
+
+func containsPortalMarker(head []byte) bool {
+	for _, marker := range captivePortalMarkers {
+		if bytes.Contains(head, marker) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,99 @@
+package vendors
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	rcloneFs "github.com/rclone/rclone/fs"
+	"github.com/stretchr/testify/assert"
+)
+
+const captivePortalPage = `<!DOCTYPE html>
+<html>
+<head><title>Guest Wi-Fi</title></head>
+<body>
+<form action="/login" method="post">
+<p>Please sign in to access the network.</p>
+</form>
+</body>
+</html>`
+
+func Test_DetectCaptivePortal(t *testing.T) {
+	testCases := []struct {
+		name          string
+		filename      string
+		content       string
+		expectedError error
+	}{
+		{
+			name:          "captive portal page",
+			filename:      "firmware.bin",
+			content:       captivePortalPage,
+			expectedError: ErrCaptivePortal,
+		},
+		{
+			name:          "meta refresh redirect",
+			filename:      "firmware.bin",
+			content:       "\n  <meta http-equiv=\"refresh\" content=\"0; url=https://portal.example.com\">",
+			expectedError: ErrCaptivePortal,
+		},
+		{
+			name:          "large html with portal marker",
+			filename:      "firmware.bin",
+			content:       captivePortalPage + strings.Repeat(" ", captivePortalMaxBytes),
+			expectedError: ErrCaptivePortal,
+		},
+		{
+			name:     "large html without portal marker",
+			filename: "firmware.bin",
+			content:  "<html>" + strings.Repeat(" ", captivePortalMaxBytes),
+		},
+		{
+			name:     "binary firmware",
+			filename: "firmware.bin",
+			content:  "\x7fELF\x02\x01\x01\x00",
+		},
+		{
+			name:     "html firmware file",
+			filename: "release-notes.html",
+			content:  captivePortalPage,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			filePath := filepath.Join(t.TempDir(), tt.filename)
+			if err := os.WriteFile(filePath, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			err := DetectCaptivePortal(filePath)
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func Test_DownloadFirmwareArchiveCaptivePortal(t *testing.T) {
+	// captive portals answer any request with a 200 and their login page
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(captivePortalPage))
+	}))
+	defer server.Close()
+
+	ctx, ci := rcloneFs.AddConfig(context.Background())
+	ci.LowLevelRetries = 1
+
+	_, err := DownloadFirmwareArchive(ctx, t.TempDir(), server.URL+"/firmware.zip", "")
+	assert.ErrorIs(t, err, ErrCaptivePortal)
+}
@@ -3,19 +3,26 @@ package vendors
 import (
 	"bytes"
 	"crypto/md5"
+	"crypto/sha1"
 	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/hex"
 	"fmt"
+	"hash"
 	"io"
-	"log"
 	"os"
 	"strings"
 
 	"github.com/pkg/errors"
+	rcloneFs "github.com/rclone/rclone/fs"
+	"github.com/zeebo/blake3"
 )
 
 const (
 	SumSuffix = ".SHA256"
+
+	// MetadataChecksumPrefix prefixes the checksum algorithm in the firmware object metadata keys, as in firmware-md5
+	MetadataChecksumPrefix = "firmware-"
 )
 
 var (
@@ -76,7 +83,7 @@ func SHA256ChecksumValidate(filename, checksum string) error {
 	defer f.Close()
 
 	h := sha256.New()
-	if _, err := io.Copy(h, f); err != nil {
+	if err := hashFile(f, h); err != nil {
 		return errors.Wrap(ErrChecksumValidate, err.Error())
 	}
 
@@ -95,48 +102,99 @@ func SHA256ChecksumValidate(filename, checksum string) error {
 	return nil
 }
 
-func validateSHA256Checksum(filename, checksum string) bool {
-	err := SHA256ChecksumValidate(filename, checksum)
-	return err == nil
+// checksumHashes maps the checksum hints to the hash.Hash computing their digests,
+// supporting another algorithm only takes adding its hint here.
+var checksumHashes = map[string]func() hash.Hash{
+	"md5sum": md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+	"blake3": func() hash.Hash { return blake3.New() },
 }
 
-func validateMD5Checksum(filename, checksum string) bool {
+// validateFileHash returns true when the digest of the file computed with h matches the hex encoded checksum.
+func validateFileHash(filename string, h hash.Hash, checksum string) bool {
 	f, err := os.Open(filename)
 	if err != nil {
-		log.Fatal(err)
 		return false
 	}
 	defer f.Close()
 
-	h := md5.New()
-
-	_, err = io.Copy(h, f)
-	if err != nil {
+	if err = hashFile(f, h); err != nil {
 		return false
 	}
 
-	return checksum == hex.EncodeToString(h.Sum(nil))
+	return strings.EqualFold(checksum, hex.EncodeToString(h.Sum(nil)))
+}
+
+// ChecksumMetadata returns the <hint>:<checksum> formatted checksums as object metadata,
+// keyed by MetadataChecksumPrefix and the checksum algorithm.
+func ChecksumMetadata(checksums ...string) rcloneFs.Metadata {
+	metadata := rcloneFs.Metadata{}
+
+	for _, checksum := range checksums {
+		hint, value, found := strings.Cut(checksum, ":")
+		if !found {
+			hint, value = "md5sum", checksum
+		}
+
+		if value == "" {
+			continue
+		}
+
+		algorithm := strings.TrimSuffix(strings.ToLower(hint), "sum")
+		metadata[MetadataChecksumPrefix+algorithm] = strings.ToLower(value)
+	}
+
+	return metadata
 }
 
 // ValidateChecksum validates the file checksum matches the given value.
-// Defaults to md5 but allows for sha256 checks
+// Defaults to md5 but allows for any of the checksumHashes hints.
 func ValidateChecksum(filename, checksum string) bool {
-	// checksum format <hint>:<checksum>
+	hint, checksum := splitChecksum(checksum)
+
+	newHash, ok := checksumHashes[hint]
+	if !ok {
+		return false
+	}
+
+	return validateFileHash(filename, newHash(), checksum)
+}
+
+// computeChecksum returns the file checksum computed with the algorithm of the given <hint>:<checksum>,
+// in the same format. It's empty when the file can't be read or the algorithm isn't supported.
+func computeChecksum(filename, checksum string) string {
+	hint, _ := splitChecksum(checksum)
+
+	newHash, ok := checksumHashes[hint]
+	if !ok {
+		return ""
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	h := newHash()
+	if err = hashFile(f, h); err != nil {
+		return ""
+	}
+
+	return hint + ":" + hex.EncodeToString(h.Sum(nil))
+}
+
+// splitChecksum returns the hint and the value of a checksum in the <hint>:<checksum> format,
+// the hint defaults to md5 when there's none.
+func splitChecksum(checksum string) (hint, value string) {
 	splittedChecksum := strings.Split(checksum, ":")
-	// default to md5 when there's no hint
-	hint := "md5sum"
+
+	hint = "md5sum"
 	if len(splittedChecksum) == 2 {
 		hint = splittedChecksum[0]
 	}
 
-	checksum = splittedChecksum[len(splittedChecksum)-1]
-
-	switch hint {
-	case "md5sum":
-		return validateMD5Checksum(filename, checksum)
-	case "sha256":
-		return validateSHA256Checksum(filename, checksum)
-	default:
-		return false
-	}
+	return hint, splittedChecksum[len(splittedChecksum)-1]
 }
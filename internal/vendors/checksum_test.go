@@ -3,8 +3,10 @@ package vendors
 import (
 	"errors"
 	"os"
+	"path/filepath"
 	"testing"
 
+	rcloneFs "github.com/rclone/rclone/fs"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -165,6 +167,26 @@ func Test_ValidateChecksum(t *testing.T) {
 			testfile,
 			"md5sum:803ac72f8be2eba9f985fd3be31b506c",
 		},
+		{
+			"sha1",
+			testfile,
+			"sha1:44b92993b53ab74cf0ce6796c966908e83981d32",
+		},
+		{
+			"sha512",
+			testfile,
+			"sha512:b65cee5962fe19f40213141360d4c1cab246da102e600e2100c634f36413e89a333785d95c7406d55d0aea4474eafb45b47ed60945651347a569f99697392fcf",
+		},
+		{
+			"blake3",
+			testfile,
+			"blake3:6132b4ac21dac2ab66d920a08d7ff9c06c16ebad6985be56158e528bffe739ba",
+		},
+		{
+			"uppercase digest",
+			testfile,
+			"blake3:6132B4AC21DAC2AB66D920A08D7FF9C06C16EBAD6985BE56158E528BFFE739BA",
+		},
 	}
 
 	for _, tt := range cases {
@@ -181,6 +203,37 @@ func Test_ValidateChecksum(t *testing.T) {
 		// nolint:gocritic
 		defer os.Remove(tt.filename)
 
-		assert.True(t, ValidateChecksum(tt.filename, tt.checksum))
+		assert.True(t, ValidateChecksum(tt.filename, tt.checksum), tt.name)
 	}
+
+	assert.False(t, ValidateChecksum(testfile, "blake3:0000000000000000000000000000000000000000000000000000000000000000"))
+	assert.False(t, ValidateChecksum(testfile, "crc32:a1b2c3d4"))
+}
+
+func Test_ComputeChecksum(t *testing.T) {
+	testfile := filepath.Join(t.TempDir(), "foo.blah")
+	if err := os.WriteFile(testfile, []byte(`checksum this`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "md5sum:803ac72f8be2eba9f985fd3be31b506c", computeChecksum(testfile, "00000000000000000000000000000000"))
+	assert.Equal(t,
+		"sha256:97e9269cd0514f864e6be9157998464c94776ebc7f669b449f581abdad4035f5",
+		computeChecksum(testfile, "sha256:0000000000000000000000000000000000000000000000000000000000000000"),
+	)
+	assert.Empty(t, computeChecksum(testfile, "crc32:a1b2c3d4"))
+	assert.Empty(t, computeChecksum(testfile+".missing", "md5sum:803ac72f8be2eba9f985fd3be31b506c"))
+}
+
+func Test_ChecksumMetadata(t *testing.T) {
+	metadata := ChecksumMetadata(
+		"803ac72f8be2eba9f985fd3be31b506c",
+		"sha256:97E9269CD0514F864E6BE9157998464C94776EBC7F669B449F581ABDAD4035F5",
+		"sha1:",
+	)
+
+	assert.Equal(t, rcloneFs.Metadata{
+		"firmware-md5":    "803ac72f8be2eba9f985fd3be31b506c",
+		"firmware-sha256": "97e9269cd0514f864e6be9157998464c94776ebc7f669b449f581abdad4035f5",
+	}, metadata)
 }
@@ -0,0 +1,147 @@
+package vendors
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// CompressedSuffix is appended to the path of the compressed copies of the firmware
+	CompressedSuffix = ".gz"
+
+	// MetadataCompression is the object metadata key of the compression of the firmware copy,
+	// the checksums in its metadata are the ones of the uncompressed firmware.
+	MetadataCompression = MetadataChecksumPrefix + "compression"
+
+	// minCompressionSaving is the fraction of the firmware size compression has to save for the compressed copy to be kept
+	minCompressionSaving = 0.1
+)
+
+var ErrCompress = errors.New("error compressing firmware")
+
+// compressedExtensions are the extensions of the formats compressing any further doesn't pay off for.
+var compressedExtensions = []string{".zip", ".gz", ".tgz", ".bz2", ".xz", ".zst", ".7z", ".rar", ".cab", ".lzma"}
+
+// compressible returns true when the firmware isn't in a compressed format, based on its extension and first bytes.
+// Tar archives are compressible.
+func compressible(firmwarePath string) bool {
+	extension := strings.ToLower(filepath.Ext(firmwarePath))
+	for _, compressed := range compressedExtensions {
+		if extension == compressed {
+			return false
+		}
+	}
+
+	sniffed, ok := sniffArchiveExtension(firmwarePath)
+
+	return !ok || sniffed == ".tar"
+}
+
+// compressFile writes the gzip compressed firmware next to it, and returns its path.
+// The path is empty when compression doesn't save minCompressionSaving of the firmware size, nothing is left behind then.
+func compressFile(firmwarePath string) (string, error) {
+	info, err := os.Stat(firmwarePath)
+	if err != nil {
+		return "", errors.Wrap(ErrCompress, err.Error())
+	}
+
+	compressedPath := firmwarePath + CompressedSuffix
+
+	if err = gzipFile(firmwarePath, compressedPath); err != nil {
+		os.Remove(compressedPath)
+		return "", err
+	}
+
+	compressedInfo, err := os.Stat(compressedPath)
+	if err != nil {
+		return "", errors.Wrap(ErrCompress, err.Error())
+	}
+
+	if float64(compressedInfo.Size()) > float64(info.Size())*(1-minCompressionSaving) {
+		os.Remove(compressedPath)
+		return "", nil
+	}
+
+	return compressedPath, nil
+}
+
+func gzipFile(srcPath, dstPath string) error {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return errors.Wrap(ErrCompress, err.Error())
+	}
+	defer src.Close()
+
+	dst, err := os.Create(dstPath)
+	if err != nil {
+		return errors.Wrap(ErrCompress, err.Error())
+	}
+	defer dst.Close()
+
+	gzipWriter, err := gzip.NewWriterLevel(dst, gzip.BestCompression)
+	if err != nil {
+		return errors.Wrap(ErrCompress, err.Error())
+	}
+
+	gzipWriter.Name = filepath.Base(srcPath)
+
+	if _, err = io.Copy(gzipWriter, src); err != nil {
+		return errors.Wrap(ErrCompress, err.Error())
+	}
+
+	if err = gzipWriter.Close(); err != nil {
+		return errors.Wrap(ErrCompress, err.Error())
+	}
+
+	if err = dst.Close(); err != nil {
+		return errors.Wrap(ErrCompress, err.Error())
+	}
+
+	return nil
+}
+
+// compressedMetadata returns the metadata of the compressed copy of the firmware,
+// the metadata of the firmware with the compression added.
+func compressedMetadata(metadata fs.Metadata) fs.Metadata {
+	compressed := fs.Metadata{MetadataCompression: "gzip"}
+	compressed.Merge(metadata)
+
+	return compressed
+}
+
+// uploadCompressed uploads a gzip compressed copy of the firmware next to it on the destination,
+// when the firmware isn't already compressed and compression saves enough space.
+func (s *Syncer) uploadCompressed(ctx context.Context, logMsg *logrus.Entry, firmwarePath, destPath string, metadata fs.Metadata) error {
+	if !compressible(firmwarePath) {
+		logMsg.Debug("Firmware is already compressed, no compressed copy uploaded")
+		return nil
+	}
+
+	compressedPath, err := compressFile(firmwarePath)
+	if err != nil {
+		return err
+	}
+
+	if compressedPath == "" {
+		logMsg.Debug("Firmware doesn't compress well, no compressed copy uploaded")
+		return nil
+	}
+
+	defer os.Remove(compressedPath)
+
+	if err = s.uploadFile(ctx, compressedPath, destPath+CompressedSuffix, compressedMetadata(metadata)); err != nil {
+		return errors.Wrap(err, "failure to upload compressed firmware")
+	}
+
+	logMsg.WithField("compressedPath", destPath+CompressedSuffix).Debug("Uploaded compressed firmware")
+
+	return nil
+}
@@ -0,0 +1,158 @@
+package vendors
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/metal-toolbox/firmware-syncer/internal/logging"
+)
+
+func Test_compressible(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	tarPath := filepath.Join(tmpDir, "firmware.tar")
+	writeTar(t, tarPath, map[string]string{"firmware.bin": "firmware"})
+
+	tarGzPath := filepath.Join(tmpDir, "firmware.tar.gz")
+	writeTarGz(t, tarGzPath, map[string]string{"firmware.bin": "firmware"})
+
+	// a zip without a .zip extension is sniffed
+	zipPath := filepath.Join(tmpDir, "firmware.bin")
+	fixture, err := os.ReadFile(getPathToFixture("foobar1.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = os.WriteFile(zipPath, fixture, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	binPath := filepath.Join(tmpDir, "firmware.rom")
+	if err = os.WriteFile(binPath, []byte("firmware"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name         string
+		firmwarePath string
+		expected     bool
+	}{
+		{"zip", getPathToFixture("foobar1.zip"), false},
+		{"gzip", getPathToFixture("foobar5.bin.gz"), false},
+		{"gzipped tar", tarGzPath, false},
+		{"sniffed zip", zipPath, false},
+		{"xz extension", filepath.Join(tmpDir, "firmware.XZ"), false},
+		{"tar", tarPath, true},
+		{"binary", binPath, true},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, compressible(tt.firmwarePath))
+		})
+	}
+}
+
+func Test_compressFile(t *testing.T) {
+	random := make([]byte, 64*1024)
+	if _, err := rand.Read(random); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name       string
+		content    []byte
+		compressed bool
+	}{
+		{"compresses well", bytes.Repeat([]byte("firmware"), 8*1024), true},
+		{"doesn't compress", random, false},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			firmwarePath := filepath.Join(t.TempDir(), "firmware.bin")
+			if err := os.WriteFile(firmwarePath, tt.content, 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			compressedPath, err := compressFile(firmwarePath)
+			assert.NoError(t, err)
+
+			if !tt.compressed {
+				assert.Empty(t, compressedPath)
+				assert.NoFileExists(t, firmwarePath+CompressedSuffix)
+
+				return
+			}
+
+			assert.Equal(t, firmwarePath+CompressedSuffix, compressedPath)
+			assert.Equal(t, tt.content, gunzipFile(t, compressedPath))
+		})
+	}
+}
+
+func Test_compressedMetadata(t *testing.T) {
+	metadata := ChecksumMetadata("md5sum:74B5B5E9570EFC5C0553BB327CD41940")
+
+	expected := fs.Metadata{
+		"firmware-md5":         "74b5b5e9570efc5c0553bb327cd41940",
+		"firmware-compression": "gzip",
+	}
+
+	assert.Equal(t, expected, compressedMetadata(metadata))
+	assert.Len(t, metadata, 1, "the firmware metadata is left as is")
+}
+
+func TestSyncerUploadCompressed(t *testing.T) {
+	ctx := context.Background()
+	tmpFs, dstFs := newLocalFs(t), newLocalFs(t)
+	s := &Syncer{dstFs: dstFs, tmpFs: tmpFs, logger: logging.NewLogger("info")}
+	logMsg := s.logger.WithField("test", t.Name())
+
+	content := bytes.Repeat([]byte("firmware"), 8*1024)
+
+	firmwarePath := filepath.Join(tmpFs.Root(), "firmware.bin")
+	if err := os.WriteFile(firmwarePath, content, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	archivePath := getPathToFixture("foobar1.zip")
+
+	assert.NoError(t, s.uploadCompressed(ctx, logMsg, firmwarePath, "foo-vendor/firmware.bin", nil))
+	assert.NoError(t, s.uploadCompressed(ctx, logMsg, archivePath, "foo-vendor/foobar1.zip", nil))
+
+	assert.Equal(t, content, gunzipFile(t, filepath.Join(dstFs.Root(), "foo-vendor", "firmware.bin.gz")))
+	assert.NoFileExists(t, firmwarePath+CompressedSuffix, "the local compressed copy is removed")
+	assert.NoFileExists(t, filepath.Join(dstFs.Root(), "foo-vendor", "foobar1.zip.gz"))
+}
+
+func gunzipFile(t *testing.T, path string) []byte {
+	t.Helper()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	gzipReader, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := io.ReadAll(gzipReader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return content
+}
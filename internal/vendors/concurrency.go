@@ -0,0 +1,126 @@
+package vendors
+
+import (
+	"sync"
+)
+
+const (
+	// concurrencyErrorWindow is the number of recent results the error rate is computed over.
+	concurrencyErrorWindow = 10
+	// concurrencyErrorThreshold is the error rate above which concurrency is decreased.
+	concurrencyErrorThreshold = 0.3
+)
+
+// AdaptiveConcurrency limits the number of concurrent firmware syncs,
+// adjusting the limit based on the recent error rate (AIMD).
+//
+// The limit starts at the initial value, is halved when the error rate over the recent results
+// rises above the threshold, and is increased by one after limit consecutive successes,
+// it is always kept between min and max.
+type AdaptiveConcurrency struct {
+	mutex    *sync.Mutex
+	cond     *sync.Cond
+	min      int
+	max      int
+	limit    int
+	inFlight int
+	streak   int
+	results  []bool
+}
+
+// NewAdaptiveConcurrency returns an AdaptiveConcurrency bounded by minimum and maximum,
+// starting at the initial concurrency.
+func NewAdaptiveConcurrency(minimum, maximum, initial int) *AdaptiveConcurrency {
+	if minimum < 1 {
+		minimum = 1
+	}
+
+	if maximum < minimum {
+		maximum = minimum
+	}
+
+	initial = min(max(initial, minimum), maximum)
+
+	mutex := &sync.Mutex{}
+
+	return &AdaptiveConcurrency{
+		mutex: mutex,
+		cond:  sync.NewCond(mutex),
+		min:   minimum,
+		max:   maximum,
+		limit: initial,
+	}
+}
+
+// Acquire blocks until there's room for another concurrent sync under the current limit.
+func (c *AdaptiveConcurrency) Acquire() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for c.inFlight >= c.limit {
+		c.cond.Wait()
+	}
+
+	c.inFlight++
+}
+
+// Release frees up the room taken by Acquire, recording the result of the sync.
+func (c *AdaptiveConcurrency) Release(err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.inFlight--
+	c.record(err == nil)
+
+	c.cond.Broadcast()
+}
+
+// Limit returns the current concurrency limit.
+func (c *AdaptiveConcurrency) Limit() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.limit
+}
+
+func (c *AdaptiveConcurrency) record(success bool) {
+	c.results = append(c.results, success)
+	if len(c.results) > concurrencyErrorWindow {
+		c.results = c.results[1:]
+	}
+
+	if !success {
+		c.streak = 0
+
+		if c.errorRate() > concurrencyErrorThreshold {
+			c.limit = max(c.limit/2, c.min)
+			// start over so the same burst doesn't decrease the limit again
+			c.results = nil
+		}
+
+		return
+	}
+
+	c.streak++
+	if c.streak >= c.limit {
+		c.limit = min(c.limit+1, c.max)
+		c.streak = 0
+	}
+}
+
+func (c *AdaptiveConcurrency) errorRate() float64 {
+	// require a minimum sample before acting on the error rate
+	if len(c.results) < concurrencyErrorWindow/2 {
+		return 0
+	}
+
+	errCount := 0
+
+	for _, success := range c.results {
+		if !success {
+			errCount++
+		}
+	}
+
+	return float64(errCount) / float64(len(c.results))
+}
@@ -0,0 +1,185 @@
+package vendors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	fleetdbapi "github.com/metal-toolbox/fleetdb/pkg/api/v1"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+
+	"github.com/metal-toolbox/firmware-syncer/internal/logging"
+)
+
+func Test_AdaptiveConcurrencyBounds(t *testing.T) {
+	cases := []struct {
+		name                      string
+		minimum, maximum, initial int
+		want                      int
+	}{
+		{"initial within bounds", 1, 8, 4, 4},
+		{"initial below min", 2, 8, 0, 2},
+		{"initial above max", 1, 8, 16, 8},
+		{"min below one", 0, 4, 0, 1},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := NewAdaptiveConcurrency(tc.minimum, tc.maximum, tc.initial)
+			assert.Equal(t, tc.want, c.Limit())
+		})
+	}
+}
+
+func Test_AdaptiveConcurrencyErrorBurst(t *testing.T) {
+	errSync := errors.New("sync failed")
+	c := NewAdaptiveConcurrency(1, 8, 4)
+
+	result := func(err error, count int) {
+		for i := 0; i < count; i++ {
+			c.Acquire()
+			c.Release(err)
+		}
+	}
+
+	// successes increase the limit up to max
+	result(nil, 100)
+	assert.Equal(t, 8, c.Limit())
+
+	// an error burst decreases the limit
+	result(errSync, 5)
+	assert.Equal(t, 4, c.Limit())
+
+	result(errSync, 5)
+	assert.Equal(t, 2, c.Limit())
+
+	// never below min
+	result(errSync, 50)
+	assert.Equal(t, 1, c.Limit())
+
+	// and recovers once the errors subside
+	result(nil, 1)
+	assert.Equal(t, 2, c.Limit())
+
+	result(nil, 2)
+	assert.Equal(t, 3, c.Limit())
+
+	result(nil, 100)
+	assert.Equal(t, 8, c.Limit())
+}
+
+func Test_AdaptiveConcurrencyOccasionalErrors(t *testing.T) {
+	errSync := errors.New("sync failed")
+	c := NewAdaptiveConcurrency(1, 8, 4)
+
+	// an error rate under the threshold doesn't decrease the limit
+	for i := 0; i < 20; i++ {
+		c.Acquire()
+		c.Release(errSync)
+
+		for j := 0; j < 4; j++ {
+			c.Acquire()
+			c.Release(nil)
+		}
+	}
+
+	assert.GreaterOrEqual(t, c.Limit(), 4)
+}
+
+func Test_AdaptiveConcurrencyLimitsInFlight(t *testing.T) {
+	c := NewAdaptiveConcurrency(1, 2, 2)
+
+	var (
+		wg          sync.WaitGroup
+		inFlight    atomic.Int32
+		maxInFlight atomic.Int32
+	)
+
+	for i := 0; i < 10; i++ {
+		c.Acquire()
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			current := inFlight.Add(1)
+			for {
+				seen := maxInFlight.Load()
+				if current <= seen || maxInFlight.CompareAndSwap(seen, current) {
+					break
+				}
+			}
+
+			time.Sleep(time.Millisecond)
+			inFlight.Add(-1)
+
+			c.Release(errors.New("sync failed"))
+		}()
+	}
+
+	wg.Wait()
+
+	assert.LessOrEqual(t, maxInFlight.Load(), int32(2))
+}
+
+func Test_SyncerConcurrency(t *testing.T) {
+	testCases := []struct {
+		name        string
+		concurrency int
+		expected    int
+	}{
+		{name: "not set", expected: 1},
+		{name: "one at a time", concurrency: 1, expected: 1},
+		{name: "in parallel", concurrency: 3, expected: 3},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				inFlight    atomic.Int32
+				maxInFlight atomic.Int32
+			)
+
+			firmwares := make([]*fleetdbapi.ComponentFirmwareVersion, 6)
+			for i := range firmwares {
+				firmwares[i] = &fleetdbapi.ComponentFirmwareVersion{Vendor: "foo-vendor", Filename: fmt.Sprintf("foobar%d.zip", i)}
+			}
+
+			mockDownloader := NewMockDownloader(gomock.NewController(t))
+			mockDownloader.EXPECT().Download(gomock.Any(), gomock.Any(), gomock.Any()).Times(len(firmwares)).
+				DoAndReturn(func(context.Context, string, *fleetdbapi.ComponentFirmwareVersion) (*FirmwareFile, error) {
+					current := inFlight.Add(1)
+					for {
+						seen := maxInFlight.Load()
+						if current <= seen || maxInFlight.CompareAndSwap(seen, current) {
+							break
+						}
+					}
+
+					time.Sleep(20 * time.Millisecond)
+					inFlight.Add(-1)
+
+					return nil, errors.New("download failed")
+				})
+
+			s := NewSyncer(
+				newLocalFs(t),
+				newLocalFs(t),
+				mockDownloader,
+				nil,
+				firmwares,
+				logging.NewLogger("debug"),
+				WithConcurrency(tt.concurrency),
+			).(*Syncer)
+
+			assert.Equal(t, tt.expected, s.ConcurrencyLimit())
+			assert.NoError(t, s.Sync(context.Background()))
+			assert.Equal(t, int32(tt.expected), maxInFlight.Load())
+		})
+	}
+}
@@ -0,0 +1,213 @@
+package vendors
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/aws/smithy-go"
+	"github.com/pkg/errors"
+
+	rcloneFs "github.com/rclone/rclone/fs"
+)
+
+var (
+	ErrS3EndpointDNS    = errors.New("s3 endpoint DNS lookup failed")
+	ErrS3EndpointTLS    = errors.New("s3 endpoint TLS handshake failed")
+	ErrS3Auth           = errors.New("s3 authentication failed")
+	ErrS3BucketNotFound = errors.New("s3 bucket not found")
+	ErrS3Connectivity   = errors.New("s3 endpoint connectivity check failed")
+)
+
+// s3AuthErrorCodes are the S3 API error codes returned for bad or insufficient credentials.
+var s3AuthErrorCodes = map[string]bool{
+	"AccessDenied":          true,
+	"InvalidAccessKeyId":    true,
+	"SignatureDoesNotMatch": true,
+	"InvalidToken":          true,
+	"ExpiredToken":          true,
+}
+
+// objectAttempts is the number of attempts of an object operation failing with a transient error
+const objectAttempts = 3
+
+// objectBackoff is the initial wait between the attempts of an object operation
+var objectBackoff = time.Second
+
+// s3ThrottleErrorCodes are the S3 API error codes returned when requests are throttled.
+var s3ThrottleErrorCodes = map[string]bool{
+	"SlowDown":             true,
+	"Throttling":           true,
+	"ThrottlingException":  true,
+	"RequestLimitExceeded": true,
+}
+
+// probeMaxBackoff is the maximum wait between the probes of an unreachable s3 fs
+const probeMaxBackoff = 10 * time.Second
+
+// probeBackoff is the initial wait between the probes of an unreachable s3 fs
+var probeBackoff = time.Second
+
+// ProbeS3Fs lists the root of the given s3 fs to confirm the endpoint is reachable,
+// the credentials are accepted and the bucket exists.
+//
+// InitS3Fs does not contact the endpoint since no_check_bucket is set,
+// this probe surfaces those failures upfront with one of the ErrS3* errors.
+func ProbeS3Fs(ctx context.Context, fs rcloneFs.Fs) error {
+	_, err := fs.List(ctx, "")
+	if err == nil {
+		return nil
+	}
+
+	return classifyS3Error(err)
+}
+
+// waitS3Fs probes the s3 fs with backoff until it's reachable or the timeout expires,
+// as when the syncer starts along with the object store. Authentication, TLS and missing bucket failures
+// won't resolve by waiting, they're returned right away. The s3 fs is probed once when the timeout is zero.
+func waitS3Fs(ctx context.Context, fs rcloneFs.Fs, timeout time.Duration) error {
+	if timeout <= 0 {
+		return ProbeS3Fs(ctx, fs)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	backoff := probeBackoff
+
+	for {
+		err := ProbeS3Fs(ctx, fs)
+		if err == nil || errors.Is(err, ErrS3Auth) || errors.Is(err, ErrS3EndpointTLS) || errors.Is(err, ErrS3BucketNotFound) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+
+		backoff = min(backoff*2, probeMaxBackoff)
+	}
+}
+
+// retryObjectOperation runs the object operation until it succeeds, fails with an error a retry won't resolve,
+// or objectAttempts are exhausted. The error is classified by classifyObjectError.
+//
+// rclone retries throttled and 5xx requests on its own, but a 403 is returned right away: with no_head set the
+// destination is reached with requests a transient 403 is returned for, as the HEAD of an object being replaced.
+func retryObjectOperation(ctx context.Context, operation func() error) error {
+	backoff := objectBackoff
+
+	for attempt := 1; ; attempt++ {
+		err := operation()
+		if err == nil {
+			return nil
+		}
+
+		transient, err := classifyObjectError(err)
+		if !transient || attempt == objectAttempts {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+	}
+}
+
+// classifyObjectError returns the error of an object operation, and whether it's transient and worth a retry.
+//
+// A missing object is returned as ErrFileNotFound and isn't retried. Throttling, 5xx and 403 responses are transient,
+// the 403s are returned as ErrS3Auth as they're permanent once retried. Credentials rejected outright are ErrS3Auth
+// and aren't retried. Other errors are returned as is.
+// nolint:gocyclo // error classification is cyclomatic
+func classifyObjectError(err error) (bool, error) {
+	if errors.Is(err, rcloneFs.ErrorObjectNotFound) {
+		return false, errors.Wrap(ErrFileNotFound, err.Error())
+	}
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch code := apiErr.ErrorCode(); {
+		case code == "NoSuchKey":
+			return false, errors.Wrap(ErrFileNotFound, err.Error())
+		case s3ThrottleErrorCodes[code]:
+			return true, err
+		case code != "AccessDenied" && s3AuthErrorCodes[code]:
+			return false, errors.Wrap(ErrS3Auth, err.Error())
+		}
+	}
+
+	var httpErr interface{ HTTPStatusCode() int }
+	if errors.As(err, &httpErr) {
+		switch statusCode := httpErr.HTTPStatusCode(); {
+		case statusCode == http.StatusNotFound:
+			return false, errors.Wrap(ErrFileNotFound, err.Error())
+		case statusCode == http.StatusForbidden:
+			return true, errors.Wrap(ErrS3Auth, err.Error())
+		case statusCode == http.StatusTooManyRequests || statusCode >= http.StatusInternalServerError:
+			return true, err
+		}
+	}
+
+	return false, err
+}
+
+// nolint:gocyclo // error classification is cyclomatic
+func classifyS3Error(err error) error {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return errors.Wrap(ErrS3EndpointDNS, err.Error())
+	}
+
+	var (
+		certVerifyErr   *tls.CertificateVerificationError
+		recordHeaderErr tls.RecordHeaderError
+		unknownAuthErr  x509.UnknownAuthorityError
+		hostnameErr     x509.HostnameError
+		certInvalidErr  x509.CertificateInvalidError
+	)
+
+	if errors.As(err, &certVerifyErr) ||
+		errors.As(err, &recordHeaderErr) ||
+		errors.As(err, &unknownAuthErr) ||
+		errors.As(err, &hostnameErr) ||
+		errors.As(err, &certInvalidErr) {
+		return errors.Wrap(ErrS3EndpointTLS, err.Error())
+	}
+
+	if errors.Is(err, rcloneFs.ErrorDirNotFound) {
+		return errors.Wrap(ErrS3BucketNotFound, err.Error())
+	}
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		if apiErr.ErrorCode() == "NoSuchBucket" {
+			return errors.Wrap(ErrS3BucketNotFound, err.Error())
+		}
+
+		if s3AuthErrorCodes[apiErr.ErrorCode()] {
+			return errors.Wrap(ErrS3Auth, err.Error())
+		}
+	}
+
+	var httpErr interface{ HTTPStatusCode() int }
+	if errors.As(err, &httpErr) {
+		switch httpErr.HTTPStatusCode() {
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return errors.Wrap(ErrS3Auth, err.Error())
+		case http.StatusNotFound:
+			return errors.Wrap(ErrS3BucketNotFound, err.Error())
+		}
+	}
+
+	return errors.Wrap(ErrS3Connectivity, err.Error())
+}
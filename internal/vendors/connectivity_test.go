@@ -0,0 +1,283 @@
+package vendors
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/smithy-go"
+	"github.com/pkg/errors"
+	rcloneFs "github.com/rclone/rclone/fs"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/metal-toolbox/firmware-syncer/internal/config"
+)
+
+func s3ErrorHandler(statusCode int, code string) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/xml")
+		w.WriteHeader(statusCode)
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>%s</Code><Message>%s</Message></Error>`, code, code)
+	}
+}
+
+func s3EmptyListHandler(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/xml")
+	fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?>`+
+		`<ListBucketResult><Name>foobar</Name><IsTruncated>false</IsTruncated></ListBucketResult>`)
+}
+
+func Test_ProbeS3Fs(t *testing.T) {
+	// single attempt per request, so retried failures don't slow the test down
+	ctx, ci := rcloneFs.AddConfig(context.Background())
+	ci.LowLevelRetries = 1
+
+	cases := []struct {
+		name     string
+		handler  http.HandlerFunc
+		tls      bool
+		endpoint string
+		err      error
+	}{
+		{
+			name:    "reachable",
+			handler: s3EmptyListHandler,
+		},
+		{
+			name:     "dns failure",
+			endpoint: "http://s3.firmware-syncer.invalid",
+			err:      ErrS3EndpointDNS,
+		},
+		{
+			name:    "tls failure",
+			handler: s3EmptyListHandler,
+			tls:     true,
+			err:     ErrS3EndpointTLS,
+		},
+		{
+			name:    "auth failure",
+			handler: s3ErrorHandler(http.StatusForbidden, "AccessDenied"),
+			err:     ErrS3Auth,
+		},
+		{
+			name:    "bucket missing",
+			handler: s3ErrorHandler(http.StatusNotFound, "NoSuchBucket"),
+			err:     ErrS3BucketNotFound,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			endpoint := tc.endpoint
+
+			if tc.handler != nil {
+				var ts *httptest.Server
+				if tc.tls {
+					ts = httptest.NewTLSServer(tc.handler)
+				} else {
+					ts = httptest.NewServer(tc.handler)
+				}
+				defer ts.Close()
+
+				endpoint = ts.URL
+			}
+
+			cfg := &config.S3Bucket{
+				Region:            "region",
+				Endpoint:          endpoint,
+				Bucket:            "foobar",
+				AccessKey:         "access",
+				SecretKey:         "sekrit",
+				ProbeConnectivity: true,
+			}
+
+			_, err := InitS3Fs(ctx, cfg, "/")
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func Test_WaitS3Fs(t *testing.T) {
+	ctx, ci := rcloneFs.AddConfig(context.Background())
+	ci.LowLevelRetries = 1
+
+	backoff := probeBackoff
+	probeBackoff = 10 * time.Millisecond
+
+	t.Cleanup(func() { probeBackoff = backoff })
+
+	cases := []struct {
+		name string
+		// unavailable is the number of requests failing with a 503 before the bucket is reachable
+		unavailable  int32
+		unauthorized bool
+		err          error
+	}{
+		{
+			name:        "reachable once started",
+			unavailable: 3,
+		},
+		{
+			name:         "unauthorized",
+			unauthorized: true,
+			err:          ErrS3Auth,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var requests atomic.Int32
+
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case tc.unauthorized:
+					s3ErrorHandler(http.StatusForbidden, "InvalidAccessKeyId")(w, r)
+				case requests.Add(1) <= tc.unavailable:
+					s3ErrorHandler(http.StatusServiceUnavailable, "ServiceUnavailable")(w, r)
+				default:
+					s3EmptyListHandler(w, r)
+				}
+			}))
+			defer ts.Close()
+
+			cfg := &config.S3Bucket{
+				Region:            "region",
+				Endpoint:          ts.URL,
+				Bucket:            "foobar",
+				AccessKey:         "access",
+				SecretKey:         "sekrit",
+				ProbeConnectivity: true,
+				ProbeTimeout:      time.Minute,
+			}
+
+			start := time.Now()
+
+			_, err := InitS3Fs(ctx, cfg, "/")
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				assert.Less(t, time.Since(start), cfg.ProbeTimeout, "authentication failures aren't waited on")
+
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Greater(t, requests.Load(), tc.unavailable)
+		})
+	}
+}
+
+// statusError is an error carrying the HTTP status code of the failed request, as the aws sdk response errors.
+type statusError struct {
+	statusCode int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("status code %d", e.statusCode)
+}
+
+func (e *statusError) HTTPStatusCode() int {
+	return e.statusCode
+}
+
+func Test_classifyObjectError(t *testing.T) {
+	errUnexpected := errors.New("unexpected")
+
+	cases := []struct {
+		name      string
+		err       error
+		transient bool
+		expected  error
+	}{
+		{"object not found", rcloneFs.ErrorObjectNotFound, false, ErrFileNotFound},
+		{"no such key", &smithy.GenericAPIError{Code: "NoSuchKey"}, false, ErrFileNotFound},
+		{"404", &statusError{http.StatusNotFound}, false, ErrFileNotFound},
+		{"403 without a body, as for a HEAD", &statusError{http.StatusForbidden}, true, ErrS3Auth},
+		{"403 wrapped", errors.Wrap(&statusError{http.StatusForbidden}, "AccessDenied"), true, ErrS3Auth},
+		{"invalid access key", &smithy.GenericAPIError{Code: "InvalidAccessKeyId"}, false, ErrS3Auth},
+		{"slow down", &smithy.GenericAPIError{Code: "SlowDown"}, true, nil},
+		{"429", &statusError{http.StatusTooManyRequests}, true, nil},
+		{"503", &statusError{http.StatusServiceUnavailable}, true, nil},
+		{"400", &statusError{http.StatusBadRequest}, false, nil},
+		{"other error", errUnexpected, false, errUnexpected},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			transient, err := classifyObjectError(tc.err)
+
+			assert.Equal(t, tc.transient, transient)
+
+			expected := tc.expected
+			if expected == nil {
+				expected = tc.err
+			}
+
+			assert.ErrorIs(t, err, expected)
+		})
+	}
+}
+
+func Test_retryObjectOperation(t *testing.T) {
+	backoff := objectBackoff
+	objectBackoff = time.Millisecond
+
+	t.Cleanup(func() { objectBackoff = backoff })
+
+	cases := []struct {
+		name     string
+		errs     []error
+		attempts int
+		err      error
+	}{
+		{
+			name:     "transient 403 retried",
+			errs:     []error{&statusError{http.StatusForbidden}, &statusError{http.StatusForbidden}},
+			attempts: 3,
+		},
+		{
+			name:     "persistent 403",
+			errs:     []error{&statusError{http.StatusForbidden}, &statusError{http.StatusForbidden}, &statusError{http.StatusForbidden}},
+			attempts: objectAttempts,
+			err:      ErrS3Auth,
+		},
+		{
+			name:     "not found isn't retried",
+			errs:     []error{rcloneFs.ErrorObjectNotFound},
+			attempts: 1,
+			err:      ErrFileNotFound,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			attempts := 0
+
+			err := retryObjectOperation(context.Background(), func() error {
+				attempts++
+				if attempts <= len(tc.errs) {
+					return tc.errs[attempts-1]
+				}
+
+				return nil
+			})
+
+			assert.Equal(t, tc.attempts, attempts)
+
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
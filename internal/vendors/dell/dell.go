@@ -0,0 +1,56 @@
+package dell
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/metal-toolbox/firmware-syncer/internal/vendors"
+
+	fleetdbapi "github.com/metal-toolbox/fleetdb/pkg/api/v1"
+)
+
+type Downloader struct {
+	logger   *logrus.Logger
+	verifier *SignatureVerifier
+}
+
+// NewDellDownloader creates a new Downloader for the Dell DUPs, verifying their signature with the given verifier.
+func NewDellDownloader(logger *logrus.Logger, verifier *SignatureVerifier) vendors.Downloader {
+	return &Downloader{logger: logger, verifier: verifier}
+}
+
+// Download will download the DUP for the given firmware to the given downloadDir,
+// and will return the full path to the DUP once its signature is verified, see SignatureVerifier.
+//
+// DUPs which aren't PE executables, as Linux DUPs, are logged and returned as is,
+// unsigned executables and executables without a trusted signature are rejected.
+func (d *Downloader) Download(ctx context.Context, downloadDir string, firmware *fleetdbapi.ComponentFirmwareVersion) (*vendors.FirmwareFile, error) {
+	downloadDir, err := vendors.FirmwareDownloadDir(downloadDir, firmware)
+	if err != nil {
+		return nil, err
+	}
+
+	dupPath, err := vendors.DownloadFirmwareArchive(ctx, downloadDir, firmware.UpstreamURL, "")
+	if err != nil {
+		return nil, err
+	}
+
+	err = d.verifier.Verify(dupPath)
+	if errors.Is(err, ErrDUPUnsupported) {
+		d.logger.WithError(err).
+			WithField("firmware", firmware.Filename).
+			Warn("DUP isn't a PE executable, its signature isn't verified")
+
+		return &vendors.FirmwareFile{Path: dupPath}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	d.logger.WithField("firmware", firmware.Filename).Debug("DUP signature verified")
+
+	return &vendors.FirmwareFile{Path: dupPath}, nil
+}
@@ -0,0 +1,591 @@
+package dell
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"debug/pe"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	fleetdbapi "github.com/metal-toolbox/fleetdb/pkg/api/v1"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/metal-toolbox/firmware-syncer/internal/logging"
+)
+
+var (
+	oidData        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidContentType = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidSHA256      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidECDSA       = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+)
+
+// testPEHeaderOffset is the offset of the PE header in the executables writeDUP writes
+const testPEHeaderOffset = 0x40
+
+type testCertificate struct {
+	certificate *x509.Certificate
+	key         *ecdsa.PrivateKey
+}
+
+// newCertificate returns a certificate signed by the parent, a self-signed CA certificate when the parent is nil.
+func newCertificate(t *testing.T, name string, parent *testCertificate, usages ...x509.ExtKeyUsage) *testCertificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name, Organization: []string{"Dell Inc."}},
+		// DUPs are signed with certificates which have since expired
+		NotBefore:   time.Now().AddDate(-3, 0, 0),
+		NotAfter:    time.Now().AddDate(-1, 0, 0),
+		ExtKeyUsage: usages,
+	}
+
+	signer, signerKey := template, key
+
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign
+		template.NotBefore = template.NotBefore.AddDate(-1, 0, 0)
+		template.NotAfter = time.Now().AddDate(10, 0, 0)
+	} else {
+		signer, signerKey = parent.certificate, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testCertificate{certificate: certificate, key: key}
+}
+
+func writeRoots(t *testing.T, roots ...*testCertificate) string {
+	t.Helper()
+
+	var bundle bytes.Buffer
+
+	for _, root := range roots {
+		if err := pem.Encode(&bundle, &pem.Block{Type: "CERTIFICATE", Bytes: root.certificate.Raw}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "roots.pem")
+	if err := os.WriteFile(path, bundle.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+// signatureOptions alter the signature signatureWith returns,
+// the zero value is the signature of the executables writeDUP writes, signed within the signer certificate validity.
+type signatureOptions struct {
+	// imageDigest replaces the digest of the executable in the signed content
+	imageDigest []byte
+	// digested replaces the signed content in the message digest of the signed attributes
+	digested []byte
+	// signingTime replaces the signing time in the signed attributes
+	signingTime time.Time
+	// noSigningTime leaves the signing time out of the signed attributes
+	noSigningTime bool
+	// timestamper countersigns the signature at timestampTime
+	timestamper   *testCertificate
+	timestampTime time.Time
+}
+
+// signature returns an Authenticode signature of the writeDUP executables holding the certificates,
+// signed with the key of the signer.
+func signature(t *testing.T, signer *testCertificate, certificates ...*x509.Certificate) []byte {
+	t.Helper()
+
+	return signatureWith(t, signer, signatureOptions{}, certificates...)
+}
+
+// signatureWith returns the signature as signature does, altered with the options.
+func signatureWith(t *testing.T, signer *testCertificate, options signatureOptions, certificates ...*x509.Certificate) []byte {
+	t.Helper()
+
+	var raw []byte
+	for _, certificate := range certificates {
+		raw = append(raw, certificate.Raw...)
+	}
+
+	if options.imageDigest == nil {
+		options.imageDigest = imageDigest(t)
+	}
+
+	content := marshal(t, spcIndirectDataContent{
+		Data:          asn1.RawValue{FullBytes: marshal(t, struct{ Type asn1.ObjectIdentifier }{oidData})},
+		MessageDigest: digestInfo{DigestAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidSHA256}, Digest: options.imageDigest},
+	})
+
+	if options.digested == nil {
+		var sequence asn1.RawValue
+		if _, err := asn1.Unmarshal(content, &sequence); err != nil {
+			t.Fatal(err)
+		}
+
+		options.digested = sequence.Bytes
+	}
+
+	signingTime := options.signingTime
+	if signingTime.IsZero() {
+		signingTime = signer.certificate.NotBefore.AddDate(0, 1, 0)
+	}
+
+	if options.noSigningTime {
+		signingTime = time.Time{}
+	}
+
+	info := signerInfoOf(t, signer, options.digested, signingTime)
+
+	if options.timestamper != nil {
+		countersignature := signerInfoOf(t, options.timestamper, info.EncryptedDigest, options.timestampTime)
+		info.UnauthenticatedAttributes = asn1.RawValue{
+			Class:      asn1.ClassContextSpecific,
+			Tag:        1,
+			IsCompound: true,
+			Bytes:      marshal(t, attribute{Type: oidCountersignature, Values: []asn1.RawValue{{FullBytes: marshal(t, countersignature)}}}),
+		}
+	}
+
+	signed := marshal(t, signedData{
+		Version:          1,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{{Algorithm: oidSHA256}},
+		ContentInfo: contentInfo{
+			ContentType: oidSpcIndirectData,
+			Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: content},
+		},
+		Certificates: rawCertificates{Raw: marshal(t, asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: raw})},
+		SignerInfos:  []signerInfo{info},
+	})
+
+	return marshal(t, contentInfo{
+		ContentType: oidSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: signed},
+	})
+}
+
+// signerInfoOf returns the signer info of the signer, with the message digest of digested
+// and the signing time, when set, in its signed attributes.
+func signerInfoOf(t *testing.T, signer *testCertificate, digested []byte, signingTime time.Time) signerInfo {
+	t.Helper()
+
+	digest := sha256.Sum256(digested)
+
+	attributes := append(
+		marshal(t, attribute{Type: oidContentType, Values: []asn1.RawValue{{FullBytes: marshal(t, oidSpcIndirectData)}}}),
+		marshal(t, attribute{Type: oidMessageDigest, Values: []asn1.RawValue{{FullBytes: marshal(t, digest[:])}}})...,
+	)
+
+	if !signingTime.IsZero() {
+		attributes = append(attributes,
+			marshal(t, attribute{Type: oidSigningTime, Values: []asn1.RawValue{{FullBytes: marshal(t, signingTime.UTC())}}})...)
+	}
+
+	attributesDigest := sha256.Sum256(marshal(t, asn1.RawValue{Tag: asn1.TagSet, IsCompound: true, Bytes: attributes}))
+
+	encryptedDigest, err := ecdsa.SignASN1(rand.Reader, signer.key, attributesDigest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signerInfo{
+		Version: 1,
+		IssuerAndSerialNumber: issuerAndSerial{
+			IssuerName:   asn1.RawValue{FullBytes: signer.certificate.RawIssuer},
+			SerialNumber: signer.certificate.SerialNumber,
+		},
+		DigestAlgorithm:           pkix.AlgorithmIdentifier{Algorithm: oidSHA256},
+		AuthenticatedAttributes:   asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: attributes},
+		DigestEncryptionAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidECDSA},
+		EncryptedDigest:           encryptedDigest,
+	}
+}
+
+// imageDigest returns the Authenticode digest of the executables writeDUP writes,
+// the digest of the file without the CheckSum field, the certificate table entry and the certificate table.
+func imageDigest(t *testing.T) []byte {
+	t.Helper()
+
+	image, err := os.ReadFile(writeDUP(t, t.TempDir(), nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	optionalHeaderOffset := testPEHeaderOffset + 4 + binary.Size(pe.FileHeader{})
+	checksumOffset := optionalHeaderOffset + 64
+	securityOffset := optionalHeaderOffset + 96 + 8*pe.IMAGE_DIRECTORY_ENTRY_SECURITY
+
+	digest := sha256.New()
+	digest.Write(image[:checksumOffset])
+	digest.Write(image[checksumOffset+4 : securityOffset])
+	digest.Write(image[securityOffset+8:])
+
+	return digest.Sum(nil)
+}
+
+func marshal(t *testing.T, value any) []byte {
+	t.Helper()
+
+	b, err := asn1.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b
+}
+
+// writeDUP writes the headers of a PE executable with the signature in its certificate table, nil writes an unsigned executable.
+func writeDUP(t *testing.T, dir string, signature []byte) string {
+	t.Helper()
+
+	return writeDUPWith(t, dir, signature, winCertTypePKCSSignedData)
+}
+
+// writeDUPWith writes the executable as writeDUP does, with the given WIN_CERTIFICATE type.
+func writeDUPWith(t *testing.T, dir string, signature []byte, certificateType uint16) string {
+	t.Helper()
+
+	var dup bytes.Buffer
+
+	dosHeader := make([]byte, testPEHeaderOffset)
+	copy(dosHeader, "MZ")
+	binary.LittleEndian.PutUint32(dosHeader[0x3c:], testPEHeaderOffset)
+	dup.Write(dosHeader)
+	dup.WriteString("PE\x00\x00")
+
+	optionalHeader := pe.OptionalHeader32{Magic: 0x10b, NumberOfRvaAndSizes: 16}
+	fileHeader := pe.FileHeader{Machine: pe.IMAGE_FILE_MACHINE_I386, SizeOfOptionalHeader: uint16(binary.Size(optionalHeader))}
+
+	certificateTableOffset := dup.Len() + binary.Size(fileHeader) + binary.Size(optionalHeader)
+
+	if signature != nil {
+		optionalHeader.DataDirectory[pe.IMAGE_DIRECTORY_ENTRY_SECURITY] = pe.DataDirectory{
+			VirtualAddress: uint32(certificateTableOffset),
+			Size:           uint32(winCertificateHeaderSize + len(signature)),
+		}
+	}
+
+	for _, header := range []any{fileHeader, optionalHeader} {
+		if err := binary.Write(&dup, binary.LittleEndian, header); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if signature != nil {
+		for _, field := range []any{uint32(winCertificateHeaderSize + len(signature)), uint16(0x0200), certificateType} {
+			if err := binary.Write(&dup, binary.LittleEndian, field); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		dup.Write(signature)
+	}
+
+	path := filepath.Join(dir, "Network_Firmware_6JHVK_WN64_22.31.6_A00.EXE")
+	if err := os.WriteFile(path, dup.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestSignatureVerifier(t *testing.T) {
+	root := newCertificate(t, "Dell Root CA", nil)
+	intermediate := newIntermediate(t, "Dell Code Signing CA", root)
+	signer := newCertificate(t, "Dell Inc.", intermediate, x509.ExtKeyUsageCodeSigning)
+	serverAuth := newCertificate(t, "Dell Inc.", intermediate, x509.ExtKeyUsageServerAuth)
+	timestamper := newCertificate(t, "Dell Timestamping", intermediate, x509.ExtKeyUsageTimeStamping)
+
+	untrustedRoot := newCertificate(t, "Dell Root CA", nil)
+	untrustedSigner := newCertificate(t, "Dell Inc.", untrustedRoot, x509.ExtKeyUsageCodeSigning)
+	untrustedTimestamper := newCertificate(t, "Dell Timestamping", untrustedRoot, x509.ExtKeyUsageTimeStamping)
+
+	verifier, err := NewSignatureVerifier(writeRoots(t, root))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	withinValidity := signer.certificate.NotBefore.AddDate(0, 1, 0)
+	chain := []*x509.Certificate{signer.certificate, intermediate.certificate}
+
+	testCases := []struct {
+		name            string
+		signature       []byte
+		certificateType uint16
+		// tamper modifies the signed executable
+		tamper bool
+		err    error
+	}{
+		{
+			name:      "trusted signer",
+			signature: signature(t, signer, chain...),
+		},
+		{
+			name: "timestamped by a trusted authority",
+			signature: signatureWith(t, signer, signatureOptions{noSigningTime: true, timestamper: timestamper, timestampTime: withinValidity},
+				signer.certificate, intermediate.certificate, timestamper.certificate),
+		},
+		{
+			name: "timestamped by an untrusted authority",
+			signature: signatureWith(t, signer, signatureOptions{timestamper: untrustedTimestamper, timestampTime: withinValidity},
+				signer.certificate, intermediate.certificate, untrustedTimestamper.certificate, untrustedRoot.certificate),
+			err: ErrDUPSignature,
+		},
+		{
+			name:      "signed after the signer certificate expired",
+			signature: signatureWith(t, signer, signatureOptions{signingTime: time.Now()}, chain...),
+			err:       ErrDUPSignature,
+		},
+		{
+			name:      "no signing time, the signer certificate expired",
+			signature: signatureWith(t, signer, signatureOptions{noSigningTime: true}, chain...),
+			err:       ErrDUPSignature,
+		},
+		{
+			name:      "untrusted signer",
+			signature: signature(t, untrustedSigner, untrustedSigner.certificate, untrustedRoot.certificate),
+			err:       ErrDUPSignature,
+		},
+		{
+			name:      "missing intermediate",
+			signature: signature(t, signer, signer.certificate),
+			err:       ErrDUPSignature,
+		},
+		{
+			name:      "signer without the code signing usage",
+			signature: signature(t, serverAuth, serverAuth.certificate, intermediate.certificate),
+			err:       ErrDUPSignature,
+		},
+		{
+			name:      "signer certificate missing",
+			signature: signature(t, signer, intermediate.certificate),
+			err:       ErrDUPSignature,
+		},
+		{
+			name:      "signed with another key",
+			signature: signature(t, &testCertificate{signer.certificate, untrustedSigner.key}, chain...),
+			err:       ErrDUPSignature,
+		},
+		{
+			name:      "signed content digest mismatch",
+			signature: signatureWith(t, signer, signatureOptions{digested: []byte("tampered")}, chain...),
+			err:       ErrDUPSignature,
+		},
+		{
+			name:      "signature of another executable",
+			signature: signatureWith(t, signer, signatureOptions{imageDigest: make([]byte, 32)}, chain...),
+			err:       ErrDUPSignature,
+		},
+		{
+			name:      "executable modified after signing",
+			signature: signature(t, signer, chain...),
+			tamper:    true,
+			err:       ErrDUPSignature,
+		},
+		{
+			name:            "certificate table of another type",
+			signature:       signature(t, signer, chain...),
+			certificateType: 0x0001,
+			err:             ErrDUPSignature,
+		},
+		{
+			name:      "malformed signature",
+			signature: []byte("not a PKCS#7 signature"),
+			err:       ErrDUPSignature,
+		},
+		{
+			name: "unsigned executable",
+			err:  ErrDUPSignature,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			certificateType := tt.certificateType
+			if certificateType == 0 {
+				certificateType = winCertTypePKCSSignedData
+			}
+
+			dup := writeDUPWith(t, t.TempDir(), tt.signature, certificateType)
+
+			if tt.tamper {
+				tamperDUP(t, dup)
+			}
+
+			err := verifier.Verify(dup)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+
+	linuxDUP := filepath.Join(t.TempDir(), "Network_Firmware_6JHVK_LN64_22.31.6_A00.BIN")
+	if err = os.WriteFile(linuxDUP, []byte("#!/bin/sh\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.ErrorIs(t, verifier.Verify(linuxDUP), ErrDUPUnsupported)
+}
+
+// tamperDUP modifies the MS-DOS stub of the executable at path, which the Authenticode digest covers.
+func tamperDUP(t *testing.T, path string) {
+	t.Helper()
+
+	dup, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dup[2] ^= 0xff
+
+	if err = os.WriteFile(path, dup, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newIntermediate returns a CA certificate signed by the root.
+func newIntermediate(t *testing.T, name string, root *testCertificate) *testCertificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name, Organization: []string{"Dell Inc."}},
+		NotBefore:             time.Now().AddDate(-4, 0, 0),
+		NotAfter:              time.Now().AddDate(5, 0, 0),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, root.certificate, &key.PublicKey, root.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testCertificate{certificate: certificate, key: key}
+}
+
+func TestNewSignatureVerifier(t *testing.T) {
+	_, err := NewSignatureVerifier(filepath.Join(t.TempDir(), "missing.pem"))
+	assert.ErrorIs(t, err, ErrTrustedRoots)
+
+	empty := filepath.Join(t.TempDir(), "empty.pem")
+	if err = os.WriteFile(empty, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = NewSignatureVerifier(empty)
+	assert.ErrorIs(t, err, ErrTrustedRoots)
+}
+
+func TestDownload(t *testing.T) {
+	root := newCertificate(t, "Dell Root CA", nil)
+	intermediate := newIntermediate(t, "Dell Code Signing CA", root)
+	signer := newCertificate(t, "Dell Inc.", intermediate, x509.ExtKeyUsageCodeSigning)
+
+	untrustedRoot := newCertificate(t, "Dell Root CA", nil)
+	untrustedSigner := newCertificate(t, "Dell Inc.", untrustedRoot, x509.ExtKeyUsageCodeSigning)
+
+	dups := map[string][]byte{}
+
+	for name, sig := range map[string][]byte{
+		"/trusted.EXE":   signature(t, signer, signer.certificate, intermediate.certificate),
+		"/untrusted.EXE": signature(t, untrustedSigner, untrustedSigner.certificate),
+	} {
+		dup, err := os.ReadFile(writeDUP(t, t.TempDir(), sig))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		dups[name] = dup
+	}
+
+	unsigned, err := os.ReadFile(writeDUP(t, t.TempDir(), nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dups["/unsigned.EXE"] = unsigned
+	dups["/linux.BIN"] = []byte("#!/bin/sh\n")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(dups[r.URL.Path])
+	}))
+	defer server.Close()
+
+	verifier, err := NewSignatureVerifier(writeRoots(t, root))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	downloader := NewDellDownloader(logging.NewLogger("debug"), verifier)
+
+	testCases := []struct {
+		path string
+		err  error
+	}{
+		{"/trusted.EXE", nil},
+		{"/untrusted.EXE", ErrDUPSignature},
+		// removing the signature of a Windows DUP doesn't skip its verification
+		{"/unsigned.EXE", ErrDUPSignature},
+		// Linux DUPs can't be verified, they're synced regardless
+		{"/linux.BIN", nil},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.path, func(t *testing.T) {
+			firmware := &fleetdbapi.ComponentFirmwareVersion{Vendor: "dell", UpstreamURL: server.URL + tt.path}
+
+			dupFile, err := downloader.Download(context.Background(), t.TempDir(), firmware)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.FileExists(t, dupFile.Path)
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package dell
+
+import (
+	"bytes"
+	"crypto"
+	"debug/pe"
+	"encoding/binary"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// winCertificateHeaderSize is the size of the WIN_CERTIFICATE header preceding the signature
+	winCertificateHeaderSize = 8
+	// winCertTypePKCSSignedData is the WIN_CERTIFICATE type of Authenticode signatures
+	winCertTypePKCSSignedData = 0x0002
+	// maxCertificateTableSize bounds the certificate table read from a DUP
+	maxCertificateTableSize = 1 << 20
+
+	// peHeaderPointerOffset is the offset of the file offset of the PE header in the MS-DOS header
+	peHeaderPointerOffset = 0x3c
+	// checksumFieldOffset is the offset of the CheckSum field in the optional header, for PE32 and PE32+ alike
+	checksumFieldOffset = 64
+	// dataDirectoryOffset32 and dataDirectoryOffset64 are the offsets of the data directories in the optional header
+	dataDirectoryOffset32 = 96
+	dataDirectoryOffset64 = 112
+	// dataDirectorySize is the size of a data directory entry
+	dataDirectorySize = 8
+)
+
+// peImage is a PE executable, along with the offsets of the parts its Authenticode digest leaves out.
+type peImage struct {
+	file *os.File
+	size int64
+	// checksumOffset is the file offset of the CheckSum field of the optional header
+	checksumOffset int64
+	// securityOffset is the file offset of the certificate table entry of the data directories
+	securityOffset int64
+	// certificateTable is the certificate table entry, its address is a file offset
+	certificateTable pe.DataDirectory
+}
+
+// parseImage returns the PE executable the file holds, which has to have a certificate table.
+// Files which aren't PE executables are reported as ErrDUPUnsupported.
+func parseImage(f *os.File) (*peImage, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	magic := make([]byte, 2)
+	if _, err = f.ReadAt(magic, 0); err != nil || !bytes.Equal(magic, []byte("MZ")) {
+		return nil, errors.Wrap(ErrDUPUnsupported, "no MS-DOS header")
+	}
+
+	peFile, err := pe.NewFile(f)
+	if err != nil {
+		return nil, errors.Wrap(ErrDUPSignature, err.Error())
+	}
+
+	pointer := make([]byte, 4)
+	if _, err = f.ReadAt(pointer, peHeaderPointerOffset); err != nil {
+		return nil, errors.Wrap(ErrDUPSignature, err.Error())
+	}
+
+	// the optional header follows the PE signature and the file header
+	optionalHeaderOffset := int64(binary.LittleEndian.Uint32(pointer)) + 4 + int64(binary.Size(pe.FileHeader{}))
+
+	image := &peImage{file: f, size: info.Size(), checksumOffset: optionalHeaderOffset + checksumFieldOffset}
+
+	switch header := peFile.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		image.securityOffset = optionalHeaderOffset + dataDirectoryOffset32 + pe.IMAGE_DIRECTORY_ENTRY_SECURITY*dataDirectorySize
+		if header.NumberOfRvaAndSizes > pe.IMAGE_DIRECTORY_ENTRY_SECURITY {
+			image.certificateTable = header.DataDirectory[pe.IMAGE_DIRECTORY_ENTRY_SECURITY]
+		}
+	case *pe.OptionalHeader64:
+		image.securityOffset = optionalHeaderOffset + dataDirectoryOffset64 + pe.IMAGE_DIRECTORY_ENTRY_SECURITY*dataDirectorySize
+		if header.NumberOfRvaAndSizes > pe.IMAGE_DIRECTORY_ENTRY_SECURITY {
+			image.certificateTable = header.DataDirectory[pe.IMAGE_DIRECTORY_ENTRY_SECURITY]
+		}
+	default:
+		return nil, errors.Wrap(ErrDUPSignature, "no optional header")
+	}
+
+	if err = image.checkCertificateTable(); err != nil {
+		return nil, errors.Wrap(ErrDUPSignature, err.Error())
+	}
+
+	return image, nil
+}
+
+// checkCertificateTable checks the certificate table is there, and lies within the file past the headers.
+func (i *peImage) checkCertificateTable() error {
+	table := i.certificateTable
+
+	switch {
+	case table.Size == 0:
+		return errors.New("unsigned executable, no certificate table")
+	case table.Size <= winCertificateHeaderSize || table.Size > maxCertificateTableSize:
+		return errors.Errorf("certificate table size %d", table.Size)
+	case int64(table.VirtualAddress) < i.securityOffset+dataDirectorySize ||
+		int64(table.VirtualAddress)+int64(table.Size) > i.size:
+		return errors.Errorf("certificate table offset %d", table.VirtualAddress)
+	}
+
+	return nil
+}
+
+// signature returns the PKCS#7 signature in the certificate table of the executable.
+func (i *peImage) signature() ([]byte, error) {
+	table := make([]byte, i.certificateTable.Size)
+	if _, err := i.file.ReadAt(table, int64(i.certificateTable.VirtualAddress)); err != nil {
+		return nil, errors.Wrap(ErrDUPSignature, "certificate table: "+err.Error())
+	}
+
+	length := binary.LittleEndian.Uint32(table[0:4])
+	certificateType := binary.LittleEndian.Uint16(table[6:8])
+
+	if certificateType != winCertTypePKCSSignedData {
+		return nil, errors.Wrapf(ErrDUPSignature, "certificate type %#x", certificateType)
+	}
+
+	if length <= winCertificateHeaderSize || length > i.certificateTable.Size {
+		return nil, errors.Wrapf(ErrDUPSignature, "certificate length %d", length)
+	}
+
+	return table[winCertificateHeaderSize:length], nil
+}
+
+// digest returns the Authenticode digest of the executable, the digest of the file
+// without the CheckSum field, the certificate table entry and the certificate table itself.
+func (i *peImage) digest(hash crypto.Hash) ([]byte, error) {
+	tableStart := int64(i.certificateTable.VirtualAddress)
+	tableEnd := tableStart + int64(i.certificateTable.Size)
+
+	ranges := [][2]int64{
+		{0, i.checksumOffset},
+		{i.checksumOffset + 4, i.securityOffset},
+		{i.securityOffset + dataDirectorySize, tableStart},
+		{tableEnd, i.size},
+	}
+
+	h := hash.New()
+
+	for _, r := range ranges {
+		if _, err := io.Copy(h, io.NewSectionReader(i.file, r[0], r[1]-r[0])); err != nil {
+			return nil, err
+		}
+	}
+
+	return h.Sum(nil), nil
+}
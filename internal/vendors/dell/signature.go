@@ -0,0 +1,420 @@
+package dell
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrDUPUnsupported = errors.New("DUP isn't a PE executable")
+	ErrDUPSignature   = errors.New("DUP signature is invalid")
+	ErrTrustedRoots   = errors.New("error loading the Dell trusted roots")
+)
+
+var (
+	oidSignedData       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidMessageDigest    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidSigningTime      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 5}
+	oidCountersignature = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 6}
+	oidSpcIndirectData  = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 2, 1, 4}
+
+	digestAlgorithms = map[string]crypto.Hash{
+		"1.3.14.3.2.26":          crypto.SHA1,
+		"2.16.840.1.101.3.4.2.1": crypto.SHA256,
+		"2.16.840.1.101.3.4.2.2": crypto.SHA384,
+		"2.16.840.1.101.3.4.2.3": crypto.SHA512,
+	}
+)
+
+// the subset of the PKCS#7 and Authenticode structures needed to verify the signature, see RFC 2315
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	ContentInfo      contentInfo
+	Certificates     rawCertificates `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue   `asn1:"optional,tag:1"`
+	SignerInfos      []signerInfo    `asn1:"set"`
+}
+
+type rawCertificates struct {
+	Raw asn1.RawContent
+}
+
+type signerInfo struct {
+	Version                   int
+	IssuerAndSerialNumber     issuerAndSerial
+	DigestAlgorithm           pkix.AlgorithmIdentifier
+	AuthenticatedAttributes   asn1.RawValue `asn1:"optional,tag:0"`
+	DigestEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedDigest           []byte
+	UnauthenticatedAttributes asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+type issuerAndSerial struct {
+	IssuerName   asn1.RawValue
+	SerialNumber *big.Int
+}
+
+type attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values []asn1.RawValue `asn1:"set"`
+}
+
+// spcIndirectDataContent is the content Authenticode signs, holding the digest of the executable.
+type spcIndirectDataContent struct {
+	Data          asn1.RawValue
+	MessageDigest digestInfo
+}
+
+type digestInfo struct {
+	DigestAlgorithm pkix.AlgorithmIdentifier
+	Digest          []byte
+}
+
+// SignatureVerifier verifies the Authenticode signature embedded in Dell DUP executables.
+//
+// The digest of the executable has to match the signed one, and the signer certificate has to chain up
+// to a trusted root with the code signing usage, at the signing time. The signing time is the one of
+// a countersignature by a timestamping authority chaining up to the trusted roots, else the signing time
+// the signer signed, else the current time. RFC 3161 timestamps aren't supported.
+// Linux DUPs (.BIN) aren't PE executables, they're reported as ErrDUPUnsupported.
+type SignatureVerifier struct {
+	roots *x509.CertPool
+}
+
+// NewSignatureVerifier returns a SignatureVerifier trusting the root certificates in the trustedRoots PEM bundle.
+func NewSignatureVerifier(trustedRoots string) (*SignatureVerifier, error) {
+	b, err := os.ReadFile(trustedRoots)
+	if err != nil {
+		return nil, errors.Wrap(ErrTrustedRoots, err.Error())
+	}
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(b) {
+		return nil, errors.Wrap(ErrTrustedRoots, "no certificate found in "+trustedRoots)
+	}
+
+	return &SignatureVerifier{roots: roots}, nil
+}
+
+// Verify checks the DUP at path is a PE executable signed by a trusted signer, see SignatureVerifier.
+// Files which aren't PE executables are reported as ErrDUPUnsupported, unsigned executables as ErrDUPSignature.
+func (v *SignatureVerifier) Verify(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	image, err := parseImage(f)
+	if err != nil {
+		return err
+	}
+
+	signature, err := image.signature()
+	if err != nil {
+		return err
+	}
+
+	signed, err := parseSignedData(signature)
+	if err != nil {
+		return errors.Wrap(ErrDUPSignature, err.Error())
+	}
+
+	if err = verifyImageDigest(image, signed); err != nil {
+		return errors.Wrap(ErrDUPSignature, err.Error())
+	}
+
+	return v.verifySigner(signed)
+}
+
+// verifySigner checks the signer certificate chains up to the trusted roots at the signing time,
+// and that the signer info was signed with its key.
+func (v *SignatureVerifier) verifySigner(signed *signedData) error {
+	certificates, err := parseCertificates(signed)
+	if err != nil {
+		return errors.Wrap(ErrDUPSignature, err.Error())
+	}
+
+	info := signed.SignerInfos[0]
+
+	signer, intermediates, err := signerChain(certificates, info.IssuerAndSerialNumber)
+	if err != nil {
+		return errors.Wrap(ErrDUPSignature, err.Error())
+	}
+
+	// the digest is computed over the content octets of the content, without its tag and length
+	var content asn1.RawValue
+	if _, err = asn1.Unmarshal(signed.ContentInfo.Content.Bytes, &content); err != nil {
+		return errors.Wrap(ErrDUPSignature, "signed content: "+err.Error())
+	}
+
+	if err = verifySignerInfo(signer, &info, content.Bytes); err != nil {
+		return errors.Wrap(ErrDUPSignature, signer.Subject.String()+": "+err.Error())
+	}
+
+	signingTime, err := v.signingTime(certificates, &info)
+	if err != nil {
+		return errors.Wrap(ErrDUPSignature, err.Error())
+	}
+
+	_, err = signer.Verify(x509.VerifyOptions{
+		Roots:         v.roots,
+		Intermediates: intermediates,
+		CurrentTime:   signingTime,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	})
+	if err != nil {
+		return errors.Wrap(ErrDUPSignature, signer.Subject.String()+": "+err.Error())
+	}
+
+	return nil
+}
+
+// signingTime returns the time the signer info was signed at, see SignatureVerifier.
+func (v *SignatureVerifier) signingTime(certificates []*x509.Certificate, info *signerInfo) (time.Time, error) {
+	countersignature, found, err := findAttribute(info.UnauthenticatedAttributes.Bytes, oidCountersignature)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if found {
+		return v.countersigningTime(certificates, info, countersignature)
+	}
+
+	signed, found, err := findAttribute(info.AuthenticatedAttributes.Bytes, oidSigningTime)
+	if err != nil || !found {
+		return time.Now(), err
+	}
+
+	var signingTime time.Time
+	if _, err = asn1.Unmarshal(signed.FullBytes, &signingTime); err != nil {
+		return time.Time{}, errors.Wrap(err, "signing time")
+	}
+
+	return signingTime, nil
+}
+
+// countersigningTime returns the signing time of the countersignature of the signer info,
+// once the countersignature is checked to be signed by a timestamping authority chaining up to the trusted roots.
+func (v *SignatureVerifier) countersigningTime(
+	certificates []*x509.Certificate,
+	info *signerInfo,
+	countersignature asn1.RawValue,
+) (time.Time, error) {
+	var counterInfo signerInfo
+	if _, err := asn1.Unmarshal(countersignature.FullBytes, &counterInfo); err != nil {
+		return time.Time{}, errors.Wrap(err, "countersignature")
+	}
+
+	timestamper, intermediates, err := signerChain(certificates, counterInfo.IssuerAndSerialNumber)
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "countersignature")
+	}
+
+	// the countersignature signs the signature of the signer info
+	if err = verifySignerInfo(timestamper, &counterInfo, info.EncryptedDigest); err != nil {
+		return time.Time{}, errors.Wrap(err, "countersignature of "+timestamper.Subject.String())
+	}
+
+	signed, found, err := findAttribute(counterInfo.AuthenticatedAttributes.Bytes, oidSigningTime)
+	if err != nil || !found {
+		return time.Time{}, errors.New("countersignature without signing time")
+	}
+
+	var signingTime time.Time
+	if _, err = asn1.Unmarshal(signed.FullBytes, &signingTime); err != nil {
+		return time.Time{}, errors.Wrap(err, "countersignature signing time")
+	}
+
+	_, err = timestamper.Verify(x509.VerifyOptions{
+		Roots:         v.roots,
+		Intermediates: intermediates,
+		CurrentTime:   signingTime,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping},
+	})
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "countersignature of "+timestamper.Subject.String())
+	}
+
+	return signingTime, nil
+}
+
+// parseSignedData returns the PKCS#7 signed data of the signature, which has a single signer.
+func parseSignedData(signature []byte) (*signedData, error) {
+	var info contentInfo
+	if _, err := asn1.Unmarshal(signature, &info); err != nil {
+		return nil, err
+	}
+
+	if !info.ContentType.Equal(oidSignedData) {
+		return nil, errors.New("not a PKCS#7 signed data: " + info.ContentType.String())
+	}
+
+	signed := &signedData{}
+	if _, err := asn1.Unmarshal(info.Content.Bytes, signed); err != nil {
+		return nil, err
+	}
+
+	if len(signed.SignerInfos) != 1 {
+		return nil, errors.Errorf("%d signers, expected one", len(signed.SignerInfos))
+	}
+
+	return signed, nil
+}
+
+// verifyImageDigest checks the signed content is an Authenticode digest matching the digest of the executable.
+func verifyImageDigest(image *peImage, signed *signedData) error {
+	if !signed.ContentInfo.ContentType.Equal(oidSpcIndirectData) {
+		return errors.New("signed content isn't an Authenticode digest: " + signed.ContentInfo.ContentType.String())
+	}
+
+	var content spcIndirectDataContent
+	if _, err := asn1.Unmarshal(signed.ContentInfo.Content.Bytes, &content); err != nil {
+		return errors.Wrap(err, "signed content")
+	}
+
+	algorithm := content.MessageDigest.DigestAlgorithm.Algorithm.String()
+
+	hash, ok := digestAlgorithms[algorithm]
+	if !ok {
+		return errors.New("unsupported digest algorithm " + algorithm)
+	}
+
+	digest, err := image.digest(hash)
+	if err != nil {
+		return errors.Wrap(err, "executable digest")
+	}
+
+	if !bytes.Equal(digest, content.MessageDigest.Digest) {
+		return errors.New("executable digest mismatch")
+	}
+
+	return nil
+}
+
+// parseCertificates returns the certificates of the signed data.
+func parseCertificates(signed *signedData) ([]*x509.Certificate, error) {
+	var certificatesSet asn1.RawValue
+	if _, err := asn1.Unmarshal(signed.Certificates.Raw, &certificatesSet); err != nil {
+		return nil, errors.Wrap(err, "certificates")
+	}
+
+	return x509.ParseCertificates(certificatesSet.Bytes)
+}
+
+// signerChain returns the certificate identified by id, along with the other certificates as its intermediates.
+func signerChain(certificates []*x509.Certificate, id issuerAndSerial) (*x509.Certificate, *x509.CertPool, error) {
+	var signer *x509.Certificate
+
+	intermediates := x509.NewCertPool()
+
+	for _, certificate := range certificates {
+		if bytes.Equal(certificate.RawIssuer, id.IssuerName.FullBytes) && certificate.SerialNumber.Cmp(id.SerialNumber) == 0 {
+			signer = certificate
+			continue
+		}
+
+		intermediates.AddCert(certificate)
+	}
+
+	if signer == nil {
+		return nil, nil, errors.New("signer certificate not found")
+	}
+
+	return signer, intermediates, nil
+}
+
+// verifySignerInfo checks the signed attributes of the signer info hold the digest of the content,
+// and that they were signed with the key of the signer certificate.
+func verifySignerInfo(signer *x509.Certificate, info *signerInfo, content []byte) error {
+	hash, ok := digestAlgorithms[info.DigestAlgorithm.Algorithm.String()]
+	if !ok {
+		return errors.New("unsupported digest algorithm " + info.DigestAlgorithm.Algorithm.String())
+	}
+
+	if len(info.AuthenticatedAttributes.Bytes) == 0 {
+		return errors.New("no signed attributes")
+	}
+
+	value, found, err := findAttribute(info.AuthenticatedAttributes.Bytes, oidMessageDigest)
+	if err != nil {
+		return err
+	}
+
+	if !found {
+		return errors.New("no message digest in the signed attributes")
+	}
+
+	var digest []byte
+	if _, err = asn1.Unmarshal(value.FullBytes, &digest); err != nil {
+		return errors.Wrap(err, "message digest")
+	}
+
+	contentHash := hash.New()
+	contentHash.Write(content)
+
+	if !bytes.Equal(digest, contentHash.Sum(nil)) {
+		return errors.New("signed content digest mismatch")
+	}
+
+	// the signed attributes are signed with their SET OF tag, rather than the implicit one of the signer info
+	attributes := append([]byte{}, info.AuthenticatedAttributes.FullBytes...)
+	attributes[0] = 0x31
+
+	attributesHash := hash.New()
+	attributesHash.Write(attributes)
+
+	return checkSignature(signer.PublicKey, hash, attributesHash.Sum(nil), info.EncryptedDigest)
+}
+
+// findAttribute returns the value of the single valued attribute of the given type in the attributes.
+func findAttribute(attributes []byte, attributeType asn1.ObjectIdentifier) (asn1.RawValue, bool, error) {
+	for rest := attributes; len(rest) > 0; {
+		var attr attribute
+
+		var err error
+		if rest, err = asn1.Unmarshal(rest, &attr); err != nil {
+			return asn1.RawValue{}, false, errors.Wrap(err, "attributes")
+		}
+
+		if attr.Type.Equal(attributeType) && len(attr.Values) == 1 {
+			return attr.Values[0], true, nil
+		}
+	}
+
+	return asn1.RawValue{}, false, nil
+}
+
+// checkSignature checks the signature of the digest with the public key of the signer.
+func checkSignature(publicKey any, hash crypto.Hash, digest, signature []byte) error {
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(key, hash, digest, signature); err != nil {
+			return errors.Wrap(err, "signer info signature")
+		}
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(key, digest, signature) {
+			return errors.New("signer info signature is invalid")
+		}
+	default:
+		return errors.Errorf("unsupported signer key %T", publicKey)
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package vendors
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"syscall"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs/fshttp"
+)
+
+var ErrInsufficientSpace = errors.New("insufficient disk space for download")
+
+// availableSpace returns the bytes available on the filesystem of dir,
+// it's a variable so tests can fake the filesystem stats.
+var availableSpace = func(dir string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return 0, err
+	}
+
+	return stat.Bavail * uint64(stat.Bsize), nil // nolint:gosec // the block size is never negative
+}
+
+// checkAvailableSpace returns an ErrInsufficientSpace when the filesystem of dir has less than the required bytes available,
+// the check is skipped when the required size is unknown.
+func checkAvailableSpace(dir string, required int64) error {
+	if required <= 0 {
+		return nil
+	}
+
+	available, err := availableSpace(dir)
+	if err != nil {
+		return errors.Wrap(err, "failure checking available disk space")
+	}
+
+	if available < uint64(required) {
+		return errors.Wrap(ErrInsufficientSpace, fmt.Sprintf("%s: required %d bytes, available %d bytes", dir, required, available))
+	}
+
+	return nil
+}
+
+// remoteContentLength returns the Content-Length the server declares for the URL,
+// -1 is returned when it's unknown or the server doesn't support HEAD requests.
+func remoteContentLength(ctx context.Context, downloadURL string) int64 {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, downloadURL, http.NoBody)
+	if err != nil {
+		return -1
+	}
+
+	resp, err := fshttp.NewClient(ctx).Do(req)
+	if err != nil {
+		return -1
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return -1
+	}
+
+	return resp.ContentLength
+}
@@ -0,0 +1,97 @@
+package vendors
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	rcloneFs "github.com/rclone/rclone/fs"
+	"github.com/stretchr/testify/assert"
+)
+
+func fakeAvailableSpace(t *testing.T, available uint64) {
+	t.Helper()
+
+	original := availableSpace
+	t.Cleanup(func() { availableSpace = original })
+
+	availableSpace = func(string) (uint64, error) {
+		return available, nil
+	}
+}
+
+func Test_CheckAvailableSpace(t *testing.T) {
+	testCases := []struct {
+		name          string
+		available     uint64
+		required      int64
+		expectedError error
+	}{
+		{
+			name:      "sufficient space",
+			available: 2048,
+			required:  1024,
+		},
+		{
+			name:      "exactly enough space",
+			available: 1024,
+			required:  1024,
+		},
+		{
+			name:          "insufficient space",
+			available:     512,
+			required:      1024,
+			expectedError: ErrInsufficientSpace,
+		},
+		{
+			name:      "unknown size",
+			available: 0,
+			required:  -1,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeAvailableSpace(t, tt.available)
+
+			err := checkAvailableSpace(t.TempDir(), tt.required)
+			if tt.expectedError == nil {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.ErrorIs(t, err, tt.expectedError)
+			assert.ErrorContains(t, err, "required 1024 bytes, available 512 bytes")
+		})
+	}
+}
+
+func Test_DownloadFirmwareArchiveInsufficientSpace(t *testing.T) {
+	var downloads int
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "1024")
+
+		if r.Method == http.MethodGet {
+			downloads++
+			_, _ = w.Write(make([]byte, 1024))
+		}
+	}))
+	defer server.Close()
+
+	ctx, ci := rcloneFs.AddConfig(context.Background())
+	ci.LowLevelRetries = 1
+
+	fakeAvailableSpace(t, 512)
+
+	_, err := DownloadFirmwareArchive(ctx, t.TempDir(), server.URL+"/firmware.zip", "")
+	assert.ErrorIs(t, err, ErrInsufficientSpace)
+	assert.Zero(t, downloads, "the download should not start")
+
+	fakeAvailableSpace(t, 2048)
+
+	_, err = DownloadFirmwareArchive(ctx, t.TempDir(), server.URL+"/firmware.zip", "")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, downloads)
+}
@@ -10,6 +10,7 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -47,18 +48,36 @@ var (
 	ErrDirEmpty        = errors.New("directory empty")
 	ErrModTimeFile     = errors.New("error retrieving file mod time")
 	ErrCreatingTmpDir  = errors.New("error creating tmp dir")
+	ErrUploadPath      = errors.New("firmware to upload is outside the tmp directory")
 
 	ErrUnexpectedStatusCode = errors.New("unexpected status code")
 	ErrDownloadingFile      = errors.New("failed to download file")
+	ErrTruncatedDownload    = errors.New("download is shorter than its declared content length")
 )
 
-//go:generate mockgen -source=downloader.go -destination=mocks/downloader.go Downloader
+//go:generate mockgen -source=downloader.go -destination=downloader_mock_test.go -package=vendors -self_package=github.com/metal-toolbox/firmware-syncer/internal/vendors Downloader
 
 // Downloader is something that can download a file for a given firmware.
 type Downloader interface {
 	// Download takes in the directory to download the file to, and the firmware to be downloaded.
-	// It should also return the full path to the downloaded file.
-	Download(ctx context.Context, downloadDir string, firmware *fleetdbapi.ComponentFirmwareVersion) (string, error)
+	// It should also return the downloaded file, along with the archive sizes when it was extracted from an archive.
+	Download(ctx context.Context, downloadDir string, firmware *fleetdbapi.ComponentFirmwareVersion) (*FirmwareFile, error)
+}
+
+// FirmwareFile is a firmware file downloaded, or extracted from a downloaded archive.
+type FirmwareFile struct {
+	// Path is the full path to the firmware file
+	Path string
+	// ArchiveSizes are the sizes of the archive the firmware was extracted from, nil when it wasn't extracted
+	ArchiveSizes *ArchiveSizes
+}
+
+// ServerSideCopier is a Downloader able to copy the firmware straight to the destination,
+// without the firmware transiting through the local filesystem.
+type ServerSideCopier interface {
+	// ServerSideCopy copies the firmware to destPath on dstFs, false is returned when the firmware
+	// can't be copied server-side and has to be downloaded and verified instead.
+	ServerSideCopy(ctx context.Context, dstFs rcloneFs.Fs, destPath string, firmware *fleetdbapi.ComponentFirmwareVersion) (bool, error)
 }
 
 // DownloaderStats includes fields for stats on file/object transfer for Downloader
@@ -71,6 +90,11 @@ type DownloaderStats struct {
 // LocalFsConfig for the downloader
 type LocalFsConfig struct {
 	Root string
+	// CopyLinks, OneFileSystem and NoPreallocation override the rclone local backend options of the same name,
+	// they default to true when not set.
+	CopyLinks       *bool
+	OneFileSystem   *bool
+	NoPreallocation *bool
 }
 
 func SetRcloneLogging(logger *logrus.Logger) {
@@ -88,10 +112,6 @@ func SrcPath(fw *fleetdbapi.ComponentFirmwareVersion) string {
 	return u.Path
 }
 
-func DstPath(fw *fleetdbapi.ComponentFirmwareVersion) string {
-	return path.Join(fw.Vendor, fw.Filename)
-}
-
 // InitLocalFs initializes and returns a rcloneFs.Fs interface on the local filesystem
 func InitLocalFs(ctx context.Context, cfg *LocalFsConfig) (rcloneFs.Fs, error) {
 	if cfg == nil {
@@ -102,23 +122,35 @@ func InitLocalFs(ctx context.Context, cfg *LocalFsConfig) (rcloneFs.Fs, error) {
 		return nil, errors.Wrap(ErrRootDirUndefined, "initLocalFs")
 	}
 
+	fs, err := rcloneLocal.NewFs(ctx, "local://"+cfg.Root, cfg.Root, localConfigmap(cfg))
+	if err != nil {
+		return nil, errors.Wrap(ErrInitFSDownloader, err.Error())
+	}
+
+	return fs, nil
+}
+
+// localConfigmap returns the rclone local backend options for the local fs.
+func localConfigmap(cfg *LocalFsConfig) rcloneConfigmap.Simple {
 	// https://github.com/rclone/rclone/blob/master/backend/local/local.go#L40
-	opts := rcloneConfigmap.Simple{
+	return rcloneConfigmap.Simple{
 		"type":             "local",
-		"copy_links":       "true",
+		"copy_links":       formatBoolOption(cfg.CopyLinks, true),
 		"no_check_updated": "false",
-		"one_file_system":  "true",
+		"one_file_system":  formatBoolOption(cfg.OneFileSystem, true),
 		"case_sensitive":   "true",
-		"no_preallocation": "true",
+		"no_preallocation": formatBoolOption(cfg.NoPreallocation, true),
 		"no_set_modtime":   "false",
 	}
+}
 
-	fs, err := rcloneLocal.NewFs(ctx, "local://"+cfg.Root, cfg.Root, opts)
-	if err != nil {
-		return nil, errors.Wrap(ErrInitFSDownloader, err.Error())
+// formatBoolOption returns the option value as an rclone option, the default value when it's not set.
+func formatBoolOption(value *bool, defaultValue bool) string {
+	if value == nil {
+		return strconv.FormatBool(defaultValue)
 	}
 
-	return fs, nil
+	return strconv.FormatBool(*value)
 }
 
 // InitS3Fs initializes and returns a rcloneFs.Fs interface on an s3 store
@@ -153,6 +185,29 @@ func InitS3Fs(ctx context.Context, cfg *config.S3Bucket, root string) (rcloneFs.
 		root = "/" + root
 	}
 
+	if err := cfg.ValidateUploadOptions(); err != nil {
+		return nil, errors.Wrap(ErrInitS3Fs, err.Error())
+	}
+
+	mount := cfg.Bucket + root
+
+	fs, err := rcloneS3.NewFs(ctx, "s3://"+mount, mount, s3Configmap(cfg))
+	if err != nil {
+		return nil, errors.Wrap(ErrInitS3Fs, err.Error())
+	}
+
+	if cfg.ProbeConnectivity {
+		if err := waitS3Fs(ctx, fs, cfg.ProbeTimeout); err != nil {
+			return nil, err
+		}
+	}
+
+	return fs, nil
+}
+
+// s3Configmap returns the rclone s3 backend options for the bucket,
+// the transient 403s returned for object lookups despite no_head are retried, see retryObjectOperation.
+func s3Configmap(cfg *config.S3Bucket) rcloneConfigmap.Simple {
 	// https://github.com/rclone/rclone/blob/master/backend/s3/s3.go#L126
 	opts := rcloneConfigmap.Simple{
 		"type":                 "s3",
@@ -161,7 +216,7 @@ func InitS3Fs(ctx context.Context, cfg *config.S3Bucket, root string) (rcloneFs.
 		"access_key_id":        cfg.AccessKey,
 		"secret_access_key":    cfg.SecretKey,
 		"endpoint":             cfg.Endpoint,
-		"leave_parts_on_error": "true",
+		"leave_parts_on_error": strconv.FormatBool(cfg.LeavePartsOnError),
 		"disable_http2":        "true",  // https://github.com/rclone/rclone/issues/3631
 		"chunk_size":           "10M",   // upload chunksize, the bytes buffered from the source before upload to destination
 		"list_chunk":           "1000",  // number of objects to return in a listing
@@ -174,14 +229,36 @@ func InitS3Fs(ctx context.Context, cfg *config.S3Bucket, root string) (rcloneFs.
 		"no_head":              "true", // XXX 1.60.0 introduced s3 versions support and it issues a HEAD request with ?VersionId which causes a 403 error in our case.
 	}
 
-	mount := cfg.Bucket + root
+	if cfg.StorageClass != "" {
+		opts["storage_class"] = cfg.StorageClass
+	}
 
-	fs, err := rcloneS3.NewFs(ctx, "s3://"+mount, mount, opts)
-	if err != nil {
-		return nil, errors.Wrap(ErrInitS3Fs, err.Error())
+	if cfg.ACL != "" {
+		opts["acl"] = cfg.ACL
 	}
 
-	return fs, nil
+	if cfg.SSEAlgorithm != "" {
+		opts["server_side_encryption"] = cfg.SSEAlgorithm
+	}
+
+	if cfg.SSEKMSKeyID != "" {
+		opts["sse_kms_key_id"] = cfg.SSEKMSKeyID
+	}
+
+	return opts
+}
+
+// SameS3Account returns true when both buckets are reached with the same endpoint, region and credentials,
+// so objects can be copied server-side between them.
+func SameS3Account(src, dst *config.S3Bucket) bool {
+	if src == nil || dst == nil {
+		return false
+	}
+
+	return src.Endpoint == dst.Endpoint &&
+		src.Region == dst.Region &&
+		src.AccessKey == dst.AccessKey &&
+		src.SecretKey == dst.SecretKey
 }
 
 // SplitURLPath returns the URL host and Path parts while including the URL scheme, user info and fragments if any
@@ -210,17 +287,28 @@ func SplitURLPath(httpURL string) (hostPart, pathPart string, err error) {
 	return hostPart, pathPart, nil
 }
 
-// DownloadFirmwareArchive downloads a zip archive from archiveURL to tmpDir optionally checking the archive checksum
+// DownloadFirmwareArchive downloads an archive from archiveURL to tmpDir optionally checking the archive checksum
 func DownloadFirmwareArchive(ctx context.Context, tmpDir, archiveURL, archiveChecksum string) (string, error) {
 	zipArchivePath := path.Join(tmpDir, filepath.Base(archiveURL))
 
-	out, err := os.Create(zipArchivePath)
+	download := downloadHTTP
+
+	switch {
+	case isFTPURL(archiveURL):
+		download = downloadFTP
+	case isWebDAVURL(archiveURL):
+		download = downloadWebDAV
+	case isGitURL(archiveURL):
+		download = downloadGit
+		zipArchivePath = path.Join(tmpDir, gitFilename(archiveURL))
+	}
+
+	err := download(ctx, archiveURL, zipArchivePath)
 	if err != nil {
 		return "", err
 	}
 
-	err = rcloneOperations.CopyURLToWriter(ctx, archiveURL, out)
-	if err != nil {
+	if err = DetectCaptivePortal(zipArchivePath); err != nil {
 		return "", err
 	}
 
@@ -233,69 +321,193 @@ func DownloadFirmwareArchive(ctx context.Context, tmpDir, archiveURL, archiveChe
 	return zipArchivePath, nil
 }
 
-// ExtractFromZipArchive extracts the given firmareFilename from zip archivePath and checks if MD5 checksum matches.
-// nolint:gocyclo // see Test_ExtractFromZipArchive for examples of zip archives found in the wild.
-func ExtractFromZipArchive(archivePath, firmwareFilename, firmwareChecksum string) (*os.File, error) {
-	r, err := zip.OpenReader(archivePath)
-	if err != nil {
-		return nil, err
+// downloadHTTP downloads the file at archiveURL to dstPath with rclone.
+func downloadHTTP(ctx context.Context, archiveURL, dstPath string) error {
+	ctx = withRcloneSourceHeaders(ctx, archiveURL)
+
+	contentLength := remoteContentLength(ctx, archiveURL)
+
+	if err := checkFileSize(ctx, archiveURL, contentLength); err != nil {
+		return err
 	}
-	defer r.Close()
 
-	var foundFile *zip.File
+	if err := checkAvailableSpace(path.Dir(dstPath), contentLength); err != nil {
+		return err
+	}
 
-	fwFilenameNoExt := strings.Replace(firmwareFilename, filepath.Ext(firmwareFilename), "", 1)
-	for _, f := range r.File {
-		if filepath.Ext(f.Name) == ".zip" && strings.Contains(f.Name, fwFilenameNoExt) {
-			foundFile = f
-			// Skip checksum verification on the nested zip archive,
-			// since we don't have a checksum for it.
-			firmwareChecksum = ""
+	out, err := os.Create(dstPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
 
-			break
+	written := &countingWriter{w: out}
+
+	err = rcloneOperations.CopyURLToWriter(ctx, archiveURL, written)
+	if err != nil {
+		// the response body ends unexpectedly when fewer bytes than the Content-Length are received
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return errors.Wrap(ErrTruncatedDownload, fmt.Sprintf("%s: received %d bytes", archiveURL, written.n))
 		}
 
-		if strings.HasSuffix(f.Name, firmwareFilename) {
-			foundFile = f
-			break
+		return err
+	}
+
+	return nil
+}
+
+// hostValue returns the value configured for the URL host, with or without its port.
+func hostValue[T any](values map[string]T, u *url.URL) (value T, found bool) {
+	for host, v := range values {
+		if strings.EqualFold(host, u.Host) || strings.EqualFold(host, u.Hostname()) {
+			return v, true
 		}
 	}
 
-	if foundFile == nil {
-		return nil, errors.New(fmt.Sprintf("couldn't find file: %s in archive: %s", firmwareFilename, archivePath))
+	return value, false
+}
+
+// downloadObject copies the filename object of srcFs, downloaded from sourceURL, to dstPath.
+func downloadObject(ctx context.Context, srcFs rcloneFs.Fs, filename, sourceURL, dstPath string) error {
+	obj, err := srcFs.NewObject(ctx, filename)
+	if err != nil {
+		return errors.Wrap(err, sourceURL)
 	}
 
-	zipContents, err := foundFile.Open()
+	if err = checkFileSize(ctx, sourceURL, obj.Size()); err != nil {
+		return err
+	}
+
+	if err = checkAvailableSpace(path.Dir(dstPath), obj.Size()); err != nil {
+		return err
+	}
+
+	localFs, err := InitLocalFs(ctx, &LocalFsConfig{Root: path.Dir(dstPath)})
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	if _, err = rcloneOperations.Copy(ctx, localFs, nil, path.Base(dstPath), obj); err != nil {
+		return errors.Wrap(err, "failure downloading "+sourceURL)
+	}
+
+	return nil
+}
+
+// ExtractFromZipArchive extracts the given firmareFilename from zip archivePath and checks if MD5 checksum matches,
+// the archive entries are checked against their CRC32 before extraction.
+// nolint:gocyclo // see Test_ExtractFromZipArchive for examples of zip archives found in the wild.
+func ExtractFromZipArchive(archivePath, firmwareFilename, firmwareChecksum string) (*FirmwareFile, error) {
+	r, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return nil, &ArchiveError{ArchivePath: archivePath, Err: errors.Wrap(ErrArchiveCorrupt, err.Error())}
+	}
+	defer r.Close()
+
+	if err = verifyZipEntries(&r.Reader); err != nil {
+		return nil, &ArchiveError{ArchivePath: archivePath, Err: err}
+	}
+
+	foundFile, nested, err := findZipEntry(r.File, firmwareFilename)
+	if err != nil {
+		return nil, &ArchiveError{ArchivePath: archivePath, Err: err}
 	}
-	defer zipContents.Close()
 
-	tmpDir := path.Dir(archivePath)
-	tmpFilename := filepath.Base(foundFile.Name)
+	if nested {
+		// Skip checksum verification on the nested zip archive,
+		// since we don't have a checksum for it.
+		firmwareChecksum = ""
+	}
 
-	out, err := os.Create(path.Join(tmpDir, tmpFilename))
+	// the archive is replaced by the firmware when it is named after it
+	archiveInfo, err := os.Stat(archivePath)
 	if err != nil {
 		return nil, err
 	}
 
-	_, err = io.Copy(out, zipContents)
+	zipContents, err := foundFile.Open()
+	if err != nil {
+		return nil, &ArchiveError{ArchivePath: archivePath, Err: errors.Wrap(ErrArchiveCorrupt, err.Error())}
+	}
+	defer zipContents.Close()
+
+	extractedPath, err := writeArchiveMember(archivePath, filepath.Base(foundFile.Name), zipContents, currentExtractLimits().MaxBytes)
 	if err != nil {
+		if errors.Is(err, ErrArchiveTooLarge) {
+			return nil, &ArchiveError{ArchivePath: archivePath, Err: err}
+		}
+
+		if errors.Is(err, zip.ErrChecksum) || errors.Is(err, zip.ErrFormat) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, &ArchiveError{ArchivePath: archivePath, Err: errors.Wrap(ErrArchiveCorrupt, err.Error())}
+		}
+
 		return nil, err
 	}
 
-	if filepath.Ext(out.Name()) == ".zip" {
-		out, err = ExtractFromZipArchive(out.Name(), firmwareFilename, firmwareChecksum)
+	if filepath.Ext(extractedPath) == ".zip" {
+		nestedFirmware, err := ExtractFromZipArchive(extractedPath, firmwareFilename, firmwareChecksum)
 		if err != nil {
+			// the downloaded archive is the one to quarantine, not the nested one
+			var archiveErr *ArchiveError
+			if errors.As(err, &archiveErr) {
+				archiveErr.ArchivePath = archivePath
+			}
+
 			return nil, err
 		}
+
+		extractedPath = nestedFirmware.Path
+	}
+
+	// the sizes are those of the downloaded archive, not of the nested one
+	firmware, err := extractedFirmware(archiveInfo.Size(), extractedPath)
+	if err != nil {
+		return nil, err
 	}
 
-	if firmwareChecksum != "" && !ValidateChecksum(out.Name(), firmwareChecksum) {
-		return nil, errors.Wrap(ErrChecksumValidate, fmt.Sprintf("firmware: %s, expected checksum: %s", out.Name(), firmwareChecksum))
+	if firmwareChecksum != "" && !ValidateChecksum(firmware.Path, firmwareChecksum) {
+		return nil, errors.Wrap(ErrChecksumValidate, fmt.Sprintf("firmware: %s, expected checksum: %s", firmware.Path, firmwareChecksum))
+	}
+
+	return firmware, nil
+}
+
+// findZipEntry returns the archive entry holding firmwareFilename, an entry named firmwareFilename wins over
+// the nested zip archives and entries the name is a suffix of, nested is true when a nested zip archive is returned.
+func findZipEntry(files []*zip.File, firmwareFilename string) (found *zip.File, nested bool, err error) {
+	var exact []*zip.File
+
+	for _, f := range files {
+		if path.Base(f.Name) == firmwareFilename {
+			exact = append(exact, f)
+		}
+	}
+
+	switch len(exact) {
+	case 0:
+	case 1:
+		return exact[0], false, nil
+	default:
+		names := make([]string, 0, len(exact))
+		for _, f := range exact {
+			names = append(names, f.Name)
+		}
+
+		return nil, false, errors.Wrap(ErrAmbiguousArchiveEntry, strings.Join(names, ", "))
+	}
+
+	fwFilenameNoExt := strings.Replace(firmwareFilename, filepath.Ext(firmwareFilename), "", 1)
+	for _, f := range files {
+		if filepath.Ext(f.Name) == ".zip" && strings.Contains(f.Name, fwFilenameNoExt) {
+			return f, true, nil
+		}
+
+		if strings.HasSuffix(f.Name, firmwareFilename) {
+			return f, false, nil
+		}
 	}
 
-	return out, nil
+	return nil, false, errors.Wrap(ErrArchiveMemberNotFound, firmwareFilename)
 }
 
 type ArchiveDownloader struct {
@@ -309,21 +521,26 @@ func NewArchiveDownloader(logger *logrus.Logger) Downloader {
 
 // Download will download the file for the given firmware into the given downloadDir,
 // and return the full path to the downloaded file.
-func (m *ArchiveDownloader) Download(ctx context.Context, downloadDir string, firmware *fleetdbapi.ComponentFirmwareVersion) (string, error) {
+func (m *ArchiveDownloader) Download(ctx context.Context, downloadDir string, firmware *fleetdbapi.ComponentFirmwareVersion) (*FirmwareFile, error) {
+	downloadDir, err := FirmwareDownloadDir(downloadDir, firmware)
+	if err != nil {
+		return nil, err
+	}
+
 	archivePath, err := DownloadFirmwareArchive(ctx, downloadDir, firmware.UpstreamURL, "")
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	m.logger.WithField("archivePath", archivePath).Debug("Archive downloaded.")
 	m.logger.Debug("Extracting firmware from archive")
 
-	fwFile, err := ExtractFromZipArchive(archivePath, firmware.Filename, "")
+	fwFile, err := ExtractFirmware(archivePath, firmware.Filename, "")
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	return fwFile.Name(), nil
+	return fwFile, nil
 }
 
 type RcloneDownloader struct {
@@ -337,8 +554,18 @@ func NewRcloneDownloader(logger *logrus.Logger) Downloader {
 
 // Download will download the file for the given firmware into the given downloadDir,
 // and return the full path to the downloaded file.
-func (r *RcloneDownloader) Download(ctx context.Context, downloadDir string, firmware *fleetdbapi.ComponentFirmwareVersion) (string, error) {
-	return DownloadFirmwareArchive(ctx, downloadDir, firmware.UpstreamURL, "")
+func (r *RcloneDownloader) Download(ctx context.Context, downloadDir string, firmware *fleetdbapi.ComponentFirmwareVersion) (*FirmwareFile, error) {
+	downloadDir, err := FirmwareDownloadDir(downloadDir, firmware)
+	if err != nil {
+		return nil, err
+	}
+
+	firmwarePath, err := DownloadFirmwareArchive(ctx, downloadDir, firmware.UpstreamURL, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return &FirmwareFile{Path: firmwarePath}, nil
 }
 
 type S3Downloader struct {
@@ -353,23 +580,78 @@ func NewS3Downloader(logger *logrus.Logger, s3Fs rcloneFs.Fs) Downloader {
 
 // Download will download the file for the given firmware into the given downloadDir,
 // and return the full path to the downloaded file.
-func (s *S3Downloader) Download(ctx context.Context, downloadDir string, firmware *fleetdbapi.ComponentFirmwareVersion) (string, error) {
+// The file is verified against the .SHA256 sidecar next to it on the source, when there's one.
+func (s *S3Downloader) Download(ctx context.Context, downloadDir string, firmware *fleetdbapi.ComponentFirmwareVersion) (*FirmwareFile, error) {
+	downloadDir, err := FirmwareDownloadDir(downloadDir, firmware)
+	if err != nil {
+		return nil, err
+	}
+
 	tmpFS, err := InitLocalFs(ctx, &LocalFsConfig{Root: downloadDir})
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	err = rcloneOperations.CopyFile(ctx, tmpFS, s.s3Fs, firmware.Filename, SrcPath(firmware))
+	err = retryObjectOperation(ctx, func() error {
+		return rcloneOperations.CopyFile(ctx, tmpFS, s.s3Fs, firmware.Filename, SrcPath(firmware))
+	})
 	if err != nil {
-		if errors.Is(err, rcloneFs.ErrorObjectNotFound) {
-			msg := fmt.Sprintf("%s: %s", err, firmware.Filename)
-			return "", errors.Wrap(ErrCopy, msg)
-		}
+		return nil, errors.Wrap(err, firmware.Filename)
+	}
 
-		return "", err
+	firmwarePath := path.Join(downloadDir, firmware.Filename)
+
+	if err = VerifyWithDetachedChecksum(ctx, s.s3Fs, SrcPath(firmware), firmwarePath); err != nil {
+		return nil, err
 	}
 
-	return path.Join(downloadDir, firmware.Filename), nil
+	return &FirmwareFile{Path: firmwarePath}, nil
+}
+
+// ServerSideCopy copies the firmware from the source bucket to destPath on dstFs,
+// when the checksum stored with the source object matches the firmware checksum.
+// The firmware is left to be downloaded and verified when the checksums can't be compared or don't match.
+func (s *S3Downloader) ServerSideCopy(
+	ctx context.Context,
+	dstFs rcloneFs.Fs,
+	destPath string,
+	firmware *fleetdbapi.ComponentFirmwareVersion,
+) (bool, error) {
+	if firmware.Checksum == "" {
+		return false, nil
+	}
+
+	hashType, checksum, err := parseChecksum(firmware.Checksum)
+	if err != nil {
+		// checksum types rclone doesn't support are verified once downloaded
+		return false, nil // nolint:nilerr // the download fallback isn't an error
+	}
+
+	var srcObj rcloneFs.Object
+
+	err = retryObjectOperation(ctx, func() (err error) {
+		srcObj, err = s.s3Fs.NewObject(ctx, SrcPath(firmware))
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+
+	// the hash is empty when it wasn't stored with the object, as for multipart uploads
+	srcHash, err := srcObj.Hash(ctx, hashType)
+	if err != nil || srcHash == "" || !strings.EqualFold(srcHash, checksum) {
+		return false, nil // nolint:nilerr // the download fallback isn't an error
+	}
+
+	// the source and destination are configured as distinct remotes
+	ctx, ci := rcloneFs.AddConfig(ctx)
+	ci.ServerSideAcrossConfigs = true
+
+	if _, err = rcloneOperations.Copy(ctx, dstFs, nil, destPath, srcObj); err != nil {
+		return false, err
+	}
+
+	return true, nil
 }
 
 // SourceOverrideDownloader is meant to download firmware from an alternate source
@@ -397,12 +679,17 @@ func NewSourceOverrideDownloader(logger *logrus.Logger, client fleetdbapi.Doer,
 // and return the full path to the downloaded file.
 // The file will be downloaded from the sourceURL provided to the SourceOverrideDownloader
 // instead of the firmware's UpstreamURL.
-func (d *SourceOverrideDownloader) Download(ctx context.Context, downloadDir string, firmware *fleetdbapi.ComponentFirmwareVersion) (string, error) {
+func (d *SourceOverrideDownloader) Download(ctx context.Context, downloadDir string, firmware *fleetdbapi.ComponentFirmwareVersion) (*FirmwareFile, error) {
+	downloadDir, err := FirmwareDownloadDir(downloadDir, firmware)
+	if err != nil {
+		return nil, err
+	}
+
 	filePath := filepath.Join(downloadDir, firmware.Filename)
 
 	firmwareURL, err := url.JoinPath(d.baseURL, firmware.Filename)
 	if err != nil {
-		return "", errors.Wrap(ErrSourceURL, err.Error())
+		return nil, errors.Wrap(ErrSourceURL, err.Error())
 	}
 
 	d.logger.WithField("url", firmwareURL).
@@ -412,27 +699,69 @@ func (d *SourceOverrideDownloader) Download(ctx context.Context, downloadDir str
 
 	file, err := os.Create(filePath)
 	if err != nil {
-		return "", errors.Wrap(ErrCreatingTmpDir, err.Error())
+		return nil, errors.Wrap(ErrCreatingTmpDir, err.Error())
 	}
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, firmwareURL, http.NoBody)
 	if err != nil {
-		return "", errors.Wrap(ErrSourceURL, err.Error())
+		return nil, errors.Wrap(ErrSourceURL, err.Error())
 	}
 
+	setSourceHeaders(ctx, req)
+
 	resp, err := d.client.Do(req)
 	if err != nil {
-		return "", errors.Wrap(ErrDownloadingFile, err.Error())
+		return nil, errors.Wrap(ErrDownloadingFile, err.Error())
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return "", errors.Wrap(ErrUnexpectedStatusCode, fmt.Sprintf("status code %d", resp.StatusCode))
+		return nil, errors.Wrap(ErrUnexpectedStatusCode, fmt.Sprintf("status code %d", resp.StatusCode))
+	}
+
+	if err = checkCaptivePortalResponse(resp, firmware.Filename); err != nil {
+		return nil, err
+	}
+
+	if err = checkFileSize(ctx, firmwareURL, resp.ContentLength); err != nil {
+		return nil, err
+	}
+
+	if err = checkAvailableSpace(downloadDir, resp.ContentLength); err != nil {
+		return nil, err
 	}
 
-	if _, err = io.Copy(file, resp.Body); err != nil {
-		return "", errors.Wrap(ErrCopy, err.Error())
+	written, err := io.Copy(file, resp.Body)
+	if truncatedErr := checkContentLength(firmwareURL, written, resp.ContentLength); truncatedErr != nil {
+		return nil, truncatedErr
 	}
 
-	return filePath, nil
+	if err != nil {
+		return nil, errors.Wrap(ErrCopy, err.Error())
+	}
+
+	return &FirmwareFile{Path: filePath}, nil
+}
+
+// checkContentLength returns an ErrTruncatedDownload when the bytes written don't match the declared content length,
+// the check is skipped when the length is unknown.
+func checkContentLength(downloadURL string, written, contentLength int64) error {
+	if contentLength <= 0 || written == contentLength {
+		return nil
+	}
+
+	return errors.Wrap(ErrTruncatedDownload, fmt.Sprintf("%s: received %d bytes, expected %d", downloadURL, written, contentLength))
+}
+
+// countingWriter counts the bytes written to the underlying writer.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+
+	return n, err
 }
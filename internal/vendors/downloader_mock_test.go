@@ -0,0 +1,97 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: downloader.go
+//
+// Generated by this command:
+//
+//	mockgen -source=downloader.go -destination=downloader_mock_test.go -package=vendors -self_package=github.com/metal-toolbox/firmware-syncer/internal/vendors Downloader
+//
+
+// Package vendors is a generated GoMock package.
+package vendors
+
+import (
+	context "context"
+	reflect "reflect"
+
+	fleetdbapi "github.com/metal-toolbox/fleetdb/pkg/api/v1"
+	fs "github.com/rclone/rclone/fs"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockDownloader is a mock of Downloader interface.
+type MockDownloader struct {
+	ctrl     *gomock.Controller
+	recorder *MockDownloaderMockRecorder
+	isgomock struct{}
+}
+
+// MockDownloaderMockRecorder is the mock recorder for MockDownloader.
+type MockDownloaderMockRecorder struct {
+	mock *MockDownloader
+}
+
+// NewMockDownloader creates a new mock instance.
+func NewMockDownloader(ctrl *gomock.Controller) *MockDownloader {
+	mock := &MockDownloader{ctrl: ctrl}
+	mock.recorder = &MockDownloaderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockDownloader) EXPECT() *MockDownloaderMockRecorder {
+	return m.recorder
+}
+
+// Download mocks base method.
+func (m *MockDownloader) Download(ctx context.Context, downloadDir string, firmware *fleetdbapi.ComponentFirmwareVersion) (*FirmwareFile, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Download", ctx, downloadDir, firmware)
+	ret0, _ := ret[0].(*FirmwareFile)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Download indicates an expected call of Download.
+func (mr *MockDownloaderMockRecorder) Download(ctx, downloadDir, firmware any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Download", reflect.TypeOf((*MockDownloader)(nil).Download), ctx, downloadDir, firmware)
+}
+
+// MockServerSideCopier is a mock of ServerSideCopier interface.
+type MockServerSideCopier struct {
+	ctrl     *gomock.Controller
+	recorder *MockServerSideCopierMockRecorder
+	isgomock struct{}
+}
+
+// MockServerSideCopierMockRecorder is the mock recorder for MockServerSideCopier.
+type MockServerSideCopierMockRecorder struct {
+	mock *MockServerSideCopier
+}
+
+// NewMockServerSideCopier creates a new mock instance.
+func NewMockServerSideCopier(ctrl *gomock.Controller) *MockServerSideCopier {
+	mock := &MockServerSideCopier{ctrl: ctrl}
+	mock.recorder = &MockServerSideCopierMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockServerSideCopier) EXPECT() *MockServerSideCopierMockRecorder {
+	return m.recorder
+}
+
+// ServerSideCopy mocks base method.
+func (m *MockServerSideCopier) ServerSideCopy(ctx context.Context, dstFs fs.Fs, destPath string, firmware *fleetdbapi.ComponentFirmwareVersion) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ServerSideCopy", ctx, dstFs, destPath, firmware)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ServerSideCopy indicates an expected call of ServerSideCopy.
+func (mr *MockServerSideCopierMockRecorder) ServerSideCopy(ctx, dstFs, destPath, firmware any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ServerSideCopy", reflect.TypeOf((*MockServerSideCopier)(nil).ServerSideCopy), ctx, dstFs, destPath, firmware)
+}
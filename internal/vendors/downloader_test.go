@@ -6,11 +6,15 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"path"
+	"strings"
+	"sync"
 	"testing"
 
 	fleetdbapi "github.com/metal-toolbox/fleetdb/pkg/api/v1"
+	rcloneFs "github.com/rclone/rclone/fs"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/mock/gomock"
@@ -63,6 +67,61 @@ func Test_InitLocalFs(t *testing.T) {
 	}
 }
 
+func Test_localConfigmap(t *testing.T) {
+	opts := localConfigmap(&LocalFsConfig{Root: "/foobar"})
+
+	for _, option := range []string{"copy_links", "one_file_system", "no_preallocation"} {
+		value, _ := opts.Get(option)
+		assert.Equal(t, "true", value, option)
+	}
+
+	enabled, disabled := true, false
+
+	opts = localConfigmap(&LocalFsConfig{Root: "/foobar", CopyLinks: &enabled, OneFileSystem: &disabled, NoPreallocation: &disabled})
+
+	copyLinks, _ := opts.Get("copy_links")
+	assert.Equal(t, "true", copyLinks)
+
+	oneFileSystem, _ := opts.Get("one_file_system")
+	assert.Equal(t, "false", oneFileSystem)
+
+	noPreallocation, _ := opts.Get("no_preallocation")
+	assert.Equal(t, "false", noPreallocation)
+}
+
+func Test_s3Configmap(t *testing.T) {
+	opts := s3Configmap(&config.S3Bucket{Region: "region"})
+
+	_, ok := opts.Get("storage_class")
+	assert.False(t, ok, "the bucket default storage class applies")
+
+	_, ok = opts.Get("acl")
+	assert.False(t, ok, "the bucket default acl applies")
+
+	opts = s3Configmap(&config.S3Bucket{Region: "region", StorageClass: "INTELLIGENT_TIERING", ACL: "private"})
+
+	storageClass, _ := opts.Get("storage_class")
+	assert.Equal(t, "INTELLIGENT_TIERING", storageClass)
+
+	acl, _ := opts.Get("acl")
+	assert.Equal(t, "private", acl)
+}
+
+func Test_s3ConfigmapSSE(t *testing.T) {
+	opts := s3Configmap(&config.S3Bucket{Region: "region"})
+
+	_, ok := opts.Get("server_side_encryption")
+	assert.False(t, ok, "the bucket default encryption applies")
+
+	opts = s3Configmap(&config.S3Bucket{Region: "region", SSEAlgorithm: "aws:kms", SSEKMSKeyID: "alias/firmware"})
+
+	algorithm, _ := opts.Get("server_side_encryption")
+	assert.Equal(t, "aws:kms", algorithm)
+
+	keyID, _ := opts.Get("sse_kms_key_id")
+	assert.Equal(t, "alias/firmware", keyID)
+}
+
 func Test_InitS3Fs(t *testing.T) {
 	cases := []struct {
 		cfg  *config.S3Bucket
@@ -113,6 +172,41 @@ func Test_InitS3Fs(t *testing.T) {
 			"S3 bucket foobar",
 			"",
 		},
+		{
+			&config.S3Bucket{Region: "region", Endpoint: "s3.example.foo", AccessKey: "sekrit", SecretKey: "sekrit", StorageClass: "COLD"},
+			"/foobar",
+			ErrInitS3Fs,
+			"",
+			"invalid storage class",
+		},
+		{
+			&config.S3Bucket{Region: "region", Endpoint: "s3.example.foo", AccessKey: "sekrit", SecretKey: "sekrit", ACL: "everyone"},
+			"/foobar",
+			ErrInitS3Fs,
+			"",
+			"invalid acl",
+		},
+		{
+			&config.S3Bucket{Region: "region", Endpoint: "s3.example.foo", AccessKey: "sekrit", SecretKey: "sekrit", SSEAlgorithm: "aws:kms"},
+			"/foobar",
+			ErrInitS3Fs,
+			"",
+			"kms key id undefined",
+		},
+		{
+			&config.S3Bucket{
+				Region:       "region",
+				Endpoint:     "s3.example.foo",
+				AccessKey:    "sekrit",
+				SecretKey:    "sekrit",
+				StorageClass: "INTELLIGENT_TIERING",
+				ACL:          "bucket-owner-full-control",
+			},
+			"/foobar",
+			nil,
+			"S3 bucket foobar",
+			"storage class and acl",
+		},
 	}
 
 	for _, tc := range cases {
@@ -256,6 +350,8 @@ func Test_SourceOverrideDownloader(t *testing.T) {
 		withBadURL      bool
 		withClientError bool
 		withCopyError   bool
+		contentType     string
+		contentLength   int64
 		expectedError   error
 	}{
 		{
@@ -281,6 +377,22 @@ func Test_SourceOverrideDownloader(t *testing.T) {
 			withCopyError: true,
 			expectedError: ErrCopy,
 		},
+		{
+			name:          "captive portal",
+			contentType:   "text/html; charset=utf-8",
+			expectedError: ErrCaptivePortal,
+		},
+		{
+			name:          "truncated download",
+			contentLength: 1024,
+			expectedError: ErrTruncatedDownload,
+		},
+		{
+			name:          "truncated download with copy error",
+			contentLength: 1024,
+			withCopyError: true,
+			expectedError: ErrTruncatedDownload,
+		},
 	}
 
 	for _, tt := range testCases {
@@ -301,7 +413,14 @@ func Test_SourceOverrideDownloader(t *testing.T) {
 				body = &readCloserErr{}
 			}
 
-			fakeResponse := &http.Response{Body: body, StatusCode: statusCode}
+			if tt.contentLength > 0 && !tt.withCopyError {
+				body = io.NopCloser(strings.NewReader("short body"))
+			}
+
+			fakeResponse := &http.Response{Body: body, StatusCode: statusCode, Header: http.Header{}, ContentLength: tt.contentLength}
+			if tt.contentType != "" {
+				fakeResponse.Header.Set("Content-Type", tt.contentType)
+			}
 
 			ctrl := gomock.NewController(t)
 			client := mock_vendors.NewMockHTTPDoer(ctrl)
@@ -322,7 +441,7 @@ func Test_SourceOverrideDownloader(t *testing.T) {
 
 			fakeFirmware := &fleetdbapi.ComponentFirmwareVersion{Filename: firmwareName}
 			downloader := NewSourceOverrideDownloader(logger, client, fakeURL)
-			firmwarePath, err := downloader.Download(ctx, tmpDir, fakeFirmware)
+			firmwareFile, err := downloader.Download(ctx, tmpDir, fakeFirmware)
 
 			if tt.expectedError != nil {
 				assert.ErrorContains(t, err, tt.expectedError.Error())
@@ -330,8 +449,93 @@ func Test_SourceOverrideDownloader(t *testing.T) {
 			}
 
 			assert.NoError(t, err)
-			assert.Equal(t, path.Join(tmpDir, firmwareName), firmwarePath)
-			assert.FileExists(t, firmwarePath)
+			assert.Equal(t, tmpDir, path.Dir(path.Dir(firmwareFile.Path)))
+			assert.Equal(t, firmwareName, path.Base(firmwareFile.Path))
+			assert.FileExists(t, firmwareFile.Path)
 		})
 	}
 }
+
+func Test_SourceOverrideDownloaderConcurrent(t *testing.T) {
+	ctx := context.Background()
+	logger := logrus.New()
+	tmpDir := t.TempDir()
+
+	// both downloads are held until the other one started, so they write to the shared directory at the same time
+	var started sync.WaitGroup
+
+	started.Add(2)
+
+	newServer := func(content string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			started.Done()
+			started.Wait()
+
+			fmt.Fprint(w, content)
+		}))
+	}
+
+	dellServer, supermicroServer := newServer("dell firmware"), newServer("supermicro firmware")
+	defer dellServer.Close()
+	defer supermicroServer.Close()
+
+	downloads := []struct {
+		downloader Downloader
+		firmware   *fleetdbapi.ComponentFirmwareVersion
+		content    string
+		file       *FirmwareFile
+		err        error
+	}{
+		{
+			downloader: NewSourceOverrideDownloader(logger, http.DefaultClient, dellServer.URL),
+			firmware:   &fleetdbapi.ComponentFirmwareVersion{Vendor: "dell", Filename: "firmware.bin"},
+			content:    "dell firmware",
+		},
+		{
+			downloader: NewSourceOverrideDownloader(logger, http.DefaultClient, supermicroServer.URL),
+			firmware:   &fleetdbapi.ComponentFirmwareVersion{Vendor: "supermicro", Filename: "firmware.bin"},
+			content:    "supermicro firmware",
+		},
+	}
+
+	var wg sync.WaitGroup
+
+	for i := range downloads {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			downloads[i].file, downloads[i].err = downloads[i].downloader.Download(ctx, tmpDir, downloads[i].firmware)
+		}(i)
+	}
+
+	wg.Wait()
+
+	for _, download := range downloads {
+		if !assert.NoError(t, download.err) {
+			return
+		}
+
+		b, err := os.ReadFile(download.file.Path)
+		assert.NoError(t, err)
+		assert.Equal(t, download.content, string(b))
+	}
+
+	assert.NotEqual(t, downloads[0].file.Path, downloads[1].file.Path)
+}
+
+func Test_DownloadFirmwareArchiveTruncated(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		// the connection is closed after the short body, before the declared length is reached
+		w.Header().Set("Content-Length", "1024")
+		_, _ = w.Write([]byte("short body"))
+	}))
+	defer server.Close()
+
+	ctx, ci := rcloneFs.AddConfig(context.Background())
+	ci.LowLevelRetries = 1
+
+	_, err := DownloadFirmwareArchive(ctx, t.TempDir(), server.URL+"/firmware.zip", "")
+	assert.ErrorIs(t, err, ErrTruncatedDownload)
+}
@@ -0,0 +1,108 @@
+package vendors
+
+import (
+	"path"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/pkg/errors"
+
+	"github.com/metal-toolbox/firmware-syncer/internal/config"
+
+	fleetdbapi "github.com/metal-toolbox/fleetdb/pkg/api/v1"
+)
+
+// DefaultDstPathTemplate lays firmware out as vendor/filename on the destination.
+const DefaultDstPathTemplate = "{{.Vendor}}/{{.Filename}}"
+
+var ErrDstPathTemplate = errors.New("invalid destination path template")
+
+var (
+	dstPathMutex sync.RWMutex
+	// dstPathTemplate renders DstPath, the default layout is used when it's not set
+	dstPathTemplate *template.Template
+)
+
+// DstPathData holds the firmware fields available to destination path templates,
+// Model is the server model the firmware is listed under in the manifest.
+type DstPathData struct {
+	Vendor    string
+	Model     string
+	Component string
+	Filename  string
+}
+
+func newDstPathData(fw *fleetdbapi.ComponentFirmwareVersion) DstPathData {
+	data := DstPathData{
+		Vendor:    config.NormalizeVendor(fw.Vendor),
+		Component: fw.Component,
+		Filename:  fw.Filename,
+	}
+
+	if len(fw.Model) > 0 {
+		data.Model = config.NormalizeModel(fw.Model[0])
+	}
+
+	return data
+}
+
+// ParseDstPathTemplate parses a text/template for the firmware destination path,
+// the template is rendered with sample firmware to check it produces a relative path ending with the filename.
+func ParseDstPathTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("dst_path").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, errors.Wrap(ErrDstPathTemplate, err.Error())
+	}
+
+	sample := DstPathData{Vendor: "vendor", Model: "model", Component: "component", Filename: "firmware.bin"}
+
+	rendered, err := renderDstPath(tmpl, sample)
+	if err != nil {
+		return nil, err
+	}
+
+	if path.Base(rendered) != sample.Filename {
+		return nil, errors.Wrap(ErrDstPathTemplate, "the path must end with the firmware filename: "+rendered)
+	}
+
+	return tmpl, nil
+}
+
+// SetDstPathTemplate sets the template DstPath renders, a nil template restores the default layout.
+func SetDstPathTemplate(tmpl *template.Template) {
+	dstPathMutex.Lock()
+	defer dstPathMutex.Unlock()
+
+	dstPathTemplate = tmpl
+}
+
+func renderDstPath(tmpl *template.Template, data DstPathData) (string, error) {
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", errors.Wrap(ErrDstPathTemplate, err.Error())
+	}
+
+	rendered := path.Clean(strings.TrimSpace(b.String()))
+	if path.IsAbs(rendered) || rendered == "." || strings.HasPrefix(rendered, "../") {
+		return "", errors.Wrap(ErrDstPathTemplate, "the path must be relative to the repository root: "+rendered)
+	}
+
+	return rendered, nil
+}
+
+// DstPath returns the firmware path on the destination, rendered with the template set by SetDstPathTemplate.
+func DstPath(fw *fleetdbapi.ComponentFirmwareVersion) string {
+	dstPathMutex.RLock()
+	tmpl := dstPathTemplate
+	dstPathMutex.RUnlock()
+
+	if tmpl != nil {
+		// templates are validated when parsed, the default layout is a last resort
+		if rendered, err := renderDstPath(tmpl, newDstPathData(fw)); err == nil {
+			return rendered
+		}
+	}
+
+	return path.Join(config.NormalizeVendor(fw.Vendor), fw.Filename)
+}
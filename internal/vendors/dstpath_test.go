@@ -0,0 +1,95 @@
+package vendors
+
+import (
+	"testing"
+
+	fleetdbapi "github.com/metal-toolbox/fleetdb/pkg/api/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDstPath(t *testing.T) {
+	firmware := &fleetdbapi.ComponentFirmwareVersion{
+		Vendor:    "dell",
+		Model:     []string{"r750", "hba355i"},
+		Component: "storage-controller",
+		Filename:  "SAS-Non-RAID_Firmware.EXE",
+	}
+
+	testCases := []struct {
+		name     string
+		template string
+		expected string
+	}{
+		{
+			name:     "no template",
+			expected: "dell/SAS-Non-RAID_Firmware.EXE",
+		},
+		{
+			name:     "default template",
+			template: DefaultDstPathTemplate,
+			expected: "dell/SAS-Non-RAID_Firmware.EXE",
+		},
+		{
+			name:     "model and component",
+			template: "{{.Vendor}}/{{.Model}}/{{.Component}}/{{.Filename}}",
+			expected: "dell/r750/storage-controller/SAS-Non-RAID_Firmware.EXE",
+		},
+		{
+			name:     "per vendor layout",
+			template: `{{if eq .Vendor "dell"}}dell/{{.Model}}{{else}}{{.Vendor}}{{end}}/{{.Filename}}`,
+			expected: "dell/r750/SAS-Non-RAID_Firmware.EXE",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Cleanup(func() { SetDstPathTemplate(nil) })
+
+			if tt.template != "" {
+				tmpl, err := ParseDstPathTemplate(tt.template)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				SetDstPathTemplate(tmpl)
+			}
+
+			assert.Equal(t, tt.expected, DstPath(firmware))
+		})
+	}
+}
+
+func TestDstPathNormalizesVendor(t *testing.T) {
+	firmware := &fleetdbapi.ComponentFirmwareVersion{
+		Vendor:   "SMC",
+		Model:    []string{"X11DPH-T"},
+		Filename: "BIOS_X11DPH-0981_20220208_3.6_STDsp.zip",
+	}
+
+	assert.Equal(t, "supermicro/BIOS_X11DPH-0981_20220208_3.6_STDsp.zip", DstPath(firmware))
+
+	tmpl, err := ParseDstPathTemplate("{{.Vendor}}/{{.Model}}/{{.Filename}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	SetDstPathTemplate(tmpl)
+	t.Cleanup(func() { SetDstPathTemplate(nil) })
+
+	assert.Equal(t, "supermicro/x11dph-t/BIOS_X11DPH-0981_20220208_3.6_STDsp.zip", DstPath(firmware))
+}
+
+func TestParseDstPathTemplateInvalid(t *testing.T) {
+	for _, text := range []string{
+		"{{.Vendor",
+		"{{.Vendor}}/{{.Slug}}",
+		"{{.Vendor}}/{{.Model}}",
+		"/{{.Vendor}}/{{.Filename}}",
+		"../{{.Filename}}",
+	} {
+		t.Run(text, func(t *testing.T) {
+			_, err := ParseDstPathTemplate(text)
+			assert.ErrorIs(t, err, ErrDstPathTemplate)
+		})
+	}
+}
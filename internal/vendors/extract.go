@@ -0,0 +1,289 @@
+package vendors
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// ArchiveExtractor extracts the given firmware file from an archive,
+// validating the extracted file against the checksum when one is given.
+// The extracted firmware file is returned with the sizes of the archive and of the firmware.
+type ArchiveExtractor interface {
+	Extract(archivePath, firmwareFilename, firmwareChecksum string) (*FirmwareFile, error)
+}
+
+// ArchiveExtractorFunc adapts a function to the ArchiveExtractor interface.
+type ArchiveExtractorFunc func(archivePath, firmwareFilename, firmwareChecksum string) (*FirmwareFile, error)
+
+// Extract calls f(archivePath, firmwareFilename, firmwareChecksum).
+func (f ArchiveExtractorFunc) Extract(archivePath, firmwareFilename, firmwareChecksum string) (*FirmwareFile, error) {
+	return f(archivePath, firmwareFilename, firmwareChecksum)
+}
+
+var (
+	extractorsMutex sync.RWMutex
+	// extractors maps archive file extensions to the ArchiveExtractor handling them
+	extractors = map[string]ArchiveExtractor{
+		".zip":    ArchiveExtractorFunc(ExtractFromZipArchive),
+		".tar.gz": ArchiveExtractorFunc(ExtractFromTarGzArchive),
+		".tgz":    ArchiveExtractorFunc(ExtractFromTarGzArchive),
+		".tar":    ArchiveExtractorFunc(ExtractFromTarArchive),
+		".gz":     ArchiveExtractorFunc(ExtractFromGzip),
+	}
+)
+
+// RegisterArchiveExtractor registers the ArchiveExtractor for archives with the given extension,
+// replacing any extractor previously registered for it.
+func RegisterArchiveExtractor(extension string, extractor ArchiveExtractor) {
+	extractorsMutex.Lock()
+	defer extractorsMutex.Unlock()
+
+	extractors[strings.ToLower(extension)] = extractor
+}
+
+// archiveExtension returns the registered extension of the archive path,
+// the longest matching extension wins so .tar.gz archives aren't treated as .gz files.
+func archiveExtension(archivePath string) (string, bool) {
+	extractorsMutex.RLock()
+	defer extractorsMutex.RUnlock()
+
+	name := strings.ToLower(archivePath)
+
+	var matched string
+
+	for extension := range extractors {
+		if strings.HasSuffix(name, extension) && len(extension) > len(matched) {
+			matched = extension
+		}
+	}
+
+	return matched, matched != ""
+}
+
+// extractorFor returns the ArchiveExtractor registered for the extension.
+func extractorFor(extension string) (ArchiveExtractor, bool) {
+	extractorsMutex.RLock()
+	defer extractorsMutex.RUnlock()
+
+	extractor, ok := extractors[extension]
+
+	return extractor, ok
+}
+
+// ExtractFirmware extracts the given firmwareFilename from archivePath,
+// using the ArchiveExtractor registered for the archive extension.
+// The extension is overridden by the archive type sniffed from the first bytes of the archive,
+// as upstream URLs don't always end with the archive filename, or end with a misleading one.
+// Archives neither sniffed nor with a registered extension are assumed to be zip archives.
+func ExtractFirmware(archivePath, firmwareFilename, firmwareChecksum string) (*FirmwareFile, error) {
+	extractor, ok := extractorFor(detectArchiveExtension(archivePath))
+	if !ok {
+		extractor = ArchiveExtractorFunc(ExtractFromZipArchive)
+	}
+
+	return extractor.Extract(archivePath, firmwareFilename, firmwareChecksum)
+}
+
+// detectArchiveExtension returns the extension of the archive type, sniffed from the first bytes of the archive
+// when its extension is missing or misleading.
+func detectArchiveExtension(archivePath string) string {
+	extension, _ := archiveExtension(archivePath)
+
+	if sniffed, ok := sniffArchiveExtension(archivePath); ok && !sameArchiveType(extension, sniffed) {
+		extension = sniffed
+	}
+
+	return extension
+}
+
+// ArchiveEntries returns the names of the regular files in the archive at archivePath, for downloaders picking
+// the firmware out of the archive before extracting it with ExtractFirmware.
+// Tar and gzipped tar archives are listed as such, the other archives are assumed to be zip archives.
+func ArchiveEntries(archivePath string) ([]string, error) {
+	switch detectArchiveExtension(archivePath) {
+	case ".tar.gz", ".tgz":
+		archive, err := os.Open(archivePath)
+		if err != nil {
+			return nil, err
+		}
+		defer archive.Close()
+
+		gzipReader, err := gzip.NewReader(archive)
+		if err != nil {
+			return nil, &ArchiveError{ArchivePath: archivePath, Err: errors.Wrap(ErrArchiveCorrupt, err.Error())}
+		}
+		defer gzipReader.Close()
+
+		return tarEntries(archivePath, tar.NewReader(gzipReader))
+	case ".tar":
+		archive, err := os.Open(archivePath)
+		if err != nil {
+			return nil, err
+		}
+		defer archive.Close()
+
+		return tarEntries(archivePath, tar.NewReader(archive))
+	default:
+		return zipEntries(archivePath)
+	}
+}
+
+func tarEntries(archivePath string, tarReader *tar.Reader) ([]string, error) {
+	var names []string
+
+	for entries := 1; ; entries++ {
+		header, err := tarReader.Next()
+		if errors.Is(err, io.EOF) {
+			return names, nil
+		}
+
+		if err != nil {
+			return nil, &ArchiveError{ArchivePath: archivePath, Err: errors.Wrap(ErrArchiveCorrupt, err.Error())}
+		}
+
+		if err = checkEntryCount(entries); err != nil {
+			return nil, &ArchiveError{ArchivePath: archivePath, Err: err}
+		}
+
+		if header.Typeflag == tar.TypeReg {
+			names = append(names, header.Name)
+		}
+	}
+}
+
+func zipEntries(archivePath string) ([]string, error) {
+	r, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return nil, &ArchiveError{ArchivePath: archivePath, Err: errors.Wrap(ErrArchiveCorrupt, err.Error())}
+	}
+	defer r.Close()
+
+	if err = checkEntryCount(len(r.File)); err != nil {
+		return nil, &ArchiveError{ArchivePath: archivePath, Err: err}
+	}
+
+	names := make([]string, 0, len(r.File))
+
+	for _, f := range r.File {
+		if !f.FileInfo().IsDir() {
+			names = append(names, f.Name)
+		}
+	}
+
+	return names, nil
+}
+
+// ExtractFromTarGzArchive extracts the given firmwareFilename from the gzipped tar archivePath.
+func ExtractFromTarGzArchive(archivePath, firmwareFilename, firmwareChecksum string) (*FirmwareFile, error) {
+	archive, err := os.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+
+	gzipReader, err := gzip.NewReader(archive)
+	if err != nil {
+		return nil, &ArchiveError{ArchivePath: archivePath, Err: errors.Wrap(ErrArchiveCorrupt, err.Error())}
+	}
+	defer gzipReader.Close()
+
+	return extractFromTar(archivePath, tar.NewReader(gzipReader), firmwareFilename, firmwareChecksum)
+}
+
+// ExtractFromTarArchive extracts the given firmwareFilename from the uncompressed tar archivePath.
+func ExtractFromTarArchive(archivePath, firmwareFilename, firmwareChecksum string) (*FirmwareFile, error) {
+	archive, err := os.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+
+	return extractFromTar(archivePath, tar.NewReader(archive), firmwareFilename, firmwareChecksum)
+}
+
+// extractFromTar extracts the first regular file with the firmwareFilename suffix read from tarReader.
+func extractFromTar(archivePath string, tarReader *tar.Reader, firmwareFilename, firmwareChecksum string) (*FirmwareFile, error) {
+	for entries := 1; ; entries++ {
+		header, err := tarReader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil, &ArchiveError{ArchivePath: archivePath, Err: errors.Wrap(ErrArchiveMemberNotFound, firmwareFilename)}
+		}
+
+		if err != nil {
+			return nil, &ArchiveError{ArchivePath: archivePath, Err: errors.Wrap(ErrArchiveCorrupt, err.Error())}
+		}
+
+		if err = checkEntryCount(entries); err != nil {
+			return nil, &ArchiveError{ArchivePath: archivePath, Err: err}
+		}
+
+		if header.Typeflag != tar.TypeReg || !strings.HasSuffix(header.Name, firmwareFilename) {
+			continue
+		}
+
+		return writeExtractedFirmware(archivePath, filepath.Base(header.Name), tarReader, firmwareChecksum)
+	}
+}
+
+// ExtractFromGzip decompresses the single gzip stream in archivePath to firmwareFilename,
+// for firmware distributed as a plain .gz file with no container directory.
+func ExtractFromGzip(archivePath, firmwareFilename, firmwareChecksum string) (*FirmwareFile, error) {
+	archive, err := os.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+
+	gzipReader, err := gzip.NewReader(archive)
+	if err != nil {
+		return nil, &ArchiveError{ArchivePath: archivePath, Err: errors.Wrap(ErrArchiveCorrupt, err.Error())}
+	}
+	defer gzipReader.Close()
+
+	gzipReader.Multistream(false)
+
+	return writeExtractedFirmware(archivePath, filepath.Base(firmwareFilename), gzipReader, firmwareChecksum)
+}
+
+// writeExtractedFirmware writes the archive member to a file next to the archive, up to the maximum extracted size,
+// and validates the firmware checksum, the firmware file is returned along with the archive sizes.
+func writeExtractedFirmware(archivePath, filename string, r io.Reader, firmwareChecksum string) (*FirmwareFile, error) {
+	// the archive is replaced by the firmware when it is named after it
+	archiveInfo, err := os.Stat(archivePath)
+	if err != nil {
+		return nil, err
+	}
+
+	extractedPath, err := writeArchiveMember(archivePath, filename, r, currentExtractLimits().MaxBytes)
+	if err != nil {
+		if errors.Is(err, ErrArchiveTooLarge) {
+			return nil, &ArchiveError{ArchivePath: archivePath, Err: err}
+		}
+
+		if errors.Is(err, gzip.ErrChecksum) || errors.Is(err, gzip.ErrHeader) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, &ArchiveError{ArchivePath: archivePath, Err: errors.Wrap(ErrArchiveCorrupt, err.Error())}
+		}
+
+		return nil, err
+	}
+
+	firmware, err := extractedFirmware(archiveInfo.Size(), extractedPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if firmwareChecksum != "" && !ValidateChecksum(firmware.Path, firmwareChecksum) {
+		return nil, errors.Wrap(ErrChecksumValidate, fmt.Sprintf("firmware: %s, expected checksum: %s", firmware.Path, firmwareChecksum))
+	}
+
+	return firmware, nil
+}
@@ -0,0 +1,324 @@
+package vendors
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeExtractor struct {
+	calls []string
+}
+
+func (f *fakeExtractor) Extract(archivePath, _, _ string) (*FirmwareFile, error) {
+	f.calls = append(f.calls, archivePath)
+	return nil, nil
+}
+
+func registerFakeExtractor(t *testing.T, extension string) *fakeExtractor {
+	t.Helper()
+
+	extractorsMutex.RLock()
+	previous, ok := extractors[extension]
+	extractorsMutex.RUnlock()
+
+	t.Cleanup(func() {
+		extractorsMutex.Lock()
+		defer extractorsMutex.Unlock()
+
+		if ok {
+			extractors[extension] = previous
+			return
+		}
+
+		delete(extractors, extension)
+	})
+
+	extractor := &fakeExtractor{}
+	RegisterArchiveExtractor(extension, extractor)
+
+	return extractor
+}
+
+func Test_ExtractFirmwareDispatch(t *testing.T) {
+	fake := registerFakeExtractor(t, ".fake")
+	gz := registerFakeExtractor(t, ".gz")
+	tarGz := registerFakeExtractor(t, ".tar.gz")
+
+	testCases := []struct {
+		archivePath string
+		expected    *fakeExtractor
+	}{
+		{"/tmp/firmware.fake", fake},
+		{"/tmp/FIRMWARE.FAKE", fake},
+		{"/tmp/firmware.bin.gz", gz},
+		{"/tmp/firmware.tar.gz", tarGz},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.archivePath, func(t *testing.T) {
+			_, err := ExtractFirmware(tt.archivePath, "firmware.bin", "")
+			assert.NoError(t, err)
+			assert.Contains(t, tt.expected.calls, tt.archivePath)
+		})
+	}
+
+	assert.Len(t, fake.calls, 2)
+	assert.Len(t, gz.calls, 1)
+	assert.Len(t, tarGz.calls, 1)
+}
+
+func Test_ExtractFirmwareDefaultsToZip(t *testing.T) {
+	archivePath := filepath.Join(t.TempDir(), "download")
+
+	b, err := os.ReadFile(getPathToFixture("foobar1.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = os.WriteFile(archivePath, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := ExtractFirmware(archivePath, "foobar1.bin", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "foobar1.bin", filepath.Base(f.Path))
+}
+
+func Test_ArchiveEntries(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"bundle/PSID_A/firmware.bin": "a",
+		"bundle/PSID_B/firmware.bin": "b",
+	}
+
+	tarGzPath := filepath.Join(tmpDir, "bundle.tgz")
+	writeTarGz(t, tarGzPath, files)
+
+	entries, err := ArchiveEntries(tarGzPath)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"bundle/PSID_A/firmware.bin", "bundle/PSID_B/firmware.bin"}, entries)
+
+	tarPath := filepath.Join(tmpDir, "bundle.tar")
+	writeTar(t, tarPath, files)
+
+	entries, err = ArchiveEntries(tarPath)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	entries, err = ArchiveEntries(getPathToFixture("foobar1.zip"))
+	assert.NoError(t, err)
+	assert.Contains(t, entries, "foobar1.bin")
+}
+
+func writeTarGz(t *testing.T, archivePath string, files map[string]string) {
+	t.Helper()
+
+	archive, err := os.Create(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer archive.Close()
+
+	gzipWriter := gzip.NewWriter(archive)
+	writeTarEntries(t, gzipWriter, files)
+
+	if err = gzipWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeTar(t *testing.T, archivePath string, files map[string]string) {
+	t.Helper()
+
+	archive, err := os.Create(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer archive.Close()
+
+	writeTarEntries(t, archive, files)
+}
+
+func writeTarEntries(t *testing.T, w io.Writer, files map[string]string) {
+	t.Helper()
+
+	tarWriter := tar.NewWriter(w)
+
+	for name, content := range files {
+		header := &tar.Header{Name: name, Mode: 0o600, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if err := tarWriter.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := tarWriter.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_ExtractFirmwareTarGz(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	for _, name := range []string{"firmware.tar.gz", "firmware.tgz"} {
+		t.Run(name, func(t *testing.T) {
+			archivePath := filepath.Join(tmpDir, name)
+			writeTarGz(t, archivePath, map[string]string{
+				"release/notes.txt":    "notes",
+				"release/firmware.bin": "firmware",
+			})
+
+			f, err := ExtractFirmware(archivePath, "firmware.bin", "md5sum:74b5b5e9570efc5c0553bb327cd41940")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			b, err := os.ReadFile(f.Path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, "firmware", string(b))
+			assert.NotNil(t, f.ArchiveSizes)
+
+			_, err = ExtractFirmware(archivePath, "missing.bin", "")
+			assert.ErrorIs(t, err, ErrArchiveMemberNotFound)
+		})
+	}
+}
+
+func Test_ExtractFromGzip(t *testing.T) {
+	archivePath := filepath.Join(t.TempDir(), "foobar5.bin.gz")
+
+	b, err := os.ReadFile(getPathToFixture("foobar5.bin.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = os.WriteFile(archivePath, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name     string
+		checksum string
+		err      error
+	}{
+		{"md5sum", "md5sum:ed8bb6fb8c0a2814f0f0bea97c70fd34", nil},
+		{"sha256", "sha256:e106ae184673fb7bb4b1483a7f495b3b59fc3614b1d6fb89c975839d4f5037eb", nil},
+		{"checksum mismatch", "md5sum:00000000000000000000000000000000", ErrChecksumValidate},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := ExtractFirmware(archivePath, "foobar5.bin", tt.checksum)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, "foobar5.bin", filepath.Base(f.Path))
+			assert.True(t, ValidateChecksum(f.Path, tt.checksum))
+		})
+	}
+
+	corruptPath := filepath.Join(t.TempDir(), "corrupt.bin.gz")
+	if err = os.WriteFile(corruptPath, []byte("not a gzip archive"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ExtractFromGzip(corruptPath, "foobar5.bin", "")
+	assert.ErrorIs(t, err, ErrArchiveCorrupt)
+}
+
+func Test_ExtractFirmwareNamedAfterArchive(t *testing.T) {
+	testCases := []struct {
+		name      string
+		fixture   string
+		extractor ArchiveExtractorFunc
+		firmware  string
+		checksum  string
+	}{
+		{"gzip", "foobar5.bin.gz", ExtractFromGzip, "foobar5.bin", "md5sum:ed8bb6fb8c0a2814f0f0bea97c70fd34"},
+		{"zip", "foobar1.zip", ExtractFromZipArchive, "foobar1.bin", "md5sum:14758f1afd44c09b7992073ccf00b43d"},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := os.ReadFile(getPathToFixture(tt.fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// the archive is downloaded under the name of the firmware it holds, as when served compressed
+			tmpDir := t.TempDir()
+			archivePath := filepath.Join(tmpDir, tt.firmware)
+
+			if err = os.WriteFile(archivePath, b, 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			f, err := tt.extractor(archivePath, tt.firmware, tt.checksum)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// the firmware replaces the archive
+			assert.Equal(t, archivePath, f.Path)
+			assert.Equal(t, int64(len(b)), f.ArchiveSizes.ArchiveBytes)
+
+			info, err := os.Stat(f.Path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, info.Size(), f.ArchiveSizes.ExtractedBytes)
+			assert.True(t, ValidateChecksum(f.Path, tt.checksum))
+
+			// no temporary file is left behind
+			entries, err := os.ReadDir(tmpDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Len(t, entries, 1)
+		})
+	}
+}
+
+func Test_ExtractTarGzLimits(t *testing.T) {
+	t.Cleanup(func() { SetExtractLimits(ExtractLimits{}) })
+
+	archivePath := filepath.Join(t.TempDir(), "firmware.tar.gz")
+	writeTarGz(t, archivePath, map[string]string{
+		"release/notes.txt":    "notes",
+		"release/extra.txt":    "extra",
+		"release/firmware.bin": "firmware",
+	})
+
+	SetExtractLimits(ExtractLimits{MaxBytes: 4})
+
+	_, err := ExtractFirmware(archivePath, "firmware.bin", "")
+	assert.ErrorIs(t, err, ErrArchiveTooLarge)
+
+	SetExtractLimits(ExtractLimits{MaxEntries: 2})
+
+	_, err = ExtractFirmware(archivePath, "missing.bin", "")
+	assert.ErrorIs(t, err, ErrArchiveTooLarge)
+}
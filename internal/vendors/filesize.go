@@ -0,0 +1,33 @@
+package vendors
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+var ErrFileTooLarge = errors.New("firmware exceeds the maximum file size")
+
+type maxFileSizeKey struct{}
+
+// withMaxFileSize returns a context limiting the size of the firmware downloaded with it,
+// a maxFileSize of zero or less doesn't set any limit.
+func withMaxFileSize(ctx context.Context, maxFileSize int64) context.Context {
+	if maxFileSize <= 0 {
+		return ctx
+	}
+
+	return context.WithValue(ctx, maxFileSizeKey{}, maxFileSize)
+}
+
+// checkFileSize returns an ErrFileTooLarge when the Content-Length declared by the server exceeds the context maximum file size,
+// the check is skipped when either is unknown.
+func checkFileSize(ctx context.Context, downloadURL string, contentLength int64) error {
+	maxFileSize, ok := ctx.Value(maxFileSizeKey{}).(int64)
+	if !ok || contentLength <= maxFileSize {
+		return nil
+	}
+
+	return errors.Wrap(ErrFileTooLarge, fmt.Sprintf("%s: %d bytes, maximum %d bytes", downloadURL, contentLength, maxFileSize))
+}
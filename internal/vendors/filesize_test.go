@@ -0,0 +1,143 @@
+package vendors
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+
+	fleetdbapi "github.com/metal-toolbox/fleetdb/pkg/api/v1"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	rcloneFs "github.com/rclone/rclone/fs"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+
+	mockinventory "github.com/metal-toolbox/firmware-syncer/internal/inventory/mocks"
+	"github.com/metal-toolbox/firmware-syncer/internal/logging"
+	"github.com/metal-toolbox/firmware-syncer/internal/metrics"
+	mockvendors "github.com/metal-toolbox/firmware-syncer/internal/vendors/mocks"
+)
+
+func Test_CheckFileSize(t *testing.T) {
+	testCases := []struct {
+		name          string
+		maxFileSize   int64
+		contentLength int64
+		expectedError error
+	}{
+		{
+			name:          "under the limit",
+			maxFileSize:   1024,
+			contentLength: 512,
+		},
+		{
+			name:          "at the limit",
+			maxFileSize:   1024,
+			contentLength: 1024,
+		},
+		{
+			name:          "over the limit",
+			maxFileSize:   1024,
+			contentLength: 2048,
+			expectedError: ErrFileTooLarge,
+		},
+		{
+			name:          "unknown size",
+			maxFileSize:   1024,
+			contentLength: -1,
+		},
+		{
+			name:          "no limit",
+			contentLength: 2048,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := withMaxFileSize(context.Background(), tt.maxFileSize)
+
+			err := checkFileSize(ctx, "https://example.com/firmware.bin", tt.contentLength)
+			if tt.expectedError == nil {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.ErrorIs(t, err, tt.expectedError)
+		})
+	}
+}
+
+func Test_DownloadFirmwareArchiveMaxFileSize(t *testing.T) {
+	var downloads int
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "1024")
+
+		if r.Method == http.MethodGet {
+			downloads++
+			_, _ = w.Write(make([]byte, 1024))
+		}
+	}))
+	defer server.Close()
+
+	ctx, ci := rcloneFs.AddConfig(context.Background())
+	ci.LowLevelRetries = 1
+
+	_, err := DownloadFirmwareArchive(withMaxFileSize(ctx, 512), t.TempDir(), server.URL+"/firmware.zip", "")
+	assert.ErrorIs(t, err, ErrFileTooLarge)
+	assert.Zero(t, downloads, "the download should not start")
+
+	_, err = DownloadFirmwareArchive(withMaxFileSize(ctx, 2048), t.TempDir(), server.URL+"/firmware.zip", "")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, downloads)
+}
+
+func TestSyncerMaxFileSize(t *testing.T) {
+	logger := logging.NewLogger("debug")
+	ctx, ci := rcloneFs.AddConfig(context.Background())
+	ci.LowLevelRetries = 1
+
+	fixture, err := os.ReadFile(path.Join("fixtures", "foobar1.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(fixture)
+	}))
+	defer server.Close()
+
+	oversized := &fleetdbapi.ComponentFirmwareVersion{
+		Vendor:      "oversized-vendor",
+		Filename:    "foobar1.bin",
+		UpstreamURL: server.URL + "/foobar1.zip",
+	}
+
+	ctrl := gomock.NewController(t)
+
+	mockDstFs := mockvendors.NewMockRCloneFS(ctrl)
+	mockTmpFs := mockvendors.NewMockRCloneFS(ctrl)
+
+	mockTmpFs.EXPECT().Root().Return(t.TempDir()).AnyTimes()
+	mockDstFs.EXPECT().NewObject(gomock.Any(), DstPath(oversized)).Return(nil, rcloneFs.ErrorObjectNotFound)
+
+	// the oversized firmware is neither downloaded nor published
+	mockInventory := mockinventory.NewMockServerService(ctrl)
+
+	s := NewSyncer(
+		mockDstFs,
+		mockTmpFs,
+		NewArchiveDownloader(logger),
+		mockInventory,
+		[]*fleetdbapi.ComponentFirmwareVersion{oversized},
+		logger,
+		WithMaxFileSize(int64(len(fixture)-1)),
+	)
+
+	assert.NoError(t, s.Sync(ctx))
+
+	skipped := testutil.ToFloat64(metrics.OversizedFirmwareCounter.With(metrics.ArchiveLabels(oversized.Vendor)))
+	assert.Equal(t, float64(1), skipped)
+}
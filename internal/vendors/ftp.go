@@ -0,0 +1,109 @@
+package vendors
+
+import (
+	"context"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+	rcloneFtp "github.com/rclone/rclone/backend/ftp"
+	rcloneFs "github.com/rclone/rclone/fs"
+	rcloneConfigmap "github.com/rclone/rclone/fs/config/configmap"
+	"github.com/rclone/rclone/fs/config/obscure"
+
+	"github.com/metal-toolbox/firmware-syncer/internal/config"
+)
+
+// ftpAnonymousUser is the user FTP sources are logged in as when no credentials are configured
+const ftpAnonymousUser = "anonymous"
+
+var ErrInitFTPFs = errors.New("error initializing ftp fs")
+
+// FTPCredentials maps FTP source hosts to the credentials logged in with.
+type FTPCredentials map[string]config.FTPCredential
+
+type ftpCredentialsKey struct{}
+
+// withFTPCredentials returns a context logging in to FTP sources with the configured credentials.
+func withFTPCredentials(ctx context.Context, credentials FTPCredentials) context.Context {
+	if len(credentials) == 0 {
+		return ctx
+	}
+
+	return context.WithValue(ctx, ftpCredentialsKey{}, credentials)
+}
+
+// isFTPURL returns true for ftp:// URLs.
+func isFTPURL(rawURL string) bool {
+	return strings.HasPrefix(strings.ToLower(rawURL), "ftp://")
+}
+
+// ftpCredential returns the credentials in the URL, the context credentials configured for the URL host,
+// or the anonymous user, in that order.
+func ftpCredential(ctx context.Context, u *url.URL) config.FTPCredential {
+	if u.User != nil {
+		password, _ := u.User.Password()
+		return config.FTPCredential{User: u.User.Username(), Password: password}
+	}
+
+	if credentials, ok := ctx.Value(ftpCredentialsKey{}).(FTPCredentials); ok {
+		if credential, found := hostValue(credentials, u); found {
+			return credential
+		}
+	}
+
+	// anonymous FTP servers conventionally ask for an email address as the password
+	return config.FTPCredential{User: ftpAnonymousUser, Password: ftpAnonymousUser}
+}
+
+// InitFTPFs initializes an rclone ftp fs rooted at the directory of the ftpURL file and returns it along with the file name.
+//
+// The rclone ftp backend only uses passive mode for data connections, trying EPSV before falling back to PASV,
+// which works for FTP servers behind NAT and clients behind firewalls alike.
+func InitFTPFs(ctx context.Context, ftpURL string) (ftpFs rcloneFs.Fs, filename string, err error) {
+	u, err := url.Parse(ftpURL)
+	if err != nil || !isFTPURL(ftpURL) || u.Hostname() == "" {
+		return nil, "", errors.Wrap(ErrURLUnsupported, ftpURL)
+	}
+
+	credential := ftpCredential(ctx, u)
+
+	password, err := obscure.Obscure(credential.Password)
+	if err != nil {
+		return nil, "", errors.Wrap(ErrInitFTPFs, err.Error())
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = "21"
+	}
+
+	// https://github.com/rclone/rclone/blob/master/backend/ftp/ftp.go#L48
+	opts := rcloneConfigmap.Simple{
+		"type": "ftp",
+		"host": u.Hostname(),
+		"port": port,
+		"user": credential.User,
+		"pass": password,
+	}
+
+	root, filename := path.Split(u.Path)
+
+	ftpFs, err = rcloneFtp.NewFs(ctx, "ftp://"+u.Host, strings.TrimSuffix(root, "/"), opts)
+	if err != nil {
+		return nil, "", errors.Wrap(ErrInitFTPFs, err.Error())
+	}
+
+	return ftpFs, filename, nil
+}
+
+// downloadFTP copies the file at ftpURL to dstPath.
+func downloadFTP(ctx context.Context, ftpURL, dstPath string) error {
+	ftpFs, filename, err := InitFTPFs(ctx, ftpURL)
+	if err != nil {
+		return err
+	}
+
+	return downloadObject(ctx, ftpFs, filename, ftpURL, dstPath)
+}
@@ -0,0 +1,154 @@
+package vendors
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/metal-toolbox/firmware-syncer/internal/config"
+)
+
+// fakeFTPServer accepts FTP logins and records the credentials logged in with,
+// it implements just enough of FTP for the rclone ftp fs to initialize.
+type fakeFTPServer struct {
+	listener net.Listener
+	mutex    sync.Mutex
+	logins   []config.FTPCredential
+}
+
+func newFakeFTPServer(t *testing.T) *fakeFTPServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &fakeFTPServer{listener: listener}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go s.serve(conn)
+		}
+	}()
+
+	return s
+}
+
+func (s *fakeFTPServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	var login config.FTPCredential
+
+	fmt.Fprint(conn, "220 fake ftp\r\n")
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		command, arg, _ := strings.Cut(scanner.Text(), " ")
+
+		switch command {
+		case "USER":
+			login.User = arg
+			fmt.Fprint(conn, "331 password required\r\n")
+		case "PASS":
+			login.Password = arg
+
+			s.mutex.Lock()
+			s.logins = append(s.logins, login)
+			s.mutex.Unlock()
+
+			fmt.Fprint(conn, "230 logged in\r\n")
+		case "FEAT":
+			fmt.Fprint(conn, "211-Features:\r\n MLST type*;size*;modify*;\r\n211 End\r\n")
+		case "MLST":
+			// the fs root is looked up in case it's a file
+			fmt.Fprint(conn, "550 no such file\r\n")
+		case "TYPE":
+			fmt.Fprint(conn, "200 type set\r\n")
+		case "QUIT":
+			fmt.Fprint(conn, "221 bye\r\n")
+			return
+		default:
+			fmt.Fprint(conn, "502 not implemented\r\n")
+		}
+	}
+}
+
+func (s *fakeFTPServer) lastLogin() config.FTPCredential {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if len(s.logins) == 0 {
+		return config.FTPCredential{}
+	}
+
+	return s.logins[len(s.logins)-1]
+}
+
+func Test_InitFTPFs(t *testing.T) {
+	server := newFakeFTPServer(t)
+	host := server.listener.Addr().String()
+
+	testCases := []struct {
+		name          string
+		url           string
+		credentials   FTPCredentials
+		expectedLogin config.FTPCredential
+	}{
+		{
+			name:          "anonymous",
+			url:           "ftp://" + host + "/pub/firmware/fw.zip",
+			expectedLogin: config.FTPCredential{User: "anonymous", Password: "anonymous"},
+		},
+		{
+			name:          "credentials in the url",
+			url:           "ftp://firmware:s3cret@" + host + "/pub/firmware/fw.zip",
+			expectedLogin: config.FTPCredential{User: "firmware", Password: "s3cret"},
+		},
+		{
+			name:          "configured credentials",
+			url:           "ftp://" + host + "/pub/firmware/fw.zip",
+			credentials:   FTPCredentials{"127.0.0.1": {User: "mirror", Password: "hunter2"}},
+			expectedLogin: config.FTPCredential{User: "mirror", Password: "hunter2"},
+		},
+		{
+			name:          "url credentials take precedence",
+			url:           "ftp://firmware:s3cret@" + host + "/pub/firmware/fw.zip",
+			credentials:   FTPCredentials{host: {User: "mirror", Password: "hunter2"}},
+			expectedLogin: config.FTPCredential{User: "firmware", Password: "s3cret"},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := withFTPCredentials(context.Background(), tt.credentials)
+
+			ftpFs, filename, err := InitFTPFs(ctx, tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, "ftp://"+host, ftpFs.Name())
+			assert.Equal(t, "/pub/firmware", ftpFs.Root())
+			assert.Equal(t, "fw.zip", filename)
+			assert.Equal(t, tt.expectedLogin, server.lastLogin())
+		})
+	}
+}
+
+func Test_InitFTPFsUnsupportedURL(t *testing.T) {
+	for _, u := range []string{"https://example.com/fw.zip", "ftp:///fw.zip"} {
+		_, _, err := InitFTPFs(context.Background(), u)
+		assert.ErrorIs(t, err, ErrURLUnsupported, u)
+	}
+}
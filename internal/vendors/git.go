@@ -0,0 +1,454 @@
+package vendors
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// gitDefaultRef is the ref fetched when the git URL doesn't name one
+	gitDefaultRef = "HEAD"
+	// lfsPointerVersion is the first line of git LFS pointer files
+	lfsPointerVersion = "version https://git-lfs.github.com/spec/v1"
+	// lfsMediaType is the media type of the git LFS batch API requests and responses
+	lfsMediaType = "application/vnd.git-lfs+json"
+	// maxLFSPointerSize bounds the size of the blobs parsed as LFS pointers, pointers are around 130 bytes
+	maxLFSPointerSize = 1024
+)
+
+var (
+	ErrGitSource   = errors.New("error fetching firmware from git")
+	ErrLFSObject   = errors.New("error fetching git LFS object")
+	ErrGitFileURLs = errors.New("git+file URLs are disabled")
+)
+
+type gitFileURLsKey struct{}
+
+// withGitFileURLs returns a context allowing firmware to be fetched from git+file:// URLs,
+// which read any repository on the syncer host.
+func withGitFileURLs(ctx context.Context, allowed bool) context.Context {
+	if !allowed {
+		return ctx
+	}
+
+	return context.WithValue(ctx, gitFileURLsKey{}, true)
+}
+
+// gitFileURLsAllowed returns true when the context allows git+file:// URLs.
+func gitFileURLsAllowed(ctx context.Context) bool {
+	allowed, _ := ctx.Value(gitFileURLsKey{}).(bool)
+	return allowed
+}
+
+// gitSource is the file a git+<scheme>://host/repo//path@ref URL points to.
+type gitSource struct {
+	// repoURL is the URL the repository is fetched from, without the git+ prefix
+	repoURL string
+	// filePath is the path of the file in the repository
+	filePath string
+	// ref is the branch, tag or commit fetched, the remote HEAD when not set in the URL
+	ref string
+}
+
+// isGitURL returns true for git+https://, git+http://, git+ssh:// and git+file:// URLs.
+func isGitURL(rawURL string) bool {
+	lower := strings.ToLower(rawURL)
+	for _, scheme := range []string{"git+https://", "git+http://", "git+ssh://", "git+file://"} {
+		if strings.HasPrefix(lower, scheme) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseGitURL returns the source of a git+<scheme>://host/repo//path@ref URL,
+// the double slash separates the repository from the file path, the ref is optional.
+func parseGitURL(gitURL string) (*gitSource, error) {
+	if !isGitURL(gitURL) {
+		return nil, errors.Wrap(ErrURLUnsupported, gitURL)
+	}
+
+	rawURL := gitURL[len("git+"):]
+	schemeEnd := strings.Index(rawURL, "://") + len("://")
+
+	sep := strings.Index(rawURL[schemeEnd:], "//")
+	if sep < 0 {
+		return nil, errors.Wrap(ErrURLUnsupported, gitURL+": the file path must follow the repository after a double slash")
+	}
+
+	repoURL := rawURL[:schemeEnd+sep]
+	filePath := rawURL[schemeEnd+sep+len("//"):]
+	ref := gitDefaultRef
+
+	if at := strings.LastIndex(filePath, "@"); at >= 0 {
+		filePath, ref = filePath[:at], filePath[at+1:]
+	}
+
+	if _, err := url.Parse(repoURL); err != nil || filePath == "" || ref == "" || strings.HasSuffix(filePath, "/") {
+		return nil, errors.Wrap(ErrURLUnsupported, gitURL)
+	}
+
+	// the ref is passed to git fetch, it must not be taken for an option
+	if strings.HasPrefix(ref, "-") {
+		return nil, errors.Wrap(ErrURLUnsupported, gitURL+": the ref must not start with a dash")
+	}
+
+	return &gitSource{repoURL: repoURL, filePath: path.Clean(filePath), ref: ref}, nil
+}
+
+// gitFilename returns the name of the file a git URL points to.
+func gitFilename(gitURL string) string {
+	source, err := parseGitURL(gitURL)
+	if err != nil {
+		return filepath.Base(gitURL)
+	}
+
+	return path.Base(source.filePath)
+}
+
+// downloadGit copies the file at gitURL to dstPath, the ref is shallow fetched to a scratch repository
+// next to dstPath and the file is resolved from the LFS storage of the repository when it's an LFS pointer.
+// The file is checked against the maximum file size and the available disk space before it's written.
+// git+file:// URLs are refused unless the context allows them.
+func downloadGit(ctx context.Context, gitURL, dstPath string) error {
+	source, err := parseGitURL(gitURL)
+	if err != nil {
+		return err
+	}
+
+	if strings.HasPrefix(strings.ToLower(source.repoURL), "file://") && !gitFileURLsAllowed(ctx) {
+		return errors.Wrap(ErrGitFileURLs, gitURL)
+	}
+
+	scratch, err := os.MkdirTemp(path.Dir(dstPath), ".git-")
+	if err != nil {
+		return errors.Wrap(ErrGitSource, err.Error())
+	}
+	defer os.RemoveAll(scratch)
+
+	if _, err = runGit(ctx, scratch, "init", "--quiet", "--bare"); err != nil {
+		return err
+	}
+
+	if _, err = runGit(ctx, scratch, "fetch", "--quiet", "--depth", "1", "--end-of-options", source.repoURL, source.ref); err != nil {
+		return err
+	}
+
+	object := "FETCH_HEAD:" + source.filePath
+
+	size, err := gitBlobSize(ctx, scratch, object)
+	if err != nil {
+		return err
+	}
+
+	if err = checkFileSize(ctx, gitURL, size); err != nil {
+		return err
+	}
+
+	// LFS pointers are small, the blobs which could be one are read to find out
+	if size <= maxLFSPointerSize {
+		blob, err := runGit(ctx, scratch, "cat-file", "blob", object)
+		if err != nil {
+			return err
+		}
+
+		pointer, ok := parseLFSPointer(blob)
+		if !ok {
+			return os.WriteFile(dstPath, blob, 0o600)
+		}
+
+		return fetchLFSObject(ctx, source.repoURL, pointer, dstPath)
+	}
+
+	if err = checkAvailableSpace(path.Dir(dstPath), size); err != nil {
+		return err
+	}
+
+	return writeGitBlob(ctx, scratch, object, dstPath)
+}
+
+// gitBlobSize returns the size of the blob object in the repository at dir.
+func gitBlobSize(ctx context.Context, dir, object string) (int64, error) {
+	out, err := runGit(ctx, dir, "cat-file", "-s", object)
+	if err != nil {
+		return 0, err
+	}
+
+	size, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+	if err != nil {
+		return 0, errors.Wrap(ErrGitSource, fmt.Sprintf("size of %s: %s", object, err))
+	}
+
+	return size, nil
+}
+
+// writeGitBlob streams the blob object in the repository at dir to dstPath.
+func writeGitBlob(ctx context.Context, dir, object, dstPath string) error {
+	out, err := os.Create(dstPath)
+	if err != nil {
+		return errors.Wrap(ErrGitSource, err.Error())
+	}
+	defer out.Close()
+
+	return streamGit(ctx, dir, out, "cat-file", "blob", object)
+}
+
+// runGit runs the git command in dir and returns its output,
+// the output of git on stderr is part of the returned error.
+func runGit(ctx context.Context, dir string, args ...string) ([]byte, error) {
+	var stdout bytes.Buffer
+
+	if err := streamGit(ctx, dir, &stdout, args...); err != nil {
+		return nil, err
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// streamGit runs the git command in dir with its output written to stdout,
+// the output of git on stderr is part of the returned error.
+func streamGit(ctx context.Context, dir string, stdout io.Writer, args ...string) error {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	// git must not prompt for credentials nor run the LFS filters of the repository
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_LFS_SKIP_SMUDGE=1")
+
+	var stderr bytes.Buffer
+
+	cmd.Stdout = stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return errors.Wrap(ErrGitSource, fmt.Sprintf("git %s: %s: %s", args[0], err, strings.TrimSpace(stderr.String())))
+	}
+
+	return nil
+}
+
+// lfsPointer is the object an LFS pointer file refers to.
+type lfsPointer struct {
+	OID  string `json:"oid"`
+	Size int64  `json:"size"`
+}
+
+// parseLFSPointer returns the object the blob points to, ok is false when the blob isn't an LFS pointer.
+func parseLFSPointer(blob []byte) (pointer lfsPointer, ok bool) {
+	if len(blob) > maxLFSPointerSize || !bytes.HasPrefix(blob, []byte(lfsPointerVersion+"\n")) {
+		return pointer, false
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(blob))
+	for scanner.Scan() {
+		key, value, _ := strings.Cut(scanner.Text(), " ")
+
+		switch key {
+		case "oid":
+			pointer.OID = strings.TrimPrefix(value, "sha256:")
+		case "size":
+			pointer.Size, _ = strconv.ParseInt(value, 10, 64)
+		}
+	}
+
+	return pointer, len(pointer.OID) == sha256.Size*2 && pointer.Size > 0
+}
+
+// lfsBatchResponse is the response of the git LFS batch API,
+// https://github.com/git-lfs/git-lfs/blob/main/docs/api/batch.md
+type lfsBatchResponse struct {
+	Objects []struct {
+		lfsPointer
+		Actions struct {
+			Download *struct {
+				Href   string            `json:"href"`
+				Header map[string]string `json:"header"`
+			} `json:"download"`
+		} `json:"actions"`
+		Error *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	} `json:"objects"`
+}
+
+// lfsEndpoint returns the LFS server of the repository, following the git LFS conventions:
+// file repositories keep the objects in their lfs/objects directory, the http(s) ones serve them at <repo>.git/info/lfs.
+func lfsEndpoint(repoURL string) (string, error) {
+	u, err := url.Parse(repoURL)
+	if err != nil {
+		return "", errors.Wrap(ErrLFSObject, err.Error())
+	}
+
+	switch u.Scheme {
+	case "file":
+		return u.Path, nil
+	case "http", "https":
+		if !strings.HasSuffix(u.Path, ".git") {
+			u.Path += ".git"
+		}
+
+		u.Path += "/info/lfs"
+
+		return u.String(), nil
+	default:
+		return "", errors.Wrap(ErrLFSObject, "LFS objects can't be fetched from "+u.Scheme+" repositories")
+	}
+}
+
+// fetchLFSObject copies the LFS object the pointer refers to from the LFS storage of the repository to dstPath,
+// the object is checked against the size and the sha256 digest in the pointer.
+func fetchLFSObject(ctx context.Context, repoURL string, pointer lfsPointer, dstPath string) error {
+	if err := checkFileSize(ctx, repoURL, pointer.Size); err != nil {
+		return err
+	}
+
+	if err := checkAvailableSpace(path.Dir(dstPath), pointer.Size); err != nil {
+		return err
+	}
+
+	endpoint, err := lfsEndpoint(repoURL)
+	if err != nil {
+		return err
+	}
+
+	var r io.ReadCloser
+
+	if strings.HasPrefix(repoURL, "file://") {
+		r, err = os.Open(path.Join(endpoint, "lfs", "objects", pointer.OID[:2], pointer.OID[2:4], pointer.OID))
+	} else {
+		r, err = downloadLFSObject(ctx, endpoint, pointer)
+	}
+
+	if err != nil {
+		return errors.Wrap(ErrLFSObject, err.Error())
+	}
+	defer r.Close()
+
+	return writeLFSObject(r, pointer, dstPath)
+}
+
+// downloadLFSObject returns the body of the download of the object, as advertised by the batch API at endpoint.
+func downloadLFSObject(ctx context.Context, endpoint string, pointer lfsPointer) (io.ReadCloser, error) {
+	href, header, err := lfsDownloadAction(ctx, endpoint, pointer)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, href, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, value := range header {
+		req.Header.Set(key, value)
+	}
+
+	// firmware downloads can take a while, they're not bound by the client timeout
+	resp, err := NewHTTPClient(&HTTPClientOptions{Timeout: -1}).Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, errors.New(href + ": " + resp.Status)
+	}
+
+	return resp.Body, nil
+}
+
+// lfsDownloadAction returns the URL and the headers the object is downloaded with, requested from the batch API at endpoint.
+// The request is authenticated with the credentials in the endpoint URL and carries the headers configured for its host.
+func lfsDownloadAction(ctx context.Context, endpoint string, pointer lfsPointer) (href string, header map[string]string, err error) {
+	body, err := json.Marshal(map[string]any{
+		"operation": "download",
+		"transfers": []string{"basic"},
+		"objects":   []lfsPointer{pointer},
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint+"/objects/batch", bytes.NewReader(body))
+	if err != nil {
+		return "", nil, err
+	}
+
+	req.Header.Set("Accept", lfsMediaType)
+	req.Header.Set("Content-Type", lfsMediaType)
+	// the batch request only reads, it's retried as an idempotent request, the nil value isn't sent
+	req.Header["Idempotency-Key"] = nil
+	setSourceHeaders(ctx, req)
+
+	resp, err := NewHTTPClient(nil).Do(req)
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", nil, errors.New(req.URL.Redacted() + ": " + resp.Status)
+	}
+
+	var batch lfsBatchResponse
+	if err = json.NewDecoder(resp.Body).Decode(&batch); err != nil {
+		return "", nil, err
+	}
+
+	if len(batch.Objects) != 1 {
+		return "", nil, errors.New("unexpected batch response for object " + pointer.OID)
+	}
+
+	object := batch.Objects[0]
+
+	switch {
+	case object.Error != nil:
+		return "", nil, fmt.Errorf("object %s: %d %s", pointer.OID, object.Error.Code, object.Error.Message)
+	case object.Actions.Download == nil:
+		return "", nil, errors.New("no download advertised for object " + pointer.OID)
+	}
+
+	return object.Actions.Download.Href, object.Actions.Download.Header, nil
+}
+
+// writeLFSObject writes the object read from r to dstPath, checking its size and digest match the pointer.
+func writeLFSObject(r io.Reader, pointer lfsPointer, dstPath string) error {
+	out, err := os.Create(dstPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	hasher := sha256.New()
+
+	written, err := io.Copy(io.MultiWriter(out, hasher), io.LimitReader(r, pointer.Size+1))
+	if err != nil {
+		return errors.Wrap(ErrLFSObject, err.Error())
+	}
+
+	if written != pointer.Size {
+		return errors.Wrap(ErrLFSObject, fmt.Sprintf("object %s: got %d bytes, expected %d bytes", pointer.OID, written, pointer.Size))
+	}
+
+	if digest := hex.EncodeToString(hasher.Sum(nil)); digest != pointer.OID {
+		return errors.Wrap(ErrLFSObject, fmt.Sprintf("object %s: got sha256 %s", pointer.OID, digest))
+	}
+
+	return nil
+}
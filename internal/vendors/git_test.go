@@ -0,0 +1,421 @@
+package vendors
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// lfsFirmware is the content of the firmware stored with LFS in the repository fixture
+var (
+	lfsFirmware = []byte("firmware stored with git LFS")
+	// largeFirmware is larger than the LFS pointers, it's streamed from git
+	largeFirmware = bytes.Repeat([]byte("large firmware "), 1024)
+)
+
+func lfsPointerFor(content []byte) lfsPointer {
+	digest := sha256.Sum256(content)
+	return lfsPointer{OID: hex.EncodeToString(digest[:]), Size: int64(len(content))}
+}
+
+func lfsPointerFile(p lfsPointer) []byte {
+	return []byte(fmt.Sprintf("%s\noid sha256:%s\nsize %d\n", lfsPointerVersion, p.OID, p.Size))
+}
+
+// newGitRepo returns a bare repository with firmware/plain.bin and firmware/large.bin committed as is
+// and firmware/lfs.bin committed as an LFS pointer, with its object in the repository LFS storage.
+// The v1 tag points to the commit, the main branch to a later commit updating plain.bin.
+func newGitRepo(t *testing.T) string {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	work := t.TempDir()
+	bare := filepath.Join(t.TempDir(), "firmware.git")
+	pointer := lfsPointerFor(lfsFirmware)
+
+	git := func(dir string, args ...string) {
+		args = append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)
+		if out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %s: %s", args, err, out)
+		}
+	}
+
+	write := func(name string, content []byte) {
+		if err := os.MkdirAll(filepath.Join(work, path.Dir(name)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(filepath.Join(work, name), content, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	git(work, "init", "--quiet", "--initial-branch", "main")
+	write("firmware/plain.bin", []byte("plain firmware v1"))
+	write("firmware/large.bin", largeFirmware)
+	write("firmware/lfs.bin", lfsPointerFile(pointer))
+	git(work, "add", ".")
+	git(work, "commit", "--quiet", "-m", "v1")
+	git(work, "tag", "v1")
+	write("firmware/plain.bin", []byte("plain firmware v2"))
+	git(work, "commit", "--quiet", "-am", "v2")
+	git(work, "clone", "--quiet", "--bare", work, bare)
+
+	objectDir := filepath.Join(bare, "lfs", "objects", pointer.OID[:2], pointer.OID[2:4])
+	if err := os.MkdirAll(objectDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(objectDir, pointer.OID), lfsFirmware, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return bare
+}
+
+func Test_ParseGitURL(t *testing.T) {
+	testCases := []struct {
+		name     string
+		gitURL   string
+		expected *gitSource
+		err      error
+	}{
+		{
+			name:     "path and ref",
+			gitURL:   "git+https://git.example.com/firmware/bmc.git//dell/r750/bmc.bin@v1.2.0",
+			expected: &gitSource{repoURL: "https://git.example.com/firmware/bmc.git", filePath: "dell/r750/bmc.bin", ref: "v1.2.0"},
+		},
+		{
+			name:     "default ref",
+			gitURL:   "git+https://git.example.com/firmware/bmc//bmc.bin",
+			expected: &gitSource{repoURL: "https://git.example.com/firmware/bmc", filePath: "bmc.bin", ref: "HEAD"},
+		},
+		{
+			name:     "ssh user",
+			gitURL:   "git+ssh://git@git.example.com/firmware/bmc.git//bmc.bin@main",
+			expected: &gitSource{repoURL: "ssh://git@git.example.com/firmware/bmc.git", filePath: "bmc.bin", ref: "main"},
+		},
+		{
+			name:     "file repository",
+			gitURL:   "git+file:///srv/git/firmware.git//bmc.bin@main",
+			expected: &gitSource{repoURL: "file:///srv/git/firmware.git", filePath: "bmc.bin", ref: "main"},
+		},
+		{
+			name:   "no file path",
+			gitURL: "git+https://git.example.com/firmware/bmc.git",
+			err:    ErrURLUnsupported,
+		},
+		{
+			name:   "directory path",
+			gitURL: "git+https://git.example.com/firmware/bmc.git//dell/@main",
+			err:    ErrURLUnsupported,
+		},
+		{
+			name:   "option ref",
+			gitURL: "git+https://git.example.com/firmware/bmc.git//bmc.bin@--upload-pack=touch /tmp/pwned;git-upload-pack",
+			err:    ErrURLUnsupported,
+		},
+		{
+			name:   "not a git URL",
+			gitURL: "https://git.example.com/firmware/bmc.git//bmc.bin",
+			err:    ErrURLUnsupported,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			source, err := parseGitURL(tt.gitURL)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, source)
+		})
+	}
+}
+
+func Test_ParseLFSPointer(t *testing.T) {
+	pointer := lfsPointerFor(lfsFirmware)
+
+	parsed, ok := parseLFSPointer(lfsPointerFile(pointer))
+	assert.True(t, ok)
+	assert.Equal(t, pointer, parsed)
+
+	_, ok = parseLFSPointer(lfsFirmware)
+	assert.False(t, ok)
+
+	_, ok = parseLFSPointer([]byte(lfsPointerVersion + "\noid sha256:1234\nsize 10\n"))
+	assert.False(t, ok, "the oid is not a sha256 digest")
+}
+
+func Test_DownloadFirmwareArchiveGit(t *testing.T) {
+	repo := newGitRepo(t)
+
+	testCases := []struct {
+		name     string
+		filePath string
+		expected []byte
+		err      error
+	}{
+		{
+			name:     "plain file at a tag",
+			filePath: "firmware/plain.bin@v1",
+			expected: []byte("plain firmware v1"),
+		},
+		{
+			name:     "plain file at a branch",
+			filePath: "firmware/plain.bin@main",
+			expected: []byte("plain firmware v2"),
+		},
+		{
+			name:     "plain file at the default branch",
+			filePath: "firmware/plain.bin",
+			expected: []byte("plain firmware v2"),
+		},
+		{
+			name:     "large file",
+			filePath: "firmware/large.bin@v1",
+			expected: largeFirmware,
+		},
+		{
+			name:     "lfs file",
+			filePath: "firmware/lfs.bin@v1",
+			expected: lfsFirmware,
+		},
+		{
+			name:     "missing file",
+			filePath: "firmware/missing.bin@main",
+			err:      ErrGitSource,
+		},
+		{
+			name:     "missing ref",
+			filePath: "firmware/plain.bin@v2",
+			err:      ErrGitSource,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+
+			ctx := withGitFileURLs(context.Background(), true)
+
+			filePath, err := DownloadFirmwareArchive(ctx, tmpDir, "git+file://"+repo+"//"+tt.filePath, "")
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+
+			assert.NoError(t, err)
+			filename, _, _ := strings.Cut(path.Base(tt.filePath), "@")
+			assert.Equal(t, path.Join(tmpDir, filename), filePath)
+
+			b, err := os.ReadFile(filePath)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, b)
+
+			entries, _ := os.ReadDir(tmpDir)
+			assert.Len(t, entries, 1, "the scratch repository is removed")
+		})
+	}
+}
+
+func Test_DownloadGitRefused(t *testing.T) {
+	repo := newGitRepo(t)
+	tmpDir := t.TempDir()
+	marker := filepath.Join(tmpDir, "injected")
+
+	testCases := []struct {
+		name   string
+		gitURL string
+		ctx    context.Context
+		err    error
+	}{
+		{
+			name:   "file URL not allowed",
+			gitURL: "git+file://" + repo + "//firmware/plain.bin@main",
+			ctx:    context.Background(),
+			err:    ErrGitFileURLs,
+		},
+		{
+			name:   "option injected in the ref",
+			gitURL: "git+file://" + repo + "//firmware/plain.bin@--upload-pack=touch " + marker + ";git-upload-pack",
+			ctx:    withGitFileURLs(context.Background(), true),
+			err:    ErrURLUnsupported,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			err := downloadGit(tt.ctx, tt.gitURL, filepath.Join(tmpDir, "plain.bin"))
+			assert.ErrorIs(t, err, tt.err)
+			assert.NoFileExists(t, marker)
+			assert.NoFileExists(t, filepath.Join(tmpDir, "plain.bin"))
+		})
+	}
+}
+
+func Test_DownloadGitMaxFileSize(t *testing.T) {
+	repo := newGitRepo(t)
+
+	testCases := []struct {
+		name     string
+		filePath string
+		err      error
+	}{
+		{
+			name:     "large file",
+			filePath: "firmware/large.bin@v1",
+			err:      ErrFileTooLarge,
+		},
+		{
+			name:     "plain file",
+			filePath: "firmware/plain.bin@v1",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			dstPath := filepath.Join(t.TempDir(), path.Base(tt.filePath))
+			ctx := withMaxFileSize(withGitFileURLs(context.Background(), true), int64(len(largeFirmware)-1))
+
+			err := downloadGit(ctx, "git+file://"+repo+"//"+tt.filePath, dstPath)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				assert.NoFileExists(t, dstPath)
+
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
+
+// newLFSServer serves the git LFS batch API for the object, behind basic auth,
+// the object is served with its digest when corrupt is set.
+func newLFSServer(t *testing.T, object []byte, corrupt bool) *httptest.Server {
+	pointer := lfsPointerFor(object)
+
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	mux.HandleFunc("/firmware.git/info/lfs/objects/batch", func(w http.ResponseWriter, r *http.Request) {
+		if u, p, ok := r.BasicAuth(); !ok || u != "user" || p != "sekrit" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		var batch struct {
+			Operation string       `json:"operation"`
+			Objects   []lfsPointer `json:"objects"`
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil || batch.Operation != "download" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", lfsMediaType)
+
+		requested := batch.Objects[0]
+		if requested != pointer {
+			fmt.Fprintf(w, `{"objects":[{"oid":%q,"size":%d,"error":{"code":404,"message":"Object does not exist"}}]}`,
+				requested.OID, requested.Size)
+
+			return
+		}
+
+		fmt.Fprintf(w, `{"objects":[{"oid":%q,"size":%d,"actions":{"download":{"href":%q,"header":{"Authorization":"Bearer token"}}}}]}`,
+			pointer.OID, pointer.Size, server.URL+"/objects/"+pointer.OID)
+	})
+
+	mux.HandleFunc("/objects/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		if corrupt {
+			_, _ = w.Write([]byte(pointer.OID))
+			return
+		}
+
+		_, _ = w.Write(object)
+	})
+
+	return server
+}
+
+func Test_FetchLFSObject(t *testing.T) {
+	pointer := lfsPointerFor(lfsFirmware)
+
+	testCases := []struct {
+		name    string
+		user    string
+		pointer lfsPointer
+		corrupt bool
+		err     error
+	}{
+		{
+			name:    "object downloaded",
+			user:    "user:sekrit@",
+			pointer: pointer,
+		},
+		{
+			name:    "unauthorized",
+			pointer: pointer,
+			err:     ErrLFSObject,
+		},
+		{
+			name:    "missing object",
+			user:    "user:sekrit@",
+			pointer: lfsPointerFor([]byte("other firmware")),
+			err:     ErrLFSObject,
+		},
+		{
+			name:    "corrupt object",
+			user:    "user:sekrit@",
+			pointer: pointer,
+			corrupt: true,
+			err:     ErrLFSObject,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newLFSServer(t, lfsFirmware, tt.corrupt)
+			repoURL := "http://" + tt.user + server.Listener.Addr().String() + "/firmware"
+			dstPath := filepath.Join(t.TempDir(), "lfs.bin")
+
+			err := fetchLFSObject(context.Background(), repoURL, tt.pointer, dstPath)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+
+			assert.NoError(t, err)
+
+			b, err := os.ReadFile(dstPath)
+			assert.NoError(t, err)
+			assert.Equal(t, lfsFirmware, b)
+		})
+	}
+}
@@ -27,9 +27,15 @@ func NewGitHubClient(ctx context.Context, githubOpenBmcToken string) *github.Cli
 	tokenSource := oauth2.StaticTokenSource(
 		&oauth2.Token{AccessToken: githubOpenBmcToken},
 	)
+	// the token client is built on the shared transport to go through the configured proxy
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, &http.Client{Transport: vendors.SharedTransport()})
 	tokenClient := oauth2.NewClient(ctx, tokenSource)
 
-	return github.NewClient(tokenClient)
+	client := github.NewClient(tokenClient)
+	// go-github sets its own user agent on the requests
+	client.UserAgent = vendors.UserAgent()
+
+	return client
 }
 
 type Downloader struct {
@@ -51,44 +57,49 @@ func (d *Downloader) Download(
 	ctx context.Context,
 	downloadDir string,
 	firmware *fleetdbapi.ComponentFirmwareVersion,
-) (string, error) {
+) (*vendors.FirmwareFile, error) {
+	downloadDir, err := vendors.FirmwareDownloadDir(downloadDir, firmware)
+	if err != nil {
+		return nil, err
+	}
+
 	tmpFs, err := vendors.InitLocalFs(ctx, &vendors.LocalFsConfig{Root: downloadDir})
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	owner, repo, tag, filename, err := parseGithubReleaseURL(firmware.UpstreamURL)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	release, _, err := d.client.Repositories.GetReleaseByTag(ctx, owner, repo, tag)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	asset, err := getAssetByName(filename, release.Assets)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	// Give enough time for the client to download the binary file.
-	redirectClient := &http.Client{
+	redirectClient := vendors.NewHTTPClient(&vendors.HTTPClientOptions{
 		Timeout: time.Second * DownloadTimeout,
-	}
+	})
 
 	rc, _, err := d.client.Repositories.DownloadReleaseAsset(ctx, owner, repo, *asset.ID, redirectClient)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer rc.Close()
 
 	_, err = operations.Rcat(ctx, tmpFs, firmware.Filename, rc, time.Now(), nil)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	return path.Join(downloadDir, firmware.Filename), nil
+	return &vendors.FirmwareFile{Path: path.Join(downloadDir, firmware.Filename)}, nil
 }
 
 func parseGithubReleaseURL(ghURL string) (owner, repo, release, filename string, err error) {
@@ -0,0 +1,91 @@
+package vendors
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	rcloneFs "github.com/rclone/rclone/fs"
+
+	"github.com/metal-toolbox/firmware-syncer/internal/config"
+)
+
+// SourceHeaders maps source hosts to the HTTP headers sent with their requests,
+// for vendor portals authenticating with a bearer token or an API key header.
+type SourceHeaders map[string]map[string]string
+
+type sourceHeadersKey struct{}
+
+// withSourceHeaders returns a context sending the configured headers with the requests to their source host.
+func withSourceHeaders(ctx context.Context, headers SourceHeaders) context.Context {
+	if len(headers) == 0 {
+		return ctx
+	}
+
+	return context.WithValue(ctx, sourceHeadersKey{}, headers)
+}
+
+// hostHeaders returns the context headers configured for the host of rawURL, sorted by header name.
+func hostHeaders(ctx context.Context, rawURL string) []*rcloneFs.HTTPOption {
+	headers, ok := ctx.Value(sourceHeadersKey{}).(SourceHeaders)
+	if !ok {
+		return nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil
+	}
+
+	var options []*rcloneFs.HTTPOption
+
+	for host, hostHeaders := range headers {
+		if !strings.EqualFold(host, u.Host) && !strings.EqualFold(host, u.Hostname()) {
+			continue
+		}
+
+		for key, value := range hostHeaders {
+			options = append(options, &rcloneFs.HTTPOption{Key: http.CanonicalHeaderKey(key), Value: value})
+		}
+	}
+
+	sort.Slice(options, func(i, j int) bool { return options[i].Key < options[j].Key })
+
+	return options
+}
+
+// withRcloneSourceHeaders returns a context whose rclone HTTP requests carry the headers configured for the host of rawURL.
+func withRcloneSourceHeaders(ctx context.Context, rawURL string) context.Context {
+	options := hostHeaders(ctx, rawURL)
+	if len(options) == 0 {
+		return ctx
+	}
+
+	ctx, ci := rcloneFs.AddConfig(ctx)
+	ci.Headers = append(append([]*rcloneFs.HTTPOption{}, ci.Headers...), options...)
+
+	return ctx
+}
+
+// setSourceHeaders sets the headers configured for the request host on the request.
+func setSourceHeaders(ctx context.Context, req *http.Request) {
+	for _, option := range hostHeaders(ctx, req.URL.String()) {
+		req.Header.Set(option.Key, option.Value)
+	}
+}
+
+// Redacted returns the headers with their values redacted, for logging.
+func (h SourceHeaders) Redacted() SourceHeaders {
+	redactedHeaders := make(SourceHeaders, len(h))
+
+	for host, headers := range h {
+		redactedHeaders[host] = make(map[string]string, len(headers))
+		for key := range headers {
+			redactedHeaders[host][key] = config.RedactedValue
+		}
+	}
+
+	return redactedHeaders
+}
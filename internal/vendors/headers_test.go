@@ -0,0 +1,97 @@
+package vendors
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	fleetdbapi "github.com/metal-toolbox/fleetdb/pkg/api/v1"
+	rcloneFs "github.com/rclone/rclone/fs"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+
+	"github.com/metal-toolbox/firmware-syncer/internal/config"
+	mock_vendors "github.com/metal-toolbox/firmware-syncer/internal/vendors/mocks"
+)
+
+type headersMatcher struct {
+	headers map[string]string
+}
+
+func (m *headersMatcher) Matches(x interface{}) bool {
+	req, ok := x.(*http.Request)
+	if !ok {
+		return false
+	}
+
+	for key, value := range m.headers {
+		if req.Header.Get(key) != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (m *headersMatcher) String() string {
+	return "has headers"
+}
+
+func Test_SourceOverrideDownloaderHeaders(t *testing.T) {
+	headers := SourceHeaders{
+		"firmware.example.com": {"authorization": "Bearer token", "X-Api-Key": "key"},
+		"other.example.com":    {"X-Other": "other"},
+	}
+
+	ctx := withSourceHeaders(context.Background(), headers)
+
+	ctrl := gomock.NewController(t)
+	client := mock_vendors.NewMockHTTPDoer(ctrl)
+	client.EXPECT().
+		Do(&headersMatcher{headers: map[string]string{"Authorization": "Bearer token", "X-Api-Key": "key", "X-Other": ""}}).
+		Return(&http.Response{Body: http.NoBody, StatusCode: http.StatusOK, Header: http.Header{}}, nil)
+
+	downloader := NewSourceOverrideDownloader(logrus.New(), client, "https://firmware.example.com")
+
+	_, err := downloader.Download(ctx, t.TempDir(), &fleetdbapi.ComponentFirmwareVersion{Filename: "firmware.bin"})
+	assert.NoError(t, err)
+}
+
+func Test_DownloadFirmwareArchiveHeaders(t *testing.T) {
+	var requests int
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		_, _ = w.Write([]byte("firmware"))
+	}))
+	defer server.Close()
+
+	ctx, ci := rcloneFs.AddConfig(context.Background())
+	ci.LowLevelRetries = 1
+
+	// the headers are configured by host, the test server host includes its port
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx = withSourceHeaders(ctx, SourceHeaders{serverURL.Host: {"Authorization": "Bearer token"}})
+
+	_, err = DownloadFirmwareArchive(ctx, t.TempDir(), server.URL+"/firmware.bin", "")
+	assert.NoError(t, err)
+
+	// the HEAD request for the content length and the download
+	assert.Equal(t, 2, requests)
+}
+
+func Test_SourceHeadersRedacted(t *testing.T) {
+	headers := SourceHeaders{"firmware.example.com": {"Authorization": "Bearer token"}}
+
+	assert.Equal(t, SourceHeaders{"firmware.example.com": {"Authorization": config.RedactedValue}}, headers.Redacted())
+	assert.Equal(t, "Bearer token", headers["firmware.example.com"]["Authorization"])
+}
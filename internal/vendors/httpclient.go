@@ -0,0 +1,246 @@
+package vendors
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	defaultHTTPTimeout      = 15 * time.Second
+	defaultHTTPMaxRetries   = 3
+	defaultHTTPRetryWaitMin = 500 * time.Millisecond
+	defaultHTTPRetryWaitMax = 30 * time.Second
+)
+
+// HTTPClientOptions configures the client returned by NewHTTPClient,
+// the defaults are used for the fields not set.
+type HTTPClientOptions struct {
+	// Timeout is the overall timeout of a request, including retries, a negative value disables the timeout
+	Timeout time.Duration
+	// MaxRetries is the number of times a failed request is retried, a negative value disables retries
+	MaxRetries int
+	// RetryWaitMin is the initial wait between retries, doubled after each retry
+	RetryWaitMin time.Duration
+	// RetryWaitMax caps the wait between retries, including the wait requested with Retry-After
+	RetryWaitMax time.Duration
+	// RateLimit is the maximum number of requests per second to a host, unlimited when not set
+	RateLimit float64
+	// Transport is the underlying RoundTripper, the SharedTransport when not set
+	Transport http.RoundTripper
+	// Proxy routes the requests sent with the SharedTransport through a proxy, unless their context sets one
+	Proxy *Proxy
+}
+
+// NewHTTPClient returns an http.Client which retries idempotent requests on connection errors,
+// 429 and 5xx responses with an exponential backoff, honoring the Retry-After header.
+// Requests with other methods, as POSTs, are only retried when marked idempotent with an Idempotency-Key
+// or X-Idempotency-Key header, as with http.Transport; a nil header value marks the request without sending it.
+func NewHTTPClient(opts *HTTPClientOptions) *http.Client {
+	if opts == nil {
+		opts = &HTTPClientOptions{}
+	}
+
+	transport := &retryTransport{
+		base:       opts.Transport,
+		maxRetries: opts.MaxRetries,
+		waitMin:    opts.RetryWaitMin,
+		waitMax:    opts.RetryWaitMax,
+		rateLimit:  opts.RateLimit,
+		limiters:   make(map[string]*rate.Limiter),
+	}
+
+	if transport.base == nil {
+		transport.base = SharedTransport()
+	}
+
+	if opts.Proxy != nil {
+		transport.base = &proxyTransport{base: transport.base, proxy: opts.Proxy}
+	}
+
+	if transport.maxRetries == 0 {
+		transport.maxRetries = defaultHTTPMaxRetries
+	}
+
+	if transport.waitMin == 0 {
+		transport.waitMin = defaultHTTPRetryWaitMin
+	}
+
+	if transport.waitMax == 0 {
+		transport.waitMax = defaultHTTPRetryWaitMax
+	}
+
+	timeout := opts.Timeout
+	if timeout == 0 {
+		timeout = defaultHTTPTimeout
+	}
+
+	if timeout < 0 {
+		timeout = 0
+	}
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+	}
+}
+
+// retryTransport is an http.RoundTripper retrying failed requests.
+type retryTransport struct {
+	base       http.RoundTripper
+	maxRetries int
+	waitMin    time.Duration
+	waitMax    time.Duration
+	rateLimit  float64
+
+	mutex    sync.Mutex
+	limiters map[string]*rate.Limiter
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	attemptReq := req
+
+	for attempt := 0; ; attempt++ {
+		if err := t.limiter(req.URL.Host).Wait(req.Context()); err != nil {
+			return nil, err
+		}
+
+		resp, err := t.base.RoundTrip(attemptReq)
+		if !isIdempotent(req) || !shouldRetry(req.Context(), resp, err) || attempt >= t.maxRetries {
+			return resp, err
+		}
+
+		retryReq, ok := cloneRequest(req)
+		if !ok {
+			return resp, err
+		}
+
+		attemptReq = retryReq
+
+		wait := t.backoff(attempt, resp)
+
+		if resp != nil {
+			// drain the body so the connection can be reused
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// limiter returns the rate limiter for the host.
+func (t *retryTransport) limiter(host string) *rate.Limiter {
+	if t.rateLimit <= 0 {
+		return rate.NewLimiter(rate.Inf, 0)
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	limiter, ok := t.limiters[host]
+	if !ok {
+		limiter = rate.NewLimiter(rate.Limit(t.rateLimit), 1)
+		t.limiters[host] = limiter
+	}
+
+	return limiter
+}
+
+// backoff returns the wait before the next attempt, the Retry-After header takes precedence when present.
+func (t *retryTransport) backoff(attempt int, resp *http.Response) time.Duration {
+	if wait, ok := retryAfter(resp); ok {
+		return min(wait, t.waitMax)
+	}
+
+	wait := float64(t.waitMin) * math.Pow(2, float64(attempt))
+	if wait > float64(t.waitMax) {
+		return t.waitMax
+	}
+
+	return time.Duration(wait)
+}
+
+// isIdempotent returns true when the request can be sent again without side effects,
+// following the http.Transport conventions.
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+
+	_, hasKey := req.Header["Idempotency-Key"]
+	_, hasXKey := req.Header["X-Idempotency-Key"]
+
+	return hasKey || hasXKey
+}
+
+func shouldRetry(ctx context.Context, resp *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	if err != nil {
+		return true
+	}
+
+	return resp.StatusCode == http.StatusTooManyRequests ||
+		(resp.StatusCode >= 500 && resp.StatusCode != http.StatusNotImplemented)
+}
+
+// retryAfter parses the Retry-After header, in either seconds or HTTP date format.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0), true
+	}
+
+	return 0, false
+}
+
+// cloneRequest returns a copy of the request with a fresh body for another attempt,
+// false is returned when the body can't be read again.
+func cloneRequest(req *http.Request) (*http.Request, bool) {
+	clone := req.Clone(req.Context())
+
+	if req.Body == nil || req.Body == http.NoBody {
+		return clone, true
+	}
+
+	if req.GetBody == nil {
+		return nil, false
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, false
+	}
+
+	clone.Body = body
+
+	return clone, true
+}
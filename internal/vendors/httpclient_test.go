@@ -0,0 +1,206 @@
+package vendors
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPClientRetries(t *testing.T) {
+	testCases := []struct {
+		name             string
+		method           string
+		idempotencyKey   bool
+		failures         int
+		failureStatus    int
+		maxRetries       int
+		expectedStatus   int
+		expectedAttempts int32
+	}{
+		{
+			name:             "retries on 5xx",
+			failures:         2,
+			failureStatus:    http.StatusBadGateway,
+			expectedStatus:   http.StatusOK,
+			expectedAttempts: 3,
+		},
+		{
+			name:             "retries on 429",
+			failures:         1,
+			failureStatus:    http.StatusTooManyRequests,
+			expectedStatus:   http.StatusOK,
+			expectedAttempts: 2,
+		},
+		{
+			name:             "gives up after max retries",
+			failures:         10,
+			failureStatus:    http.StatusServiceUnavailable,
+			maxRetries:       2,
+			expectedStatus:   http.StatusServiceUnavailable,
+			expectedAttempts: 3,
+		},
+		{
+			name:             "no retry on 4xx",
+			failures:         1,
+			failureStatus:    http.StatusNotFound,
+			expectedStatus:   http.StatusNotFound,
+			expectedAttempts: 1,
+		},
+		{
+			name:             "retries disabled",
+			failures:         1,
+			failureStatus:    http.StatusInternalServerError,
+			maxRetries:       -1,
+			expectedStatus:   http.StatusInternalServerError,
+			expectedAttempts: 1,
+		},
+		{
+			name:             "no retry of POST",
+			method:           http.MethodPost,
+			failures:         1,
+			failureStatus:    http.StatusBadGateway,
+			expectedStatus:   http.StatusBadGateway,
+			expectedAttempts: 1,
+		},
+		{
+			name:             "retries POST with an idempotency key",
+			method:           http.MethodPost,
+			idempotencyKey:   true,
+			failures:         1,
+			failureStatus:    http.StatusBadGateway,
+			expectedStatus:   http.StatusOK,
+			expectedAttempts: 2,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				assert.Equal(t, "payload", string(body))
+
+				if int(attempts.Add(1)) <= tt.failures {
+					w.WriteHeader(tt.failureStatus)
+					return
+				}
+
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer server.Close()
+
+			client := NewHTTPClient(&HTTPClientOptions{
+				MaxRetries:   tt.maxRetries,
+				RetryWaitMin: time.Millisecond,
+			})
+
+			method := http.MethodPut
+			if tt.method != "" {
+				method = tt.method
+			}
+
+			req, err := http.NewRequestWithContext(context.Background(), method, server.URL, strings.NewReader("payload"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.idempotencyKey {
+				req.Header["Idempotency-Key"] = nil
+			}
+
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			assert.Equal(t, tt.expectedStatus, resp.StatusCode)
+			assert.Equal(t, tt.expectedAttempts, attempts.Load())
+		})
+	}
+}
+
+func TestHTTPClientRetryAfter(t *testing.T) {
+	var attempts atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if attempts.Add(1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusServiceUnavailable)
+
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	// the exponential backoff alone would retry right away
+	client := NewHTTPClient(&HTTPClientOptions{RetryWaitMin: time.Millisecond})
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, server.URL, http.NoBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int32(2), attempts.Load())
+	assert.GreaterOrEqual(t, time.Since(start), time.Second)
+}
+
+func TestHTTPClientRetryAfterCapped(t *testing.T) {
+	transport := &retryTransport{waitMin: time.Millisecond, waitMax: 100 * time.Millisecond}
+
+	resp := &http.Response{Header: http.Header{}}
+	resp.Header.Set("Retry-After", "3600")
+	assert.Equal(t, 100*time.Millisecond, transport.backoff(0, resp))
+
+	resp.Header.Set("Retry-After", time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat))
+	assert.Equal(t, time.Duration(0), transport.backoff(0, resp))
+
+	assert.Equal(t, 4*time.Millisecond, transport.backoff(2, nil))
+}
+
+func TestHTTPClientRateLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewHTTPClient(&HTTPClientOptions{RateLimit: 20})
+
+	start := time.Now()
+
+	for i := 0; i < 3; i++ {
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, server.URL, http.NoBody)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp.Body.Close()
+	}
+
+	// at 20 requests per second with a burst of 1, 3 requests take at least 100ms
+	assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+}
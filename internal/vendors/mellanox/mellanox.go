@@ -0,0 +1,137 @@
+package mellanox
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/metal-toolbox/firmware-syncer/internal/vendors"
+
+	fleetdbapi "github.com/metal-toolbox/fleetdb/pkg/api/v1"
+)
+
+// imageExtension is the extension of the ConnectX firmware images
+const imageExtension = ".bin"
+
+type Downloader struct {
+	logger *logrus.Logger
+}
+
+// NewMellanoxDownloader creates a new Downloader for the NVIDIA/Mellanox NIC firmware.
+func NewMellanoxDownloader(logger *logrus.Logger) vendors.Downloader {
+	return &Downloader{logger: logger}
+}
+
+// Download will download the firmware bundle for the given firmware to the given downloadDir,
+// and will return the full path to the firmware image extracted from the bundle.
+//
+// Legacy bundles are zips holding a single image, MFT bundles are gzipped tars holding the images
+// of several cards in nested PSID directories. The image is the bundle entry named after the firmware filename,
+// or else the .bin entry under the firmware model, its PSID, when the bundle holds more than one.
+func (d *Downloader) Download(ctx context.Context, downloadDir string, firmware *fleetdbapi.ComponentFirmwareVersion) (*vendors.FirmwareFile, error) {
+	downloadDir, err := vendors.FirmwareDownloadDir(downloadDir, firmware)
+	if err != nil {
+		return nil, err
+	}
+
+	archivePath, err := vendors.DownloadFirmwareArchive(ctx, downloadDir, firmware.UpstreamURL, "")
+	if err != nil {
+		return nil, err
+	}
+
+	// images are sometimes published as is
+	if isImage(archivePath) {
+		if firmware.Checksum != "" && !vendors.ValidateChecksum(archivePath, firmware.Checksum) {
+			return nil, errors.Wrap(vendors.ErrChecksumValidate, fmt.Sprintf("firmware: %s, expected checksum: %s", archivePath, firmware.Checksum))
+		}
+
+		return &vendors.FirmwareFile{Path: archivePath}, nil
+	}
+
+	entries, err := vendors.ArchiveEntries(archivePath)
+	if err != nil {
+		return nil, err
+	}
+
+	entry, err := findImage(entries, firmware)
+	if errors.Is(err, vendors.ErrArchiveMemberNotFound) {
+		// the firmware filename is looked up as for the other vendors, in nested zips included
+		entry = firmware.Filename
+	} else if err != nil {
+		return nil, &vendors.ArchiveError{ArchivePath: archivePath, Err: err}
+	}
+
+	d.logger.WithField("archivePath", archivePath).
+		WithField("entry", entry).
+		Debug("Extracting firmware image from bundle")
+
+	// the entry path is extracted, the image name alone could match the image under another PSID
+	fwFile, err := vendors.ExtractFirmware(archivePath, entry, firmware.Checksum)
+	if err != nil {
+		return nil, err
+	}
+
+	return fwFile, nil
+}
+
+// findImage returns the bundle entry holding the firmware image: the entries named after the firmware filename,
+// or else the .bin entries, narrowed down to the ones under the firmware PSID when there's more than one.
+func findImage(entries []string, firmware *fleetdbapi.ComponentFirmwareVersion) (string, error) {
+	var named, images []string
+
+	for _, entry := range entries {
+		if path.Base(entry) == firmware.Filename {
+			named = append(named, entry)
+		}
+
+		if isImage(entry) {
+			images = append(images, entry)
+		}
+	}
+
+	candidates := named
+	if len(candidates) == 0 {
+		candidates = images
+	}
+
+	if len(candidates) > 1 {
+		candidates = underPSID(candidates, firmware.Model)
+	}
+
+	switch len(candidates) {
+	case 0:
+		return "", errors.Wrap(vendors.ErrArchiveMemberNotFound, "no "+imageExtension+" entry for "+firmware.Filename)
+	case 1:
+		return candidates[0], nil
+	default:
+		return "", errors.Wrap(vendors.ErrAmbiguousArchiveEntry, strings.Join(candidates, ", "))
+	}
+}
+
+// underPSID returns the entries with one of the PSIDs in their path, the entries are returned as is when none has.
+func underPSID(entries, psids []string) []string {
+	var matched []string
+
+	for _, entry := range entries {
+		for _, psid := range psids {
+			if psid != "" && strings.Contains(strings.ToLower(entry), strings.ToLower(psid)) {
+				matched = append(matched, entry)
+				break
+			}
+		}
+	}
+
+	if len(matched) == 0 {
+		return entries
+	}
+
+	return matched
+}
+
+func isImage(name string) bool {
+	return strings.HasSuffix(strings.ToLower(name), imageExtension)
+}
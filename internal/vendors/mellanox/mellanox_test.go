@@ -0,0 +1,161 @@
+package mellanox
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+
+	fleetdbapi "github.com/metal-toolbox/fleetdb/pkg/api/v1"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/metal-toolbox/firmware-syncer/internal/logging"
+	"github.com/metal-toolbox/firmware-syncer/internal/vendors"
+)
+
+const (
+	legacyBundle   = "fw-ConnectX5-rel-16_35_2000-MCX512A-ACA_Ax_Bx.zip"
+	legacyImage    = "fw-ConnectX5-rel-16_35_2000-MCX512A-ACA_Ax_Bx.bin"
+	legacyImageMD5 = "c4fe7402ac8586b7803ed6a2e276b91a"
+
+	mftBundle      = "mft-bundle-26.36.1010.tgz"
+	mftImage       = "fw-ConnectX6Lx-rel-26_36_1010-MCX631102AN-ADA_Ax.bin"
+	mftImageMD5    = "89e4692c577360c17b657452d88f702f"
+	mftImageSHA256 = "81043d6ec3e28e129956999550c86392aa91af56f95a7f501fb89834fc525518"
+)
+
+// newDownloadServer serves the fixtures, the legacy image is also served as is.
+func newDownloadServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+
+	for _, fixture := range []string{legacyBundle, mftBundle} {
+		b, err := os.ReadFile(path.Join("fixtures", fixture))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		mux.HandleFunc("/"+fixture, func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write(b)
+		})
+	}
+
+	mux.HandleFunc("/"+legacyImage, func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("ConnectX-5 firmware 16.35.2000 MCX512A-ACA\n"))
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestDownload(t *testing.T) {
+	server := newDownloadServer(t)
+	logger := logging.NewLogger("debug")
+
+	testCases := []struct {
+		name     string
+		bundle   string
+		filename string
+		models   []string
+		checksum string
+		expected string
+		err      error
+	}{
+		{
+			name:     "legacy zip bundle",
+			bundle:   legacyBundle,
+			filename: legacyImage,
+			checksum: legacyImageMD5,
+			expected: legacyImage,
+		},
+		{
+			name:     "bare image",
+			bundle:   legacyImage,
+			filename: legacyImage,
+			checksum: legacyImageMD5,
+			expected: legacyImage,
+		},
+		{
+			name:     "MFT bundle image in a nested directory",
+			bundle:   mftBundle,
+			filename: mftImage,
+			checksum: "sha256:" + mftImageSHA256,
+			expected: mftImage,
+		},
+		{
+			name:     "MFT bundle image located by PSID",
+			bundle:   mftBundle,
+			filename: "ConnectX6Lx_26.36.1010.bin",
+			models:   []string{"r6515", "MT_0000000531"},
+			checksum: mftImageMD5,
+			expected: mftImage,
+		},
+		{
+			name:     "MFT bundle without the firmware PSID",
+			bundle:   mftBundle,
+			filename: "ConnectX6Lx_26.36.1010.bin",
+			models:   []string{"r6515", "MT_0000000999"},
+			err:      vendors.ErrAmbiguousArchiveEntry,
+		},
+		{
+			name:     "MFT bundle checksum mismatch",
+			bundle:   mftBundle,
+			filename: mftImage,
+			checksum: "00000000000000000000000000000000",
+			err:      vendors.ErrChecksumValidate,
+		},
+		{
+			name:     "legacy zip bundle checksum mismatch",
+			bundle:   legacyBundle,
+			filename: legacyImage,
+			checksum: mftImageMD5,
+			err:      vendors.ErrChecksumValidate,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			firmware := &fleetdbapi.ComponentFirmwareVersion{
+				Vendor:      "mellanox",
+				Model:       tt.models,
+				Filename:    tt.filename,
+				UpstreamURL: server.URL + "/" + tt.bundle,
+				Checksum:    tt.checksum,
+			}
+
+			firmwareFile, err := NewMellanoxDownloader(logger).Download(context.Background(), t.TempDir(), firmware)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, filepath.Base(firmwareFile.Path))
+			assert.True(t, vendors.ValidateChecksum(firmwareFile.Path, tt.checksum))
+		})
+	}
+}
+
+func Test_findImage(t *testing.T) {
+	entries := []string{
+		"bundle/README",
+		"bundle/MT_0000000531/" + mftImage,
+		"bundle/MT_0000000532/fw-ConnectX6Lx-rel-26_36_1010-MCX631432AN-ADA_Ax.bin",
+		"bundle/MT_0000000532/" + mftImage,
+	}
+
+	// the image name is shared by two PSIDs
+	entry, err := findImage(entries, &fleetdbapi.ComponentFirmwareVersion{Filename: mftImage, Model: []string{"mt_0000000532"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "bundle/MT_0000000532/"+mftImage, entry)
+
+	_, err = findImage(entries, &fleetdbapi.ComponentFirmwareVersion{Filename: mftImage})
+	assert.ErrorIs(t, err, vendors.ErrAmbiguousArchiveEntry)
+
+	_, err = findImage(entries[:1], &fleetdbapi.ComponentFirmwareVersion{Filename: mftImage})
+	assert.ErrorIs(t, err, vendors.ErrArchiveMemberNotFound)
+}
@@ -0,0 +1,122 @@
+package vendors
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/sirupsen/logrus"
+)
+
+var ErrUploadQuorum = errors.New("firmware uploaded to fewer destinations than the upload quorum")
+
+// DestinationErrors maps the destinations an upload failed on to the error encountered.
+type DestinationErrors map[string]error
+
+func (e DestinationErrors) Error() string {
+	destinations := make([]string, 0, len(e))
+	for destination := range e {
+		destinations = append(destinations, destination)
+	}
+
+	sort.Strings(destinations)
+
+	msgs := make([]string, len(destinations))
+	for i, destination := range destinations {
+		msgs[i] = fmt.Sprintf("%s: %s", destination, e[destination])
+	}
+
+	return fmt.Sprintf("failed to upload to %d destination(s): %s", len(e), strings.Join(msgs, "; "))
+}
+
+// uploadQuorum returns the number of destinations, the dstFs included, a file has to be uploaded to.
+func (s *Syncer) uploadQuorum() int {
+	if s.quorum > 0 {
+		return s.quorum
+	}
+
+	return len(s.mirrors) + 1
+}
+
+// uploadMirrors copies the file uploaded to the dstFs to the mirrors in parallel,
+// an ErrUploadQuorum is returned when the quorum isn't met, the mirror failures are logged otherwise.
+func (s *Syncer) uploadMirrors(ctx context.Context, firmwareRelativePath, destPath string) error {
+	var (
+		mutex    sync.Mutex
+		wg       sync.WaitGroup
+		failures = DestinationErrors{}
+	)
+
+	for _, mirror := range s.mirrors {
+		wg.Add(1)
+
+		go func(mirror fs.Fs) {
+			defer wg.Done()
+
+			if err := operations.CopyFile(ctx, mirror, s.tmpFs, destPath, firmwareRelativePath); err != nil {
+				mutex.Lock()
+				failures[mirror.String()] = err
+				mutex.Unlock()
+			}
+		}(mirror)
+	}
+
+	wg.Wait()
+
+	if len(failures) == 0 {
+		return nil
+	}
+
+	uploaded := len(s.mirrors) + 1 - len(failures)
+	if uploaded < s.uploadQuorum() {
+		msg := fmt.Sprintf("%d/%d destinations, quorum %d: %s", uploaded, len(s.mirrors)+1, s.uploadQuorum(), failures)
+		return errors.Wrap(ErrUploadQuorum, msg)
+	}
+
+	for destination, err := range failures {
+		s.logger.WithError(err).
+			WithField("destination", destination).
+			WithField("path", destPath).
+			Warn("Failed to upload to mirror, the upload quorum is met")
+	}
+
+	return nil
+}
+
+// backfillMirrors copies the firmware present at destPath on the dstFs to the mirrors missing it,
+// mirrors added once the firmware was synced, or failing while the upload quorum was met, would never get it otherwise.
+// Failures are logged, as the firmware is on the dstFs regardless.
+func (s *Syncer) backfillMirrors(ctx context.Context, logMsg *logrus.Entry, destPath string) {
+	if len(s.mirrors) == 0 {
+		return
+	}
+
+	// the checksum and original filename metadata go along with the firmware
+	ctx, ci := fs.AddConfig(ctx)
+	ci.Metadata = true
+
+	for _, mirror := range s.mirrors {
+		mirrorMsg := logMsg.WithField("destination", mirror.String())
+
+		exists, err := fs.FileExists(ctx, mirror, destPath)
+		if err == nil && exists {
+			continue
+		}
+
+		if err == nil {
+			err = operations.CopyFile(ctx, mirror, s.dstFs, destPath, destPath)
+		}
+
+		if err != nil {
+			mirrorMsg.WithError(err).Warn("Failed to backfill the mirror missing the firmware")
+			continue
+		}
+
+		mirrorMsg.Info("Backfilled the mirror missing the firmware")
+	}
+}
@@ -0,0 +1,187 @@
+package vendors
+
+import (
+	"context"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+
+	fleetdbapi "github.com/metal-toolbox/fleetdb/pkg/api/v1"
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+
+	mockinventory "github.com/metal-toolbox/firmware-syncer/internal/inventory/mocks"
+	"github.com/metal-toolbox/firmware-syncer/internal/logging"
+)
+
+var errMirrorDown = errors.New("mirror is down")
+
+// failingFs is a destination fs every upload to fails.
+type failingFs struct {
+	fs.Fs
+}
+
+func (f *failingFs) Put(context.Context, io.Reader, fs.ObjectInfo, ...fs.OpenOption) (fs.Object, error) {
+	return nil, errMirrorDown
+}
+
+func (f *failingFs) Features() *fs.Features {
+	return &fs.Features{}
+}
+
+func newLocalFs(t *testing.T) fs.Fs {
+	localFs, err := InitLocalFs(context.Background(), &LocalFsConfig{Root: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return localFs
+}
+
+func TestSyncerMirrors(t *testing.T) {
+	fixture, err := os.ReadFile(path.Join("fixtures", "foobar1.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name    string
+		failing bool
+		quorum  int
+		// published is true when the firmware is synced and published to inventory
+		published bool
+	}{
+		{name: "every mirror uploaded to", published: true},
+		{name: "mirror failure with every destination required", failing: true},
+		{name: "mirror failure with the quorum met", failing: true, quorum: 2, published: true},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := logging.NewLogger("debug")
+			ctx := context.Background()
+
+			dstFs, healthyFs := newLocalFs(t), newLocalFs(t)
+
+			mirrors := []fs.Fs{healthyFs}
+			if tt.failing {
+				mirrors = append(mirrors, &failingFs{Fs: newLocalFs(t)})
+			}
+
+			firmware := &fleetdbapi.ComponentFirmwareVersion{
+				Vendor:   "foo-vendor",
+				Filename: "foobar1.zip",
+				Checksum: "79ec3cf629b56317111d5640b8df1220", // real checksum of fixtures/foobar1.zip
+			}
+
+			ctrl := gomock.NewController(t)
+
+			mockDownloader := NewMockDownloader(ctrl)
+			mockDownloader.EXPECT().Download(gomock.Any(), gomock.Any(), firmware).
+				DoAndReturn(func(_ context.Context, downloadDir string, firmware *fleetdbapi.ComponentFirmwareVersion) (*FirmwareFile, error) {
+					firmwarePath := filepath.Join(downloadDir, firmware.Filename)
+					return &FirmwareFile{Path: firmwarePath}, os.WriteFile(firmwarePath, fixture, 0o600)
+				})
+
+			mockInventory := mockinventory.NewMockServerService(ctrl)
+			if tt.published {
+				mockInventory.EXPECT().Publish(gomock.Any(), firmware)
+			}
+
+			s := NewSyncer(
+				dstFs,
+				newLocalFs(t),
+				mockDownloader,
+				mockInventory,
+				[]*fleetdbapi.ComponentFirmwareVersion{firmware},
+				logger,
+				WithMirrors(mirrors, tt.quorum),
+			).(*Syncer)
+
+			err := s.syncFirmware(ctx, firmware)
+			if !tt.published {
+				assert.ErrorIs(t, err, ErrUploadQuorum)
+				assert.ErrorContains(t, err, errMirrorDown.Error())
+
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.FileExists(t, filepath.Join(dstFs.Root(), DstPath(firmware)))
+			assert.FileExists(t, filepath.Join(healthyFs.Root(), DstPath(firmware)))
+		})
+	}
+}
+
+func TestSyncerBackfillsMirrors(t *testing.T) {
+	ctx := context.Background()
+
+	fixture, err := os.ReadFile(path.Join("fixtures", "foobar1.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	firmware := &fleetdbapi.ComponentFirmwareVersion{
+		Vendor:   "foo-vendor",
+		Filename: "foobar1.zip",
+		Checksum: "79ec3cf629b56317111d5640b8df1220",
+	}
+
+	writeFirmware := func(t *testing.T, destination fs.Fs, content []byte) {
+		t.Helper()
+
+		firmwarePath := filepath.Join(destination.Root(), DstPath(firmware))
+		if err = os.MkdirAll(filepath.Dir(firmwarePath), 0o755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err = os.WriteFile(firmwarePath, content, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the firmware is on the dstFs and on one of the mirrors, the other mirrors were added since
+	dstFs, missingFs, presentFs := newLocalFs(t), newLocalFs(t), newLocalFs(t)
+	writeFirmware(t, dstFs, fixture)
+	writeFirmware(t, presentFs, []byte("already mirrored"))
+
+	ctrl := gomock.NewController(t)
+
+	// nothing is downloaded, the firmware is copied from the dstFs
+	mockDownloader := NewMockDownloader(ctrl)
+
+	mockInventory := mockinventory.NewMockServerService(ctrl)
+	mockInventory.EXPECT().Publish(gomock.Any(), firmware)
+
+	s := NewSyncer(
+		dstFs,
+		newLocalFs(t),
+		mockDownloader,
+		mockInventory,
+		[]*fleetdbapi.ComponentFirmwareVersion{firmware},
+		logging.NewLogger("debug"),
+		WithMirrors([]fs.Fs{missingFs, presentFs, &failingFs{Fs: newLocalFs(t)}}, 0),
+	).(*Syncer)
+
+	// a mirror failing the backfill doesn't fail the sync of the firmware present on the dstFs
+	assert.NoError(t, s.syncFirmware(ctx, firmware))
+
+	backfilled, err := os.ReadFile(filepath.Join(missingFs.Root(), DstPath(firmware)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, fixture, backfilled)
+
+	// the firmware already on a mirror is left as is
+	mirrored, err := os.ReadFile(filepath.Join(presentFs.Root(), DstPath(firmware)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "already mirrored", string(mirrored))
+}
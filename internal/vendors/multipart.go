@@ -0,0 +1,27 @@
+package vendors
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	rcloneFs "github.com/rclone/rclone/fs"
+)
+
+var ErrMultipartCleanup = errors.New("error aborting incomplete multipart uploads")
+
+// AbortStaleMultipartUploads aborts the incomplete multipart uploads on the s3 fs initiated more than maxAge ago,
+// the parts left behind by interrupted uploads are billed until aborted.
+func AbortStaleMultipartUploads(ctx context.Context, fs rcloneFs.Fs, maxAge time.Duration) error {
+	commander, ok := fs.(rcloneFs.Commander)
+	if !ok {
+		return errors.Wrap(ErrMultipartCleanup, fs.Name()+": the backend doesn't support cleanup")
+	}
+
+	if _, err := commander.Command(ctx, "cleanup", nil, map[string]string{"max-age": maxAge.String()}); err != nil {
+		return errors.Wrap(ErrMultipartCleanup, err.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,46 @@
+package vendors
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	rcloneFs "github.com/rclone/rclone/fs"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+
+	mockvendors "github.com/metal-toolbox/firmware-syncer/internal/vendors/mocks"
+)
+
+// commanderFs is an rclone fs running backend commands, as the s3 backend does
+type commanderFs struct {
+	rcloneFs.Fs
+	name string
+	opt  map[string]string
+	err  error
+}
+
+func (c *commanderFs) Command(_ context.Context, name string, _ []string, opt map[string]string) (interface{}, error) {
+	c.name, c.opt = name, opt
+	return nil, c.err
+}
+
+func Test_AbortStaleMultipartUploads(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	fs := &commanderFs{Fs: mockvendors.NewMockRCloneFS(ctrl)}
+
+	assert.NoError(t, AbortStaleMultipartUploads(context.Background(), fs, 6*time.Hour))
+	assert.Equal(t, "cleanup", fs.name)
+	assert.Equal(t, map[string]string{"max-age": "6h0m0s"}, fs.opt)
+
+	fs.err = errors.New("AccessDenied")
+	assert.ErrorIs(t, AbortStaleMultipartUploads(context.Background(), fs, time.Hour), ErrMultipartCleanup)
+
+	// backends without commands can't be cleaned up
+	localFs := mockvendors.NewMockRCloneFS(ctrl)
+	localFs.EXPECT().Name().Return("local")
+
+	assert.ErrorIs(t, AbortStaleMultipartUploads(context.Background(), localFs, time.Hour), ErrMultipartCleanup)
+}
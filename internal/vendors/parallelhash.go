@@ -0,0 +1,131 @@
+package vendors
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// parallelHashChunkSize is the size of the chunks read ahead of the hash by ParallelSHA256
+	parallelHashChunkSize = 4 << 20
+	// parallelHashWorkers is the number of chunks read concurrently when verifying large files
+	parallelHashWorkers = 4
+)
+
+// parallelHashThreshold is the size from which files are verified with reads running ahead of the hash
+var parallelHashThreshold int64 = 64 << 20
+
+// hashChunk is a chunk of the file read for the hash, or the error reading it.
+type hashChunk struct {
+	buf []byte
+	err error
+}
+
+// ParallelSHA256 returns the hex encoded sha256 digest of the file at path,
+// with workers reading the file chunks concurrently ahead of the hash.
+//
+// The sha256 digest is sequential, the chunks are hashed in order as they're read,
+// so the digest matches the one computed by reading the file serially.
+func ParallelSHA256(path string, workers int) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", errors.Wrap(ErrChecksumGenerate, err.Error())
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", errors.Wrap(ErrChecksumGenerate, err.Error())
+	}
+
+	h := sha256.New()
+	if err := parallelHashFile(f, info.Size(), h, workers); err != nil {
+		return "", errors.Wrap(ErrChecksumGenerate, err.Error())
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashFile writes the file to h, large files are read ahead of the hash by parallelHashWorkers.
+func hashFile(f *os.File, h hash.Hash) error {
+	if info, err := f.Stat(); err == nil && info.Size() >= parallelHashThreshold {
+		return parallelHashFile(f, info.Size(), h, parallelHashWorkers)
+	}
+
+	_, err := io.Copy(h, f)
+
+	return err
+}
+
+// parallelHashFile writes the size bytes of the file to h, in order,
+// the chunk i is read by the worker i % workers.
+func parallelHashFile(f *os.File, size int64, h hash.Hash, workers int) error {
+	if workers < 1 {
+		workers = 1
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	chunks := (size + parallelHashChunkSize - 1) / parallelHashChunkSize
+	ready := make([]chan hashChunk, workers)
+	free := make([]chan []byte, workers)
+
+	for w := range ready {
+		ready[w] = make(chan hashChunk, 1)
+		// a worker reads a chunk while the previous one waits to be hashed
+		free[w] = make(chan []byte, 2)
+		free[w] <- make([]byte, parallelHashChunkSize)
+		free[w] <- make([]byte, parallelHashChunkSize)
+
+		go readChunks(f, size, int64(w), int64(workers), ready[w], free[w], done)
+	}
+
+	for i := int64(0); i < chunks; i++ {
+		w := i % int64(workers)
+
+		chunk := <-ready[w]
+		if chunk.err != nil {
+			return chunk.err
+		}
+
+		_, _ = h.Write(chunk.buf)
+		free[w] <- chunk.buf[:cap(chunk.buf)]
+	}
+
+	return nil
+}
+
+// readChunks reads the chunks first, first+stride, first+2*stride... of the file into the free buffers
+// and sends them to ready, until the file is read, a read fails or done is closed.
+func readChunks(f *os.File, size, first, stride int64, ready chan<- hashChunk, free <-chan []byte, done <-chan struct{}) {
+	for offset := first * parallelHashChunkSize; offset < size; offset += stride * parallelHashChunkSize {
+		var buf []byte
+
+		select {
+		case buf = <-free:
+		case <-done:
+			return
+		}
+
+		n, err := f.ReadAt(buf[:min(parallelHashChunkSize, size-offset)], offset)
+		if errors.Is(err, io.EOF) && int64(n) == min(parallelHashChunkSize, size-offset) {
+			err = nil
+		}
+
+		select {
+		case ready <- hashChunk{buf: buf[:n], err: err}:
+		case <-done:
+			return
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
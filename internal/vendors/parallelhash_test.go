@@ -0,0 +1,116 @@
+package vendors
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeRandomFile writes size random bytes to a file in a temporary directory and returns its path.
+func writeRandomFile(tb testing.TB, size int) string {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		tb.Fatal(err)
+	}
+
+	filename := filepath.Join(tb.TempDir(), "firmware.bin")
+	if err := os.WriteFile(filename, b, 0o600); err != nil {
+		tb.Fatal(err)
+	}
+
+	return filename
+}
+
+func serialSHA256(tb testing.TB, filename string) string {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	sum := sha256.Sum256(b)
+
+	return hex.EncodeToString(sum[:])
+}
+
+func Test_ParallelSHA256(t *testing.T) {
+	sizes := []int{
+		0,
+		1,
+		parallelHashChunkSize - 1,
+		parallelHashChunkSize,
+		5*parallelHashChunkSize + 12345,
+	}
+
+	for _, size := range sizes {
+		filename := writeRandomFile(t, size)
+		expected := serialSHA256(t, filename)
+
+		for _, workers := range []int{0, 1, 2, 3, 8} {
+			t.Run(strconv.Itoa(size)+"-bytes-"+strconv.Itoa(workers)+"-workers", func(t *testing.T) {
+				digest, err := ParallelSHA256(filename, workers)
+				assert.NoError(t, err)
+				assert.Equal(t, expected, digest)
+			})
+		}
+	}
+
+	_, err := ParallelSHA256(filepath.Join(t.TempDir(), "missing.bin"), 4)
+	assert.ErrorIs(t, err, ErrChecksumGenerate)
+}
+
+func Test_ChecksumValidateParallel(t *testing.T) {
+	threshold := parallelHashThreshold
+	parallelHashThreshold = parallelHashChunkSize
+
+	t.Cleanup(func() { parallelHashThreshold = threshold })
+
+	filename := writeRandomFile(t, 3*parallelHashChunkSize+1)
+	digest := serialSHA256(t, filename)
+
+	assert.NoError(t, SHA256ChecksumValidate(filename, digest))
+	assert.True(t, ValidateChecksum(filename, "sha256:"+digest))
+	assert.ErrorIs(t, SHA256ChecksumValidate(filename, serialSHA256(t, writeRandomFile(t, 1))), ErrChecksumInvalid)
+}
+
+// The parallel reads pay off on storage where a single reader doesn't saturate the hash:
+//
+//	go test ./internal/vendors -run '^$' -bench SHA256 -benchmem
+func BenchmarkSHA256Serial(b *testing.B) {
+	filename := writeRandomFile(b, 64<<20)
+
+	b.SetBytes(64 << 20)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		f, err := os.Open(filename)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		if _, err := io.Copy(sha256.New(), f); err != nil {
+			b.Fatal(err)
+		}
+
+		f.Close()
+	}
+}
+
+func BenchmarkParallelSHA256(b *testing.B) {
+	filename := writeRandomFile(b, 64<<20)
+
+	b.SetBytes(64 << 20)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := ParallelSHA256(filename, parallelHashWorkers); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
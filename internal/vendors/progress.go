@@ -0,0 +1,90 @@
+package vendors
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/fs/rc"
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultProgressInterval is how often the progress of an in flight firmware transfer is logged.
+const DefaultProgressInterval = 30 * time.Second
+
+// statsGroupID makes the rclone stats group of each transfer unique.
+var statsGroupID atomic.Uint64
+
+// withStatsGroup returns a context accounting its rclone transfers in a dedicated stats group,
+// so the byte, transfer and error counts of a firmware aren't mixed up with the transfers running next to it.
+// The group is removed from the rclone global stats when the returned release func is called.
+func withStatsGroup(ctx context.Context) (statsCtx context.Context, stats *accounting.StatsInfo, release func()) {
+	group := fmt.Sprintf("firmware-syncer-%d", statsGroupID.Add(1))
+	statsCtx = accounting.WithStatsGroup(ctx, group)
+	stats = accounting.StatsGroup(statsCtx, group)
+
+	return statsCtx, stats, func() {
+		// the stats groups are only deleted through the rclone remote control API
+		if call := rc.Calls.Get("core/stats-delete"); call != nil {
+			_, _ = call.Fn(ctx, rc.Params{"group": group})
+		}
+	}
+}
+
+// trackProgress logs the progress of the transfers accounted in stats every interval,
+// until the returned stop func is called.
+func trackProgress(ctx context.Context, logMsg *logrus.Entry, stats *accounting.StatsInfo, interval time.Duration) (stop func()) {
+	if interval <= 0 {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				logProgress(logMsg, stats)
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+// logProgress logs the bytes transferred, the transfer rate and the ETA when it can be estimated.
+func logProgress(logMsg *logrus.Entry, stats *accounting.StatsInfo) {
+	remoteStats, err := stats.RemoteStats()
+	if err != nil {
+		return
+	}
+
+	entry := logMsg.WithField("bytes", remoteStats["bytes"]).
+		WithField("totalBytes", remoteStats["totalBytes"]).
+		WithField("bytesPerSecond", remoteStats["speed"])
+
+	if eta, ok := remoteStats["eta"].(float64); ok {
+		entry = entry.WithField("eta", time.Duration(eta*float64(time.Second)).Round(time.Second).String())
+	}
+
+	entry.Info("Transfer in progress")
+}
@@ -0,0 +1,89 @@
+package vendors
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs/accounting"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_TrackProgress(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	logMsg := logger.WithField("firmware", "firmware.bin")
+
+	statsCtx, stats, release := withStatsGroup(context.Background())
+	defer release()
+
+	stop := trackProgress(context.Background(), logMsg, stats, 10*time.Millisecond)
+
+	// a fake copy, slowly accounting the bytes transferred
+	assert.Same(t, stats, accounting.Stats(statsCtx))
+
+	for i := 0; i < 5; i++ {
+		stats.Bytes(1024)
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	stop()
+
+	var progressEntries []*logrus.Entry
+
+	for _, entry := range hook.AllEntries() {
+		if entry.Message == "Transfer in progress" {
+			progressEntries = append(progressEntries, entry)
+		}
+	}
+
+	if assert.NotEmpty(t, progressEntries) {
+		last := progressEntries[len(progressEntries)-1]
+		assert.Equal(t, "firmware.bin", last.Data["firmware"])
+		assert.Contains(t, last.Data, "bytes")
+		assert.Contains(t, last.Data, "bytesPerSecond")
+	}
+
+	// no more progress is logged once stopped
+	count := len(hook.AllEntries())
+
+	time.Sleep(30 * time.Millisecond)
+	assert.Len(t, hook.AllEntries(), count)
+
+	// transfers outside the tracked context are not accounted in its stats group
+	assert.NotSame(t, stats, accounting.Stats(context.Background()))
+}
+
+func Test_TrackProgressDisabled(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+
+	stats := accounting.NewStats(context.Background())
+	stop := trackProgress(context.Background(), logger.WithField("firmware", "firmware.bin"), stats, -1)
+
+	stats.Bytes(1024)
+	time.Sleep(10 * time.Millisecond)
+	stop()
+
+	assert.Empty(t, hook.AllEntries())
+}
+
+func Test_WithStatsGroup(t *testing.T) {
+	ctx := context.Background()
+
+	firstCtx, first, releaseFirst := withStatsGroup(ctx)
+	secondCtx, second, releaseSecond := withStatsGroup(ctx)
+
+	accounting.Stats(firstCtx).Bytes(1024)
+	accounting.Stats(secondCtx).Bytes(512)
+
+	assert.Equal(t, int64(1024), first.GetBytes())
+	assert.Equal(t, int64(512), second.GetBytes())
+
+	releaseFirst()
+	releaseSecond()
+
+	// released groups are removed from the rclone global stats
+	assert.Zero(t, first.GetBytes())
+	assert.Zero(t, second.GetBytes())
+}
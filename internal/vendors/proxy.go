@@ -0,0 +1,106 @@
+package vendors
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/http/httpproxy"
+)
+
+var ErrProxy = errors.New("invalid proxy")
+
+// sharedTransport is the transport of the outbound HTTP clients, it routes requests through the proxy
+// of their context or of their client, see WithProxy and HTTPClientOptions.Proxy, and sets the configured user agent
+var sharedTransport http.RoundTripper = &userAgentTransport{base: newSharedTransport()}
+
+func newSharedTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxyForRequest
+
+	return transport
+}
+
+// SharedTransport returns the transport outbound HTTP clients are expected to use,
+// it routes requests through the Proxy of their context, the standard proxy env vars are honored otherwise,
+// and sends the user agent set with SetUserAgent.
+func SharedTransport() http.RoundTripper {
+	return sharedTransport
+}
+
+// Proxy routes the outbound http and https requests through a proxy, except for the requests to the excluded hosts.
+type Proxy struct {
+	cfg       *httpproxy.Config
+	proxyFunc func(*url.URL) (*url.URL, error)
+}
+
+// NewProxy returns the Proxy routing the outbound http and https requests through the httpProxy URL,
+// except for the requests to the comma separated noProxy hosts, domains and CIDRs.
+// The HTTP_PROXY, HTTPS_PROXY and NO_PROXY env vars are used for the parameters not set.
+func NewProxy(httpProxy, noProxy string) (*Proxy, error) {
+	cfg := httpproxy.FromEnvironment()
+
+	if httpProxy != "" {
+		if u, err := url.Parse(httpProxy); err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, errors.Wrap(ErrProxy, httpProxy)
+		}
+
+		cfg.HTTPProxy = httpProxy
+		cfg.HTTPSProxy = httpProxy
+	}
+
+	if noProxy != "" {
+		cfg.NoProxy = noProxy
+	}
+
+	return &Proxy{cfg: cfg, proxyFunc: cfg.ProxyFunc()}, nil
+}
+
+// SetRcloneEnv sets the HTTP_PROXY, HTTPS_PROXY and NO_PROXY env vars to the proxy, for the rclone backends:
+// rclone v1.68 has no proxy setting, its transport only honors the env vars and reads them once,
+// so SetRcloneEnv has to be called before rclone makes its first request.
+func (p *Proxy) SetRcloneEnv() error {
+	for key, value := range map[string]string{"HTTP_PROXY": p.cfg.HTTPProxy, "HTTPS_PROXY": p.cfg.HTTPSProxy, "NO_PROXY": p.cfg.NoProxy} {
+		if err := os.Setenv(key, value); err != nil {
+			return errors.Wrap(ErrProxy, err.Error())
+		}
+	}
+
+	return nil
+}
+
+type proxyKey struct{}
+
+// withProxy returns a context the requests sent with the SharedTransport are routed through the proxy with.
+func withProxy(ctx context.Context, proxy *Proxy) context.Context {
+	if proxy == nil {
+		return ctx
+	}
+
+	return context.WithValue(ctx, proxyKey{}, proxy)
+}
+
+// proxyForRequest returns the proxy of the request, from the request context or the standard proxy env vars.
+func proxyForRequest(req *http.Request) (*url.URL, error) {
+	if proxy, ok := req.Context().Value(proxyKey{}).(*Proxy); ok {
+		return proxy.proxyFunc(req.URL)
+	}
+
+	return http.ProxyFromEnvironment(req)
+}
+
+// proxyTransport routes the requests through the proxy, unless their context sets one.
+type proxyTransport struct {
+	base  http.RoundTripper
+	proxy *Proxy
+}
+
+func (t *proxyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if _, ok := req.Context().Value(proxyKey{}).(*Proxy); ok {
+		return t.base.RoundTrip(req)
+	}
+
+	return t.base.RoundTrip(req.WithContext(withProxy(req.Context(), t.proxy)))
+}
@@ -0,0 +1,91 @@
+package vendors
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Proxy(t *testing.T) {
+	var proxied []string
+
+	proxyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// requests to a proxy carry the absolute URL
+		proxied = append(proxied, r.URL.String())
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer proxyServer.Close()
+
+	direct := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer direct.Close()
+
+	_, err := NewProxy("not a proxy", "")
+	assert.ErrorIs(t, err, ErrProxy)
+
+	proxy, err := NewProxy(proxyServer.URL, "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(t *testing.T, ctx context.Context, client *http.Client, url string) int {
+		t.Helper()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		return resp.StatusCode
+	}
+
+	ctx := context.Background()
+	firmwareURL := "http://firmware.example.invalid/firmware.zip"
+
+	t.Run("client proxy", func(t *testing.T) {
+		proxied = nil
+		client := NewHTTPClient(&HTTPClientOptions{Proxy: proxy})
+
+		assert.Equal(t, http.StatusOK, get(t, ctx, client, firmwareURL))
+		assert.Equal(t, []string{firmwareURL}, proxied)
+
+		// hosts excluded from the proxy are requested directly
+		assert.Equal(t, http.StatusNoContent, get(t, ctx, client, direct.URL))
+		assert.Len(t, proxied, 1)
+	})
+
+	t.Run("context proxy", func(t *testing.T) {
+		proxied = nil
+		client := NewHTTPClient(nil)
+
+		assert.Equal(t, http.StatusOK, get(t, withProxy(ctx, proxy), client, firmwareURL))
+		assert.Equal(t, []string{firmwareURL}, proxied)
+	})
+}
+
+func Test_ProxySetRcloneEnv(t *testing.T) {
+	for _, key := range []string{"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY"} {
+		t.Setenv(key, "")
+	}
+
+	proxy, err := NewProxy("http://proxy.example.invalid:3128", "10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NoError(t, proxy.SetRcloneEnv())
+	assert.Equal(t, "http://proxy.example.invalid:3128", os.Getenv("HTTP_PROXY"))
+	assert.Equal(t, "http://proxy.example.invalid:3128", os.Getenv("HTTPS_PROXY"))
+	assert.Equal(t, "10.0.0.0/8", os.Getenv("NO_PROXY"))
+}
@@ -0,0 +1,56 @@
+package vendors
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+var ErrArchiveQuarantined = errors.New("archive quarantined")
+
+// quarantineArchive moves the archive into the vendor subdirectory of the quarantine directory,
+// returning the quarantined archive path.
+func quarantineArchive(quarantineDir, vendor, archivePath string) (string, error) {
+	vendorDir := filepath.Join(quarantineDir, vendor)
+	if err := os.MkdirAll(vendorDir, 0o750); err != nil {
+		return "", err
+	}
+
+	dst := filepath.Join(vendorDir, filepath.Base(archivePath))
+	if err := moveFile(archivePath, dst); err != nil {
+		return "", err
+	}
+
+	return dst, nil
+}
+
+// moveFile renames src to dst, falling back to copying when they're on different filesystems.
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+
+	if err = out.Close(); err != nil {
+		return err
+	}
+
+	return os.Remove(src)
+}
@@ -0,0 +1,136 @@
+package vendors
+
+import (
+	"path"
+	"sync"
+	"time"
+
+	fleetdbapi "github.com/metal-toolbox/fleetdb/pkg/api/v1"
+)
+
+// Reporter is implemented by the vendors reporting the outcome of their last sync.
+type Reporter interface {
+	Report() *SyncReport
+}
+
+// SyncReport is the outcome of a vendor sync.
+type SyncReport struct {
+	mutex sync.Mutex
+
+	Vendor string `json:"vendor"`
+	// Synced is the number of firmware transferred to the firmware repository
+	Synced int `json:"synced"`
+	// Present is the number of firmware already in the firmware repository, they're published all the same
+	Present int `json:"present"`
+	// Skipped is the number of firmware skipped for their build date or size
+	Skipped int `json:"skipped"`
+	// Failed is the number of firmware which failed to sync, the reasons are listed in Failures
+	Failed   int           `json:"failed"`
+	Failures []SyncFailure `json:"failures"`
+	// Bytes is the size of the firmware transferred to the firmware repository
+	Bytes           int64   `json:"bytes"`
+	DurationSeconds float64 `json:"duration_seconds"`
+
+	// published are the firmware counted as synced or present keyed by <vendor>/<filename>,
+	// their publishing to inventory can still fail when it's deferred, see PublishFailed
+	published map[string]publishedFirmware
+}
+
+// publishedFirmware is a firmware counted as synced or present in the report.
+type publishedFirmware struct {
+	firmware    *fleetdbapi.ComponentFirmwareVersion
+	present     bool
+	transferred int64
+}
+
+// SyncFailure is a firmware which failed to sync, with the reason why.
+type SyncFailure struct {
+	Filename    string `json:"filename"`
+	Version     string `json:"version"`
+	UpstreamURL string `json:"upstream_url"`
+	Reason      string `json:"reason"`
+}
+
+func newSyncReport(vendor string) *SyncReport {
+	return &SyncReport{Vendor: vendor, Failures: []SyncFailure{}, published: map[string]publishedFirmware{}}
+}
+
+func (r *SyncReport) synced(firmware *fleetdbapi.ComponentFirmwareVersion, transferred int64) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.Synced++
+	r.Bytes += transferred
+	r.published[reportKey(firmware)] = publishedFirmware{firmware: firmware, transferred: transferred}
+}
+
+func (r *SyncReport) present(firmware *fleetdbapi.ComponentFirmwareVersion) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.Present++
+	r.published[reportKey(firmware)] = publishedFirmware{firmware: firmware, present: true}
+}
+
+// PublishFailed reports the firmware counted as synced or present as failed, for its deferred publishing to inventory
+// failed, as with the inventory write-behind queue. The key is <vendor>/<filename> as in inventory.PublishErrors,
+// false is returned when the report has no such firmware.
+func (r *SyncReport) PublishFailed(key string, err error) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	published, found := r.published[key]
+	if !found {
+		return false
+	}
+
+	delete(r.published, key)
+
+	if published.present {
+		r.Present--
+	} else {
+		r.Synced--
+		r.Bytes -= published.transferred
+	}
+
+	r.Failed++
+	r.Failures = append(r.Failures, SyncFailure{
+		Filename:    published.firmware.Filename,
+		Version:     published.firmware.Version,
+		UpstreamURL: published.firmware.UpstreamURL,
+		Reason:      err.Error(),
+	})
+
+	return true
+}
+
+func reportKey(firmware *fleetdbapi.ComponentFirmwareVersion) string {
+	return path.Join(firmware.Vendor, firmware.Filename)
+}
+
+func (r *SyncReport) skipped() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.Skipped++
+}
+
+func (r *SyncReport) failed(firmware *fleetdbapi.ComponentFirmwareVersion, err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.Failed++
+	r.Failures = append(r.Failures, SyncFailure{
+		Filename:    firmware.Filename,
+		Version:     firmware.Version,
+		UpstreamURL: firmware.UpstreamURL,
+		Reason:      err.Error(),
+	})
+}
+
+func (r *SyncReport) done(started time.Time) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.DurationSeconds = time.Since(started).Seconds()
+}
@@ -0,0 +1,100 @@
+package vendors
+
+import (
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+
+	fleetdbapi "github.com/metal-toolbox/fleetdb/pkg/api/v1"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+
+	mockinventory "github.com/metal-toolbox/firmware-syncer/internal/inventory/mocks"
+	"github.com/metal-toolbox/firmware-syncer/internal/logging"
+)
+
+func TestSyncerReport(t *testing.T) {
+	fixture, err := os.ReadFile(path.Join("fixtures", "foobar1.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newFirmware := func(filename string) *fleetdbapi.ComponentFirmwareVersion {
+		return &fleetdbapi.ComponentFirmwareVersion{
+			Vendor:      "foo-vendor",
+			Filename:    filename,
+			Version:     "1.0",
+			UpstreamURL: "https://example.com/" + filename,
+			Checksum:    "79ec3cf629b56317111d5640b8df1220", // real checksum of fixtures/foobar1.zip
+		}
+	}
+
+	synced, present, failed := newFirmware("synced.zip"), newFirmware("present.zip"), newFirmware("failed.zip")
+
+	dstFs := newLocalFs(t)
+	if err = os.MkdirAll(filepath.Join(dstFs.Root(), filepath.Dir(DstPath(present))), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = os.WriteFile(filepath.Join(dstFs.Root(), DstPath(present)), fixture, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	ctrl := gomock.NewController(t)
+
+	mockDownloader := NewMockDownloader(ctrl)
+	mockDownloader.EXPECT().Download(gomock.Any(), gomock.Any(), synced).
+		DoAndReturn(func(_ context.Context, downloadDir string, firmware *fleetdbapi.ComponentFirmwareVersion) (*FirmwareFile, error) {
+			firmwarePath := filepath.Join(downloadDir, firmware.Filename)
+			return &FirmwareFile{Path: firmwarePath}, os.WriteFile(firmwarePath, fixture, 0o600)
+		})
+	mockDownloader.EXPECT().Download(gomock.Any(), gomock.Any(), failed).Return(nil, errors.New("connection reset"))
+
+	mockInventory := mockinventory.NewMockServerService(ctrl)
+	mockInventory.EXPECT().Publish(gomock.Any(), synced)
+	mockInventory.EXPECT().Publish(gomock.Any(), present)
+
+	s := NewSyncer(
+		dstFs,
+		newLocalFs(t),
+		mockDownloader,
+		mockInventory,
+		[]*fleetdbapi.ComponentFirmwareVersion{synced, present, failed},
+		logging.NewLogger("debug"),
+	).(*Syncer)
+
+	assert.NoError(t, s.Sync(context.Background()))
+
+	report := s.Report()
+	assert.Equal(t, "foo-vendor", report.Vendor)
+	assert.Equal(t, 1, report.Synced)
+	assert.Equal(t, 1, report.Present)
+	assert.Equal(t, 0, report.Skipped)
+	assert.Equal(t, 1, report.Failed)
+	assert.Equal(t, int64(len(fixture)), report.Bytes)
+	assert.Positive(t, report.DurationSeconds)
+
+	if assert.Len(t, report.Failures, 1) {
+		assert.Equal(t, "failed.zip", report.Failures[0].Filename)
+		assert.Equal(t, "https://example.com/failed.zip", report.Failures[0].UpstreamURL)
+		assert.Contains(t, report.Failures[0].Reason, "connection reset")
+	}
+
+	// the deferred publishing of the synced and present firmware fails
+	assert.True(t, report.PublishFailed("foo-vendor/synced.zip", errors.New("inventory unavailable")))
+	assert.True(t, report.PublishFailed("foo-vendor/present.zip", errors.New("inventory unavailable")))
+	assert.False(t, report.PublishFailed("foo-vendor/unknown.zip", errors.New("inventory unavailable")))
+
+	assert.Equal(t, 0, report.Synced)
+	assert.Equal(t, 0, report.Present)
+	assert.Equal(t, 3, report.Failed)
+	assert.Zero(t, report.Bytes)
+
+	if assert.Len(t, report.Failures, 3) {
+		assert.Equal(t, "synced.zip", report.Failures[1].Filename)
+		assert.Equal(t, "inventory unavailable", report.Failures[1].Reason)
+	}
+}
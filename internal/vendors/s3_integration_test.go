@@ -0,0 +1,188 @@
+//go:build integration
+
+package vendors
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+	"time"
+
+	fleetdbapi "github.com/metal-toolbox/fleetdb/pkg/api/v1"
+	"github.com/pkg/errors"
+	rcloneFs "github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/fs/object"
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+
+	"github.com/metal-toolbox/firmware-syncer/internal/config"
+	mockinventory "github.com/metal-toolbox/firmware-syncer/internal/inventory/mocks"
+	"github.com/metal-toolbox/firmware-syncer/internal/logging"
+)
+
+// s3TestBucket returns the S3 bucket the integration tests run against, set with the TEST_S3_* env vars,
+// see the test-integration make target which starts a MinIO container for it.
+func s3TestBucket(t *testing.T) *config.S3Bucket {
+	t.Helper()
+
+	endpoint := os.Getenv("TEST_S3_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("TEST_S3_ENDPOINT is not set")
+	}
+
+	getenv := func(key, fallback string) string {
+		if value := os.Getenv(key); value != "" {
+			return value
+		}
+
+		return fallback
+	}
+
+	return &config.S3Bucket{
+		Region:    getenv("TEST_S3_REGION", "us-east-1"),
+		Endpoint:  endpoint,
+		Bucket:    getenv("TEST_S3_BUCKET", "firmware-syncer-test"),
+		AccessKey: getenv("TEST_S3_ACCESS_KEY", "minioadmin"),
+		SecretKey: getenv("TEST_S3_SECRET_KEY", "minioadmin"),
+	}
+}
+
+func TestSyncerS3RoundTrip(t *testing.T) {
+	logger := logging.NewLogger("debug")
+	ctx := context.Background()
+
+	dstFs, err := InitS3Fs(ctx, s3TestBucket(t), t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the bucket is created when missing, as the fs is initialized without checking it
+	if err = dstFs.Mkdir(ctx, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = operations.Purge(ctx, dstFs, "") })
+
+	tmpFs, err := InitLocalFs(ctx, &LocalFsConfig{Root: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fixture, err := os.ReadFile(path.Join("fixtures", "foobar1.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(fixture)
+	}))
+	defer server.Close()
+
+	firmware := &fleetdbapi.ComponentFirmwareVersion{
+		Vendor:      "foo-vendor",
+		Filename:    "foobar1.bin",
+		UpstreamURL: server.URL + "/foobar1.zip",
+		Checksum:    "md5sum:14758f1afd44c09b7992073ccf00b43d",
+	}
+
+	ctrl := gomock.NewController(t)
+
+	mockInventory := mockinventory.NewMockServerService(ctrl)
+	mockInventory.EXPECT().Publish(gomock.Any(), firmware)
+
+	s := NewSyncer(dstFs, tmpFs, NewArchiveDownloader(logger), mockInventory, []*fleetdbapi.ComponentFirmwareVersion{firmware}, logger)
+
+	if err = s.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	obj, err := dstFs.NewObject(ctx, DstPath(firmware))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	md5sum, err := obj.Hash(ctx, hash.MD5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "14758f1afd44c09b7992073ccf00b43d", md5sum)
+
+	// a second sync finds the firmware on the bucket and doesn't download it again
+	s = NewSyncer(dstFs, tmpFs, NewMockDownloader(ctrl), mockInventory, []*fleetdbapi.ComponentFirmwareVersion{firmware}, logger)
+	mockInventory.EXPECT().Publish(gomock.Any(), firmware)
+
+	assert.NoError(t, s.Sync(ctx))
+}
+
+// failingReader fails after size bytes, as a source dropping the connection mid upload
+type failingReader struct {
+	size int64
+}
+
+func (f *failingReader) Read(p []byte) (int, error) {
+	if f.size <= 0 {
+		return 0, errors.New("connection reset by peer")
+	}
+
+	n := int64(len(p))
+	if n > f.size {
+		n = f.size
+	}
+
+	f.size -= n
+
+	return int(n), nil
+}
+
+// multipartUploads returns the number of incomplete multipart uploads on the fs
+func multipartUploads(ctx context.Context, t *testing.T, fs rcloneFs.Fs) int {
+	t.Helper()
+
+	out, err := fs.(rcloneFs.Commander).Command(ctx, "list-multipart-uploads", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var uploads int
+
+	// uploads are listed by bucket
+	iter := reflect.ValueOf(out).MapRange()
+	for iter.Next() {
+		uploads += iter.Value().Len()
+	}
+
+	return uploads
+}
+
+func TestS3AbortStaleMultipartUploads(t *testing.T) {
+	ctx := context.Background()
+
+	cfg := s3TestBucket(t)
+	cfg.LeavePartsOnError = true
+
+	dstFs, err := InitS3Fs(ctx, cfg, t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = dstFs.Mkdir(ctx, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// an upload of unknown size is a multipart upload, its first 10M part is uploaded before the source fails
+	info := object.NewStaticObjectInfo("orphan.bin", time.Now(), -1, true, nil, nil)
+
+	_, err = dstFs.Put(ctx, &failingReader{size: 15 << 20}, info)
+	assert.Error(t, err)
+	assert.NotZero(t, multipartUploads(ctx, t, dstFs), "the failed upload parts should be left on the bucket")
+
+	assert.NoError(t, AbortStaleMultipartUploads(ctx, dstFs, 0))
+	assert.Zero(t, multipartUploads(ctx, t, dstFs))
+}
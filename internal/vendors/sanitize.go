@@ -0,0 +1,106 @@
+package vendors
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+)
+
+// MetadataOriginalFilename is the object metadata key of the original filename of firmware uploaded under its
+// sanitized filename. The filename is path escaped, as the metadata values are sent as HTTP headers.
+const MetadataOriginalFilename = MetadataChecksumPrefix + "original-filename"
+
+var (
+	ErrSanitizedFilenameCollision = errors.New("sanitized filename collides with another firmware filename")
+
+	// unsafeFilenameChars matches characters that are problematic in S3 keys and local filesystem paths.
+	unsafeFilenameChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+)
+
+// SanitizeFilename returns the filename with the characters problematic for S3 keys or local filesystems
+// replaced with an underscore, as FilenameSanitizer does without checking for collisions.
+func SanitizeFilename(filename string) string {
+	return unsafeFilenameChars.ReplaceAllString(filename, "_")
+}
+
+// OriginalFilenameMetadata returns the object metadata recording the original filename of the firmware
+// uploaded under the sanitized filename, it's empty when the filename wasn't changed.
+func OriginalFilenameMetadata(original, sanitized string) fs.Metadata {
+	if original == sanitized {
+		return fs.Metadata{}
+	}
+
+	return fs.Metadata{MetadataOriginalFilename: url.PathEscape(original)}
+}
+
+// OriginalFilename returns the original filename recorded in the object metadata of sanitized firmware.
+func OriginalFilename(metadata fs.Metadata) (string, bool) {
+	escaped, found := metadata[MetadataOriginalFilename]
+	if !found {
+		return "", false
+	}
+
+	original, err := url.PathUnescape(escaped)
+	if err != nil {
+		return "", false
+	}
+
+	return original, true
+}
+
+// FilenameSanitizer replaces characters in firmware filenames which are problematic
+// for S3 keys or local filesystems (spaces, parentheses...) with an underscore.
+//
+// The original filename for each sanitized filename is recorded to detect collisions, the Syncer persists it
+// in the object metadata of the firmware, see OriginalFilename.
+// A nil *FilenameSanitizer returns filenames unchanged.
+type FilenameSanitizer struct {
+	mutex     sync.RWMutex
+	originals map[string]string
+}
+
+// NewFilenameSanitizer returns a new FilenameSanitizer.
+func NewFilenameSanitizer() *FilenameSanitizer {
+	return &FilenameSanitizer{originals: make(map[string]string)}
+}
+
+// Sanitize returns the sanitized filename and records its original name.
+//
+// An error is returned when two different filenames sanitize to the same value.
+func (s *FilenameSanitizer) Sanitize(filename string) (string, error) {
+	if s == nil {
+		return filename, nil
+	}
+
+	sanitized := SanitizeFilename(filename)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if original, exists := s.originals[sanitized]; exists && original != filename {
+		msg := fmt.Sprintf("%s and %s sanitize to %s", original, filename, sanitized)
+		return "", errors.Wrap(ErrSanitizedFilenameCollision, msg)
+	}
+
+	s.originals[sanitized] = filename
+
+	return sanitized, nil
+}
+
+// Original returns the original filename for the given sanitized filename.
+func (s *FilenameSanitizer) Original(sanitized string) (string, bool) {
+	if s == nil {
+		return sanitized, true
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	original, exists := s.originals[sanitized]
+
+	return original, exists
+}
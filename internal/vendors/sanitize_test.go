@@ -0,0 +1,89 @@
+package vendors
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_FilenameSanitizer(t *testing.T) {
+	safeFilename := regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+	cases := []struct {
+		name     string
+		filename string
+		want     string
+	}{
+		{
+			"already safe",
+			"BIOS_X11SCH-F-1B11_20210525_1.6_STDsp.zip",
+			"BIOS_X11SCH-F-1B11_20210525_1.6_STDsp.zip",
+		},
+		{
+			"parentheses",
+			"X11SCH-(LN4)F_BIOS_1.6_release_notes.pdf",
+			"X11SCH-_LN4_F_BIOS_1.6_release_notes.pdf",
+		},
+		{
+			"spaces",
+			"SAS RAID Firmware 2.5.13.EXE",
+			"SAS_RAID_Firmware_2.5.13.EXE",
+		},
+		{
+			"spaces and parentheses",
+			"firmware (copy 1).bin",
+			"firmware__copy_1_.bin",
+		},
+	}
+
+	sanitizer := NewFilenameSanitizer()
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := sanitizer.Sanitize(tc.filename)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+			assert.Regexp(t, safeFilename, got)
+
+			// sanitizing is consistent
+			again, err := sanitizer.Sanitize(tc.filename)
+			assert.NoError(t, err)
+			assert.Equal(t, got, again)
+
+			// original is recoverable
+			original, ok := sanitizer.Original(got)
+			assert.True(t, ok)
+			assert.Equal(t, tc.filename, original)
+		})
+	}
+}
+
+func Test_FilenameSanitizerCollision(t *testing.T) {
+	sanitizer := NewFilenameSanitizer()
+
+	_, err := sanitizer.Sanitize("firmware (1).bin")
+	assert.NoError(t, err)
+
+	_, err = sanitizer.Sanitize("firmware [1].bin")
+	assert.ErrorIs(t, err, ErrSanitizedFilenameCollision)
+}
+
+func Test_FilenameSanitizerNil(t *testing.T) {
+	var sanitizer *FilenameSanitizer
+
+	got, err := sanitizer.Sanitize("firmware (1).bin")
+	assert.NoError(t, err)
+	assert.Equal(t, "firmware (1).bin", got)
+}
+
+func Test_OriginalFilenameMetadata(t *testing.T) {
+	metadata := OriginalFilenameMetadata("X11SCH-(LN4)F BIOS.zip", "X11SCH-_LN4_F_BIOS.zip")
+
+	original, ok := OriginalFilename(metadata)
+	assert.True(t, ok)
+	assert.Equal(t, "X11SCH-(LN4)F BIOS.zip", original)
+
+	_, ok = OriginalFilename(OriginalFilenameMetadata("BIOS.zip", "BIOS.zip"))
+	assert.False(t, ok, "unchanged filenames aren't recorded")
+}
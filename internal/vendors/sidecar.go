@@ -0,0 +1,64 @@
+package vendors
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	rcloneFs "github.com/rclone/rclone/fs"
+)
+
+// maxSidecarSize bounds the bytes read from a checksum sidecar, which holds a single hex digest
+const maxSidecarSize = 1024
+
+var ErrSidecarChecksum = errors.New("error reading sidecar checksum")
+
+// VerifyWithDetachedChecksum validates localPath, the downloaded copy of filename on srcFs,
+// against the filename.SHA256 sidecar published next to it.
+// Nothing is verified when the source has no sidecar.
+func VerifyWithDetachedChecksum(ctx context.Context, srcFs rcloneFs.Fs, filename, localPath string) error {
+	checksum, err := readSidecarChecksum(ctx, srcFs, filename+SumSuffix)
+	if errors.Is(err, ErrFileNotFound) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return SHA256ChecksumValidate(localPath, checksum)
+}
+
+// readSidecarChecksum returns the digest in the sidecar object,
+// either a bare digest or the "<digest>  <filename>" sha256sum output.
+func readSidecarChecksum(ctx context.Context, srcFs rcloneFs.Fs, sidecarPath string) (string, error) {
+	var obj rcloneFs.Object
+
+	err := retryObjectOperation(ctx, func() (err error) {
+		obj, err = srcFs.NewObject(ctx, sidecarPath)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	r, err := obj.Open(ctx)
+	if err != nil {
+		return "", errors.Wrap(ErrSidecarChecksum, err.Error())
+	}
+	defer r.Close()
+
+	b, err := io.ReadAll(io.LimitReader(r, maxSidecarSize))
+	if err != nil {
+		return "", errors.Wrap(ErrSidecarChecksum, err.Error())
+	}
+
+	fields := strings.Fields(string(b))
+	if len(fields) == 0 {
+		return "", errors.Wrap(ErrSidecarChecksum, sidecarPath+": empty")
+	}
+
+	return fields[0], nil
+}
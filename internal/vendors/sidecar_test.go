@@ -0,0 +1,79 @@
+package vendors
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	rcloneFs "github.com/rclone/rclone/fs"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+
+	mockvendors "github.com/metal-toolbox/firmware-syncer/internal/vendors/mocks"
+)
+
+func TestVerifyWithDetachedChecksum(t *testing.T) {
+	ctx := context.Background()
+
+	localPath := filepath.Join(t.TempDir(), "firmware.bin")
+	if err := os.WriteFile(localPath, []byte(`checksum this`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name          string
+		sidecar       string
+		noSidecar     bool
+		expectedError error
+	}{
+		{
+			name:    "sidecar matches",
+			sidecar: "97e9269cd0514f864e6be9157998464c94776ebc7f669b449f581abdad4035f5",
+		},
+		{
+			name:    "sha256sum output",
+			sidecar: "97e9269cd0514f864e6be9157998464c94776ebc7f669b449f581abdad4035f5  firmware.bin\n",
+		},
+		{
+			name:          "sidecar mismatches",
+			sidecar:       "0000000000000000000000000000000000000000000000000000000000000000",
+			expectedError: ErrChecksumInvalid,
+		},
+		{
+			name:          "empty sidecar",
+			sidecar:       "",
+			expectedError: ErrSidecarChecksum,
+		},
+		{
+			name:      "no sidecar",
+			noSidecar: true,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			mockSrcFs := mockvendors.NewMockRCloneFS(ctrl)
+
+			if tt.noSidecar {
+				mockSrcFs.EXPECT().NewObject(ctx, "vendor/firmware.bin.SHA256").Return(nil, rcloneFs.ErrorObjectNotFound)
+			} else {
+				sidecar := mockvendors.NewMockRCloneObject(ctrl)
+				sidecar.EXPECT().Open(ctx).Return(io.NopCloser(strings.NewReader(tt.sidecar)), nil)
+				mockSrcFs.EXPECT().NewObject(ctx, "vendor/firmware.bin.SHA256").Return(sidecar, nil)
+			}
+
+			err := VerifyWithDetachedChecksum(ctx, mockSrcFs, "vendor/firmware.bin", localPath)
+			if tt.expectedError == nil {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.ErrorIs(t, err, tt.expectedError)
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package vendors
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// sniffLen is the number of bytes read to sniff the archive type, a tar header block
+	sniffLen = 512
+	// tarMagicOffset is the offset of the ustar magic in a tar header block
+	tarMagicOffset = 257
+)
+
+// tarMagic is the magic of the POSIX and GNU tar header blocks
+var tarMagic = []byte("ustar")
+
+// sniffArchiveExtension returns the extension of the archive type detected from the first bytes of archivePath,
+// ok is false when the file can't be read or isn't a zip, gzip or tar archive.
+func sniffArchiveExtension(archivePath string) (extension string, ok bool) {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return "", false
+	}
+	defer f.Close()
+
+	head := make([]byte, sniffLen)
+
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return "", false
+	}
+
+	head = head[:n]
+
+	switch http.DetectContentType(head) {
+	case "application/zip":
+		return ".zip", true
+	case "application/x-gzip":
+		if _, err = f.Seek(0, io.SeekStart); err == nil && isGzippedTar(f) {
+			return ".tar.gz", true
+		}
+
+		return ".gz", true
+	}
+
+	if isTarHeader(head) {
+		return ".tar", true
+	}
+
+	return "", false
+}
+
+// isGzippedTar returns true when the gzip stream read from r decompresses to a tar archive.
+func isGzippedTar(r io.Reader) bool {
+	gzipReader, err := gzip.NewReader(r)
+	if err != nil {
+		return false
+	}
+	defer gzipReader.Close()
+
+	head := make([]byte, sniffLen)
+	if _, err = io.ReadFull(gzipReader, head); err != nil {
+		return false
+	}
+
+	return isTarHeader(head)
+}
+
+func isTarHeader(head []byte) bool {
+	return len(head) >= sniffLen && bytes.HasPrefix(head[tarMagicOffset:], tarMagic)
+}
+
+// sameArchiveType returns true when the extension names the sniffed archive type,
+// the extension is kept then so the extractor registered for it is used.
+func sameArchiveType(extension, sniffed string) bool {
+	if extension == ".tgz" {
+		extension = ".tar.gz"
+	}
+
+	return extension == sniffed
+}
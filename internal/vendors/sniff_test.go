@@ -0,0 +1,129 @@
+package vendors
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// copyFixture copies the fixture to a file named filename in a temporary directory and returns its path.
+func copyFixture(t *testing.T, fixture, filename string) string {
+	t.Helper()
+
+	b, err := os.ReadFile(getPathToFixture(fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archivePath := filepath.Join(t.TempDir(), filename)
+	if err = os.WriteFile(archivePath, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return archivePath
+}
+
+func Test_sniffArchiveExtension(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	tarPath := filepath.Join(tmpDir, "firmware.tar")
+	writeTar(t, tarPath, map[string]string{"firmware.bin": "firmware"})
+
+	tarGzPath := filepath.Join(tmpDir, "firmware.tar.gz")
+	writeTarGz(t, tarGzPath, map[string]string{"firmware.bin": "firmware"})
+
+	textPath := filepath.Join(tmpDir, "notes.txt")
+	if err := os.WriteFile(textPath, []byte("release notes"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name        string
+		archivePath string
+		expected    string
+		ok          bool
+	}{
+		{"zip", getPathToFixture("foobar1.zip"), ".zip", true},
+		{"gzip", getPathToFixture("foobar5.bin.gz"), ".gz", true},
+		{"gzipped tar", tarGzPath, ".tar.gz", true},
+		{"tar", tarPath, ".tar", true},
+		{"not an archive", textPath, "", false},
+		{"missing file", filepath.Join(tmpDir, "missing.zip"), "", false},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			extension, ok := sniffArchiveExtension(tt.archivePath)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.expected, extension)
+		})
+	}
+}
+
+func Test_ExtractFirmwareSniffsMisleadingExtension(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// a tar archive named like a gzip stream
+	tarPath := filepath.Join(tmpDir, "firmware.gz")
+	writeTar(t, tarPath, map[string]string{"release/firmware.bin": "firmware"})
+
+	// a gzipped tar archive named like a zip archive
+	tarGzPath := filepath.Join(tmpDir, "firmware.zip")
+	writeTarGz(t, tarGzPath, map[string]string{"release/firmware.bin": "firmware"})
+
+	testCases := []struct {
+		name        string
+		archivePath string
+		filename    string
+		checksum    string
+	}{
+		{
+			name:        "zip named like a gzipped tar",
+			archivePath: copyFixture(t, "foobar1.zip", "foobar1.tar.gz"),
+			filename:    "foobar1.bin",
+		},
+		{
+			name:        "gzip named like a zip",
+			archivePath: copyFixture(t, "foobar5.bin.gz", "download.zip"),
+			filename:    "foobar5.bin",
+			checksum:    "md5sum:ed8bb6fb8c0a2814f0f0bea97c70fd34",
+		},
+		{
+			name:        "gzipped tar named like a zip",
+			archivePath: tarGzPath,
+			filename:    "firmware.bin",
+			checksum:    "md5sum:74b5b5e9570efc5c0553bb327cd41940",
+		},
+		{
+			name:        "tar named like a gzip",
+			archivePath: tarPath,
+			filename:    "firmware.bin",
+			checksum:    "md5sum:74b5b5e9570efc5c0553bb327cd41940",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := ExtractFirmware(tt.archivePath, tt.filename, tt.checksum)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, tt.filename, filepath.Base(f.Path))
+			assert.Equal(t, filepath.Dir(tt.archivePath), filepath.Dir(f.Path))
+		})
+	}
+}
+
+func Test_ExtractFirmwareKeepsRegisteredExtension(t *testing.T) {
+	archivePath := filepath.Join(t.TempDir(), "firmware.tgz")
+	writeTarGz(t, archivePath, map[string]string{"firmware.bin": "firmware"})
+
+	tgz := registerFakeExtractor(t, ".tgz")
+
+	_, err := ExtractFirmware(archivePath, "firmware.bin", "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{archivePath}, tgz.calls)
+}
@@ -0,0 +1,145 @@
+package vendors
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+
+	fleetdbapi "github.com/metal-toolbox/fleetdb/pkg/api/v1"
+	"github.com/pkg/errors"
+)
+
+var ErrSyncState = errors.New("sync state error")
+
+// syncStateFile is the content of the state file.
+type syncStateFile struct {
+	// ManifestURL is the manifest the state was recorded for, the state of another manifest is discarded
+	ManifestURL string `json:"manifest_url"`
+	// Completed maps the destination path of the firmware synced during the run to its checksum
+	Completed map[string]string `json:"completed"`
+}
+
+// SyncState records the firmware synced during a run in a state file, for an interrupted run to resume
+// without checking the destination for the firmware it already synced.
+//
+// The state file is rewritten atomically on each record, it's meant to be removed once the run completes.
+type SyncState struct {
+	mutex sync.Mutex
+	path  string
+	state syncStateFile
+}
+
+// LoadSyncState returns the SyncState recorded in the state file at path, an empty state when it doesn't exist.
+// The recorded firmware is validated against the current manifest: the state of another manifest is discarded
+// and firmware whose checksum is no longer listed in the manifest is dropped.
+func LoadSyncState(path, manifestURL string, firmwares []*fleetdbapi.ComponentFirmwareVersion) (*SyncState, error) {
+	s := &SyncState{
+		path:  path,
+		state: syncStateFile{ManifestURL: manifestURL, Completed: make(map[string]string)},
+	}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(ErrSyncState, err.Error())
+	}
+
+	var recorded syncStateFile
+	if err = json.Unmarshal(b, &recorded); err != nil {
+		return nil, errors.Wrap(ErrSyncState, path+": "+err.Error())
+	}
+
+	if recorded.ManifestURL != manifestURL {
+		return s, nil
+	}
+
+	checksums := make(map[string]bool, len(firmwares))
+	for _, firmware := range firmwares {
+		checksums[firmware.Checksum] = true
+	}
+
+	for destPath, checksum := range recorded.Completed {
+		if checksums[checksum] {
+			s.state.Completed[destPath] = checksum
+		}
+	}
+
+	return s, nil
+}
+
+// Completed returns true when the firmware with the checksum was synced to destPath during the run.
+func (s *SyncState) Completed(destPath, checksum string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	recorded, ok := s.state.Completed[destPath]
+
+	return ok && recorded == checksum
+}
+
+// Len returns the number of firmware recorded as synced.
+func (s *SyncState) Len() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return len(s.state.Completed)
+}
+
+// Record records the firmware with the checksum as synced to destPath, and writes the state file.
+func (s *SyncState) Record(destPath, checksum string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.state.Completed[destPath] = checksum
+
+	return s.write()
+}
+
+// Remove removes the state file, the next run starts over.
+func (s *SyncState) Remove() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := os.Remove(s.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return errors.Wrap(ErrSyncState, err.Error())
+	}
+
+	return nil
+}
+
+// write replaces the state file with a temporary file renamed over it, so an interrupted write leaves the previous state.
+func (s *SyncState) write() error {
+	b, err := json.Marshal(s.state)
+	if err != nil {
+		return errors.Wrap(ErrSyncState, err.Error())
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp-")
+	if err != nil {
+		return errors.Wrap(ErrSyncState, err.Error())
+	}
+
+	defer os.Remove(f.Name())
+
+	if _, err = f.Write(b); err == nil {
+		err = f.Sync()
+	}
+
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Rename(f.Name(), s.path)
+	}
+
+	if err != nil {
+		return errors.Wrap(ErrSyncState, err.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,146 @@
+package vendors
+
+import (
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+
+	fleetdbapi "github.com/metal-toolbox/fleetdb/pkg/api/v1"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+
+	mockinventory "github.com/metal-toolbox/firmware-syncer/internal/inventory/mocks"
+	"github.com/metal-toolbox/firmware-syncer/internal/logging"
+)
+
+const stateManifestURL = "https://example.com/modeldata.json"
+
+func TestSyncState(t *testing.T) {
+	firmwares := []*fleetdbapi.ComponentFirmwareVersion{
+		{Vendor: "foo-vendor", Filename: "foobar1.zip", Checksum: "79ec3cf629b56317111d5640b8df1220"},
+		{Vendor: "foo-vendor", Filename: "foobar2.zip", Checksum: "b9f12aeec12b00ad5aea6e3b0fef7feb"},
+	}
+
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+
+	state, err := LoadSyncState(stateFile, stateManifestURL, firmwares)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 0, state.Len())
+	assert.NoFileExists(t, stateFile)
+
+	assert.NoError(t, state.Record("foo-vendor/foobar1.zip", firmwares[0].Checksum))
+	assert.NoError(t, state.Record("foo-vendor/foobar2.zip", firmwares[1].Checksum))
+
+	// the temporary files are renamed over the state file
+	entries, err := os.ReadDir(filepath.Dir(stateFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, entries, 1)
+
+	resumed, err := LoadSyncState(stateFile, stateManifestURL, firmwares)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 2, resumed.Len())
+	assert.True(t, resumed.Completed("foo-vendor/foobar1.zip", firmwares[0].Checksum))
+	assert.False(t, resumed.Completed("foo-vendor/foobar1.zip", firmwares[1].Checksum), "checksum changed")
+	assert.False(t, resumed.Completed("foo-vendor/foobar3.zip", firmwares[0].Checksum), "not synced")
+
+	// the firmware no longer listed in the manifest is dropped
+	updated, err := LoadSyncState(stateFile, stateManifestURL, firmwares[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 1, updated.Len())
+	assert.False(t, updated.Completed("foo-vendor/foobar2.zip", firmwares[1].Checksum))
+
+	// the state of another manifest is discarded
+	otherManifest, err := LoadSyncState(stateFile, "https://example.com/other.json", firmwares)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 0, otherManifest.Len())
+
+	assert.NoError(t, resumed.Remove())
+	assert.NoFileExists(t, stateFile)
+	assert.NoError(t, resumed.Remove(), "removing a missing state file")
+}
+
+func TestSyncStateMalformed(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(stateFile, []byte(`{"completed": [`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := LoadSyncState(stateFile, stateManifestURL, nil)
+	assert.ErrorIs(t, err, ErrSyncState)
+}
+
+func TestSyncerSyncState(t *testing.T) {
+	fixture, err := os.ReadFile(path.Join("fixtures", "foobar1.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resumed := &fleetdbapi.ComponentFirmwareVersion{
+		Vendor:   "foo-vendor",
+		Filename: "foobar1.zip",
+		Checksum: "79ec3cf629b56317111d5640b8df1220",
+	}
+	pending := &fleetdbapi.ComponentFirmwareVersion{
+		Vendor:   "foo-vendor",
+		Filename: "foobar2.zip",
+		Checksum: "79ec3cf629b56317111d5640b8df1220", // real checksum of fixtures/foobar1.zip
+	}
+
+	firmwares := []*fleetdbapi.ComponentFirmwareVersion{resumed, pending}
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+
+	state, err := LoadSyncState(stateFile, stateManifestURL, firmwares)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = state.Record(DstPath(resumed), resumed.Checksum); err != nil {
+		t.Fatal(err)
+	}
+
+	ctrl := gomock.NewController(t)
+
+	// the firmware recorded in the state isn't downloaded again, it's published as it's present on the destination
+	mockDownloader := NewMockDownloader(ctrl)
+	mockDownloader.EXPECT().Download(gomock.Any(), gomock.Any(), pending).
+		DoAndReturn(func(_ context.Context, downloadDir string, firmware *fleetdbapi.ComponentFirmwareVersion) (*FirmwareFile, error) {
+			firmwarePath := filepath.Join(downloadDir, firmware.Filename)
+			return &FirmwareFile{Path: firmwarePath}, os.WriteFile(firmwarePath, fixture, 0o600)
+		})
+
+	mockInventory := mockinventory.NewMockServerService(ctrl)
+	mockInventory.EXPECT().Publish(gomock.Any(), resumed)
+	mockInventory.EXPECT().Publish(gomock.Any(), pending)
+
+	dstFs := newLocalFs(t)
+
+	s := NewSyncer(dstFs, newLocalFs(t), mockDownloader, mockInventory, firmwares, logging.NewLogger("debug"), WithSyncState(state))
+
+	assert.NoError(t, s.Sync(context.Background()))
+	assert.NoFileExists(t, filepath.Join(dstFs.Root(), DstPath(resumed)))
+	assert.FileExists(t, filepath.Join(dstFs.Root(), DstPath(pending)))
+
+	recorded, err := LoadSyncState(stateFile, stateManifestURL, firmwares)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.True(t, recorded.Completed(DstPath(pending), pending.Checksum))
+}
@@ -19,26 +19,43 @@ import (
 
 var ErrMissingFirmwareID = errors.New("upstream URL is missing firmwareID")
 
+const biosURL = "https://www.supermicro.com/Bios"
+
+// checksum hints as expected by vendors.ValidateChecksum
+const (
+	checksumHintMD5  = "md5sum"
+	checksumHintSHA1 = "sha1"
+)
+
 type Downloader struct {
 	logger *logrus.Logger
+	client fleetdbapi.Doer
 }
 
 // NewSupermicroDownloader creates a new Downloader for downloading files from Supermicro.
 func NewSupermicroDownloader(logger *logrus.Logger) vendors.Downloader {
-	return &Downloader{logger: logger}
+	return &Downloader{
+		logger: logger,
+		client: vendors.NewHTTPClient(&vendors.HTTPClientOptions{Timeout: time.Second * 15}),
+	}
 }
 
 // Download will download a file for the given firmware to the given downloadDir,
 // and will return the full path to the downloaded file.
-func (d *Downloader) Download(ctx context.Context, downloadDir string, firmware *fleetdbapi.ComponentFirmwareVersion) (string, error) {
+func (d *Downloader) Download(ctx context.Context, downloadDir string, firmware *fleetdbapi.ComponentFirmwareVersion) (*vendors.FirmwareFile, error) {
+	downloadDir, err := vendors.FirmwareDownloadDir(downloadDir, firmware)
+	if err != nil {
+		return nil, err
+	}
+
 	urlSplit := strings.Split(firmware.UpstreamURL, "=")
 
 	if len(urlSplit) < 2 {
-		return "", errors.Wrap(ErrMissingFirmwareID, firmware.UpstreamURL)
+		return nil, errors.Wrap(ErrMissingFirmwareID, firmware.UpstreamURL)
 	}
 
 	firmwareID := urlSplit[1]
-	archiveURL, archiveChecksum, err := getArchiveURLAndChecksum(ctx, firmwareID)
+	archiveURL, archiveChecksum, err := getArchiveURLAndChecksum(ctx, d.client, firmwareID)
 
 	d.logger.WithField("archiveURL", archiveURL).
 		WithField("archiveChecksum", archiveChecksum).
@@ -46,36 +63,32 @@ func (d *Downloader) Download(ctx context.Context, downloadDir string, firmware
 
 	if err != nil {
 		d.logger.WithField("firmwareID", firmwareID).Debug("failed to get archiveURL and archiveChecksum")
-		return "", err
+		return nil, err
 	}
 
 	d.logger.Debug("Downloading archive")
 
 	archivePath, err := vendors.DownloadFirmwareArchive(ctx, downloadDir, archiveURL, archiveChecksum)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	d.logger.WithField("archivePath", archivePath).Debug("Archive downloaded.")
 	d.logger.Debug("Extracting firmware from archive")
 
-	fwFile, err := vendors.ExtractFromZipArchive(archivePath, firmware.Filename, "")
+	fwFile, err := vendors.ExtractFirmware(archivePath, firmware.Filename, "")
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	return fwFile.Name(), nil
+	return fwFile, nil
 }
 
-func getArchiveURLAndChecksum(ctx context.Context, id string) (url, checksum string, err error) {
-	var httpClient = &http.Client{
-		Timeout: time.Second * 15,
-	}
-
+func getArchiveURLAndChecksum(ctx context.Context, httpClient fleetdbapi.Doer, id string) (url, checksum string, err error) {
 	req, err := http.NewRequestWithContext(
 		ctx,
 		"GET",
-		fmt.Sprintf("https://www.supermicro.com/Bios/softfiles/%s/checksum.txt", id),
+		fmt.Sprintf("%s/softfiles/%s/checksum.txt", biosURL, id),
 		http.NoBody,
 	)
 	if err != nil {
@@ -88,20 +101,38 @@ func getArchiveURLAndChecksum(ctx context.Context, id string) (url, checksum str
 	}
 	defer resp.Body.Close()
 
-	filename, checksum, err := parseFilenameAndChecksum(resp.Body)
+	archivePath, checksum, hint, err := parseArchivePathAndChecksum(resp.Body)
 	if err != nil {
 		return "", "", err
 	}
 
-	archiveURL := fmt.Sprintf("https://www.supermicro.com/Bios/softfiles/%s/%s", id, filename)
+	if checksum != "" {
+		checksum = hint + ":" + checksum
+	}
+
+	return archiveURL(id, archivePath), checksum, nil
+}
+
+// archiveURL returns the URL of the archive listed in the checksum file,
+// the softfiles id segment in the listed path may differ from the requested id,
+// so the URL is only assembled from the id when the checksum file lists a bare filename.
+func archiveURL(id, archivePath string) string {
+	if strings.Contains(archivePath, "/") {
+		return fmt.Sprintf("%s/%s", biosURL, strings.TrimPrefix(archivePath, "/"))
+	}
 
-	return archiveURL, checksum, nil
+	return fmt.Sprintf("%s/softfiles/%s/%s", biosURL, id, archivePath)
 }
 
-func parseFilenameAndChecksum(checksumFile io.Reader) (filename, checksum string, err error) {
+// parseArchivePathAndChecksum returns the archive path and checksum listed in the checksum file,
+// along with the checksum hint understood by vendors.ValidateChecksum.
+// The archive path is either a path under softfiles/ or a bare filename, depending on the checksum file format.
+// The MD5 checksum is preferred, the SHA1 checksum is returned when it's the only one listed.
+func parseArchivePathAndChecksum(checksumFile io.Reader) (archivePath, checksum, hint string, err error) {
 	scanner := bufio.NewScanner(checksumFile)
-	checksum = ""
-	filename = ""
+	archivePath = ""
+
+	var md5sum, sha1sum string
 
 	defer func() {
 		if r := recover(); r != nil {
@@ -113,27 +144,33 @@ func parseFilenameAndChecksum(checksumFile io.Reader) (filename, checksum string
 		line := scanner.Text()
 
 		switch {
-		case strings.HasPrefix(line, "/softfiles"):
-			if strings.Contains(line, "MD5") {
-				filename = strings.Split(strings.Split(line, "/")[3], " ")[0]
-				checksum = strings.TrimSpace(strings.Split(line, "=")[1])
-
-				break
-			} else {
-				continue
-			}
-		case strings.HasPrefix(line, "softfiles"):
-			filename = strings.Split(line, "/")[2]
 		case strings.HasPrefix(line, "MD5 CheckSum:"):
-			checksum = strings.TrimSpace(strings.Split(line, ":")[1])
+			md5sum = strings.TrimSpace(strings.Split(line, ":")[1])
+		case strings.HasPrefix(line, "SHA1 CheckSum:"):
+			sha1sum = strings.TrimSpace(strings.Split(line, ":")[1])
+		case strings.Contains(line, "MD5"):
+			md5sum = strings.TrimSpace(strings.Split(line, "=")[1])
+			archivePath = strings.Fields(line)[0]
+		case strings.Contains(line, "SHA1"):
+			sha1sum = strings.TrimSpace(strings.Split(line, "=")[1])
+			archivePath = strings.Fields(line)[0]
+		case strings.HasPrefix(line, "softfiles"), strings.HasPrefix(line, "/softfiles"):
+			archivePath = strings.TrimSpace(line)
 		default:
 			continue
 		}
 
 		if err := scanner.Err(); err != nil {
-			return "", "", err
+			return "", "", "", err
 		}
 	}
 
-	return filename, checksum, nil
+	switch {
+	case md5sum != "":
+		return archivePath, md5sum, checksumHintMD5, nil
+	case sha1sum != "":
+		return archivePath, sha1sum, checksumHintSHA1, nil
+	default:
+		return archivePath, "", "", nil
+	}
 }
@@ -8,7 +8,7 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
-func Test_getChecksumFilename(t *testing.T) {
+func Test_parseArchivePathAndChecksum(t *testing.T) {
 	checksumFileExample1 := `
 softfiles/14021/BMC_X11AST2500-4101MS_20210510_01.73.12_STDsp.zip
 CRC32 CheckSum: 5d32ec4b
@@ -30,49 +30,121 @@ MD5 CheckSum: 3f5cecadf92192d86d049a99b36939ab
 `
 	checksumFileExample4 := `
 /softfiles/MD5
+`
+	checksumFileExample5 := `
+/softfiles/4390/SMT_MBIPMI_339_REDFISH.zip SHA1 = 103a717fbaf3b88f23e64e7bfe81e97ce2af10c3
+`
+	checksumFileExample6 := `
+softfiles/14021/BMC_X11AST2500-4101MS_20210510_01.73.12_STDsp.zip
+CRC32 CheckSum: 5d32ec4b
+SHA1 CheckSum: 103a717fbaf3b88f23e64e7bfe81e97ce2af10c3
+`
+	checksumFileExample7 := `
+SMT_MBIPMI_339_REDFISH.zip MD5 = 33cdcd726f36f8ac35d8a0e4cea4a2a8
 `
 	cases := []struct {
 		name         string
 		checksumFile io.Reader
 		wantChecksum string
-		wantFilename string
+		wantPath     string
+		wantHint     string
 	}{
 		{
 			"checksumFileExample1",
 			strings.NewReader(checksumFileExample1),
 			"1a18d5d94fad55dc6fc51630383b1e7f",
-			"BMC_X11AST2500-4101MS_20210510_01.73.12_STDsp.zip",
+			"softfiles/14021/BMC_X11AST2500-4101MS_20210510_01.73.12_STDsp.zip",
+			"md5sum",
 		},
 		{
 			"checksumFileExample2",
 			strings.NewReader(checksumFileExample2),
 			"9cd49a78f10d513f43f861e674d51c10",
-			"BIOS_X11SCH-F-1B11_20210525_1.6_STDsp.zip",
+			"softfiles/14075/BIOS_X11SCH-F-1B11_20210525_1.6_STDsp.zip",
+			"md5sum",
 		},
 		{
 			"checksumFileExample3",
 			strings.NewReader(checksumFileExample3),
 			"33cdcd726f36f8ac35d8a0e4cea4a2a8",
-			"SMT_MBIPMI_339_REDFISH.zip",
+			"/softfiles/4390/SMT_MBIPMI_339_REDFISH.zip",
+			"md5sum",
 		},
 		{"checksumFileExample4",
 			strings.NewReader(checksumFileExample4),
 			"",
 			"",
+			"",
+		},
+		{
+			"checksumFileExample5",
+			strings.NewReader(checksumFileExample5),
+			"103a717fbaf3b88f23e64e7bfe81e97ce2af10c3",
+			"/softfiles/4390/SMT_MBIPMI_339_REDFISH.zip",
+			"sha1",
+		},
+		{
+			"checksumFileExample6",
+			strings.NewReader(checksumFileExample6),
+			"103a717fbaf3b88f23e64e7bfe81e97ce2af10c3",
+			"softfiles/14021/BMC_X11AST2500-4101MS_20210510_01.73.12_STDsp.zip",
+			"sha1",
+		},
+		{
+			"checksumFileExample7",
+			strings.NewReader(checksumFileExample7),
+			"33cdcd726f36f8ac35d8a0e4cea4a2a8",
+			"SMT_MBIPMI_339_REDFISH.zip",
+			"md5sum",
 		},
 	}
 
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
-			filename, checksum, err := parseFilenameAndChecksum(tc.checksumFile)
+			archivePath, checksum, hint, err := parseArchivePathAndChecksum(tc.checksumFile)
 			if err != nil {
 				assert.ErrorContains(t, err, "parsing failed: runtime error:")
-				assert.Equal(t, tc.wantFilename, filename)
+				assert.Equal(t, tc.wantPath, archivePath)
 				assert.Equal(t, tc.wantChecksum, checksum)
 			}
 
-			assert.Equal(t, tc.wantFilename, filename)
+			assert.Equal(t, tc.wantPath, archivePath)
 			assert.Equal(t, tc.wantChecksum, checksum)
+			assert.Equal(t, tc.wantHint, hint)
+		})
+	}
+}
+
+func Test_archiveURL(t *testing.T) {
+	cases := []struct {
+		name        string
+		id          string
+		archivePath string
+		want        string
+	}{
+		{
+			"full path",
+			"14390",
+			"/softfiles/4390/SMT_MBIPMI_339_REDFISH.zip",
+			"https://www.supermicro.com/Bios/softfiles/4390/SMT_MBIPMI_339_REDFISH.zip",
+		},
+		{
+			"relative path",
+			"14021",
+			"softfiles/14021/BMC_X11AST2500-4101MS_20210510_01.73.12_STDsp.zip",
+			"https://www.supermicro.com/Bios/softfiles/14021/BMC_X11AST2500-4101MS_20210510_01.73.12_STDsp.zip",
+		},
+		{
+			"bare filename",
+			"14390",
+			"SMT_MBIPMI_339_REDFISH.zip",
+			"https://www.supermicro.com/Bios/softfiles/14390/SMT_MBIPMI_339_REDFISH.zip",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, archiveURL(tc.id, tc.archivePath))
 		})
 	}
 }
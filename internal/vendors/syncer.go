@@ -3,15 +3,26 @@ package vendors
 import (
 	"context"
 	"fmt"
+	"net/url"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/accounting"
 	"github.com/rclone/rclone/fs/operations"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
 
+	"github.com/metal-toolbox/firmware-syncer/internal/audit"
+	"github.com/metal-toolbox/firmware-syncer/internal/config"
+	"github.com/metal-toolbox/firmware-syncer/internal/events"
 	"github.com/metal-toolbox/firmware-syncer/internal/inventory"
+	"github.com/metal-toolbox/firmware-syncer/internal/metrics"
 
 	fleetdbapi "github.com/metal-toolbox/fleetdb/pkg/api/v1"
 )
@@ -23,6 +34,225 @@ type Syncer struct {
 	firmwares  []*fleetdbapi.ComponentFirmwareVersion
 	logger     *logrus.Logger
 	inventory  inventory.ServerService
+	sanitizer  *FilenameSanitizer
+	limiter    *AdaptiveConcurrency
+	// quarantineDir is where corrupt archives are moved to, they're discarded when not set
+	quarantineDir string
+	// eventPublisher is notified of newly synced firmware, artifactsURL is used for the firmware URL in events and audit records
+	eventPublisher events.Publisher
+	artifactsURL   string
+	// auditLogger records the newly synced firmware
+	auditLogger *audit.Logger
+	// progressInterval is how often the progress of a firmware download and upload is logged
+	progressInterval time.Duration
+	// verifyUpload reads back the hash of the objects uploaded to the dstFs, a corrupt object is uploaded once more
+	verifyUpload bool
+	// compressUploads uploads a gzip compressed copy next to the firmware that compresses well
+	compressUploads bool
+	// serverSideCopy enables copying firmware straight from the source when the downloader is a ServerSideCopier
+	serverSideCopy bool
+	// expectedSizes are the firmware download sizes declared in the manifest, checked against the available disk space
+	expectedSizes config.FirmwareSizes
+	// checksums are the firmware digests declared in the manifest, the firmware has to match all of them
+	checksums config.FirmwareChecksums
+	// sourceHeaders are sent with the download requests to their source host
+	sourceHeaders SourceHeaders
+	// ftpCredentials and webdavCredentials are logged in with to the FTP and WebDAV sources
+	ftpCredentials    FTPCredentials
+	webdavCredentials WebDAVCredentials
+	// maxFileSize skips firmware with a larger declared download size, there's no limit when zero
+	maxFileSize int64
+	// gitFileURLs allows firmware to be fetched from git+file:// URLs, reading repositories on the syncer host
+	gitFileURLs bool
+	// proxy routes the download requests sent with the SharedTransport, the standard proxy env vars are honored when nil
+	proxy *Proxy
+	// since skips firmware built before it, based on the manifest buildDates, it's not checked when zero
+	since      time.Time
+	buildDates config.FirmwareBuildDates
+	// mirrors are uploaded to in addition to the dstFs, quorum is the number of destinations an upload has to succeed on,
+	// every destination is required when it's zero
+	mirrors []fs.Fs
+	quorum  int
+	// metrics accumulates the bytes, transfers and errors of the firmware transfers
+	metrics *Metrics
+	// state records the synced firmware, the firmware it already holds isn't checked on the destination fs
+	state *SyncState
+	// report is the outcome of the last Sync
+	report *SyncReport
+}
+
+// SyncerOption sets optional parameters on the Syncer.
+type SyncerOption func(*Syncer)
+
+// WithFilenameSanitizer sets the FilenameSanitizer applied to firmware filenames
+// before they are written locally, uploaded to the destination and published to inventory.
+func WithFilenameSanitizer(sanitizer *FilenameSanitizer) SyncerOption {
+	return func(s *Syncer) {
+		s.sanitizer = sanitizer
+	}
+}
+
+// WithAdaptiveConcurrency syncs firmwares concurrently, bounded by the given AdaptiveConcurrency.
+func WithAdaptiveConcurrency(limiter *AdaptiveConcurrency) SyncerOption {
+	return func(s *Syncer) {
+		s.limiter = limiter
+	}
+}
+
+// WithConcurrency syncs up to concurrency firmwares in parallel, they're synced one at a time when it's lower than 2.
+func WithConcurrency(concurrency int) SyncerOption {
+	return func(s *Syncer) {
+		if concurrency > 1 {
+			s.limiter = NewAdaptiveConcurrency(concurrency, concurrency, concurrency)
+		}
+	}
+}
+
+// WithSyncState records the synced firmware in the state, for an interrupted run to resume from it,
+// firmware already recorded in the state is assumed to be present on the destination fs.
+func WithSyncState(state *SyncState) SyncerOption {
+	return func(s *Syncer) {
+		s.state = state
+	}
+}
+
+// WithQuarantine moves archives the firmware can't be extracted from into the given directory.
+func WithQuarantine(dir string) SyncerOption {
+	return func(s *Syncer) {
+		s.quarantineDir = dir
+	}
+}
+
+// WithAuditLogger writes an audit record for each firmware newly synced to the destination,
+// the record repository URL is the firmware destination path joined to the artifactsURL.
+func WithAuditLogger(logger *audit.Logger, artifactsURL string) SyncerOption {
+	return func(s *Syncer) {
+		s.auditLogger = logger
+		s.artifactsURL = artifactsURL
+	}
+}
+
+// WithEventPublisher emits an event on the publisher for each firmware newly synced to the destination,
+// the event URL is the firmware destination path joined to the artifactsURL.
+func WithEventPublisher(publisher events.Publisher, artifactsURL string) SyncerOption {
+	return func(s *Syncer) {
+		s.eventPublisher = publisher
+		s.artifactsURL = artifactsURL
+	}
+}
+
+// WithProgressInterval logs the progress of firmware transfers at the given interval instead of the DefaultProgressInterval,
+// a negative interval disables progress logging.
+func WithProgressInterval(interval time.Duration) SyncerOption {
+	return func(s *Syncer) {
+		s.progressInterval = interval
+	}
+}
+
+// WithServerSideCopy copies firmware straight from the source to the destination when the downloader is a ServerSideCopier,
+// it's meant for sources reachable with the destination credentials, see SameS3Account.
+// Firmware is downloaded regardless when uploaded to mirrors or compressed.
+func WithServerSideCopy() SyncerOption {
+	return func(s *Syncer) {
+		s.serverSideCopy = true
+	}
+}
+
+// WithUploadVerification compares the hash of the objects uploaded to the destination fs with the local file,
+// a corrupt object is removed and uploaded once more before the upload fails with ErrVerifyMismatch.
+func WithUploadVerification() SyncerOption {
+	return func(s *Syncer) {
+		s.verifyUpload = true
+	}
+}
+
+// WithUploadCompression uploads a gzip compressed copy of the firmware next to it, with the CompressedSuffix,
+// when the firmware isn't already compressed and compression saves enough space. The firmware is still uploaded
+// and published as is, the compressed copy carries the checksums of the uncompressed firmware in its metadata.
+func WithUploadCompression() SyncerOption {
+	return func(s *Syncer) {
+		s.compressUploads = true
+	}
+}
+
+// WithMirrors uploads the firmware to the mirrors in addition to the destination fs,
+// the upload succeeds when quorum destinations, the destination fs included, are uploaded to.
+// The destination fs is always required as the firmware is published with its path,
+// firmware already present on the destination fs isn't uploaded to the mirrors.
+func WithMirrors(mirrors []fs.Fs, quorum int) SyncerOption {
+	return func(s *Syncer) {
+		s.mirrors = mirrors
+		s.quorum = quorum
+	}
+}
+
+// WithExpectedSizes checks the work directory has room for the firmware download size declared in the manifest,
+// before downloading the firmware.
+func WithExpectedSizes(sizes config.FirmwareSizes) SyncerOption {
+	return func(s *Syncer) {
+		s.expectedSizes = sizes
+	}
+}
+
+// WithChecksums validates the firmware against all the checksums declared in the manifest,
+// in addition to the firmware checksum.
+func WithChecksums(checksums config.FirmwareChecksums) SyncerOption {
+	return func(s *Syncer) {
+		s.checksums = checksums
+	}
+}
+
+// WithSourceHeaders sends the configured HTTP headers with the firmware download requests to their source host.
+func WithSourceHeaders(headers SourceHeaders) SyncerOption {
+	return func(s *Syncer) {
+		s.sourceHeaders = headers
+	}
+}
+
+// WithFTPCredentials logs in to the ftp:// firmware sources with the credentials configured for their host.
+func WithFTPCredentials(credentials FTPCredentials) SyncerOption {
+	return func(s *Syncer) {
+		s.ftpCredentials = credentials
+	}
+}
+
+// WithWebDAVCredentials logs in to the webdav:// and webdavs:// firmware sources with the credentials configured for their host.
+func WithWebDAVCredentials(credentials WebDAVCredentials) SyncerOption {
+	return func(s *Syncer) {
+		s.webdavCredentials = credentials
+	}
+}
+
+// WithGitFileURLs allows firmware to be fetched from git+file:// URLs, they are refused otherwise
+// as they let the manifest read any repository on the syncer host.
+func WithGitFileURLs() SyncerOption {
+	return func(s *Syncer) {
+		s.gitFileURLs = true
+	}
+}
+
+// WithProxy routes the download requests sent with the SharedTransport through the proxy.
+func WithProxy(proxy *Proxy) SyncerOption {
+	return func(s *Syncer) {
+		s.proxy = proxy
+	}
+}
+
+// WithMaxFileSize skips firmware when the server reports a Content-Length larger than maxFileSize bytes,
+// instead of downloading it.
+func WithMaxFileSize(maxFileSize int64) SyncerOption {
+	return func(s *Syncer) {
+		s.maxFileSize = maxFileSize
+	}
+}
+
+// WithSince skips firmware with a manifest build date before since, for incremental syncs.
+// Firmware with a missing or unparseable build date is synced, with a warning.
+func WithSince(since time.Time, buildDates config.FirmwareBuildDates) SyncerOption {
+	return func(s *Syncer) {
+		s.since = since
+		s.buildDates = buildDates
+	}
 }
 
 // NewSyncer creates a new Syncer.
@@ -33,91 +263,704 @@ func NewSyncer(
 	inventoryClient inventory.ServerService,
 	firmwares []*fleetdbapi.ComponentFirmwareVersion,
 	logger *logrus.Logger,
+	opts ...SyncerOption,
 ) Vendor {
 	SetRcloneLogging(logger)
 
-	return &Syncer{
+	s := &Syncer{
 		dstFs:      dstFs,
 		tmpFs:      tmpFs,
 		downloader: downloader,
 		inventory:  inventoryClient,
 		firmwares:  firmwares,
 		logger:     logger,
+		metrics:    NewMetrics(),
+
+		progressInterval: DefaultProgressInterval,
+	}
+
+	s.report = newSyncReport(s.vendor())
+
+	for _, opt := range opts {
+		opt(s)
 	}
+
+	return s
 }
 
 // Sync will synchronize the firmwares with the destination file system and inventory.
 // Files that do not exist on the destination will be downloaded from their source and uploaded to the destination.
 // Information about the firmware file will be updated using the inventory client.
 func (s *Syncer) Sync(ctx context.Context) (err error) {
+	s.report = newSyncReport(s.vendor())
+	defer s.report.done(time.Now())
+
+	if s.limiter != nil {
+		s.syncConcurrently(ctx)
+		return nil
+	}
+
 	for _, firmware := range s.firmwares {
+		if ctx.Err() != nil {
+			break
+		}
+
 		if err = s.syncFirmware(ctx, firmware); err != nil {
 			// Log error without returning, to sync other firmwares
-			s.logger.WithError(err).
-				WithField("firmware", firmware.Filename).
-				WithField("vendor", firmware.Vendor).
-				WithField("version", firmware.Version).
-				WithField("url", firmware.UpstreamURL).
-				Error("Failed to sync firmware")
+			s.syncFailed(firmware, err)
 		}
 	}
 
 	return nil
 }
 
+// ConcurrencyLimit returns the number of firmwares currently synced in parallel.
+func (s *Syncer) ConcurrencyLimit() int {
+	if s.limiter == nil {
+		return 1
+	}
+
+	return s.limiter.Limit()
+}
+
+// syncConcurrently syncs the firmwares in parallel with the concurrency set by the limiter.
+func (s *Syncer) syncConcurrently(ctx context.Context) {
+	var wg sync.WaitGroup
+
+	for _, firmware := range s.firmwares {
+		if ctx.Err() != nil {
+			break
+		}
+
+		s.limiter.Acquire()
+		wg.Add(1)
+
+		go func(firmware *fleetdbapi.ComponentFirmwareVersion) {
+			defer wg.Done()
+
+			err := s.syncFirmware(ctx, firmware)
+			if err != nil {
+				s.syncFailed(firmware, err)
+			}
+
+			s.limiter.Release(err)
+		}(firmware)
+	}
+
+	wg.Wait()
+}
+
+// Report returns the outcome of the last Sync.
+func (s *Syncer) Report() *SyncReport {
+	return s.report
+}
+
+// vendor returns the vendor of the synced firmware.
+func (s *Syncer) vendor() string {
+	if len(s.firmwares) == 0 {
+		return ""
+	}
+
+	return s.firmwares[0].Vendor
+}
+
+// syncFailed logs the firmware sync failure and reports it.
+func (s *Syncer) syncFailed(firmware *fleetdbapi.ComponentFirmwareVersion, err error) {
+	s.report.failed(firmware, err)
+
+	s.logger.WithError(err).
+		WithField("firmware", firmware.Filename).
+		WithField("vendor", firmware.Vendor).
+		WithField("version", firmware.Version).
+		WithField("url", firmware.UpstreamURL).
+		Error("Failed to sync firmware")
+}
+
 // syncFirmware does the synchronization for the given firmware.
-func (s *Syncer) syncFirmware(ctx context.Context, firmware *fleetdbapi.ComponentFirmwareVersion) error {
-	destPath := DstPath(firmware)
+func (s *Syncer) syncFirmware(ctx context.Context, firmware *fleetdbapi.ComponentFirmwareVersion) (err error) {
+	ctx, span := startSpan(ctx, SpanSyncFirmware, firmware)
+	defer func() { endSpan(span, err) }()
 
 	logMsg := s.logger.WithField("firmware", firmware.Filename).
 		WithField("vendor", firmware.Vendor).
 		WithField("version", firmware.Version).
 		WithField("url", firmware.UpstreamURL)
 
+	if s.builtBeforeSince(logMsg, firmware) {
+		logMsg.WithField("since", s.since).Debug("Skipping firmware built before the since date")
+		s.report.skipped()
+
+		return nil
+	}
+
 	logMsg.Info("Syncing Firmware")
 
-	fileExists, err := fs.FileExists(ctx, s.dstFs, destPath)
+	published, err := s.sanitizeFirmware(firmware)
 	if err != nil {
-		return errors.Wrap(err, "failure checking if firmware file exists")
+		return err
 	}
 
+	if published.Filename != firmware.Filename {
+		logMsg.WithField("sanitizedFilename", published.Filename).Info("Sanitized firmware filename")
+	}
+
+	destPath := DstPath(published)
+
+	fileExists, err := s.fileExists(ctx, logMsg, published, destPath)
+	if err != nil {
+		return err
+	}
+
+	var (
+		transferred int64
+		started     = time.Now()
+	)
+
 	if !fileExists {
-		downloadDir, err := os.MkdirTemp(s.tmpFs.Root(), "firmware-download")
-		if err != nil {
-			return errors.Wrap(err, "failure creating download directory")
+		transferred, err = s.transferFirmware(ctx, logMsg, firmware, published, destPath)
+		if errors.Is(err, ErrFileTooLarge) {
+			s.skipOversized(logMsg, firmware, err)
+			return nil
 		}
 
-		defer func() {
-			if err = os.RemoveAll(downloadDir); err != nil {
-				logMsg.WithError(err).Error("Failure to clean up download directory")
-			}
-		}()
-
-		firmwareFilePath, err := s.downloader.Download(ctx, downloadDir, firmware)
 		if err != nil {
-			logMsg.WithError(err).Error("Failed to download firmware")
-			return nil // Only logging the error, so we don't fail the whole process
+			return err
 		}
+	} else {
+		s.backfillMirrors(ctx, logMsg, destPath)
+	}
 
-		if err = validateChecksum(firmwareFilePath, firmware.Checksum); err != nil {
-			logMsg.WithError(err).Error("Checksum validation failure")
-			return nil // Only logging the error, so we don't fail the whole process
+	if err = s.publish(ctx, published); err != nil {
+		return err
+	}
+
+	// with a deferred publish, as with the inventory write-behind queue, the firmware is only queued here:
+	// the state and the synced event are about the firmware transferred to the repository, which it is.
+	// A failed deferred publish is reported once the queue is flushed, the next run publishes the firmware again.
+	s.recordState(logMsg, published, destPath)
+
+	// firmware already present on the destination is skipped
+	if fileExists {
+		s.report.present(published)
+		return nil
+	}
+
+	s.report.synced(published, transferred)
+	s.emitSynced(ctx, logMsg, published, destPath)
+	s.audit(logMsg, published, destPath, transferred, time.Since(started))
+
+	return nil
+}
+
+// fileExists returns true when the firmware is present at destPath on the destination fs,
+// the firmware recorded in the state as synced to destPath isn't checked.
+func (s *Syncer) fileExists(
+	ctx context.Context,
+	logMsg *logrus.Entry,
+	firmware *fleetdbapi.ComponentFirmwareVersion,
+	destPath string,
+) (bool, error) {
+	if s.state != nil && s.state.Completed(destPath, firmware.Checksum) {
+		logMsg.Debug("Firmware recorded as synced in the state file")
+		return true, nil
+	}
+
+	var fileExists bool
+
+	err := retryObjectOperation(ctx, func() (err error) {
+		fileExists, err = fs.FileExists(ctx, s.dstFs, destPath)
+		return err
+	})
+	if err != nil {
+		return false, errors.Wrap(err, "failure checking if firmware file exists")
+	}
+
+	return fileExists, nil
+}
+
+// recordState records the firmware as synced to destPath in the state, failures are logged as the firmware is synced regardless.
+func (s *Syncer) recordState(logMsg *logrus.Entry, firmware *fleetdbapi.ComponentFirmwareVersion, destPath string) {
+	if s.state == nil {
+		return
+	}
+
+	if err := s.state.Record(destPath, firmware.Checksum); err != nil {
+		logMsg.WithError(err).Warn("Failed to record the synced firmware in the state file")
+	}
+}
+
+// builtBeforeSince returns true when the firmware manifest build date is before the since date.
+func (s *Syncer) builtBeforeSince(logMsg *logrus.Entry, firmware *fleetdbapi.ComponentFirmwareVersion) bool {
+	if s.since.IsZero() {
+		return false
+	}
+
+	buildDate, err := config.ParseBuildDate(s.buildDates[firmware.UpstreamURL])
+	if err != nil {
+		logMsg.WithError(err).Warn("Unparseable firmware build date, the firmware is synced regardless of the since date")
+		return false
+	}
+
+	return buildDate.Before(s.since)
+}
+
+// transferFirmware downloads the firmware, verifies it and uploads it to destPath on the destination fs,
+// the size of the firmware is returned, as declared in the manifest for server-side copies.
+func (s *Syncer) transferFirmware(
+	ctx context.Context,
+	logMsg *logrus.Entry,
+	firmware, published *fleetdbapi.ComponentFirmwareVersion,
+	destPath string,
+) (int64, error) {
+	// the checksums let the object be verified without downloading it again,
+	// and the original filename of sanitized firmware is recoverable from the object
+	metadata := ChecksumMetadata(s.firmwareChecksums(firmware)...)
+	metadata.Merge(OriginalFilenameMetadata(firmware.Filename, published.Filename))
+
+	// server-side copies only reach the destination fs, and aren't compressed
+	if s.serverSideCopy && len(s.mirrors) == 0 && !s.compressUploads &&
+		s.copyServerSide(ctx, logMsg, firmware, destPath, metadata) {
+		return s.expectedSizes[firmware.UpstreamURL], nil
+	}
+
+	downloadDir, err := os.MkdirTemp(s.tmpFs.Root(), DownloadDirPrefix)
+	if err != nil {
+		return 0, errors.Wrap(err, "failure creating download directory")
+	}
+
+	defer func() {
+		if err = os.RemoveAll(downloadDir); err != nil {
+			logMsg.WithError(err).Error("Failure to clean up download directory")
 		}
+	}()
+
+	if err = checkAvailableSpace(downloadDir, s.expectedSizes[firmware.UpstreamURL]); err != nil {
+		return 0, err
+	}
+
+	progressCtx, stats, releaseStats := withStatsGroup(ctx)
+	defer releaseStats()
+
+	stopProgress := trackProgress(ctx, logMsg, stats, s.progressInterval)
 
-		if err = s.uploadFile(ctx, firmwareFilePath, destPath); err != nil {
-			msg := fmt.Sprintf("failure to upload firmware %s", firmware.Filename)
-			return errors.Wrap(err, msg)
+	defer func() {
+		stopProgress()
+		s.recordTransferStats(firmware, stats)
+	}()
+
+	firmwareFile, err := s.downloadFirmware(progressCtx, logMsg, downloadDir, firmware)
+	if err != nil {
+		return 0, err
+	}
+
+	if firmwareFile.ArchiveSizes != nil {
+		metadata.Merge(s.recordArchiveSizes(logMsg, firmware, *firmwareFile.ArchiveSizes))
+	}
+
+	firmwareFilePath, err := s.renameSanitized(firmwareFile.Path, published)
+	if err != nil {
+		return 0, err
+	}
+
+	info, err := os.Stat(firmwareFilePath)
+	if err != nil {
+		return 0, err
+	}
+
+	if err = s.uploadFirmware(progressCtx, firmware, firmwareFilePath, destPath, metadata); err != nil {
+		msg := fmt.Sprintf("failure to upload firmware %s", firmware.Filename)
+		return 0, errors.Wrap(err, msg)
+	}
+
+	if s.compressUploads {
+		if err = s.uploadCompressed(progressCtx, logMsg, firmwareFilePath, destPath, metadata); err != nil {
+			return 0, err
 		}
 	}
 
+	return info.Size(), nil
+}
+
+// publish publishes the firmware to inventory.
+func (s *Syncer) publish(ctx context.Context, firmware *fleetdbapi.ComponentFirmwareVersion) (err error) {
+	ctx, span := startSpan(ctx, SpanPublishFirmware, firmware)
+	defer func() { endSpan(span, err) }()
+
 	return s.inventory.Publish(ctx, firmware)
 }
 
-func (s *Syncer) uploadFile(ctx context.Context, firmwarePath, destPath string) error {
-	// Remove root of tmpdir from filename since CopyFile doesn't use it
-	firmwareRelativePath := strings.Replace(firmwarePath, s.tmpFs.Root(), "", 1)
+// recordTransferStats records the bytes, transfers and errors accounted for the firmware transfer.
+func (s *Syncer) recordTransferStats(firmware *fleetdbapi.ComponentFirmwareVersion, stats *accounting.StatsInfo) {
+	s.metrics.FromStats(stats)
+
+	labels := metrics.UpdateSyncLabels(firmware.Vendor, ActionSync)
+	metrics.SyncBytesCounter.With(labels).Add(float64(stats.GetBytes()))
+	metrics.SyncObjectsCounter.With(labels).Add(float64(stats.GetTransfers()))
+	metrics.SyncErrorsCounter.With(labels).Add(float64(stats.GetErrors()))
+}
+
+// Metrics returns the bytes, transfers and errors accumulated by the firmware transfers of the Syncer.
+func (s *Syncer) Metrics() *Metrics {
+	return s.metrics
+}
+
+// downloadFirmware downloads the firmware into downloadDir and verifies it,
+// the archive the firmware couldn't be extracted from is quarantined when a quarantine directory is set.
+func (s *Syncer) downloadFirmware(
+	ctx context.Context,
+	logMsg *logrus.Entry,
+	downloadDir string,
+	firmware *fleetdbapi.ComponentFirmwareVersion,
+) (*FirmwareFile, error) {
+	ctx = withSourceHeaders(withMaxFileSize(ctx, s.maxFileSize), s.sourceHeaders)
+	ctx = withProxy(withGitFileURLs(ctx, s.gitFileURLs), s.proxy)
+	ctx = withWebDAVCredentials(withFTPCredentials(ctx, s.ftpCredentials), s.webdavCredentials)
+
+	spanCtx, span := startSpan(ctx, SpanDownloadFirmware, firmware)
+
+	firmwareFile, err := s.downloader.Download(spanCtx, downloadDir, firmware)
+	if err == nil {
+		setSizeAttribute(span, firmwareFile.Path)
+	}
+
+	endSpan(span, err)
+
+	if err != nil {
+		if errors.Is(err, ErrFileTooLarge) {
+			return nil, err
+		}
+
+		// the file failing the checksum validation in the downloader is gone, only the expected checksum is known
+		if errors.Is(err, ErrChecksumValidate) {
+			s.checksumMismatch(logMsg, firmware, firmware.Checksum, "")
+		}
+
+		var archiveErr *ArchiveError
+		if s.quarantineDir != "" && errors.As(err, &archiveErr) {
+			return nil, s.quarantine(logMsg, firmware, archiveErr)
+		}
+
+		return nil, errors.Wrap(err, "failure downloading firmware")
+	}
+
+	if err = DetectCaptivePortal(firmwareFile.Path); err != nil {
+		return nil, err
+	}
+
+	if err = s.validateChecksums(logMsg, firmwareFile.Path, firmware); err != nil {
+		return nil, err
+	}
+
+	return firmwareFile, nil
+}
+
+// renameSanitized renames the downloaded firmware to its sanitized filename, returning the firmware path.
+func (s *Syncer) renameSanitized(firmwareFilePath string, published *fleetdbapi.ComponentFirmwareVersion) (string, error) {
+	if s.sanitizer == nil || filepath.Base(firmwareFilePath) == published.Filename {
+		return firmwareFilePath, nil
+	}
+
+	sanitizedPath := filepath.Join(filepath.Dir(firmwareFilePath), published.Filename)
+	if err := os.Rename(firmwareFilePath, sanitizedPath); err != nil {
+		return "", errors.Wrap(err, "failure renaming firmware to sanitized filename")
+	}
+
+	return sanitizedPath, nil
+}
+
+// copyServerSide copies the firmware to destPath without downloading it when the downloader supports it,
+// setting the given metadata on the copy.
+// False is returned when the firmware has to be downloaded and verified instead.
+func (s *Syncer) copyServerSide(
+	ctx context.Context,
+	logMsg *logrus.Entry,
+	firmware *fleetdbapi.ComponentFirmwareVersion,
+	destPath string,
+	metadata fs.Metadata,
+) bool {
+	copier, ok := s.downloader.(ServerSideCopier)
+	if !ok {
+		return false
+	}
+
+	copied, err := copier.ServerSideCopy(withMetadata(ctx, metadata), s.dstFs, destPath, firmware)
+	if err != nil {
+		logMsg.WithError(err).Warn("Server-side copy failed, falling back to download")
+		return false
+	}
+
+	if copied {
+		logMsg.Info("Copied firmware server-side")
+	}
+
+	return copied
+}
+
+// audit writes the audit record of the firmware synced to destPath, failures are logged since the firmware was synced.
+func (s *Syncer) audit(
+	logMsg *logrus.Entry,
+	firmware *fleetdbapi.ComponentFirmwareVersion,
+	destPath string,
+	size int64,
+	duration time.Duration,
+) {
+	if s.auditLogger == nil {
+		return
+	}
+
+	repositoryURL, err := url.JoinPath(s.artifactsURL, destPath)
+	if err != nil {
+		logMsg.WithError(err).Error("Failed to build firmware URL for the audit record")
+		return
+	}
+
+	record := &audit.Record{
+		Time:            time.Now().UTC(),
+		Vendor:          firmware.Vendor,
+		Filename:        firmware.Filename,
+		Version:         firmware.Version,
+		UpstreamURL:     firmware.UpstreamURL,
+		RepositoryURL:   repositoryURL,
+		Checksum:        firmware.Checksum,
+		Bytes:           size,
+		DurationSeconds: duration.Seconds(),
+	}
+
+	if err = s.auditLogger.Log(record); err != nil {
+		logMsg.WithError(err).Error("Failed to write the firmware audit record")
+	}
+}
+
+// emitSynced publishes a KindFirmwareSynced event, failures are logged since the firmware was synced.
+func (s *Syncer) emitSynced(ctx context.Context, logMsg *logrus.Entry, firmware *fleetdbapi.ComponentFirmwareVersion, destPath string) {
+	if s.eventPublisher == nil {
+		return
+	}
+
+	firmwareURL, err := url.JoinPath(s.artifactsURL, destPath)
+	if err != nil {
+		logMsg.WithError(err).Error("Failed to build firmware URL for event")
+		return
+	}
+
+	event := &events.Event{
+		Kind:     events.KindFirmwareSynced,
+		Firmware: firmware,
+		URL:      firmwareURL,
+	}
+
+	if err = s.eventPublisher.Publish(ctx, event); err != nil {
+		logMsg.WithError(err).Error("Failed to publish firmware synced event")
+	}
+}
+
+// quarantine moves the archive the firmware couldn't be extracted from into the quarantine directory.
+func (s *Syncer) quarantine(logMsg *logrus.Entry, firmware *fleetdbapi.ComponentFirmwareVersion, archiveErr *ArchiveError) error {
+	quarantinedPath, err := quarantineArchive(s.quarantineDir, firmware.Vendor, archiveErr.ArchivePath)
+	if err != nil {
+		return errors.Wrap(err, "failure quarantining archive: "+archiveErr.Error())
+	}
+
+	metrics.QuarantinedArchiveCounter.With(metrics.ArchiveLabels(firmware.Vendor)).Inc()
+
+	logMsg.WithError(archiveErr.Err).
+		WithField("quarantinedPath", quarantinedPath).
+		Warn("Quarantined archive")
+
+	return errors.Wrap(ErrArchiveQuarantined, archiveErr.Error())
+}
+
+// skipOversized records the firmware was skipped for exceeding the maximum file size,
+// it's not published to inventory since it was never synced.
+func (s *Syncer) skipOversized(logMsg *logrus.Entry, firmware *fleetdbapi.ComponentFirmwareVersion, err error) {
+	metrics.OversizedFirmwareCounter.With(metrics.ArchiveLabels(firmware.Vendor)).Inc()
+	s.report.skipped()
+
+	logMsg.WithError(err).Warn("Skipped firmware larger than the maximum file size")
+}
+
+// sanitizeFirmware returns a copy of the firmware with its filename sanitized,
+// the firmware is returned as is when no FilenameSanitizer is configured.
+func (s *Syncer) sanitizeFirmware(firmware *fleetdbapi.ComponentFirmwareVersion) (*fleetdbapi.ComponentFirmwareVersion, error) {
+	if s.sanitizer == nil {
+		return firmware, nil
+	}
+
+	filename, err := s.sanitizer.Sanitize(firmware.Filename)
+	if err != nil {
+		return nil, err
+	}
+
+	sanitized := *firmware
+	sanitized.Filename = filename
+
+	return &sanitized, nil
+}
+
+// recordArchiveSizes records the archive and extracted firmware sizes as metrics,
+// flagging extreme size discrepancies, and returns the sizes as object metadata.
+func (s *Syncer) recordArchiveSizes(
+	logMsg *logrus.Entry,
+	firmware *fleetdbapi.ComponentFirmwareVersion,
+	sizes ArchiveSizes,
+) fs.Metadata {
+	labels := metrics.ArchiveLabels(firmware.Vendor)
+	metrics.ArchiveBytesCounter.With(labels).Add(float64(sizes.ArchiveBytes))
+	metrics.ExtractedBytesCounter.With(labels).Add(float64(sizes.ExtractedBytes))
+
+	logMsg = logMsg.WithField("archiveBytes", sizes.ArchiveBytes).
+		WithField("extractedBytes", sizes.ExtractedBytes)
+
+	if sizes.Suspicious() {
+		metrics.SuspiciousArchiveCounter.With(labels).Inc()
+		logMsg.WithField("ratio", sizes.Ratio()).
+			Warn("Extracted firmware size is out of proportion with the archive size, possible zip bomb or wrong file")
+	} else {
+		logMsg.Debug("Extracted firmware from archive")
+	}
+
+	return sizes.Metadata()
+}
+
+// uploadFirmware uploads the firmware file to the destPath on the destination fs, see uploadFile.
+func (s *Syncer) uploadFirmware(
+	ctx context.Context,
+	firmware *fleetdbapi.ComponentFirmwareVersion,
+	firmwarePath, destPath string,
+	metadata fs.Metadata,
+) (err error) {
+	ctx, span := startSpan(ctx, SpanUploadFirmware, firmware)
+	defer func() { endSpan(span, err) }()
+
+	span.SetAttributes(attribute.String("firmware.destination", destPath))
+	setSizeAttribute(span, firmwarePath)
+
+	return s.uploadFile(ctx, firmwarePath, destPath, metadata)
+}
+
+// uploadFile copies the firmware to the destPath on the destination fs and the mirrors,
+// the given metadata is set on the uploaded objects.
+func (s *Syncer) uploadFile(ctx context.Context, firmwarePath, destPath string, metadata fs.Metadata) error {
+	firmwareRelativePath, err := tmpRelativePath(s.tmpFs.Root(), firmwarePath)
+	if err != nil {
+		return err
+	}
+
+	ctx = withMetadata(ctx, metadata)
+
+	if err = s.copyToDst(ctx, firmwarePath, firmwareRelativePath, destPath); err != nil {
+		return err
+	}
+
+	if len(s.mirrors) == 0 {
+		return nil
+	}
+
+	return s.uploadMirrors(ctx, firmwareRelativePath, destPath)
+}
+
+// withMetadata returns a context the objects copied with are set the given metadata on.
+func withMetadata(ctx context.Context, metadata fs.Metadata) context.Context {
+	if len(metadata) == 0 {
+		return ctx
+	}
+
+	ctx, ci := fs.AddConfig(ctx)
+	ci.Metadata = true
+	ci.MetadataSet = metadata
+
+	return ctx
+}
+
+// tmpRelativePath returns the path of the firmware relative to the root of the tmp fs, as CopyFile expects.
+// Paths outside the root are refused rather than trimmed, so a sibling directory sharing the root as a prefix
+// doesn't resolve to another file under the root.
+func tmpRelativePath(root, firmwarePath string) (string, error) {
+	relativePath, err := filepath.Rel(root, firmwarePath)
+	if err != nil || relativePath == ".." || strings.HasPrefix(relativePath, ".."+string(filepath.Separator)) {
+		return "", errors.Wrap(ErrUploadPath, fmt.Sprintf("%s isn't under %s", firmwarePath, root))
+	}
+
+	return relativePath, nil
+}
+
+// copyToDst copies the firmware to the destPath on the destination fs, and verifies the uploaded object
+// when upload verification is enabled: a corrupt object is removed and uploaded once more.
+func (s *Syncer) copyToDst(ctx context.Context, firmwarePath, firmwareRelativePath, destPath string) error {
+	if err := operations.CopyFile(ctx, s.dstFs, s.tmpFs, destPath, firmwareRelativePath); err != nil {
+		return err
+	}
+
+	if !s.verifyUpload {
+		return nil
+	}
+
+	obj, err := s.verifyUploaded(ctx, firmwarePath, destPath)
+	if !errors.Is(err, ErrVerifyMismatch) {
+		return err
+	}
+
+	s.logger.WithError(err).WithField("destPath", destPath).Warn("Uploaded firmware is corrupt, uploading it again")
+
+	// the corrupt object is removed first, rclone would skip a copy over an object of the same size and modtime
+	if err = obj.Remove(ctx); err != nil {
+		return err
+	}
+
+	if err = operations.CopyFile(ctx, s.dstFs, s.tmpFs, destPath, firmwareRelativePath); err != nil {
+		return err
+	}
+
+	_, err = s.verifyUploaded(ctx, firmwarePath, destPath)
+
+	return err
+}
+
+// firmwareChecksums returns the firmware checksum followed by every other checksum declared for it,
+// in the <hint>:<checksum> format.
+func (s *Syncer) firmwareChecksums(firmware *fleetdbapi.ComponentFirmwareVersion) []string {
+	checksums := []string{firmware.Checksum}
+
+	declared := s.checksums[firmware.UpstreamURL]
+
+	hints := make([]string, 0, len(declared))
+	for hint := range declared {
+		hints = append(hints, hint)
+	}
+
+	sort.Strings(hints)
+
+	for _, hint := range hints {
+		checksum := hint + ":" + declared[hint]
+		if checksum != firmware.Checksum {
+			checksums = append(checksums, checksum)
+		}
+	}
+
+	return checksums
+}
+
+// validateChecksums validates the file against the firmware checksum and every other checksum declared for it,
+// failing on the first mismatch.
+func (s *Syncer) validateChecksums(logMsg *logrus.Entry, file string, firmware *fleetdbapi.ComponentFirmwareVersion) error {
+	for _, checksum := range s.firmwareChecksums(firmware) {
+		if err := validateChecksum(file, checksum); err != nil {
+			s.checksumMismatch(logMsg, firmware, checksum, computeChecksum(file, checksum))
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checksumMismatch logs the expected and computed checksums of the firmware and counts the mismatch,
+// a burst of mismatches points to a corrupt manifest or mirror.
+func (s *Syncer) checksumMismatch(logMsg *logrus.Entry, firmware *fleetdbapi.ComponentFirmwareVersion, expected, computed string) {
+	metrics.ChecksumMismatchCounter.With(metrics.ArchiveLabels(firmware.Vendor)).Inc()
 
-	return operations.CopyFile(ctx, s.dstFs, s.tmpFs, destPath, firmwareRelativePath)
+	logMsg.WithField("expectedChecksum", expected).
+		WithField("computedChecksum", computed).
+		Warn("Firmware checksum mismatch")
 }
 
 func validateChecksum(file, checksum string) error {
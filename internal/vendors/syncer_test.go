@@ -1,22 +1,39 @@
 package vendors
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
 	fleetdbapi "github.com/metal-toolbox/fleetdb/pkg/api/v1"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rclone/rclone/backend/memory"
 	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/fs/config/configmap"
+	"github.com/rclone/rclone/fs/operations"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/mock/gomock"
 
+	"github.com/metal-toolbox/firmware-syncer/internal/audit"
+	"github.com/metal-toolbox/firmware-syncer/internal/config"
+	"github.com/metal-toolbox/firmware-syncer/internal/events"
+	mockevents "github.com/metal-toolbox/firmware-syncer/internal/events/mocks"
 	mockinventory "github.com/metal-toolbox/firmware-syncer/internal/inventory/mocks"
 	"github.com/metal-toolbox/firmware-syncer/internal/logging"
+	"github.com/metal-toolbox/firmware-syncer/internal/metrics"
 	mockvendors "github.com/metal-toolbox/firmware-syncer/internal/vendors/mocks"
 )
 
@@ -103,7 +120,7 @@ func TestSyncer(t *testing.T) {
 
 			mockDstFs := mockvendors.NewMockRCloneFS(ctrl)
 			mockTmpFs := mockvendors.NewMockRCloneFS(ctrl)
-			mockDownloader := mockvendors.NewMockDownloader(ctrl)
+			mockDownloader := NewMockDownloader(ctrl)
 			obj := mockvendors.NewMockRCloneObject(ctrl)
 
 			if !tt.fileShouldExist {
@@ -111,28 +128,28 @@ func TestSyncer(t *testing.T) {
 					Download(ctx, MatchesRootDir(tmpDir), firmware).
 					Return(localPath, nil)
 
-				mockDstFs.EXPECT().NewObject(ctx, dstPath).Return(nil, fs.ErrorObjectNotFound)
+				mockDstFs.EXPECT().NewObject(gomock.Any(), dstPath).Return(nil, fs.ErrorObjectNotFound)
 
 				info := mockvendors.NewMockRCloneInfo(ctrl)
 				info.EXPECT().Precision().Return(time.Duration(0)).AnyTimes()
 
 				obj.EXPECT().Size().Return(int64(0)).AnyTimes()
-				obj.EXPECT().ModTime(ctx).Return(time.Now()).AnyTimes()
+				obj.EXPECT().ModTime(gomock.Any()).Return(time.Now()).AnyTimes()
 				obj.EXPECT().Fs().Return(info).AnyTimes()
 				obj.EXPECT().String().Return("rclone-object").AnyTimes()
 
 				mockDstFs.EXPECT().Root()
 				mockDstFs.EXPECT().Name()
 
-				mockTmpFs.EXPECT().NewObject(ctx, localPath).Return(obj, nil)
+				mockTmpFs.EXPECT().NewObject(gomock.Any(), localPath).Return(obj, nil)
 				mockTmpFs.EXPECT().Root().Return(tmpDir).AnyTimes()
 				mockTmpFs.EXPECT().Name().Return("local").AnyTimes()
 			}
 
-			mockDstFs.EXPECT().NewObject(ctx, dstPath).Return(obj, nil).AnyTimes()
+			mockDstFs.EXPECT().NewObject(gomock.Any(), dstPath).Return(obj, nil).AnyTimes()
 
 			mockInventory := mockinventory.NewMockServerService(ctrl)
-			mockInventory.EXPECT().Publish(ctx, firmware)
+			mockInventory.EXPECT().Publish(gomock.Any(), firmware)
 
 			s := NewSyncer(
 				mockDstFs,
@@ -147,3 +164,853 @@ func TestSyncer(t *testing.T) {
 		})
 	}
 }
+
+func TestSyncerSanitizedFilename(t *testing.T) {
+	logger := logging.NewLogger("debug")
+	ctx := context.Background()
+
+	firmware := &fleetdbapi.ComponentFirmwareVersion{
+		UUID:        uuid.New(),
+		Vendor:      "foo-vendor",
+		Filename:    "foo bar (1).zip",
+		Version:     "v0.0.0",
+		Component:   "foo-component",
+		Checksum:    "79ec3cf629b56317111d5640b8df1220",
+		UpstreamURL: "vendor-url",
+	}
+
+	sanitized := *firmware
+	sanitized.Filename = "foo_bar__1_.zip"
+
+	ctrl := gomock.NewController(t)
+
+	mockDstFs := mockvendors.NewMockRCloneFS(ctrl)
+	mockTmpFs := mockvendors.NewMockRCloneFS(ctrl)
+	mockDownloader := NewMockDownloader(ctrl)
+	obj := mockvendors.NewMockRCloneObject(ctrl)
+
+	mockDstFs.EXPECT().NewObject(gomock.Any(), path.Join(firmware.Vendor, sanitized.Filename)).Return(obj, nil)
+
+	mockInventory := mockinventory.NewMockServerService(ctrl)
+	mockInventory.EXPECT().Publish(gomock.Any(), &sanitized)
+
+	s := NewSyncer(
+		mockDstFs,
+		mockTmpFs,
+		mockDownloader,
+		mockInventory,
+		[]*fleetdbapi.ComponentFirmwareVersion{firmware},
+		logger,
+		WithFilenameSanitizer(NewFilenameSanitizer()),
+	)
+
+	assert.NoError(t, s.Sync(ctx))
+	assert.Equal(t, "foo bar (1).zip", firmware.Filename)
+}
+
+func TestSyncerQuarantine(t *testing.T) {
+	logger := logging.NewLogger("debug")
+	ctx, ci := fs.AddConfig(context.Background())
+	ci.LowLevelRetries = 1
+
+	fixture, err := os.ReadFile(path.Join("fixtures", "foobar1.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/corrupt.zip" {
+			_, _ = w.Write([]byte("PK\x03\x04 definitely not a zip archive"))
+			return
+		}
+
+		_, _ = w.Write(fixture)
+	}))
+	defer server.Close()
+
+	corrupt := &fleetdbapi.ComponentFirmwareVersion{
+		Vendor:      "foo-vendor",
+		Filename:    "corrupt.bin",
+		UpstreamURL: server.URL + "/corrupt.zip",
+	}
+
+	missing := &fleetdbapi.ComponentFirmwareVersion{
+		Vendor:      "foo-vendor",
+		Filename:    "missing.bin",
+		UpstreamURL: server.URL + "/foobar1.zip",
+	}
+
+	existing := &fleetdbapi.ComponentFirmwareVersion{
+		Vendor:   "foo-vendor",
+		Filename: "existing.bin",
+	}
+
+	tmpDir := t.TempDir()
+	quarantineDir := t.TempDir()
+
+	ctrl := gomock.NewController(t)
+
+	mockDstFs := mockvendors.NewMockRCloneFS(ctrl)
+	mockTmpFs := mockvendors.NewMockRCloneFS(ctrl)
+	obj := mockvendors.NewMockRCloneObject(ctrl)
+
+	mockTmpFs.EXPECT().Root().Return(tmpDir).AnyTimes()
+	mockDstFs.EXPECT().NewObject(gomock.Any(), DstPath(corrupt)).Return(nil, fs.ErrorObjectNotFound)
+	mockDstFs.EXPECT().NewObject(gomock.Any(), DstPath(missing)).Return(nil, fs.ErrorObjectNotFound)
+	mockDstFs.EXPECT().NewObject(gomock.Any(), DstPath(existing)).Return(obj, nil)
+
+	// the bad archives are quarantined and the vendor sync continues
+	mockInventory := mockinventory.NewMockServerService(ctrl)
+	mockInventory.EXPECT().Publish(gomock.Any(), existing)
+
+	s := NewSyncer(
+		mockDstFs,
+		mockTmpFs,
+		NewArchiveDownloader(logger),
+		mockInventory,
+		[]*fleetdbapi.ComponentFirmwareVersion{corrupt, missing, existing},
+		logger,
+		WithQuarantine(quarantineDir),
+	)
+
+	assert.NoError(t, s.Sync(ctx))
+
+	assert.FileExists(t, filepath.Join(quarantineDir, "foo-vendor", "corrupt.zip"))
+	assert.FileExists(t, filepath.Join(quarantineDir, "foo-vendor", "foobar1.zip"))
+}
+
+func TestSyncerEvents(t *testing.T) {
+	logger := logging.NewLogger("debug")
+	ctx := context.Background()
+
+	dstFs, err := InitLocalFs(ctx, &LocalFsConfig{Root: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpFs, err := InitLocalFs(ctx, &LocalFsConfig{Root: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fixture, err := os.ReadFile(path.Join("fixtures", "foobar1.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newFirmware := &fleetdbapi.ComponentFirmwareVersion{
+		Vendor:   "foo-vendor",
+		Filename: "new.zip",
+		Checksum: "79ec3cf629b56317111d5640b8df1220", // real checksum of fixtures/foobar1.zip
+	}
+
+	existingFirmware := &fleetdbapi.ComponentFirmwareVersion{
+		Vendor:   "foo-vendor",
+		Filename: "existing.zip",
+		Checksum: "79ec3cf629b56317111d5640b8df1220",
+	}
+
+	// the existing firmware is already present on the destination and is skipped
+	existingPath := filepath.Join(dstFs.Root(), DstPath(existingFirmware))
+	if err = os.MkdirAll(filepath.Dir(existingPath), 0o750); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = os.WriteFile(existingPath, fixture, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	ctrl := gomock.NewController(t)
+
+	mockDownloader := NewMockDownloader(ctrl)
+	// the download context accounts the transfer progress in its own stats group
+	mockDownloader.EXPECT().Download(gomock.Any(), gomock.Any(), newFirmware).
+		DoAndReturn(func(_ context.Context, downloadDir string, firmware *fleetdbapi.ComponentFirmwareVersion) (*FirmwareFile, error) {
+			firmwarePath := filepath.Join(downloadDir, firmware.Filename)
+			return &FirmwareFile{Path: firmwarePath}, os.WriteFile(firmwarePath, fixture, 0o600)
+		})
+
+	mockInventory := mockinventory.NewMockServerService(ctrl)
+	mockInventory.EXPECT().Publish(gomock.Any(), newFirmware)
+	mockInventory.EXPECT().Publish(gomock.Any(), existingFirmware)
+
+	mockPublisher := mockevents.NewMockPublisher(ctrl)
+	mockPublisher.EXPECT().Publish(gomock.Any(), &events.Event{
+		Kind:     events.KindFirmwareSynced,
+		Firmware: newFirmware,
+		URL:      "https://example.com/artifacts/foo-vendor/new.zip",
+	})
+
+	s := NewSyncer(
+		dstFs,
+		tmpFs,
+		mockDownloader,
+		mockInventory,
+		[]*fleetdbapi.ComponentFirmwareVersion{newFirmware, existingFirmware},
+		logger,
+		WithEventPublisher(mockPublisher, "https://example.com/artifacts"),
+	)
+
+	assert.NoError(t, s.Sync(ctx))
+	assert.FileExists(t, filepath.Join(dstFs.Root(), DstPath(newFirmware)))
+}
+
+func TestSyncerAuditLog(t *testing.T) {
+	logger := logging.NewLogger("debug")
+	ctx := context.Background()
+
+	dstFs, err := InitLocalFs(ctx, &LocalFsConfig{Root: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpFs, err := InitLocalFs(ctx, &LocalFsConfig{Root: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fixture, err := os.ReadFile(path.Join("fixtures", "foobar1.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	firmware := &fleetdbapi.ComponentFirmwareVersion{
+		Vendor:      "foo-vendor",
+		Filename:    "foobar1.zip",
+		Version:     "1.0.0",
+		UpstreamURL: "https://example.com/foobar1.zip",
+		Checksum:    "md5sum:79ec3cf629b56317111d5640b8df1220",
+	}
+
+	ctrl := gomock.NewController(t)
+
+	mockDownloader := NewMockDownloader(ctrl)
+	mockDownloader.EXPECT().Download(gomock.Any(), gomock.Any(), firmware).
+		DoAndReturn(func(_ context.Context, downloadDir string, firmware *fleetdbapi.ComponentFirmwareVersion) (*FirmwareFile, error) {
+			firmwarePath := filepath.Join(downloadDir, firmware.Filename)
+			return &FirmwareFile{Path: firmwarePath}, os.WriteFile(firmwarePath, fixture, 0o600)
+		})
+
+	mockInventory := mockinventory.NewMockServerService(ctrl)
+	mockInventory.EXPECT().Publish(gomock.Any(), firmware).Times(2)
+
+	var auditLog bytes.Buffer
+
+	// the second sync finds the firmware on the destination, nothing is audited
+	for range 2 {
+		s := NewSyncer(
+			dstFs,
+			tmpFs,
+			mockDownloader,
+			mockInventory,
+			[]*fleetdbapi.ComponentFirmwareVersion{firmware},
+			logger,
+			WithAuditLogger(audit.NewLogger(&auditLog), "https://example.com/artifacts"),
+		)
+
+		assert.NoError(t, s.Sync(ctx))
+	}
+
+	lines := strings.Split(strings.TrimSpace(auditLog.String()), "\n")
+	if !assert.Len(t, lines, 1) {
+		return
+	}
+
+	var record audit.Record
+	if err = json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "foo-vendor", record.Vendor)
+	assert.Equal(t, "foobar1.zip", record.Filename)
+	assert.Equal(t, "1.0.0", record.Version)
+	assert.Equal(t, firmware.UpstreamURL, record.UpstreamURL)
+	assert.Equal(t, "https://example.com/artifacts/foo-vendor/foobar1.zip", record.RepositoryURL)
+	assert.Equal(t, firmware.Checksum, record.Checksum)
+	assert.Equal(t, int64(len(fixture)), record.Bytes)
+	assert.False(t, record.Time.IsZero())
+}
+
+func TestSyncerServerSideCopy(t *testing.T) {
+	logger := logging.NewLogger("debug")
+
+	fixture, err := os.ReadFile(path.Join("fixtures", "foobar1.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name             string
+		serverSideCopy   bool
+		compressUploads  bool
+		checksum         string
+		expectSynced     bool
+		expectServerSide int64
+	}{
+		{
+			name:             "checksums match",
+			serverSideCopy:   true,
+			checksum:         "79ec3cf629b56317111d5640b8df1220", // real checksum of fixtures/foobar1.zip
+			expectSynced:     true,
+			expectServerSide: 1,
+		},
+		{
+			name:         "server-side copy disabled",
+			checksum:     "79ec3cf629b56317111d5640b8df1220",
+			expectSynced: true,
+		},
+		{
+			name:           "checksums mismatch falls back to download and verify",
+			serverSideCopy: true,
+			checksum:       "00000000000000000000000000000000",
+		},
+		{
+			name:            "compressed uploads are downloaded to be compressed",
+			serverSideCopy:  true,
+			compressUploads: true,
+			checksum:        "79ec3cf629b56317111d5640b8df1220",
+			expectSynced:    true,
+		},
+	}
+
+	for i, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := accounting.WithStatsGroup(context.Background(), t.Name())
+
+			// memory remotes share their buckets, each test case gets its own
+			srcFs, err := memory.NewFs(ctx, "src", fmt.Sprintf("src-bucket-%d", i), configmap.Simple{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			dstFs, err := memory.NewFs(ctx, "dst", fmt.Sprintf("dst-bucket-%d", i), configmap.Simple{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tmpFs, err := InitLocalFs(ctx, &LocalFsConfig{Root: t.TempDir()})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = operations.Rcat(ctx, srcFs, "firmware/foobar1.zip", io.NopCloser(bytes.NewReader(fixture)), time.Now(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			firmware := &fleetdbapi.ComponentFirmwareVersion{
+				Vendor:      "asrockrack",
+				Filename:    "foobar1.zip",
+				Checksum:    tt.checksum,
+				UpstreamURL: "s3://src-bucket/firmware/foobar1.zip",
+			}
+
+			ctrl := gomock.NewController(t)
+
+			mockInventory := mockinventory.NewMockServerService(ctrl)
+			if tt.expectSynced {
+				mockInventory.EXPECT().Publish(gomock.Any(), firmware)
+			}
+
+			var opts []SyncerOption
+			if tt.serverSideCopy {
+				opts = append(opts, WithServerSideCopy())
+			}
+
+			if tt.compressUploads {
+				opts = append(opts, WithUploadCompression())
+			}
+
+			s := NewSyncer(
+				dstFs,
+				tmpFs,
+				NewS3Downloader(logger, srcFs),
+				mockInventory,
+				[]*fleetdbapi.ComponentFirmwareVersion{firmware},
+				logger,
+				opts...,
+			)
+
+			assert.NoError(t, s.Sync(ctx))
+
+			exists, err := fs.FileExists(ctx, dstFs, DstPath(firmware))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectSynced, exists)
+
+			stats, err := accounting.Stats(ctx).RemoteStats()
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectServerSide, stats["serverSideCopies"])
+		})
+	}
+}
+
+// serverSideDownloader is a Downloader able to copy the firmware server-side.
+type serverSideDownloader struct {
+	*MockDownloader
+	*MockServerSideCopier
+}
+
+func TestSyncerServerSideCopyMetadata(t *testing.T) {
+	firmware := &fleetdbapi.ComponentFirmwareVersion{
+		Vendor:      "asrockrack",
+		Filename:    "foo bar (1).zip",
+		Checksum:    "md5sum:79ec3cf629b56317111d5640b8df1220",
+		UpstreamURL: "s3://src-bucket/firmware/foo bar (1).zip",
+	}
+
+	ctrl := gomock.NewController(t)
+
+	// the server-side copy carries the metadata of uploaded firmware
+	mockCopier := NewMockServerSideCopier(ctrl)
+	mockCopier.EXPECT().ServerSideCopy(gomock.Any(), gomock.Any(), "asrockrack/foo_bar__1_.zip", firmware).
+		DoAndReturn(func(ctx context.Context, _ fs.Fs, _ string, _ *fleetdbapi.ComponentFirmwareVersion) (bool, error) {
+			ci := fs.GetConfig(ctx)
+			assert.True(t, ci.Metadata)
+			assert.Equal(t, "79ec3cf629b56317111d5640b8df1220", ci.MetadataSet["firmware-md5"])
+
+			original, ok := OriginalFilename(ci.MetadataSet)
+			assert.True(t, ok)
+			assert.Equal(t, "foo bar (1).zip", original)
+
+			return true, nil
+		})
+
+	mockInventory := mockinventory.NewMockServerService(ctrl)
+	mockInventory.EXPECT().Publish(gomock.Any(), gomock.Any())
+
+	s := NewSyncer(
+		newLocalFs(t),
+		newLocalFs(t),
+		&serverSideDownloader{MockDownloader: NewMockDownloader(ctrl), MockServerSideCopier: mockCopier},
+		mockInventory,
+		[]*fleetdbapi.ComponentFirmwareVersion{firmware},
+		logging.NewLogger("debug"),
+		WithServerSideCopy(),
+		WithFilenameSanitizer(NewFilenameSanitizer()),
+	)
+
+	assert.NoError(t, s.Sync(context.Background()))
+}
+
+func TestSyncerChecksums(t *testing.T) {
+	logger := logging.NewLogger("debug")
+	ctx := context.Background()
+
+	fixture, err := os.ReadFile(path.Join("fixtures", "foobar1.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name         string
+		checksums    map[string]string
+		expectSynced bool
+	}{
+		{
+			name: "all checksums match",
+			checksums: map[string]string{
+				"md5sum": "79ec3cf629b56317111d5640b8df1220", // real checksums of fixtures/foobar1.zip
+				"sha256": "ab36ec58c25098015b911bed7448b7d2506068a7362c28993fd1182e59710dae",
+			},
+			expectSynced: true,
+		},
+		{
+			name: "one checksum mismatches",
+			checksums: map[string]string{
+				"md5sum": "79ec3cf629b56317111d5640b8df1220",
+				"sha256": "0000000000000000000000000000000000000000000000000000000000000000",
+			},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			dstFs, err := InitLocalFs(ctx, &LocalFsConfig{Root: t.TempDir()})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tmpFs, err := InitLocalFs(ctx, &LocalFsConfig{Root: t.TempDir()})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			firmware := &fleetdbapi.ComponentFirmwareVersion{
+				Vendor:      "foo-vendor",
+				Filename:    "foobar1.zip",
+				UpstreamURL: "https://example.com/foobar1.zip",
+				Checksum:    "md5sum:79ec3cf629b56317111d5640b8df1220",
+			}
+
+			ctrl := gomock.NewController(t)
+
+			mockDownloader := NewMockDownloader(ctrl)
+			mockDownloader.EXPECT().Download(gomock.Any(), gomock.Any(), firmware).
+				DoAndReturn(func(_ context.Context, downloadDir string, firmware *fleetdbapi.ComponentFirmwareVersion) (*FirmwareFile, error) {
+					firmwarePath := filepath.Join(downloadDir, firmware.Filename)
+					return &FirmwareFile{Path: firmwarePath}, os.WriteFile(firmwarePath, fixture, 0o600)
+				})
+
+			// firmware failing validation is not published
+			mockInventory := mockinventory.NewMockServerService(ctrl)
+			if tt.expectSynced {
+				mockInventory.EXPECT().Publish(gomock.Any(), firmware)
+			}
+
+			s := NewSyncer(
+				dstFs,
+				tmpFs,
+				mockDownloader,
+				mockInventory,
+				[]*fleetdbapi.ComponentFirmwareVersion{firmware},
+				logger,
+				WithChecksums(config.FirmwareChecksums{firmware.UpstreamURL: tt.checksums}),
+			)
+
+			mismatches := metrics.ChecksumMismatchCounter.With(metrics.ArchiveLabels(firmware.Vendor))
+			mismatchesBefore := testutil.ToFloat64(mismatches)
+
+			assert.NoError(t, s.Sync(ctx))
+
+			if tt.expectSynced {
+				assert.FileExists(t, filepath.Join(dstFs.Root(), DstPath(firmware)))
+				assert.Equal(t, mismatchesBefore, testutil.ToFloat64(mismatches))
+
+				return
+			}
+
+			assert.NoFileExists(t, filepath.Join(dstFs.Root(), DstPath(firmware)))
+			assert.Equal(t, mismatchesBefore+1, testutil.ToFloat64(mismatches))
+		})
+	}
+}
+
+func TestSyncerChecksumMetadata(t *testing.T) {
+	logger := logging.NewLogger("debug")
+	ctx := context.Background()
+
+	dstFs, err := InitLocalFs(ctx, &LocalFsConfig{Root: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the local backend stores user metadata as extended attributes
+	if !dstFs.Features().UserMetadata {
+		t.Skip("the filesystem doesn't support extended attributes")
+	}
+
+	tmpFs, err := InitLocalFs(ctx, &LocalFsConfig{Root: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fixture, err := os.ReadFile(path.Join("fixtures", "foobar1.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	firmware := &fleetdbapi.ComponentFirmwareVersion{
+		Vendor:      "foo-vendor",
+		Filename:    "foobar1.zip",
+		UpstreamURL: "https://example.com/foobar1.zip",
+		Checksum:    "md5sum:79ec3cf629b56317111d5640b8df1220", // real checksums of fixtures/foobar1.zip
+	}
+
+	ctrl := gomock.NewController(t)
+
+	mockDownloader := NewMockDownloader(ctrl)
+	mockDownloader.EXPECT().Download(gomock.Any(), gomock.Any(), firmware).
+		DoAndReturn(func(_ context.Context, downloadDir string, firmware *fleetdbapi.ComponentFirmwareVersion) (*FirmwareFile, error) {
+			firmwarePath := filepath.Join(downloadDir, firmware.Filename)
+			return &FirmwareFile{Path: firmwarePath}, os.WriteFile(firmwarePath, fixture, 0o600)
+		})
+
+	mockInventory := mockinventory.NewMockServerService(ctrl)
+	mockInventory.EXPECT().Publish(gomock.Any(), firmware)
+
+	s := NewSyncer(
+		dstFs,
+		tmpFs,
+		mockDownloader,
+		mockInventory,
+		[]*fleetdbapi.ComponentFirmwareVersion{firmware},
+		logger,
+		WithChecksums(config.FirmwareChecksums{
+			firmware.UpstreamURL: {"sha256": "ab36ec58c25098015b911bed7448b7d2506068a7362c28993fd1182e59710dae"},
+		}),
+	)
+
+	assert.NoError(t, s.Sync(ctx))
+
+	obj, err := dstFs.NewObject(ctx, DstPath(firmware))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metadata, err := fs.GetMetadata(ctx, obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "79ec3cf629b56317111d5640b8df1220", metadata["firmware-md5"])
+	assert.Equal(t, "ab36ec58c25098015b911bed7448b7d2506068a7362c28993fd1182e59710dae", metadata["firmware-sha256"])
+}
+
+func TestSyncerSanitizedFilenameMetadata(t *testing.T) {
+	ctx := context.Background()
+	tmpFs, dstFs := newLocalFs(t), newLocalFs(t)
+
+	// the local backend stores user metadata as extended attributes
+	if !dstFs.Features().UserMetadata {
+		t.Skip("the filesystem doesn't support extended attributes")
+	}
+
+	fixture, err := os.ReadFile(path.Join("fixtures", "foobar1.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	firmware := &fleetdbapi.ComponentFirmwareVersion{
+		Vendor:      "foo-vendor",
+		Filename:    "foo bar (1).zip",
+		UpstreamURL: "https://example.com/foobar1.zip",
+		Checksum:    "md5sum:79ec3cf629b56317111d5640b8df1220",
+	}
+
+	ctrl := gomock.NewController(t)
+
+	mockDownloader := NewMockDownloader(ctrl)
+	mockDownloader.EXPECT().Download(gomock.Any(), gomock.Any(), firmware).
+		DoAndReturn(func(_ context.Context, downloadDir string, firmware *fleetdbapi.ComponentFirmwareVersion) (*FirmwareFile, error) {
+			firmwarePath := filepath.Join(downloadDir, firmware.Filename)
+			return &FirmwareFile{Path: firmwarePath}, os.WriteFile(firmwarePath, fixture, 0o600)
+		})
+
+	mockInventory := mockinventory.NewMockServerService(ctrl)
+	mockInventory.EXPECT().Publish(gomock.Any(), gomock.Any())
+
+	s := NewSyncer(
+		dstFs,
+		tmpFs,
+		mockDownloader,
+		mockInventory,
+		[]*fleetdbapi.ComponentFirmwareVersion{firmware},
+		logging.NewLogger("debug"),
+		WithFilenameSanitizer(NewFilenameSanitizer()),
+	)
+
+	assert.NoError(t, s.Sync(ctx))
+
+	obj, err := dstFs.NewObject(ctx, "foo-vendor/foo_bar__1_.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metadata, err := fs.GetMetadata(ctx, obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	original, ok := OriginalFilename(metadata)
+	assert.True(t, ok)
+	assert.Equal(t, "foo bar (1).zip", original)
+}
+
+func TestSyncerArchiveSizesMetadata(t *testing.T) {
+	ctx := context.Background()
+	tmpFs, dstFs := newLocalFs(t), newLocalFs(t)
+
+	// the local backend stores user metadata as extended attributes
+	if !dstFs.Features().UserMetadata {
+		t.Skip("the filesystem doesn't support extended attributes")
+	}
+
+	fixture, err := os.ReadFile(path.Join("fixtures", "foobar1.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	firmware := &fleetdbapi.ComponentFirmwareVersion{
+		Vendor:      "foo-vendor",
+		Filename:    "foobar1.zip",
+		UpstreamURL: "https://example.com/foobar1.zip",
+		Checksum:    "md5sum:79ec3cf629b56317111d5640b8df1220",
+	}
+
+	ctrl := gomock.NewController(t)
+
+	// the sizes of the archive the firmware was extracted from come with the downloaded file
+	mockDownloader := NewMockDownloader(ctrl)
+	mockDownloader.EXPECT().Download(gomock.Any(), gomock.Any(), firmware).
+		DoAndReturn(func(_ context.Context, downloadDir string, firmware *fleetdbapi.ComponentFirmwareVersion) (*FirmwareFile, error) {
+			firmwarePath := filepath.Join(downloadDir, firmware.Filename)
+			sizes := &ArchiveSizes{ArchiveBytes: 100, ExtractedBytes: int64(len(fixture))}
+
+			return &FirmwareFile{Path: firmwarePath, ArchiveSizes: sizes}, os.WriteFile(firmwarePath, fixture, 0o600)
+		})
+
+	mockInventory := mockinventory.NewMockServerService(ctrl)
+	mockInventory.EXPECT().Publish(gomock.Any(), firmware)
+
+	s := NewSyncer(
+		dstFs,
+		tmpFs,
+		mockDownloader,
+		mockInventory,
+		[]*fleetdbapi.ComponentFirmwareVersion{firmware},
+		logging.NewLogger("debug"),
+	)
+
+	assert.NoError(t, s.Sync(ctx))
+
+	obj, err := dstFs.NewObject(ctx, DstPath(firmware))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metadata, err := fs.GetMetadata(ctx, obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "100", metadata[MetadataArchiveSize])
+	assert.Equal(t, strconv.Itoa(len(fixture)), metadata[MetadataExtractedSize])
+}
+
+func TestSyncerTransferStats(t *testing.T) {
+	logger := logging.NewLogger("debug")
+	ctx := context.Background()
+
+	testCases := []struct {
+		fixture  string
+		checksum string
+	}{
+		{fixture: "foobar1.zip", checksum: "md5sum:79ec3cf629b56317111d5640b8df1220"},
+		{fixture: "foobar2.zip", checksum: "md5sum:f42bea0f92e4d8b1f871381ff757ae22"},
+	}
+
+	// each sync accounts its own transfers, whatever the syncs before it transferred
+	for _, tt := range testCases {
+		dstFs, err := InitLocalFs(ctx, &LocalFsConfig{Root: t.TempDir()})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tmpFs, err := InitLocalFs(ctx, &LocalFsConfig{Root: t.TempDir()})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		fixture, err := os.ReadFile(path.Join("fixtures", tt.fixture))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		firmware := &fleetdbapi.ComponentFirmwareVersion{
+			Vendor:      "foo-vendor",
+			Filename:    tt.fixture,
+			UpstreamURL: "https://example.com/" + tt.fixture,
+			Checksum:    tt.checksum,
+		}
+
+		ctrl := gomock.NewController(t)
+
+		mockDownloader := NewMockDownloader(ctrl)
+		mockDownloader.EXPECT().Download(gomock.Any(), gomock.Any(), firmware).
+			DoAndReturn(func(_ context.Context, downloadDir string, firmware *fleetdbapi.ComponentFirmwareVersion) (*FirmwareFile, error) {
+				firmwarePath := filepath.Join(downloadDir, firmware.Filename)
+				return &FirmwareFile{Path: firmwarePath}, os.WriteFile(firmwarePath, fixture, 0o600)
+			})
+
+		mockInventory := mockinventory.NewMockServerService(ctrl)
+		mockInventory.EXPECT().Publish(gomock.Any(), firmware)
+
+		s := NewSyncer(dstFs, tmpFs, mockDownloader, mockInventory, []*fleetdbapi.ComponentFirmwareVersion{firmware}, logger)
+
+		assert.NoError(t, s.Sync(ctx))
+
+		values := s.(*Syncer).Metrics().GetAllInt64Values()
+		assert.Equal(t, int64(len(fixture)), values[MetricTransferredBytes], tt.fixture)
+		assert.Equal(t, int64(1), values[MetricTransferredObjects], tt.fixture)
+		assert.Zero(t, values[MetricErrorsCount], tt.fixture)
+	}
+}
+
+func TestSyncerSince(t *testing.T) {
+	since := time.Date(2023, 3, 15, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name      string
+		buildDate string
+		skipped   bool
+	}{
+		{name: "built the day before", buildDate: "03/14/2023", skipped: true},
+		{name: "built on the since date", buildDate: "03/15/2023"},
+		{name: "built the day after", buildDate: "2023-03-16"},
+		{name: "unparseable build date", buildDate: "Q1 2023"},
+		{name: "no build date"},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			firmware := &fleetdbapi.ComponentFirmwareVersion{
+				Vendor:      "foo-vendor",
+				Filename:    "foobar1.zip",
+				UpstreamURL: "https://example.com/foobar1.zip",
+			}
+
+			buildDates := config.FirmwareBuildDates{}
+			if tt.buildDate != "" {
+				buildDates[firmware.UpstreamURL] = tt.buildDate
+			}
+
+			s := &Syncer{logger: logging.NewLogger("debug")}
+			WithSince(since, buildDates)(s)
+
+			assert.Equal(t, tt.skipped, s.builtBeforeSince(s.logger.WithField("firmware", firmware.Filename), firmware))
+		})
+	}
+
+	// skipped firmware isn't looked up on the destination nor published
+	ctrl := gomock.NewController(t)
+
+	old := &fleetdbapi.ComponentFirmwareVersion{Vendor: "foo-vendor", Filename: "old.bin", UpstreamURL: "https://example.com/old.bin"}
+
+	s := NewSyncer(
+		mockvendors.NewMockRCloneFS(ctrl),
+		mockvendors.NewMockRCloneFS(ctrl),
+		NewMockDownloader(ctrl),
+		mockinventory.NewMockServerService(ctrl),
+		[]*fleetdbapi.ComponentFirmwareVersion{old},
+		logging.NewLogger("debug"),
+		WithSince(since, config.FirmwareBuildDates{old.UpstreamURL: "01/02/2020"}),
+	)
+
+	assert.NoError(t, s.Sync(context.Background()))
+}
+
+func Test_tmpRelativePath(t *testing.T) {
+	testCases := []struct {
+		name         string
+		firmwarePath string
+		expected     string
+		err          error
+	}{
+		{"under the root", "/tmp/work/firmware-download1/dell-firmware.bin-2/fw.bin", "firmware-download1/dell-firmware.bin-2/fw.bin", nil},
+		{"sibling sharing the root prefix", "/tmp/workdir/firmware.bin", "", ErrUploadPath},
+		{"outside the root", "/var/firmware.bin", "", ErrUploadPath},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			relativePath, err := tmpRelativePath("/tmp/work", tt.firmwarePath)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, relativePath)
+		})
+	}
+}
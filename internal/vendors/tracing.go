@@ -0,0 +1,48 @@
+package vendors
+
+import (
+	"context"
+	"os"
+
+	fleetdbapi "github.com/metal-toolbox/fleetdb/pkg/api/v1"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/metal-toolbox/firmware-syncer/internal/vendors"
+
+// Span names of the firmware sync steps
+const (
+	SpanSyncFirmware     = "firmware.sync"
+	SpanDownloadFirmware = "firmware.download"
+	SpanUploadFirmware   = "firmware.upload"
+	SpanPublishFirmware  = "firmware.publish"
+)
+
+// startSpan starts a span of the firmware sync, a child of the span in ctx, with the firmware vendor and filename attributes.
+func startSpan(ctx context.Context, name string, firmware *fleetdbapi.ComponentFirmwareVersion) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(
+		attribute.String("firmware.vendor", firmware.Vendor),
+		attribute.String("firmware.filename", firmware.Filename),
+		attribute.String("firmware.version", firmware.Version),
+	))
+}
+
+// setSizeAttribute sets the size of the file at filePath on the span, the attribute is left out when the file can't be stat'd.
+func setSizeAttribute(span trace.Span, filePath string) {
+	if info, err := os.Stat(filePath); err == nil {
+		span.SetAttributes(attribute.Int64("firmware.size", info.Size()))
+	}
+}
+
+// endSpan ends the span, flagging it as failed with the error when err is not nil.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
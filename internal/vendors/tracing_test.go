@@ -0,0 +1,148 @@
+package vendors
+
+import (
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	fleetdbapi "github.com/metal-toolbox/fleetdb/pkg/api/v1"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	"go.uber.org/mock/gomock"
+
+	mockinventory "github.com/metal-toolbox/firmware-syncer/internal/inventory/mocks"
+	"github.com/metal-toolbox/firmware-syncer/internal/logging"
+)
+
+// spanRecorder is a TracerProvider recording the spans started with it
+type spanRecorder struct {
+	noop.TracerProvider
+	mutex sync.Mutex
+	spans []*recordedSpan
+}
+
+type recordedSpan struct {
+	noop.Span
+	name       string
+	parent     string
+	attributes map[attribute.Key]attribute.Value
+	ended      bool
+}
+
+type recordingTracer struct {
+	noop.Tracer
+	recorder *spanRecorder
+}
+
+func (r *spanRecorder) Tracer(string, ...trace.TracerOption) trace.Tracer {
+	return &recordingTracer{recorder: r}
+}
+
+func (t *recordingTracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	span := &recordedSpan{name: name, attributes: make(map[attribute.Key]attribute.Value)}
+	cfg := trace.NewSpanStartConfig(opts...)
+	span.SetAttributes(cfg.Attributes()...)
+
+	if parent, ok := trace.SpanFromContext(ctx).(*recordedSpan); ok {
+		span.parent = parent.name
+	}
+
+	t.recorder.mutex.Lock()
+	t.recorder.spans = append(t.recorder.spans, span)
+	t.recorder.mutex.Unlock()
+
+	return trace.ContextWithSpan(ctx, span), span
+}
+
+func (s *recordedSpan) SetAttributes(attributes ...attribute.KeyValue) {
+	for _, kv := range attributes {
+		s.attributes[kv.Key] = kv.Value
+	}
+}
+
+func (s *recordedSpan) End(...trace.SpanEndOption) {
+	s.ended = true
+}
+
+func TestSyncerSpans(t *testing.T) {
+	recorder := &spanRecorder{}
+	otel.SetTracerProvider(recorder)
+
+	t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+
+	logger := logging.NewLogger("debug")
+
+	fixture, err := os.ReadFile(path.Join("fixtures", "foobar1.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dstFs, err := InitLocalFs(context.Background(), &LocalFsConfig{Root: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpFs, err := InitLocalFs(context.Background(), &LocalFsConfig{Root: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	firmware := &fleetdbapi.ComponentFirmwareVersion{
+		Vendor:      "foo-vendor",
+		Filename:    "foobar1.zip",
+		UpstreamURL: "https://example.com/foobar1.zip",
+		Checksum:    "md5sum:79ec3cf629b56317111d5640b8df1220",
+	}
+
+	ctrl := gomock.NewController(t)
+
+	mockDownloader := NewMockDownloader(ctrl)
+	mockDownloader.EXPECT().Download(gomock.Any(), gomock.Any(), firmware).
+		DoAndReturn(func(_ context.Context, downloadDir string, firmware *fleetdbapi.ComponentFirmwareVersion) (*FirmwareFile, error) {
+			firmwarePath := filepath.Join(downloadDir, firmware.Filename)
+			return &FirmwareFile{Path: firmwarePath}, os.WriteFile(firmwarePath, fixture, 0o600)
+		})
+
+	mockInventory := mockinventory.NewMockServerService(ctrl)
+	mockInventory.EXPECT().Publish(gomock.Any(), firmware)
+
+	// the spans nest under the span of the caller
+	ctx, handlerSpan := otel.Tracer("test").Start(context.Background(), "handler")
+
+	s := NewSyncer(dstFs, tmpFs, mockDownloader, mockInventory, []*fleetdbapi.ComponentFirmwareVersion{firmware}, logger)
+	assert.NoError(t, s.Sync(ctx))
+
+	handlerSpan.End()
+
+	parents := make(map[string]string)
+	spans := make(map[string]*recordedSpan)
+
+	for _, span := range recorder.spans {
+		parents[span.name] = span.parent
+		spans[span.name] = span
+
+		assert.True(t, span.ended, span.name)
+	}
+
+	assert.Equal(t, map[string]string{
+		"handler":            "",
+		SpanSyncFirmware:     "handler",
+		SpanDownloadFirmware: SpanSyncFirmware,
+		SpanUploadFirmware:   SpanSyncFirmware,
+		SpanPublishFirmware:  SpanSyncFirmware,
+	}, parents)
+
+	for _, name := range []string{SpanSyncFirmware, SpanDownloadFirmware, SpanUploadFirmware, SpanPublishFirmware} {
+		assert.Equal(t, "foo-vendor", spans[name].attributes["firmware.vendor"].AsString(), name)
+		assert.Equal(t, "foobar1.zip", spans[name].attributes["firmware.filename"].AsString(), name)
+	}
+
+	assert.Equal(t, int64(len(fixture)), spans[SpanDownloadFirmware].attributes["firmware.size"].AsInt64())
+	assert.Equal(t, int64(len(fixture)), spans[SpanUploadFirmware].attributes["firmware.size"].AsInt64())
+}
@@ -0,0 +1,62 @@
+package vendors
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	rcloneFs "github.com/rclone/rclone/fs"
+
+	"github.com/metal-toolbox/firmware-syncer/internal/version"
+)
+
+var (
+	userAgentMutex sync.RWMutex
+	// userAgent is sent with the outbound requests, DefaultUserAgent is sent until SetUserAgent is called
+	userAgent string
+)
+
+// DefaultUserAgent returns the user agent sent when none is configured, firmware-syncer/<version>.
+func DefaultUserAgent() string {
+	return "firmware-syncer/" + version.Version()
+}
+
+// UserAgent returns the user agent sent with the outbound requests.
+func UserAgent() string {
+	userAgentMutex.RLock()
+	defer userAgentMutex.RUnlock()
+
+	if userAgent == "" {
+		return DefaultUserAgent()
+	}
+
+	return userAgent
+}
+
+// SetUserAgent sets the user agent of the requests sent with the SharedTransport and by the rclone backends,
+// an empty userAgent restores the DefaultUserAgent.
+func SetUserAgent(ua string) {
+	userAgentMutex.Lock()
+	userAgent = ua
+	userAgentMutex.Unlock()
+
+	// the rclone http, s3 and webdav backends send the user agent of the global config
+	rcloneFs.GetConfig(context.Background()).UserAgent = UserAgent()
+}
+
+// userAgentTransport sets the UserAgent on the requests which don't set theirs.
+type userAgentTransport struct {
+	base http.RoundTripper
+}
+
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("User-Agent") != "" {
+		return t.base.RoundTrip(req)
+	}
+
+	// a RoundTripper must not modify the request
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", UserAgent())
+
+	return t.base.RoundTrip(req)
+}
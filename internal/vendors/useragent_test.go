@@ -0,0 +1,54 @@
+package vendors
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	rcloneFs "github.com/rclone/rclone/fs"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_UserAgent(t *testing.T) {
+	var received string
+
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		received = r.UserAgent()
+	}))
+	defer server.Close()
+
+	t.Cleanup(func() { SetUserAgent("") })
+
+	get := func(ua string) string {
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, server.URL, http.NoBody)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ua != "" {
+			req.Header.Set("User-Agent", ua)
+		}
+
+		resp, err := NewHTTPClient(nil).Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp.Body.Close()
+
+		return received
+	}
+
+	assert.Equal(t, "firmware-syncer/dev", get(""))
+
+	SetUserAgent("firmware-syncer (ops@example.com)")
+	assert.Equal(t, "firmware-syncer (ops@example.com)", get(""))
+	assert.Equal(t, "firmware-syncer (ops@example.com)", rcloneFs.GetConfig(context.Background()).UserAgent)
+
+	assert.Equal(t, "custom-client/1.0", get("custom-client/1.0"), "the user agent set on the request is kept")
+
+	SetUserAgent("")
+	assert.Equal(t, DefaultUserAgent(), get(""))
+	assert.Equal(t, DefaultUserAgent(), rcloneFs.GetConfig(context.Background()).UserAgent)
+}
@@ -3,6 +3,8 @@ package vendors
 import (
 	"context"
 	"sync"
+
+	"github.com/rclone/rclone/fs/accounting"
 )
 
 const (
@@ -50,6 +52,13 @@ func (m *Metrics) GetAllInt64Values() map[string]int64 {
 	return values
 }
 
+// FromStats adds the bytes, transfers and errors accounted in the rclone stats
+func (m *Metrics) FromStats(stats *accounting.StatsInfo) {
+	m.AddInt64Value(MetricTransferredBytes, stats.GetBytes())
+	m.AddInt64Value(MetricTransferredObjects, stats.GetTransfers())
+	m.AddInt64Value(MetricErrorsCount, stats.GetErrors())
+}
+
 // Clear purges all existing key, values
 func (m *Metrics) Clear() {
 	m.mutex.Lock()
@@ -94,6 +94,16 @@ func Test_ExtractFromZipArchive(t *testing.T) {
 			"foo.bar",
 			"14758f1afd44c09b7992073ccf00b43d",
 		},
+		{
+			// foobar7.zip
+			//  |-foobar7/old-foobar7.bin
+			//  |-foobar7/foobar7.bin
+			//  |-foobar7/foobar7.bin.sig
+			"exact firmware name wins over suffix match",
+			getPathToFixture("foobar7.zip"),
+			"foobar7.bin",
+			"14758f1afd44c09b7992073ccf00b43d",
+		},
 	}
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -103,9 +113,9 @@ func Test_ExtractFromZipArchive(t *testing.T) {
 				return
 			}
 
-			assert.Equal(t, tc.firmwareFilename, filepath.Base(f.Name()))
+			assert.Equal(t, tc.firmwareFilename, filepath.Base(f.Path))
 			// Remove the unzipped file from the filesystem
-			defer os.Remove(f.Name())
+			defer os.Remove(f.Path)
 		})
 	}
 }
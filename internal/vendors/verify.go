@@ -0,0 +1,210 @@
+package vendors
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+
+	"github.com/metal-toolbox/firmware-syncer/internal/metrics"
+
+	fleetdbapi "github.com/metal-toolbox/fleetdb/pkg/api/v1"
+	rcloneFs "github.com/rclone/rclone/fs"
+	rcloneHash "github.com/rclone/rclone/fs/hash"
+)
+
+var (
+	ErrVerifyMismatch        = errors.New("firmware object checksum does not match")
+	ErrVerifyHashUnavailable = errors.New("firmware object checksum unavailable")
+	ErrVerifyChecksumType    = errors.New("unsupported firmware checksum type")
+)
+
+// VerifyResult is the result of verifying a firmware object on the destination,
+// Err is nil when the object checksum matches the firmware checksum.
+type VerifyResult struct {
+	Firmware *fleetdbapi.ComponentFirmwareVersion
+	Path     string
+	Err      error
+}
+
+// Verifier verifies the checksum of firmware objects already present on the destination fs.
+type Verifier struct {
+	dstFs       rcloneFs.Fs
+	logger      *logrus.Logger
+	concurrency int
+	limiter     *rate.Limiter
+}
+
+// NewVerifier returns a Verifier reading up to concurrency object hashes in parallel,
+// at no more than rateLimit objects per second, a rateLimit of 0 disables rate limiting.
+func NewVerifier(dstFs rcloneFs.Fs, logger *logrus.Logger, concurrency int, rateLimit float64) *Verifier {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	limiter := rate.NewLimiter(rate.Inf, 0)
+	if rateLimit > 0 {
+		limiter = rate.NewLimiter(rate.Limit(rateLimit), 1)
+	}
+
+	return &Verifier{
+		dstFs:       dstFs,
+		logger:      logger,
+		concurrency: concurrency,
+		limiter:     limiter,
+	}
+}
+
+// Verify verifies the given firmwares, the results are returned in the order of the firmwares.
+func (v *Verifier) Verify(ctx context.Context, firmwares []*fleetdbapi.ComponentFirmwareVersion) []VerifyResult {
+	results := make([]VerifyResult, len(firmwares))
+	slots := make(chan struct{}, v.concurrency)
+
+	var wg sync.WaitGroup
+
+	for i, firmware := range firmwares {
+		results[i] = VerifyResult{Firmware: firmware, Path: DstPath(firmware)}
+
+		if err := v.limiter.Wait(ctx); err != nil {
+			results[i].Err = err
+			continue
+		}
+
+		slots <- struct{}{}
+
+		wg.Add(1)
+
+		go func(result *VerifyResult) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			result.Err = v.verifyObject(ctx, result.Path, result.Firmware.Checksum)
+			v.logResult(result)
+		}(&results[i])
+	}
+
+	wg.Wait()
+
+	return results
+}
+
+func (v *Verifier) verifyObject(ctx context.Context, objectPath, checksum string) error {
+	hashType, expected, err := parseChecksum(checksum)
+	if err != nil {
+		return err
+	}
+
+	obj, err := v.dstFs.NewObject(ctx, objectPath)
+	if err != nil {
+		return err
+	}
+
+	actual, err := obj.Hash(ctx, hashType)
+	if err != nil {
+		return errors.Wrap(ErrVerifyHashUnavailable, err.Error())
+	}
+
+	if actual == "" {
+		return errors.Wrap(ErrVerifyHashUnavailable, objectPath)
+	}
+
+	if !strings.EqualFold(actual, expected) {
+		msg := fmt.Sprintf("%s: expected %s, got %s", objectPath, expected, actual)
+		return errors.Wrap(ErrVerifyMismatch, msg)
+	}
+
+	return nil
+}
+
+func (v *Verifier) logResult(result *VerifyResult) {
+	logMsg := v.logger.WithField("firmware", result.Firmware.Filename).
+		WithField("vendor", result.Firmware.Vendor).
+		WithField("path", result.Path)
+
+	if result.Err != nil {
+		metrics.SyncErrorsCounter.With(metrics.UpdateSyncLabels(result.Firmware.Vendor, ActionVerify)).Inc()
+		logMsg.WithError(result.Err).Error("Failed to verify firmware")
+
+		return
+	}
+
+	metrics.SyncObjectsCounter.With(metrics.UpdateSyncLabels(result.Firmware.Vendor, ActionVerify)).Inc()
+	logMsg.Debug("Verified firmware")
+}
+
+// verifyUploaded compares the hash of the object uploaded to destPath on the dstFs with the hash of the local file,
+// ErrVerifyMismatch is returned along with the object when they differ. Objects without a hash, as multipart uploads,
+// can't be verified and are accepted.
+func (s *Syncer) verifyUploaded(ctx context.Context, firmwarePath, destPath string) (rcloneFs.Object, error) {
+	hashType := s.dstFs.Hashes().GetOne()
+	if hashType == rcloneHash.None {
+		s.logger.WithField("destPath", destPath).Warn("Destination doesn't support hashes, the upload isn't verified")
+		return nil, nil
+	}
+
+	obj, err := s.dstFs.NewObject(ctx, destPath)
+	if err != nil {
+		return nil, err
+	}
+
+	actual, err := obj.Hash(ctx, hashType)
+	if err != nil {
+		return nil, errors.Wrap(ErrVerifyHashUnavailable, err.Error())
+	}
+
+	if actual == "" {
+		s.logger.WithField("destPath", destPath).Warn("Uploaded firmware has no " + hashType.String() + " hash, the upload isn't verified")
+		return nil, nil
+	}
+
+	expected, err := localHash(firmwarePath, hashType)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.EqualFold(actual, expected) {
+		msg := fmt.Sprintf("%s: expected %s %s, got %s", destPath, hashType, expected, actual)
+		return obj, errors.Wrap(ErrVerifyMismatch, msg)
+	}
+
+	return obj, nil
+}
+
+// localHash returns the hex encoded hash of the local file.
+func localHash(filename string, hashType rcloneHash.Type) (string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hashes, err := rcloneHash.StreamTypes(f, rcloneHash.NewHashSet(hashType))
+	if err != nil {
+		return "", err
+	}
+
+	return hashes[hashType], nil
+}
+
+// parseChecksum returns the hash type and value of a firmware checksum in the <hint>:<checksum> format,
+// md5 is assumed when there's no hint, as in ValidateChecksum.
+func parseChecksum(checksum string) (rcloneHash.Type, string, error) {
+	hint, value, found := strings.Cut(checksum, ":")
+	if !found {
+		return rcloneHash.MD5, checksum, nil
+	}
+
+	switch hint {
+	case "md5sum":
+		return rcloneHash.MD5, value, nil
+	case "sha256":
+		return rcloneHash.SHA256, value, nil
+	default:
+		return rcloneHash.None, "", errors.Wrap(ErrVerifyChecksumType, hint)
+	}
+}
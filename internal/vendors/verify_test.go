@@ -0,0 +1,227 @@
+package vendors
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	fleetdbapi "github.com/metal-toolbox/fleetdb/pkg/api/v1"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/hash"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+
+	"github.com/metal-toolbox/firmware-syncer/internal/logging"
+	mockvendors "github.com/metal-toolbox/firmware-syncer/internal/vendors/mocks"
+)
+
+func TestVerifier(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+
+	const concurrency = 3
+
+	var inFlight, maxInFlight atomic.Int32
+
+	firmwares := make([]*fleetdbapi.ComponentFirmwareVersion, 0, 10)
+	hashes := map[string]string{}
+
+	for i := 0; i < 10; i++ {
+		firmware := &fleetdbapi.ComponentFirmwareVersion{
+			Vendor:   "foo-vendor",
+			Filename: fmt.Sprintf("firmware-%d.bin", i),
+			Checksum: fmt.Sprintf("%032d", i),
+		}
+
+		firmwares = append(firmwares, firmware)
+		hashes[DstPath(firmware)] = fmt.Sprintf("%032d", i)
+	}
+
+	// firmware-3 has been corrupted, firmware-5 is missing, firmware-7 has a sha256 checksum
+	hashes["foo-vendor/firmware-3.bin"] = "corrupt"
+	delete(hashes, "foo-vendor/firmware-5.bin")
+	firmwares[7].Checksum = "sha256:" + firmwares[7].Checksum
+
+	mockDstFs := mockvendors.NewMockRCloneFS(ctrl)
+	mockDstFs.EXPECT().NewObject(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, remote string) (fs.Object, error) {
+			n := inFlight.Add(1)
+			defer inFlight.Add(-1)
+
+			for {
+				current := maxInFlight.Load()
+				if n <= current || maxInFlight.CompareAndSwap(current, n) {
+					break
+				}
+			}
+
+			time.Sleep(10 * time.Millisecond)
+
+			objectHash, ok := hashes[remote]
+			if !ok {
+				return nil, fs.ErrorObjectNotFound
+			}
+
+			obj := mockvendors.NewMockRCloneObject(ctrl)
+			obj.EXPECT().Hash(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ context.Context, hashType hash.Type) (string, error) {
+					if remote == "foo-vendor/firmware-7.bin" {
+						assert.Equal(t, hash.SHA256, hashType)
+					} else {
+						assert.Equal(t, hash.MD5, hashType)
+					}
+
+					return objectHash, nil
+				})
+
+			return obj, nil
+		}).
+		Times(len(firmwares))
+
+	verifier := NewVerifier(mockDstFs, logging.NewLogger("info"), concurrency, 0)
+	results := verifier.Verify(ctx, firmwares)
+
+	assert.Len(t, results, len(firmwares))
+
+	for i, result := range results {
+		assert.Equal(t, firmwares[i], result.Firmware)
+		assert.Equal(t, DstPath(firmwares[i]), result.Path)
+
+		switch i {
+		case 3:
+			assert.ErrorIs(t, result.Err, ErrVerifyMismatch)
+		case 5:
+			assert.ErrorIs(t, result.Err, fs.ErrorObjectNotFound)
+		default:
+			assert.NoError(t, result.Err, result.Path)
+		}
+	}
+
+	assert.LessOrEqual(t, maxInFlight.Load(), int32(concurrency))
+	assert.Greater(t, maxInFlight.Load(), int32(1))
+}
+
+func TestVerifierRateLimit(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+
+	firmwares := []*fleetdbapi.ComponentFirmwareVersion{
+		{Vendor: "foo-vendor", Filename: "a.bin", Checksum: "unknown:1234"},
+		{Vendor: "foo-vendor", Filename: "b.bin", Checksum: "unknown:1234"},
+		{Vendor: "foo-vendor", Filename: "c.bin", Checksum: "unknown:1234"},
+	}
+
+	// at 20 objects per second with a burst of 1, 3 objects take at least 100ms
+	verifier := NewVerifier(mockvendors.NewMockRCloneFS(ctrl), logging.NewLogger("info"), 3, 20)
+
+	start := time.Now()
+	results := verifier.Verify(ctx, firmwares)
+
+	assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+
+	for _, result := range results {
+		assert.ErrorIs(t, result.Err, ErrVerifyChecksumType)
+	}
+}
+
+func TestSyncerVerifyUploaded(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+
+	firmwarePath := filepath.Join(t.TempDir(), "firmware.bin")
+	if err := os.WriteFile(firmwarePath, []byte("firmware"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// md5 of "firmware"
+	hashes := []string{"74b5b5e9570efc5c0553bb327cd41940", "corrupt"}
+
+	mockDstFs := mockvendors.NewMockRCloneFS(ctrl)
+	mockDstFs.EXPECT().Hashes().Return(hash.NewHashSet(hash.MD5, hash.SHA256)).Times(len(hashes))
+
+	for _, objectHash := range hashes {
+		obj := mockvendors.NewMockRCloneObject(ctrl)
+		obj.EXPECT().Hash(gomock.Any(), hash.MD5).Return(objectHash, nil)
+
+		mockDstFs.EXPECT().NewObject(gomock.Any(), "foo-vendor/firmware.bin").Return(obj, nil)
+	}
+
+	s := &Syncer{dstFs: mockDstFs, logger: logging.NewLogger("info")}
+
+	_, err := s.verifyUploaded(ctx, firmwarePath, "foo-vendor/firmware.bin")
+	assert.NoError(t, err)
+
+	obj, err := s.verifyUploaded(ctx, firmwarePath, "foo-vendor/firmware.bin")
+	assert.ErrorIs(t, err, ErrVerifyMismatch)
+	assert.NotNil(t, obj)
+}
+
+// corruptingFs is a destination fs whose objects report a corrupt hash for the first corrupt hash reads.
+type corruptingFs struct {
+	fs.Fs
+	corrupt int
+}
+
+func (f *corruptingFs) NewObject(ctx context.Context, remote string) (fs.Object, error) {
+	obj, err := f.Fs.NewObject(ctx, remote)
+	if err != nil {
+		return nil, err
+	}
+
+	return &corruptObject{Object: obj, fs: f}, nil
+}
+
+type corruptObject struct {
+	fs.Object
+	fs *corruptingFs
+}
+
+func (o *corruptObject) Hash(ctx context.Context, hashType hash.Type) (string, error) {
+	if o.fs.corrupt > 0 {
+		o.fs.corrupt--
+		return "corrupt", nil
+	}
+
+	return o.Object.Hash(ctx, hashType)
+}
+
+func TestSyncerUploadVerification(t *testing.T) {
+	testCases := []struct {
+		name    string
+		corrupt int
+		err     error
+	}{
+		{name: "intact upload"},
+		{name: "corrupt upload uploaded again", corrupt: 1},
+		{name: "corrupt upload uploaded again corrupt", corrupt: 2, err: ErrVerifyMismatch},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			tmpFs, localFs := newLocalFs(t), newLocalFs(t)
+			dstFs := &corruptingFs{Fs: localFs, corrupt: tt.corrupt}
+
+			firmwarePath := filepath.Join(tmpFs.Root(), "firmware.bin")
+			if err := os.WriteFile(firmwarePath, []byte("firmware"), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			s := &Syncer{dstFs: dstFs, tmpFs: tmpFs, logger: logging.NewLogger("info"), verifyUpload: true}
+
+			err := s.uploadFile(ctx, firmwarePath, "foo-vendor/firmware.bin", nil)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Zero(t, dstFs.corrupt)
+			assert.FileExists(t, filepath.Join(localFs.Root(), "foo-vendor", "firmware.bin"))
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package vendors
+
+import (
+	"context"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+	rcloneWebdav "github.com/rclone/rclone/backend/webdav"
+	rcloneFs "github.com/rclone/rclone/fs"
+	rcloneConfigmap "github.com/rclone/rclone/fs/config/configmap"
+	"github.com/rclone/rclone/fs/config/obscure"
+
+	"github.com/metal-toolbox/firmware-syncer/internal/config"
+)
+
+var ErrInitWebDAVFs = errors.New("error initializing webdav fs")
+
+// WebDAVCredentials maps WebDAV source hosts to the credentials logged in with.
+type WebDAVCredentials map[string]config.WebDAVCredential
+
+type webdavCredentialsKey struct{}
+
+// withWebDAVCredentials returns a context logging in to WebDAV sources with the configured credentials.
+func withWebDAVCredentials(ctx context.Context, credentials WebDAVCredentials) context.Context {
+	if len(credentials) == 0 {
+		return ctx
+	}
+
+	return context.WithValue(ctx, webdavCredentialsKey{}, credentials)
+}
+
+// isWebDAVURL returns true for webdav:// and webdavs:// URLs, for WebDAV shares served over http and https.
+func isWebDAVURL(rawURL string) bool {
+	lower := strings.ToLower(rawURL)
+
+	return strings.HasPrefix(lower, "webdav://") || strings.HasPrefix(lower, "webdavs://")
+}
+
+// webdavCredential returns the credentials in the URL or the context credentials configured for the URL host,
+// the vendor is only taken from the configured credentials.
+func webdavCredential(ctx context.Context, u *url.URL) config.WebDAVCredential {
+	var credential config.WebDAVCredential
+
+	if credentials, ok := ctx.Value(webdavCredentialsKey{}).(WebDAVCredentials); ok {
+		credential, _ = hostValue(credentials, u)
+	}
+
+	if u.User != nil {
+		credential.User = u.User.Username()
+		credential.Password, _ = u.User.Password()
+	}
+
+	return credential
+}
+
+// webdavConfigmap returns the rclone webdav backend parameters for the share at u.
+func webdavConfigmap(u *url.URL, credential config.WebDAVCredential) (rcloneConfigmap.Simple, error) {
+	scheme := "https"
+	if strings.EqualFold(u.Scheme, "webdav") {
+		scheme = "http"
+	}
+
+	vendor := credential.Vendor
+	if vendor == "" {
+		vendor = "other"
+	}
+
+	// https://github.com/rclone/rclone/blob/master/backend/webdav/webdav.go#L80
+	opts := rcloneConfigmap.Simple{
+		"type":   "webdav",
+		"url":    scheme + "://" + u.Host,
+		"vendor": vendor,
+	}
+
+	if credential.User != "" {
+		password, err := obscure.Obscure(credential.Password)
+		if err != nil {
+			return nil, errors.Wrap(ErrInitWebDAVFs, err.Error())
+		}
+
+		opts["user"] = credential.User
+		opts["pass"] = password
+	}
+
+	return opts, nil
+}
+
+// InitWebDAVFs initializes an rclone webdav fs rooted at the directory of the webdavURL file and returns it along with the file name.
+// Credentials in the URL take precedence over the credentials configured for its host.
+func InitWebDAVFs(ctx context.Context, webdavURL string) (webdavFs rcloneFs.Fs, filename string, err error) {
+	u, err := url.Parse(webdavURL)
+	if err != nil || !isWebDAVURL(webdavURL) || u.Host == "" {
+		return nil, "", errors.Wrap(ErrURLUnsupported, webdavURL)
+	}
+
+	opts, err := webdavConfigmap(u, webdavCredential(ctx, u))
+	if err != nil {
+		return nil, "", err
+	}
+
+	dir, filename := path.Split(u.Path)
+
+	// the trailing slash tells rclone the root is a directory, sparing a request to check it's not a file
+	webdavFs, err = rcloneWebdav.NewFs(withRcloneSourceHeaders(ctx, opts["url"]), "webdav://"+u.Host, dir, opts)
+	if err != nil {
+		return nil, "", errors.Wrap(ErrInitWebDAVFs, err.Error())
+	}
+
+	return webdavFs, filename, nil
+}
+
+// downloadWebDAV copies the file at webdavURL to dstPath.
+func downloadWebDAV(ctx context.Context, webdavURL, dstPath string) error {
+	webdavFs, filename, err := InitWebDAVFs(ctx, webdavURL)
+	if err != nil {
+		return err
+	}
+
+	return downloadObject(ctx, webdavFs, filename, webdavURL, dstPath)
+}
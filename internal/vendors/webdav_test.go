@@ -0,0 +1,148 @@
+package vendors
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/rclone/rclone/fs/config/obscure"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/webdav"
+
+	"github.com/metal-toolbox/firmware-syncer/internal/config"
+)
+
+// newWebDAVServer serves a WebDAV share holding /firmware/dell/fw.zip, behind basic auth.
+func newWebDAVServer(t *testing.T, user, password string) *httptest.Server {
+	ctx := context.Background()
+
+	memFs := webdav.NewMemFS()
+	for _, dir := range []string{"/firmware", "/firmware/dell"} {
+		if err := memFs.Mkdir(ctx, dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f, err := memFs.OpenFile(ctx, "/firmware/dell/fw.zip", os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = f.Write([]byte("firmware")); err != nil {
+		t.Fatal(err)
+	}
+
+	f.Close()
+
+	handler := &webdav.Handler{FileSystem: memFs, LockSystem: webdav.NewMemLS()}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if u, p, ok := r.BasicAuth(); !ok || u != user || p != password {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func Test_WebDAVConfigmap(t *testing.T) {
+	u, err := url.Parse("webdavs://cloud.example.com/remote.php/dav/files/firmware/fw.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts, err := webdavConfigmap(u, config.WebDAVCredential{User: "firmware", Password: "s3cret", Vendor: "nextcloud"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "https://cloud.example.com", opts["url"])
+	assert.Equal(t, "nextcloud", opts["vendor"])
+	assert.Equal(t, "firmware", opts["user"])
+	assert.Equal(t, "s3cret", obscure.MustReveal(opts["pass"]))
+
+	// anonymous shares
+	u.Scheme = "webdav"
+
+	opts, err = webdavConfigmap(u, config.WebDAVCredential{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "http://cloud.example.com", opts["url"])
+	assert.Equal(t, "other", opts["vendor"])
+	assert.NotContains(t, opts, "user")
+	assert.NotContains(t, opts, "pass")
+}
+
+func Test_InitWebDAVFs(t *testing.T) {
+	server := newWebDAVServer(t, "firmware", "s3cret")
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	testCases := []struct {
+		name        string
+		url         string
+		credentials WebDAVCredentials
+	}{
+		{
+			name: "credentials in the url",
+			url:  "webdav://firmware:s3cret@" + host + "/firmware/dell/fw.zip",
+		},
+		{
+			name:        "configured credentials",
+			url:         "webdav://" + host + "/firmware/dell/fw.zip",
+			credentials: WebDAVCredentials{host: {User: "firmware", Password: "s3cret"}},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := withWebDAVCredentials(context.Background(), tt.credentials)
+
+			webdavFs, filename, err := InitWebDAVFs(ctx, tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, "webdav://"+host, webdavFs.Name())
+			assert.Equal(t, "firmware/dell", webdavFs.Root())
+			assert.Equal(t, "fw.zip", filename)
+
+			entries, err := webdavFs.List(ctx, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if assert.Len(t, entries, 1) {
+				assert.Equal(t, "fw.zip", entries[0].Remote())
+			}
+
+			archivePath, err := DownloadFirmwareArchive(ctx, t.TempDir(), tt.url, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			b, err := os.ReadFile(archivePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, "firmware", string(b))
+		})
+	}
+
+	// the share rejects requests without credentials
+	_, err := DownloadFirmwareArchive(context.Background(), t.TempDir(), "webdav://"+host+"/firmware/dell/fw.zip", "")
+	assert.Error(t, err)
+
+	_, _, err = InitWebDAVFs(context.Background(), "https://"+host+"/firmware/dell/fw.zip")
+	assert.ErrorIs(t, err, ErrURLUnsupported)
+}
@@ -0,0 +1,94 @@
+package vendors
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	fleetdbapi "github.com/metal-toolbox/fleetdb/pkg/api/v1"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DownloadDirPrefix is the prefix of the directories created under the work directory to download firmware in.
+	DownloadDirPrefix = "firmware-download"
+
+	// maxFirmwareDirPrefix bounds the length of the vendor and filename prefix of the firmware download directories
+	maxFirmwareDirPrefix = 64
+)
+
+// FirmwareDownloadDir creates the directory a Downloader downloads a single firmware in, under the given downloadDir.
+// It's named after the firmware vendor and filename followed by random digits, so firmware with the same filename
+// downloaded at the same time in a shared downloadDir, as for distinct vendors, don't overwrite each other.
+func FirmwareDownloadDir(downloadDir string, firmware *fleetdbapi.ComponentFirmwareVersion) (string, error) {
+	prefix := strings.Trim(unsafeFilenameChars.ReplaceAllString(firmware.Vendor+"-"+firmware.Filename, "_"), "-")
+	if len(prefix) > maxFirmwareDirPrefix {
+		prefix = prefix[:maxFirmwareDirPrefix]
+	}
+
+	dir, err := os.MkdirTemp(downloadDir, prefix+"-")
+	if err != nil {
+		return "", errors.Wrap(ErrCreatingTmpDir, err.Error())
+	}
+
+	return dir, nil
+}
+
+// CleanStaleDownloadDirs removes the download directories under root last modified before maxAge,
+// which are left behind when the syncer is killed mid sync.
+// Only directories named by os.MkdirTemp with the DownloadDirPrefix are removed, the removed paths are returned.
+func CleanStaleDownloadDirs(root string, maxAge time.Duration) ([]string, error) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+
+	for _, entry := range entries {
+		// symlinks aren't reported as directories, so they're never followed
+		if !entry.IsDir() || !isDownloadDirName(entry.Name()) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+
+			return removed, err
+		}
+
+		if time.Since(info.ModTime()) < maxAge {
+			continue
+		}
+
+		dir := filepath.Join(root, entry.Name())
+		if err := os.RemoveAll(dir); err != nil {
+			return removed, err
+		}
+
+		removed = append(removed, dir)
+	}
+
+	return removed, nil
+}
+
+// isDownloadDirName returns true for the names os.MkdirTemp gives download directories,
+// the DownloadDirPrefix followed by random digits.
+func isDownloadDirName(name string) bool {
+	suffix, found := strings.CutPrefix(name, DownloadDirPrefix)
+	if !found || suffix == "" {
+		return false
+	}
+
+	for _, r := range suffix {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,52 @@
+package vendors
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_CleanStaleDownloadDirs(t *testing.T) {
+	root := t.TempDir()
+	old := time.Now().Add(-48 * time.Hour)
+
+	seed := func(name string, dir bool, modTime time.Time) string {
+		p := filepath.Join(root, name)
+
+		var err error
+		if dir {
+			err = os.MkdirAll(filepath.Join(p, "nested"), 0o750)
+		} else {
+			err = os.WriteFile(p, []byte("firmware"), 0o600)
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err = os.Chtimes(p, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+
+		return p
+	}
+
+	stale := seed("firmware-download1234567", true, old)
+	recent := seed("firmware-download7654321", true, time.Now())
+	unrelatedDir := seed("firmware-downloads-backup", true, old)
+	unrelatedPrefix := seed("other-download1234567", true, old)
+	matchingFile := seed("firmware-download2345678", false, old)
+
+	removed, err := CleanStaleDownloadDirs(root, 24*time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{stale}, removed)
+
+	assert.NoDirExists(t, stale)
+	assert.DirExists(t, recent)
+	assert.DirExists(t, unrelatedDir)
+	assert.DirExists(t, unrelatedPrefix)
+	assert.FileExists(t, matchingFile)
+}
@@ -0,0 +1,21 @@
+// Package version reports the version of the firmware-syncer build.
+package version
+
+import "runtime/debug"
+
+// version is set at build time with -ldflags "-X github.com/metal-toolbox/firmware-syncer/internal/version.version=<version>"
+var version string
+
+// Version returns the version set at build time, or the module version for go install builds,
+// dev is returned for local builds.
+func Version() string {
+	if version != "" {
+		return version
+	}
+
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+
+	return "dev"
+}